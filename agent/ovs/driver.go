@@ -0,0 +1,424 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	driver
+	Abstract:	A native replacement for the ql_bw_fmods, ql_bwow_fmods, send_ovs_fmod and
+				create_ovs_queues scripts. The driver builds the ovs-ofctl and ovs-vsctl
+				commands from the data tegu sends in an action and hands them to a Runner
+				which executes them on the target host (via the ssh broker in the agent, or
+				a Recorder when testing).
+
+				Flow-mods are not executed as they are added; they are collected for each
+				host and Flush() sends them as a single ovs-ofctl --bundle add-flows command
+				so that all of the flow-mods for a host are applied (or rejected) together.
+				The bundle requires OpenFlow 1.4. Before the first bundle is sent to a host the
+				bridge protocols are read (ovs-vsctl get) and OpenFlow14 is added only if it is
+				missing; the check is repeated after a bundle fails.
+
+				Build errors (missing or unrecognised data) are returned to the caller before
+				anything is queued, which allows the agent to fall back to the script for the
+				action.
+
+	Date:		17 October 2026
+
+	Mods:		17 Oct 2026 - Bridge protocols are checked once per host rather than set on every flush.
+*/
+
+package ovs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	bw_cookie	string = "0xb0ff"			// cookies used by the scripts; must match so either can delete
+	bwow_cookie	string = "0xf00d"
+
+	def_bridge	string = "br-int"
+	of_protos	string = "OpenFlow10,OpenFlow11,OpenFlow12,OpenFlow13,OpenFlow14"
+)
+
+/*
+	A single command to execute. Input lines, if any, are written to the command's stdin.
+*/
+type Cmd struct {
+	Argv	[]string
+	Input	[]string
+}
+
+/*
+	Something that can run a list of commands on a host. The commands must be executed in
+	order, and execution must stop at the first failure.
+*/
+type Runner interface {
+	Run( host string, cmds []*Cmd ) ( stdout []string, stderr []string, err error )
+}
+
+type Driver struct {
+	Bridge		string						// bridge that flow-mods and queues are applied to
+	Allow_htb	bool						// when false (default) queues are removed rather than set (see queue.go)
+	Uplinks		[]string					// ports which receive the -128 (outward) queues
+
+	runner		Runner
+	pending		map[string][]string			// flow-mod lines waiting to be flushed, by host
+	of14		map[string]bool				// hosts whose bridge is known to have OpenFlow14 enabled
+}
+
+func Mk_cmd( argv ...string ) ( *Cmd ) {
+	return &Cmd{ Argv: argv }
+}
+
+/*
+	Generate a shell ready command string. If there is input a here document is used to
+	supply it.
+*/
+func (c *Cmd) To_str( ) ( string ) {
+	toks := make( []string, len( c.Argv ) )
+	for i, a := range c.Argv {
+		toks[i] = quote( a )
+	}
+	s := strings.Join( toks, " " )
+
+	if len( c.Input ) > 0 {
+		s += " <<'endKat'\n" + strings.Join( c.Input, "\n" ) + "\nendKat"
+	}
+
+	return s
+}
+
+/*
+	Single quote the token if it contains anything that the shell would interpret.
+*/
+func quote( tok string ) ( string ) {
+	if tok != "" && strings.Trim( tok, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=.,:/@+" ) == "" {
+		return tok
+	}
+
+	return "'" + strings.Replace( tok, "'", `'"'"'`, -1 ) + "'"
+}
+
+/*
+	Create a driver which uses the runner to execute commands.
+*/
+func Mk_driver( runner Runner ) ( *Driver ) {
+	return &Driver {
+		Bridge:		def_bridge,
+		Uplinks:	[]string{ "qosirl0" },
+		runner:		runner,
+		pending:	make( map[string][]string ),
+		of14:		make( map[string]bool ),
+	}
+}
+
+// ---- bandwidth flow-mods -------------------------------------------------------------------------
+
+/*
+	Build the flow-mods for one endpoint of a bandwidth reservation (ql_bw_fmods) and add them
+	to the pending list for the host. The data map is the Data from a bw_fmod action:
+		smac, dmac		local and remote mac (required)
+		extip, extdir	external address and -S/-D to associate it with the local or remote mac
+		vlan_match		vlan to match on the outbound flow-mod
+		koe				true to keep the dscp value on exit
+		sproto, dproto	proto:port to match
+		timeout, dscp, oneswitch, ipv6
+		queue, vlan_action are ignored (as they are by the script)
+
+	Two flow-mods are generated: inbound (remote -> local) which clears the dscp marking,
+	and outbound (local -> remote) which sets it. When both endpoints are on the same switch
+	only the outbound flow-mod is needed.
+*/
+func (d *Driver) Bw_fmods( host string, data map[string]string ) ( err error ) {
+	lmac := data["smac"]
+	rmac := data["dmac"]
+	if lmac == "" || rmac == "" {
+		return fmt.Errorf( "must have source and dest mac addresses to generate flow-mods" )
+	}
+
+	koe := is_true( data["koe"] )
+	one_switch := is_true( data["oneswitch"] )
+	v6 := is_true( data["ipv6"] )
+	ex_local := data["extdir"] != "-D"
+
+	pri_base := 0
+	proto := data["sproto"]						// script accepts only one; the last given (dproto) wins
+	proto_src := true
+	if data["dproto"] != "" {
+		proto = data["dproto"]
+		proto_src = false
+	}
+	if proto != "" {
+		pri_base = 5
+	}
+
+	vp_base := 0
+	if data["vlan_match"] != "" {
+		vp_base = 5
+	}
+
+	lines := make( []string, 0, 2 )
+	if ! one_switch {
+		in := Mk_fmod( bw_cookie, 450 + pri_base )						// inbound
+		if err = in.Set_timeout( data["timeout"] ); err != nil {
+			return err
+		}
+		in.Match_ip_type( v6 )
+		in.Match_meta( "0x0/0x7" )
+		if data["extip"] != "" {
+			in.Match_ip( data["extip"], ! ex_local )
+		}
+		in.Match_dmac( lmac )
+		in.Match_smac( rmac )
+		if proto != "" {
+			if err = in.Match_proto( proto, proto_src ); err != nil {
+				return err
+			}
+		}
+		if ! koe {
+			in.Set_tos( "0" )
+		}
+		in.Set_meta( "0x01" )
+		in.Resubmit( "", "0" )
+
+		lines = append( lines, in.Add_str() )
+	}
+
+	out := Mk_fmod( bw_cookie, 400 + vp_base + pri_base )				// outbound
+	if err = out.Set_timeout( data["timeout"] ); err != nil {
+		return err
+	}
+	if data["vlan_match"] != "" {
+		out.Match_vlan( data["vlan_match"] )
+	}
+	out.Match_ip_type( v6 )
+	out.Match_meta( "0x0/0x7" )
+	if data["extip"] != "" {
+		out.Match_ip( data["extip"], ex_local )
+	}
+	out.Match_smac( lmac )
+	out.Match_dmac( rmac )
+	if proto != "" {
+		if err = out.Match_proto( proto, proto_src ); err != nil {
+			return err
+		}
+	}
+	if data["dscp"] != "" && ( koe || ! one_switch ) {				// one switch, not leaving the environment; no marking
+		out.Set_tos( data["dscp"] )
+	}
+	out.Set_meta( "0x01" )
+	out.Resubmit( "", "0" )
+
+	lines = append( lines, out.Add_str() )
+
+	d.pending[host] = append( d.pending[host], lines... )
+	return nil
+}
+
+/*
+	Build the single flow-mod needed for one way bandwidth reservation (ql_bwow_fmods) and
+	add it to the pending list for the host. Data is the same as for bw_fmods except that
+	either dmac or extip is required, and both sproto and dproto may be given.
+*/
+func (d *Driver) Bwow_fmods( host string, data map[string]string ) ( err error ) {
+	smac := data["smac"]
+	if smac == "" {
+		return fmt.Errorf( "must have source mac address to generate oneway flow-mods" )
+	}
+	if data["dmac"] == "" && data["extip"] == "" {
+		return fmt.Errorf( "must have either destination mac address or external IP address to generate oneway flow-mods" )
+	}
+	if data["dproto"] != "" && data["extip"] == "" {
+		return fmt.Errorf( "external ip address required when destination protocol is given" )
+	}
+
+	pri_base := 0
+	if data["sproto"] != "" || data["dproto"] != "" {
+		pri_base = 5
+	}
+
+	f := Mk_fmod( bwow_cookie, 400 + pri_base )
+	if err = f.Set_timeout( data["timeout"] ); err != nil {
+		return err
+	}
+	if data["vlan_match"] != "" {
+		f.Match_vlan( data["vlan_match"] )
+	}
+	f.Match_ip_type( is_true( data["ipv6"] ) )
+	f.Match_meta( "0x0/0x7" )
+	if data["extip"] != "" {
+		f.Match_ip( data["extip"], false )
+	}
+	f.Match_smac( smac )
+	if data["dmac"] != "" {
+		f.Match_dmac( data["dmac"] )
+	}
+	if data["dproto"] != "" {
+		if err = f.Match_proto( data["dproto"], false ); err != nil {
+			return err
+		}
+	}
+	if data["sproto"] != "" {
+		if err = f.Match_proto( data["sproto"], true ); err != nil {
+			return err
+		}
+	}
+	if data["dscp"] != "" {
+		f.Set_tos( data["dscp"] )
+	}
+	f.Set_meta( "0x01" )
+	f.Resubmit( "", "0" )
+
+	d.pending[host] = append( d.pending[host], f.Add_str() )
+	return nil
+}
+
+func is_true( v string ) ( bool ) {
+	switch v {
+		case "true", "True", "TRUE":
+			return true
+	}
+	return false
+}
+
+// ---- execution -----------------------------------------------------------------------------------
+
+/*
+	Return the hosts which have flow-mods waiting, sorted.
+*/
+func (d *Driver) Pending( ) ( hosts []string ) {
+	hosts = make( []string, 0, len( d.pending ) )
+	for h := range d.pending {
+		hosts = append( hosts, h )
+	}
+	sort.Strings( hosts )
+
+	return
+}
+
+/*
+	Return the commands which would be run to flush the host's pending flow-mods. Nil is
+	returned if nothing is pending.
+*/
+func (d *Driver) Bundle_cmds( host string ) ( []*Cmd ) {
+	lines := d.pending[host]
+	if len( lines ) == 0 {
+		return nil
+	}
+
+	bundle := Mk_cmd( "ovs-ofctl", "-O", "OpenFlow14", "--bundle", "add-flows", d.Bridge, "-" )
+	bundle.Input = lines
+
+	return []*Cmd{ bundle }
+}
+
+/*
+	Return the command which lists the protocols enabled on the bridge.
+*/
+func (d *Driver) Proto_get_cmd( ) ( *Cmd ) {
+	return Mk_cmd( "ovs-vsctl", "get", "bridge", d.Bridge, "protocols" )
+}
+
+/*
+	Given the output of the protocol get command (e.g. ["OpenFlow10", "OpenFlow13"]), return
+	the command which adds OpenFlow14 to those enabled, or nil if it is already enabled. An
+	empty (or unrecognised) list leaves the choice to ovs, so the full list that we need is set.
+*/
+func (d *Driver) Proto_set_cmd( out []string ) ( *Cmd ) {
+	s := strings.Join( out, " " )
+	i := strings.Index( s, "[" )
+	j := strings.LastIndex( s, "]" )
+	if i < 0 || j < i {
+		s = ""
+	} else {
+		s = s[i+1:j]
+	}
+
+	var protos []string
+	for _, p := range strings.Split( s, "," ) {
+		p = strings.Trim( p, " \t\"" )
+		if p == "OpenFlow14" {
+			return nil
+		}
+		if p != "" {
+			protos = append( protos, p )
+		}
+	}
+
+	if len( protos ) == 0 {
+		return Mk_cmd( "ovs-vsctl", "set", "bridge", d.Bridge, "protocols=" + of_protos )
+	}
+	return Mk_cmd( "ovs-vsctl", "set", "bridge", d.Bridge, "protocols=" + strings.Join( append( protos, "OpenFlow14" ), "," ) )
+}
+
+/*
+	Ensure that the bridge on the host has OpenFlow14 enabled. The bridge is checked only
+	if we haven't already seen that it is.
+*/
+func (d *Driver) check_protos( host string ) ( stdout []string, stderr []string, err error ) {
+	if d.of14[host] {
+		return nil, nil, nil
+	}
+
+	stdout, stderr, err = d.runner.Run( host, []*Cmd{ d.Proto_get_cmd() } )
+	if err != nil {
+		return
+	}
+
+	if set := d.Proto_set_cmd( stdout ); set != nil {
+		if stdout, stderr, err = d.runner.Run( host, []*Cmd{ set } ); err != nil {
+			return
+		}
+	}
+
+	d.of14[host] = true
+	return
+}
+
+/*
+	Send the pending flow-mods for the host as a single bundle. The pending list for the host
+	is cleared regardless of the outcome. If the bundle fails the bridge protocols are checked
+	again before the next one is sent, as the bridge might have been rebuilt.
+*/
+func (d *Driver) Flush( host string ) ( stdout []string, stderr []string, err error ) {
+	cmds := d.Bundle_cmds( host )
+	delete( d.pending, host )
+	if cmds == nil {
+		return nil, nil, nil
+	}
+
+	if stdout, stderr, err = d.check_protos( host ); err != nil {
+		return
+	}
+
+	if stdout, stderr, err = d.runner.Run( host, cmds ); err != nil {
+		delete( d.of14, host )
+	}
+	return
+}
+
+/*
+	Discard anything pending for the host.
+*/
+func (d *Driver) Discard( host string ) {
+	delete( d.pending, host )
+}
@@ -0,0 +1,198 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	dump
+	Abstract:	Support for the dump_state action which tegu's reconciler uses to compare what
+				is installed on a host with what should be there. The flow-mods on the bridge
+				are listed with ovs-ofctl, and the queues on each port with a QoS are listed
+				with ovs-appctl qos/show. The output is reduced to simple records:
+					flow <host> <cookie> <table> <smac> <dmac> <match>
+					queue <host> <port> <qnum> <min-rate> <max-rate>
+
+				Only flow-mods with one of the cookies given are listed. A dash is used for a
+				mac which is not part of the match, and for a rate which isn't shown. The match
+				is the priority and match fields as listed by ovs-ofctl (no spaces). Queue 0 is
+				listed by qos/show as the default queue.
+
+	Date:		17 October 2026
+
+*/
+
+package ovs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	qos_list_cmd	string = `for p in $(ovs-vsctl --bare --columns=name find Port 'qos!=[]'); do ovs-appctl qos/show $p; done`
+)
+
+/*
+	Build the commands which list the flow-mods and, if queues is true, the queues.
+*/
+func (d *Driver) Dump_cmds( queues bool ) ( cmds []*Cmd ) {
+	cmds = []*Cmd{ Mk_cmd( "ovs-ofctl", "dump-flows", d.Bridge ) }
+	if queues {
+		cmds = append( cmds, Mk_cmd( "sh", "-c", qos_list_cmd ) )
+	}
+
+	return
+}
+
+/*
+	Compare two cookie strings (e.g. 0xb0ff and 0x0b0ff) by value.
+*/
+func same_cookie( c1 string, c2 string ) ( bool ) {
+	v1, err1 := strconv.ParseUint( c1, 0, 64 )
+	v2, err2 := strconv.ParseUint( c2, 0, 64 )
+	if err1 != nil || err2 != nil {
+		return c1 == c2
+	}
+
+	return v1 == v2
+}
+
+/*
+	Reduce a line of dump-flows output to a flow record. Nil is returned if the line isn't a
+	flow-mod, or the cookie isn't one of those wanted. A typical line:
+		cookie=0xb0ff, duration=5.1s, table=0, n_packets=0, ..., priority=400,ip,dl_src=...,dl_dst=... actions=...
+*/
+func parse_flow( host string, line string, cookies []string ) ( rec string, ok bool ) {
+	line = strings.TrimSpace( line )
+	if ! strings.HasPrefix( line, "cookie=" ) {
+		return "", false
+	}
+
+	if i := strings.Index( line, " actions=" ); i >= 0 {
+		line = line[0:i]
+	}
+	toks := strings.Split( line, ", " )
+
+	cookie := strings.TrimPrefix( toks[0], "cookie=" )
+	wanted := false
+	for _, c := range cookies {
+		if same_cookie( c, cookie ) {
+			wanted = true
+			break
+		}
+	}
+	if ! wanted {
+		return "", false
+	}
+
+	table := "0"
+	for _, t := range toks[1:] {
+		if strings.HasPrefix( t, "table=" ) {
+			table = strings.TrimPrefix( t, "table=" )
+		}
+	}
+
+	match := toks[len( toks )-1]					// priority and match fields are the last token
+	smac := "-"
+	dmac := "-"
+	for _, m := range strings.Split( match, "," ) {
+		switch {
+			case strings.HasPrefix( m, "dl_src=" ):		smac = strings.TrimPrefix( m, "dl_src=" )
+			case strings.HasPrefix( m, "dl_dst=" ):		dmac = strings.TrimPrefix( m, "dl_dst=" )
+		}
+	}
+
+	return fmt.Sprintf( "flow %s %s %s %s %s %s", host, cookie, table, smac, dmac, match ), true
+}
+
+/*
+	Parse the dump-flows and qos/show output from a host and return the flow and queue records.
+	Qos/show lists each port as:
+		QoS: qosirl0 linux-htb
+		...
+		Default:
+		  min-rate: 1000
+		  max-rate: 10000000000
+		Queue 1:
+		  ...
+*/
+func Parse_dump( host string, lines []string, cookies []string ) ( recs []string ) {
+	port := ""
+	qnum := -1
+	min := "-"
+	max := "-"
+
+	flush_queue := func() {
+		if port != "" && qnum >= 0 {
+			recs = append( recs, fmt.Sprintf( "queue %s %s %d %s %s", host, port, qnum, min, max ) )
+		}
+		qnum = -1
+		min = "-"
+		max = "-"
+	}
+
+	for _, l := range lines {
+		if rec, ok := parse_flow( host, l, cookies ); ok {
+			recs = append( recs, rec )
+			continue
+		}
+
+		tl := strings.TrimSpace( l )
+		switch {
+			case strings.HasPrefix( tl, "QoS:" ):
+				flush_queue()
+				port = ""
+				if toks := strings.Fields( tl ); len( toks ) > 1 {
+					port = toks[1]
+				}
+
+			case tl == "Default:":
+				flush_queue()
+				qnum = 0
+
+			case strings.HasPrefix( tl, "Queue " ) && strings.HasSuffix( tl, ":" ):
+				flush_queue()
+				if n, err := strconv.Atoi( strings.TrimSuffix( strings.TrimPrefix( tl, "Queue " ), ":" ) ); err == nil {
+					qnum = n
+				}
+
+			case qnum >= 0 && strings.HasPrefix( tl, "min-rate:" ):
+				min = strings.TrimSpace( strings.TrimPrefix( tl, "min-rate:" ) )
+
+			case qnum >= 0 && strings.HasPrefix( tl, "max-rate:" ):
+				max = strings.TrimSpace( strings.TrimPrefix( tl, "max-rate:" ) )
+		}
+	}
+	flush_queue()
+
+	return
+}
+
+/*
+	List the flow-mods with one of the cookies given, and the queues if requested, on the host.
+*/
+func (d *Driver) Dump_state( host string, cookies []string, queues bool ) ( recs []string, stderr []string, err error ) {
+	stdout, stderr, err := d.runner.Run( host, d.Dump_cmds( queues ) )
+	if err != nil {
+		return nil, stderr, err
+	}
+
+	return Parse_dump( host, stdout, cookies ), stderr, nil
+}
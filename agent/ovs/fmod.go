@@ -0,0 +1,278 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	fmod
+	Abstract:	A single flow-mod and the functions which build the match and action portions
+				of it. The field names and formatting follow what send_ovs_fmod generates so
+				that flow-mods from the driver and from the scripts are interchangable (the
+				script can delete what the driver added and vice versa).
+
+				The string generated by Add_str() or Del_str() is a single line suitable for
+				an ovs-ofctl add-flows file (including the --bundle form).
+
+	Date:		17 October 2026
+
+*/
+
+package ovs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	ip4_type	string = "dl_type=0x0800"
+	ip6_type	string = "dl_type=0x86dd"
+
+	max_timeout	int = 3600 * 18				// ovs limits hard timeout to about 18h12m; we cap at 18h as the script does
+	def_timeout	int = 60					// the script default when -t isn't given
+)
+
+type Fmod struct {
+	Cookie		string
+	Table		int							// table to add to; < 0 leaves it off (table 0)
+	Priority	int
+	Timeout		int							// hard timeout (seconds); 0 is no timeout
+
+	dl_type		string						// type match is always placed first
+	match		[]string
+	action		[]string
+}
+
+/*
+	Create a flow-mod with the default hard timeout and no table.
+*/
+func Mk_fmod( cookie string, priority int ) ( *Fmod ) {
+	return &Fmod {
+		Cookie:		cookie,
+		Table:		-1,
+		Priority:	priority,
+		Timeout:	def_timeout,
+		match:		make( []string, 0, 8 ),
+		action:		make( []string, 0, 4 ),
+	}
+}
+
+/*
+	Set the hard timeout from the string passed from tegu. An empty string leaves the default,
+	a value <= 0 results in no timeout, and values over 18 hours are capped.
+*/
+func (f *Fmod) Set_timeout( tstr string ) ( err error ) {
+	if tstr == "" {
+		return nil
+	}
+
+	t, err := strconv.Atoi( tstr )
+	if err != nil {
+		return fmt.Errorf( "invalid timeout: %s", tstr )
+	}
+
+	switch {
+		case t <= 0:			f.Timeout = 0
+		case t > max_timeout:	f.Timeout = max_timeout
+		default:				f.Timeout = t
+	}
+
+	return nil
+}
+
+// ---- match ---------------------------------------------------------------------------------------
+
+/*
+	Force an IPv4 or IPv6 type match (-4/-6 on the script command line).
+*/
+func (f *Fmod) Match_ip_type( v6 bool ) {
+	if v6 {
+		f.dl_type = ip6_type
+	} else {
+		f.dl_type = ip4_type
+	}
+}
+
+func (f *Fmod) Match_smac( mac string ) {
+	f.match = append( f.match, "dl_src=" + mac )
+}
+
+func (f *Fmod) Match_dmac( mac string ) {
+	f.match = append( f.match, "dl_dst=" + mac )
+}
+
+/*
+	Add a network layer address match. The address type (v4 or v6) is determined by the
+	address and the type match is set accordingly.
+*/
+func (f *Fmod) Match_ip( addr string, src bool ) {
+	pfx := "nw"
+	f.dl_type = ip4_type
+	if strings.Contains( addr, ":" ) {
+		pfx = "ipv6"
+		f.dl_type = ip6_type
+	}
+
+	if src {
+		f.match = append( f.match, pfx + "_src=" + addr )
+	} else {
+		f.match = append( f.match, pfx + "_dst=" + addr )
+	}
+}
+
+/*
+	Add a transport protocol/port match. Pp is proto:port (e.g. tcp:80, udp6:53, 17:0);
+	a port of 0 or missing matches only the protocol.
+*/
+func (f *Fmod) Match_proto( pp string, src bool ) ( err error ) {
+	proto := pp
+	port := ""
+	if i := strings.Index( pp, ":" ); i >= 0 {
+		proto = pp[0:i]
+		port = pp[i+1:]
+	}
+
+	nwp := ""
+	switch strings.ToLower( proto ) {
+		case "icmp":			nwp = "1"
+		case "tcp":				nwp = "6"
+		case "tcp4":			nwp = "6"; f.dl_type = ip4_type
+		case "tcp6":			nwp = "6"; f.dl_type = ip6_type
+		case "udp":				nwp = "17"
+		case "udp4":			nwp = "17"; f.dl_type = ip4_type
+		case "udp6":			nwp = "17"; f.dl_type = ip6_type
+		case "gre":				nwp = "47"
+
+		default:
+			if _, err = strconv.Atoi( proto ); err != nil {
+				return fmt.Errorf( "unrecognised protocol: %s", pp )
+			}
+			nwp = proto
+	}
+
+	if ! f.has_match( "nw_proto=" + nwp ) {						// both src and dest ports may be given
+		f.match = append( f.match, "nw_proto=" + nwp )
+	}
+	if port != "" && port != "0" {
+		if src {
+			f.match = append( f.match, "tp_src=" + port )
+		} else {
+			f.match = append( f.match, "tp_dst=" + port )
+		}
+	}
+
+	return nil
+}
+
+/*
+	Match a metadata value[/mask].
+*/
+func (f *Fmod) Match_meta( vm string ) {
+	f.match = append( f.match, "metadata=" + vm )
+}
+
+/*
+	Match vlan tci value[/mask].
+*/
+func (f *Fmod) Match_vlan( tci string ) {
+	f.match = append( f.match, "vlan_tci=" + tci )
+}
+
+func (f *Fmod) has_match( m string ) ( bool ) {
+	for _, v := range f.match {
+		if v == m {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ---- action --------------------------------------------------------------------------------------
+
+/*
+	Set the type of service (diffserv) value in the packet.
+*/
+func (f *Fmod) Set_tos( tos string ) {
+	f.action = append( f.action, "mod_nw_tos:" + tos )
+}
+
+/*
+	Set the metadata value 'inline' (the script's -M action option).
+*/
+func (f *Fmod) Set_meta( value string ) {
+	f.action = append( f.action, "set_field:" + value + "->metadata" )
+}
+
+/*
+	Resubmit to the port and/or table. Either may be empty.
+*/
+func (f *Fmod) Resubmit( port string, table string ) {
+	f.action = append( f.action, "resubmit(" + port + "," + table + ")" )
+}
+
+// ---- generation ----------------------------------------------------------------------------------
+
+func (f *Fmod) match_str( ) ( string ) {
+	m := f.match
+	if f.dl_type != "" {
+		m = append( []string{ f.dl_type }, f.match... )
+	}
+
+	if len( m ) == 0 {
+		return ""
+	}
+	return strings.Join( m, "," ) + ","
+}
+
+/*
+	Generate the add flow line. If no actions were set the packet is dropped.
+*/
+func (f *Fmod) Add_str( ) ( string ) {
+	hto := ""
+	if f.Timeout > 0 {
+		hto = fmt.Sprintf( "hard_timeout=%d,", f.Timeout )
+	}
+
+	table := ""
+	if f.Table >= 0 {
+		table = fmt.Sprintf( "table=%d,", f.Table )
+	}
+
+	action := "drop"
+	if len( f.action ) > 0 {
+		action = strings.Join( f.action, "," )
+	}
+
+	return fmt.Sprintf( "add %s%scookie=%s,%spriority=%d,action=%s", hto, table, f.Cookie, f.match_str(), f.Priority, action )
+}
+
+/*
+	Generate the delete flow line. The cookie must match exactly; the remaining match fields
+	are those given for the add.
+*/
+func (f *Fmod) Del_str( ) ( string ) {
+	cookie := f.Cookie
+	if ! strings.Contains( cookie, "/" ) {
+		cookie += "/-1"
+	}
+
+	return strings.TrimRight( fmt.Sprintf( "delete cookie=%s,%s", cookie, f.match_str() ), "," )
+}
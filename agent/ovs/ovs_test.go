@@ -0,0 +1,402 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	ovs_test
+	Abstract:	Tests for the ovs driver. Commands are captured with a recorder and compared
+				with what the scripts would have generated for the same data.
+	Date:		17 October 2026
+
+	Mods:		17 Oct 2026 - Bridge protocols are read and OpenFlow14 added only when missing.
+*/
+
+package ovs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func bw_data( ) ( map[string]string ) {
+	return map[string]string {					// as To_bw_map() in fq_req.go builds it
+		"smac":			"fa:16:3e:00:00:02",
+		"dmac":			"fa:16:3e:00:00:03",
+		"extip":		"",
+		"extdir":		"",
+		"vlan_match":	"",
+		"vlan_action":	"",
+		"queue":		"1",
+		"dscp":			"184",
+		"ipv6":			"false",
+		"timeout":		"120",
+		"oneswitch":	"false",
+		"koe":			"false",
+	}
+}
+
+func Test_fmod( t *testing.T ) {
+	fmt.Fprintf( os.Stderr, "\n----------- ovs driver tests --------------\n" )
+
+	f := Mk_fmod( "0xb0ff", 405 )
+	f.Set_timeout( "999999" )
+	f.Match_ip_type( false )
+	f.Match_meta( "0x0/0x7" )
+	f.Match_ip( "2001:db8::1", true )			// must switch the type to v6
+	f.Match_smac( "fa:16:3e:00:00:02" )
+	if err := f.Match_proto( "tcp:80", false ); err != nil {
+		t.Fatal( err )
+	}
+	f.Set_tos( "184" )
+	f.Set_meta( "0x01" )
+	f.Resubmit( "", "0" )
+
+	expect := "add hard_timeout=64800,cookie=0xb0ff,dl_type=0x86dd,metadata=0x0/0x7,ipv6_src=2001:db8::1,dl_src=fa:16:3e:00:00:02,nw_proto=6,tp_dst=80,priority=405," +
+		"action=mod_nw_tos:184,set_field:0x01->metadata,resubmit(,0)"
+	if s := f.Add_str(); s != expect {
+		fmt.Fprintf( os.Stderr, "FAIL:   add string:\n\tgot:    %s\n\texpect: %s\n", s, expect )
+		t.Fail()
+	}
+
+	expect = "delete cookie=0xb0ff/-1,dl_type=0x86dd,metadata=0x0/0x7,ipv6_src=2001:db8::1,dl_src=fa:16:3e:00:00:02,nw_proto=6,tp_dst=80"
+	if s := f.Del_str(); s != expect {
+		fmt.Fprintf( os.Stderr, "FAIL:   delete string:\n\tgot:    %s\n\texpect: %s\n", s, expect )
+		t.Fail()
+	}
+
+	if err := f.Match_proto( "sctp:9", true ); err == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   unknown protocol accepted\n" )
+		t.Fail()
+	}
+
+	f = Mk_fmod( "0xf00d", 10 )
+	f.Set_timeout( "0" )
+	if s := f.Add_str(); s != "add cookie=0xf00d,priority=10,action=drop" {
+		fmt.Fprintf( os.Stderr, "FAIL:   empty flow-mod: %s\n", s )
+		t.Fail()
+	}
+
+	if ! t.Failed() {
+		fmt.Fprintf( os.Stderr, "OK:     flow-mod strings\n" )
+	}
+}
+
+func Test_bw_fmods( t *testing.T ) {
+	r := Mk_recorder( )
+	d := Mk_driver( r )
+	r.Set_output( "ovs-vsctl get bridge br-int protocols", `["OpenFlow10", "OpenFlow13"]` )
+
+	if err := d.Bw_fmods( "compute1", bw_data() ); err != nil {
+		t.Fatal( err )
+	}
+	data := bw_data()
+	data["smac"] = "fa:16:3e:00:00:03"
+	data["dmac"] = "fa:16:3e:00:00:02"
+	data["dproto"] = "udp:5060"
+	data["extip"] = "192.168.1.1"
+	data["extdir"] = "-D"
+	if err := d.Bw_fmods( "compute2", data ); err != nil {
+		t.Fatal( err )
+	}
+
+	if len( r.Runs ) != 0 {
+		fmt.Fprintf( os.Stderr, "FAIL:   commands were run before flush\n" )
+		t.Fail()
+	}
+	if h := d.Pending(); len( h ) != 2 || h[0] != "compute1" || h[1] != "compute2" {
+		fmt.Fprintf( os.Stderr, "FAIL:   pending hosts: %v\n", h )
+		t.Fail()
+	}
+
+	for _, h := range d.Pending() {
+		if _, _, err := d.Flush( h ); err != nil {
+			t.Fatal( err )
+		}
+	}
+
+	cmds := r.Get_cmds( "compute1" )
+	if len( cmds ) != 3 || cmds[2].Argv[0] != "ovs-ofctl" || len( cmds[2].Input ) != 2 {
+		t.Fatalf( "expected protocol get, set and one bundle for compute1, got: %v", cmds )
+	}
+	if s := cmds[0].To_str(); s != "ovs-vsctl get bridge br-int protocols" {
+		fmt.Fprintf( os.Stderr, "FAIL:   protocol get command: %s\n", s )
+		t.Fail()
+	}
+	if s := cmds[1].To_str(); s != "ovs-vsctl set bridge br-int protocols=OpenFlow10,OpenFlow13,OpenFlow14" {
+		fmt.Fprintf( os.Stderr, "FAIL:   protocol set command: %s\n", s )
+		t.Fail()
+	}
+	if s := cmds[2].To_str(); ! strings.HasPrefix( s, "ovs-ofctl -O OpenFlow14 --bundle add-flows br-int - <<'endKat'\n" ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   bundle command: %s\n", s )
+		t.Fail()
+	}
+
+	expect := []string {						// what ql_bw_fmods/send_ovs_fmod generate for the same data
+		"add hard_timeout=120,cookie=0xb0ff,dl_type=0x0800,metadata=0x0/0x7,dl_dst=fa:16:3e:00:00:02,dl_src=fa:16:3e:00:00:03,priority=450,action=mod_nw_tos:0,set_field:0x01->metadata,resubmit(,0)",
+		"add hard_timeout=120,cookie=0xb0ff,dl_type=0x0800,metadata=0x0/0x7,dl_src=fa:16:3e:00:00:02,dl_dst=fa:16:3e:00:00:03,priority=400,action=mod_nw_tos:184,set_field:0x01->metadata,resubmit(,0)",
+	}
+	for i := range expect {
+		if cmds[2].Input[i] != expect[i] {
+			fmt.Fprintf( os.Stderr, "FAIL:   compute1 fmod %d:\n\tgot:    %s\n\texpect: %s\n", i, cmds[2].Input[i], expect[i] )
+			t.Fail()
+		}
+	}
+
+	cmds = r.Get_cmds( "compute2" )							// external ip associated with the remote, and a dest port
+	expect = []string {
+		"add hard_timeout=120,cookie=0xb0ff,dl_type=0x0800,metadata=0x0/0x7,nw_src=192.168.1.1,dl_dst=fa:16:3e:00:00:03,dl_src=fa:16:3e:00:00:02,nw_proto=17,tp_dst=5060,priority=455,action=mod_nw_tos:0,set_field:0x01->metadata,resubmit(,0)",
+		"add hard_timeout=120,cookie=0xb0ff,dl_type=0x0800,metadata=0x0/0x7,nw_dst=192.168.1.1,dl_src=fa:16:3e:00:00:03,dl_dst=fa:16:3e:00:00:02,nw_proto=17,tp_dst=5060,priority=405,action=mod_nw_tos:184,set_field:0x01->metadata,resubmit(,0)",
+	}
+	if len( cmds ) != 3 || len( cmds[2].Input ) != 2 {
+		t.Fatalf( "expected one bundle for compute2, got: %v", cmds )
+	}
+	for i := range expect {
+		if cmds[2].Input[i] != expect[i] {
+			fmt.Fprintf( os.Stderr, "FAIL:   compute2 fmod %d:\n\tgot:    %s\n\texpect: %s\n", i, cmds[2].Input[i], expect[i] )
+			t.Fail()
+		}
+	}
+
+	if len( d.Pending() ) != 0 {
+		fmt.Fprintf( os.Stderr, "FAIL:   hosts still pending after flush: %v\n", d.Pending() )
+		t.Fail()
+	}
+
+	if ! t.Failed() {
+		fmt.Fprintf( os.Stderr, "OK:     bw_fmods: %d runs\n", len( r.Runs ) )
+	}
+}
+
+func Test_bw_oneswitch( t *testing.T ) {
+	r := Mk_recorder( )
+	d := Mk_driver( r )
+	r.Set_output( "ovs-vsctl get bridge br-int protocols", `["OpenFlow13", "OpenFlow14"]` )		// nothing to set
+
+	data := bw_data()
+	data["oneswitch"] = "true"
+	data["vlan_match"] = "100"
+	d.Bw_fmods( "compute1", data )
+	data = bw_data()
+	data["oneswitch"] = "true"
+	data["koe"] = "true"
+	d.Bw_fmods( "compute1", data )									// both endpoints batched into one bundle
+	d.Flush( "compute1" )
+
+	cmds := r.Get_cmds( "compute1" )
+	if len( r.Runs ) != 2 || len( cmds ) != 2 || len( cmds[1].Input ) != 2 {
+		t.Fatalf( "expected a single bundle with 2 flow-mods: %v", cmds )
+	}
+
+	expect := "add hard_timeout=120,cookie=0xb0ff,dl_type=0x0800,vlan_tci=100,metadata=0x0/0x7,dl_src=fa:16:3e:00:00:02,dl_dst=fa:16:3e:00:00:03,priority=405,action=set_field:0x01->metadata,resubmit(,0)"
+	if cmds[1].Input[0] != expect {
+		fmt.Fprintf( os.Stderr, "FAIL:   one switch fmod:\n\tgot:    %s\n\texpect: %s\n", cmds[1].Input[0], expect )
+		t.Fail()
+	}
+	if ! strings.Contains( cmds[1].Input[1], "action=mod_nw_tos:184," ) {			// keep on exit still marks
+		fmt.Fprintf( os.Stderr, "FAIL:   one switch koe fmod did not set dscp: %s\n", cmds[1].Input[1] )
+		t.Fail()
+	}
+
+	if ! t.Failed() {
+		fmt.Fprintf( os.Stderr, "OK:     one switch bw_fmods\n" )
+	}
+}
+
+func Test_bwow_fmods( t *testing.T ) {
+	r := Mk_recorder( )
+	d := Mk_driver( r )
+
+	data := bw_data()
+	data["dmac"] = ""
+	if err := d.Bwow_fmods( "compute1", data ); err == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   oneway without dmac or extip accepted\n" )
+		t.Fail()
+	}
+	data["dproto"] = "tcp:443"
+	data["sproto"] = "tcp:0"
+	data["extip"] = "10.0.0.9"
+	if err := d.Bwow_fmods( "compute1", data ); err != nil {
+		t.Fatal( err )
+	}
+
+	cmds := d.Bundle_cmds( "compute1" )
+	r.Set_fail( "compute1", true )
+	if _, stderr, err := d.Flush( "compute1" ); err == nil || len( stderr ) == 0 {
+		fmt.Fprintf( os.Stderr, "FAIL:   runner failure not returned by flush\n" )
+		t.Fail()
+	}
+
+	expect := "add hard_timeout=120,cookie=0xf00d,dl_type=0x0800,metadata=0x0/0x7,nw_dst=10.0.0.9,dl_src=fa:16:3e:00:00:02,nw_proto=6,tp_dst=443,priority=405,action=mod_nw_tos:184,set_field:0x01->metadata,resubmit(,0)"
+	if len( cmds ) != 1 || len( cmds[0].Input ) != 1 || cmds[0].Input[0] != expect {
+		fmt.Fprintf( os.Stderr, "FAIL:   oneway fmod:\n\tgot:    %v\n\texpect: %s\n", cmds, expect )
+		t.Fail()
+	}
+
+	if ! t.Failed() {
+		fmt.Fprintf( os.Stderr, "OK:     bwow_fmods\n" )
+	}
+}
+
+func Test_bridge_protos( t *testing.T ) {
+	r := Mk_recorder( )
+	d := Mk_driver( r )
+	r.Set_output( "ovs-vsctl get bridge br-int protocols", "[]" )
+
+	if c := d.Proto_set_cmd( []string{ `["OpenFlow10", "OpenFlow14"]` } ); c != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   protocols set when OpenFlow14 is already enabled: %s\n", c.To_str() )
+		t.Fail()
+	}
+
+	d.Bw_fmods( "compute1", bw_data() )
+	d.Flush( "compute1" )
+	cmds := r.Get_cmds( "compute1" )
+	if len( cmds ) != 3 || cmds[1].To_str() != "ovs-vsctl set bridge br-int protocols=OpenFlow10,OpenFlow11,OpenFlow12,OpenFlow13,OpenFlow14" {
+		t.Fatalf( "expected get, set of all protocols and the bundle on the first flush: %v", cmds )
+	}
+
+	r.Reset()
+	d.Bw_fmods( "compute1", bw_data() )
+	d.Flush( "compute1" )
+	if cmds = r.Get_cmds( "compute1" ); len( cmds ) != 1 || cmds[0].Argv[0] != "ovs-ofctl" {
+		fmt.Fprintf( os.Stderr, "FAIL:   protocols checked again on the second flush: %d commands\n", len( cmds ) )
+		t.Fail()
+	}
+
+	r.Reset()
+	r.Set_fail( "compute1", true )									// a failed bundle causes the next flush to check again
+	d.Bw_fmods( "compute1", bw_data() )
+	d.Flush( "compute1" )
+	r.Set_fail( "compute1", false )
+	r.Reset()
+	d.Bw_fmods( "compute1", bw_data() )
+	d.Flush( "compute1" )
+	if cmds = r.Get_cmds( "compute1" ); len( cmds ) != 3 || cmds[0].To_str() != "ovs-vsctl get bridge br-int protocols" {
+		fmt.Fprintf( os.Stderr, "FAIL:   protocols not checked after a failed bundle: %d commands\n", len( cmds ) )
+		t.Fail()
+	}
+
+	if ! t.Failed() {
+		fmt.Fprintf( os.Stderr, "OK:     bridge protocols checked once, and again after a failure\n" )
+	}
+}
+
+func Test_queues( t *testing.T ) {
+	r := Mk_recorder( )
+	d := Mk_driver( r )
+
+	qdata := []string {
+		"compute1/-128,res1,2,10000000,10000000,200",
+		"compute1/-128,res2,2,5000000,5000000,200",
+		"compute1/fa:16:3e:00:00:02,res1,2,10000000,10000000,200",			// egress queue; ignored
+		"compute2/-128,res3,3,1000000,1000000,200",							// other host; ignored
+	}
+
+	d.Set_queues( "compute1", qdata )
+	if cmds := r.Get_cmds( "compute1" ); len( cmds ) != 1 || cmds[0].To_str() != "ovs-vsctl clear Port qosirl0 qos" {
+		fmt.Fprintf( os.Stderr, "FAIL:   queues not cleared when htb is not allowed: %v\n", cmds )
+		t.Fail()
+	}
+
+	r.Reset()
+	d.Allow_htb = true
+	d.Set_queues( "compute1.example.com", qdata )
+	cmds := r.Get_cmds( "compute1.example.com" )
+	if len( cmds ) != 1 {
+		t.Fatalf( "expected one ovs-vsctl command: %v", cmds )
+	}
+	s := cmds[0].To_str()
+	for _, want := range []string {
+		"-- --id=@iq0 create Queue other-config:min-rate=1000 other-config:max-rate=10000000000 other-config:priority=1000",
+		"-- --id=@iq2 create Queue other-config:min-rate=15000000 other-config:max-rate=15000000 other-config:priority=200",
+		"-- --id=@qc0 create QoS type=linux-htb other-config:max-rate=10000000000 queues=0=@iq0,2=@iq2",
+		"-- set Port qosirl0 qos=@qc0",
+	} {
+		if ! strings.Contains( s, want ) {
+			fmt.Fprintf( os.Stderr, "FAIL:   queue command missing: %s\n\tgot: %s\n", want, s )
+			t.Fail()
+		}
+	}
+	if strings.Contains( s, "@iq3" ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   queue for another host was included: %s\n", s )
+		t.Fail()
+	}
+
+	if _, _, err := d.Set_queues( "compute1", []string{ "compute1/-128,res1,x,1,1,1" } ); err == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   bad queue data accepted\n" )
+		t.Fail()
+	}
+
+	if ! t.Failed() {
+		fmt.Fprintf( os.Stderr, "OK:     queues\n" )
+	}
+}
+
+func Test_dump( t *testing.T ) {
+	r := Mk_recorder( )
+	d := Mk_driver( r )
+
+	d.Dump_state( "compute1", []string{ "0xb0ff" }, true )
+	cmds := r.Get_cmds( "compute1" )
+	if len( cmds ) != 2 || cmds[0].To_str() != "ovs-ofctl dump-flows br-int" || ! strings.Contains( cmds[1].To_str(), "qos/show" ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   unexpected dump commands: %v\n", cmds )
+		t.Fail()
+	}
+
+	out := []string {
+		"NXST_FLOW reply (xid=0x4):",
+		" cookie=0xb0ff, duration=5.123s, table=0, n_packets=0, n_bytes=0, hard_timeout=120, idle_age=5, priority=400,ip,metadata=0/0x7,dl_src=fa:16:3e:00:00:02,dl_dst=fa:16:3e:00:00:03 actions=mod_nw_tos:184,set_field:0x1->metadata,resubmit(,0)",
+		" cookie=0xf00d, duration=5.1s, table=0, n_packets=0, n_bytes=0, priority=400,ip,dl_src=fa:16:3e:00:00:02 actions=resubmit(,0)",
+		" cookie=0x0, duration=900.2s, table=0, n_packets=10, n_bytes=900, priority=0 actions=NORMAL",
+		"QoS: qosirl0 linux-htb",
+		"max-rate: 10000000000",
+		"",
+		"Default:",
+		"  min-rate: 1000",
+		"  max-rate: 10000000000",
+		"Queue 2:",
+		"  min-rate: 15000000",
+		"  max-rate: 15000000",
+		"  tx_packets: 0",
+	}
+
+	recs := Parse_dump( "compute1", out, []string{ "0xb0ff", "0xf00d" } )
+	expect := []string {
+		"flow compute1 0xb0ff 0 fa:16:3e:00:00:02 fa:16:3e:00:00:03 priority=400,ip,metadata=0/0x7,dl_src=fa:16:3e:00:00:02,dl_dst=fa:16:3e:00:00:03",
+		"flow compute1 0xf00d 0 fa:16:3e:00:00:02 - priority=400,ip,dl_src=fa:16:3e:00:00:02",
+		"queue compute1 qosirl0 0 1000 10000000000",
+		"queue compute1 qosirl0 2 15000000 15000000",
+	}
+	if len( recs ) != len( expect ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   expected %d dump records, got %d: %v\n", len( expect ), len( recs ), recs )
+		t.FailNow()
+	}
+	for i := range expect {
+		if recs[i] != expect[i] {
+			fmt.Fprintf( os.Stderr, "FAIL:   dump record %d:\n\tgot:    %s\n\texpect: %s\n", i, recs[i], expect[i] )
+			t.Fail()
+		}
+	}
+
+	if ! t.Failed() {
+		fmt.Fprintf( os.Stderr, "OK:     dump parsing\n" )
+	}
+}
@@ -0,0 +1,179 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	queue
+	Abstract:	Queue and QoS command generation (create_ovs_queues). Tegu sends a list of
+				queue definitions for a setqueues action, one per queue, of the form:
+					host/port,res-name,qnum,min,max,priority
+
+				HTB queues are no longer used by qos-lite, and the script only lays them down
+				if /etc/tegu/allow_cq exists; otherwise it just removes them. The driver follows
+				suit: unless Allow_htb is set the commands generated clear the QoS from the
+				uplink ports. When allowed, a single ovs-vsctl command creates the queues, a
+				QoS for each uplink port and attaches it.
+
+				Only the outward (-128) port data is used; queues on the VM facing ports
+				(egress queues) are off by default in the script and are not supported.
+
+	Date:		17 October 2026
+
+*/
+
+package ovs
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	qos_max_rate	int64 = 10000000000			// overarching max rate for a QoS set (10G)
+	burst_opts		string = "other-config:burst=250000b other-config:cburst=250000b"
+)
+
+type queue struct {
+	min		int64
+	max		int64
+	pri		int
+}
+
+/*
+	Pick out the queues for the host's outward ports. When min and max are the same (the
+	normal case) the values of duplicate queue numbers are added together; otherwise the
+	smallest min is kept.
+*/
+func parse_qdata( host string, qdata []string ) ( queues map[int]*queue, err error ) {
+	queues = make( map[int]*queue )
+
+	for _, qd := range qdata {
+		toks := strings.Split( qd, "," )
+		if len( toks ) < 6 {
+			return nil, fmt.Errorf( "bad queue data: %s", qd )
+		}
+
+		hp := strings.SplitN( toks[0], "/", 2 )
+		if len( hp ) != 2 || ! same_host( hp[0], host ) || hp[1] != "-128" {
+			continue
+		}
+
+		qnum, err1 := strconv.Atoi( toks[2] )
+		min, err2 := strconv.ParseInt( toks[3], 10, 64 )
+		max, err3 := strconv.ParseInt( toks[4], 10, 64 )
+		pri, err4 := strconv.Atoi( toks[5] )
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			return nil, fmt.Errorf( "bad queue data: %s", qd )
+		}
+
+		q := queues[qnum]
+		if q == nil {
+			q = &queue{ }
+			queues[qnum] = q
+		}
+
+		if min != max {
+			if q.min == 0 || min < q.min {
+				q.min = min
+			}
+		} else {
+			q.min += min
+		}
+		q.max += max
+		q.pri = pri
+	}
+
+	return queues, nil
+}
+
+/*
+	Host names in the data might, or might not, have a domain.
+*/
+func same_host( h1 string, h2 string ) ( bool ) {
+	if h1 == h2 {
+		return true
+	}
+
+	return strings.SplitN( h1, ".", 2 )[0] == strings.SplitN( h2, ".", 2 )[0]
+}
+
+/*
+	Build the commands needed to set the queues for the host from the setqueues data.
+*/
+func (d *Driver) Queue_cmds( host string, qdata []string ) ( cmds []*Cmd, err error ) {
+	cmds = make( []*Cmd, 0, len( d.Uplinks ) )
+
+	if ! d.Allow_htb {
+		for _, p := range d.Uplinks {
+			cmds = append( cmds, Mk_cmd( "ovs-vsctl", "clear", "Port", p, "qos" ) )
+		}
+		return cmds, nil
+	}
+
+	queues, err := parse_qdata( host, qdata )
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := queues[0]; ! ok {
+		queues[0] = &queue{ min: 1000, max: qos_max_rate, pri: 1000 }		// static queue 0 as the script does by default
+	}
+
+	qnums := make( []int, 0, len( queues ) )
+	for n := range queues {
+		qnums = append( qnums, n )
+	}
+	sort.Ints( qnums )
+
+	argv := []string{ "ovs-vsctl" }
+	qlist := ""
+	sep := ""
+	for _, n := range qnums {
+		q := queues[n]
+		argv = append( argv, "--", fmt.Sprintf( "--id=@iq%d", n ), "create", "Queue",
+			fmt.Sprintf( "other-config:min-rate=%d", q.min ), fmt.Sprintf( "other-config:max-rate=%d", q.max ),
+			fmt.Sprintf( "other-config:priority=%d", q.pri ) )
+		argv = append( argv, strings.Split( burst_opts, " " )... )
+
+		qlist += fmt.Sprintf( "%s%d=@iq%d", sep, n, n )
+		sep = ","
+	}
+
+	for i, p := range d.Uplinks {
+		argv = append( argv, "--", fmt.Sprintf( "--id=@qc%d", i ), "create", "QoS", "type=linux-htb",
+			fmt.Sprintf( "other-config:max-rate=%d", qos_max_rate ), "queues=" + qlist )
+		argv = append( argv, "--", "set", "Port", p, fmt.Sprintf( "qos=@qc%d", i ) )
+	}
+
+	return append( cmds, Mk_cmd( argv... ) ), nil
+}
+
+/*
+	Set (or clear) the queues on the host.
+*/
+func (d *Driver) Set_queues( host string, qdata []string ) ( stdout []string, stderr []string, err error ) {
+	cmds, err := d.Queue_cmds( host, qdata )
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d.runner.Run( host, cmds )
+}
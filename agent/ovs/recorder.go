@@ -0,0 +1,116 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	recorder
+	Abstract:	A Runner which records the commands it is given rather than executing them.
+				Used for testing.
+
+	Date:		17 October 2026
+
+	Mods:		17 Oct 2026 - Output for a command can be supplied (Set_output).
+*/
+
+package ovs
+
+import (
+	"fmt"
+	"sync"
+)
+
+type Run_rec struct {
+	Host	string
+	Cmds	[]*Cmd
+}
+
+type Recorder struct {
+	Runs	[]*Run_rec
+	fail	map[string]bool				// hosts which should report a failure
+	output	map[string][]string			// canned output by command string
+	mu		sync.Mutex
+}
+
+func Mk_recorder( ) ( *Recorder ) {
+	return &Recorder{ fail: make( map[string]bool ), output: make( map[string][]string ) }
+}
+
+/*
+	Supply the output returned when the command (as generated by To_str()) is run.
+*/
+func (r *Recorder) Set_output( cmd string, out ...string ) {
+	r.mu.Lock()
+	r.output[cmd] = out
+	r.mu.Unlock()
+}
+
+/*
+	Cause runs on the host to fail (or succeed again if state is false).
+*/
+func (r *Recorder) Set_fail( host string, state bool ) {
+	r.mu.Lock()
+	r.fail[host] = state
+	r.mu.Unlock()
+}
+
+/*
+	Record the commands; the stdout returned is the command strings, or the output set for
+	the command.
+*/
+func (r *Recorder) Run( host string, cmds []*Cmd ) ( stdout []string, stderr []string, err error ) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Runs = append( r.Runs, &Run_rec{ Host: host, Cmds: cmds } )
+	for _, c := range cmds {
+		if out, ok := r.output[c.To_str()]; ok {
+			stdout = append( stdout, out... )
+		} else {
+			stdout = append( stdout, c.To_str() )
+		}
+	}
+
+	if r.fail[host] {
+		return stdout, []string{ "recorder: forced failure" }, fmt.Errorf( "forced failure on %s", host )
+	}
+	return stdout, nil, nil
+}
+
+/*
+	Return the commands run on the host, in order.
+*/
+func (r *Recorder) Get_cmds( host string ) ( cmds []*Cmd ) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, rr := range r.Runs {
+		if rr.Host == host {
+			cmds = append( cmds, rr.Cmds... )
+		}
+	}
+
+	return
+}
+
+func (r *Recorder) Reset( ) {
+	r.mu.Lock()
+	r.Runs = nil
+	r.mu.Unlock()
+}
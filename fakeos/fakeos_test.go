@@ -0,0 +1,217 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	fakeos_test
+	Abstract:	Tests which exercise the stand-in server directly over http so that the
+				responses the osif manager depends on are known to be sane.
+	Date:		17 October 2026
+
+*/
+
+package fakeos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+)
+
+/*
+	Send a request to the server and unpack the json response into a generic map.
+*/
+func fetch( method string, url string, token string, body string ) ( status int, data map[string]interface{} ) {
+	req, err := http.NewRequest( method, url, bytes.NewBufferString( body ) )
+	if err != nil {
+		return 0, nil
+	}
+	if token != "" {
+		req.Header.Set( "X-Auth-Token", token )
+	}
+
+	resp, err := http.DefaultClient.Do( req )
+	if err != nil {
+		return 0, nil
+	}
+	defer resp.Body.Close()
+
+	data = make( map[string]interface{} )
+	json.NewDecoder( resp.Body ).Decode( &data )
+	return resp.StatusCode, data
+}
+
+func load_basic( t *testing.T ) ( *Server ) {
+	fx, err := Load_fixture( "testdata/basic.json" )
+	if err != nil {
+		t.Fatal( err )
+	}
+
+	return Mk_server( fx )
+}
+
+func Test_fixture( t *testing.T ) {
+	fmt.Fprintf( os.Stderr, "\n----------- fake openstack tests --------------\n" )
+
+	if _, err := Parse_fixture( []byte( `{ "Projects": [ { "Name": "p1" } ], "Servers": [ { "Name": "vm1", "Project": "p2" } ] }` ) ); err == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   fixture with unknown project reference was accepted\n" )
+		t.Fail()
+	}
+
+	fx, err := Parse_fixture( []byte( `{ "Projects": [ { "Name": "p1" } ] }` ) )
+	if err != nil || fx.pid( "p1" ) != "p1" {
+		fmt.Fprintf( os.Stderr, "FAIL:   project id did not default to name: %v\n", err )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     fixture checks\n" )
+	}
+}
+
+func Test_keystone( t *testing.T ) {
+	s := load_basic( t )
+	defer s.Close()
+
+	status, _ := fetch( "POST", s.Url() + "v2.0/tokens", "", `{ "auth": { "tenantName": "cloudqos", "passwordCredentials": { "username": "alice", "password": "wrong" } } }` )
+	if status != http.StatusUnauthorized {
+		fmt.Fprintf( os.Stderr, "FAIL:   bad password: expected 401, got %d\n", status )
+		t.Fail()
+	}
+
+	status, data := fetch( "POST", s.Url() + "v2.0/tokens", "", `{ "auth": { "tenantName": "cloudqos", "passwordCredentials": { "username": "alice", "password": "alice-pw" } } }` )
+	access, _ := data["access"].( map[string]interface{} )
+	if status != http.StatusOK || access == nil {
+		t.Fatalf( "authorisation failed: %d %v", status, data )
+	}
+	tok := access["token"].( map[string]interface{} )
+	tid := tok["id"].( string )
+	if tok["tenant"].( map[string]interface{} )["id"] != "c1c1c1c1000000000000000000000000" || len( access["serviceCatalog"].( []interface{} ) ) != 3 {
+		fmt.Fprintf( os.Stderr, "FAIL:   authorisation response: %v\n", access )
+		t.Fail()
+	}
+
+	if status, _ = fetch( "POST", s.Url() + "v2.0/tokens", "", `{ "auth": { "tenantName": "webfarm", "passwordCredentials": { "username": "alice", "password": "alice-pw" } } }` ); status != http.StatusUnauthorized {
+		fmt.Fprintf( os.Stderr, "FAIL:   authorised into a project without a role: %d\n", status )
+		t.Fail()
+	}
+
+	status, data = fetch( "GET", s.Url() + "v2.0/tenants", tid, "" )
+	if status != http.StatusOK || len( data["tenants"].( []interface{} ) ) != 1 {
+		fmt.Fprintf( os.Stderr, "FAIL:   tenant list: %d %v\n", status, data )
+		t.Fail()
+	}
+
+	status, data = fetch( "GET", s.Url() + "v2.0/tokens/tok-alice?belongsTo=c1c1c1c1000000000000000000000000", tid, "" )
+	if status != http.StatusOK {
+		fmt.Fprintf( os.Stderr, "FAIL:   validate fixture token: %d %v\n", status, data )
+		t.Fail()
+	} else {
+		roles := data["access"].( map[string]interface{} )["user"].( map[string]interface{} )["roles"].( []interface{} )
+		if len( roles ) != 2 {
+			fmt.Fprintf( os.Stderr, "FAIL:   validate fixture token roles: %v\n", roles )
+			t.Fail()
+		}
+	}
+
+	if status, _ = fetch( "GET", s.Url() + "v2.0/tokens/tok-alice?belongsTo=webfarm", tid, "" ); status != http.StatusNotFound {
+		fmt.Fprintf( os.Stderr, "FAIL:   token validated for the wrong project: %d\n", status )
+		t.Fail()
+	}
+	if status, _ = fetch( "GET", s.Url() + "v2.0/tokens/tok-expired", tid, "" ); status != http.StatusNotFound {
+		fmt.Fprintf( os.Stderr, "FAIL:   expired token validated: %d\n", status )
+		t.Fail()
+	}
+
+	s.Revoke( tid )
+	if status, _ = fetch( "GET", s.Url() + "v2.0/tenants", tid, "" ); status != http.StatusUnauthorized {
+		fmt.Fprintf( os.Stderr, "FAIL:   revoked token accepted: %d\n", status )
+		t.Fail()
+	}
+
+	if ! t.Failed() {
+		fmt.Fprintf( os.Stderr, "OK:     keystone: %d token requests, %d validations\n", s.Count( "tokens" ), s.Count( "validate" ) )
+	}
+}
+
+func Test_nova_neutron( t *testing.T ) {
+	s := load_basic( t )
+	defer s.Close()
+
+	status, data := fetch( "POST", s.Url() + "v2.0/tokens", "", `{ "auth": { "tenantName": "admin", "passwordCredentials": { "username": "tegu", "password": "tegu-pw" } } }` )
+	if status != http.StatusOK {
+		t.Fatalf( "admin authorisation failed: %d %v", status, data )
+	}
+	tid := data["access"].( map[string]interface{} )["token"].( map[string]interface{} )["id"].( string )
+
+	base := s.srv.URL
+	status, data = fetch( "GET", base + "/compute/v2/c1c1c1c1000000000000000000000000/servers/detail", tid, "" )
+	if status != http.StatusOK || len( data["servers"].( []interface{} ) ) != 2 {
+		fmt.Fprintf( os.Stderr, "FAIL:   project server list: %d %v\n", status, data )
+		t.Fail()
+	} else {
+		vm := data["servers"].( []interface{} )[0].( map[string]interface{} )
+		addrs := vm["addresses"].( map[string]interface{} )["private"].( []interface{} )
+		if vm["OS-EXT-SRV-ATTR:host"] != "compute1" || len( addrs ) != 2 || addrs[1].( map[string]interface{} )["OS-EXT-IPS:type"] != "floating" {
+			fmt.Fprintf( os.Stderr, "FAIL:   server detail: %v\n", vm )
+			t.Fail()
+		}
+	}
+
+	if status, data = fetch( "GET", base + "/compute/v2/c1c1c1c1000000000000000000000000/servers/detail?all_tenants=1", tid, "" ); len( data["servers"].( []interface{} ) ) != 3 {
+		fmt.Fprintf( os.Stderr, "FAIL:   all tenants server list: %d %v\n", status, data )
+		t.Fail()
+	}
+
+	if _, data = fetch( "GET", base + "/network/v2.0/ports?tenant_id=f2f2f2f2000000000000000000000000", tid, "" ); len( data["ports"].( []interface{} ) ) != 2 {
+		fmt.Fprintf( os.Stderr, "FAIL:   filtered port list: %v\n", data )
+		t.Fail()
+	}
+	if _, data = fetch( "GET", base + "/network/v2.0/subnets.json", tid, "" ); len( data["subnets"].( []interface{} ) ) != 2 {
+		fmt.Fprintf( os.Stderr, "FAIL:   subnet list: %v\n", data )
+		t.Fail()
+	}
+	if _, data = fetch( "GET", base + "/network/v2.0/floatingips", tid, "" ); len( data["floatingips"].( []interface{} ) ) != 1 {
+		fmt.Fprintf( os.Stderr, "FAIL:   floating ip list: %v\n", data )
+		t.Fail()
+	}
+	if _, data = fetch( "GET", base + "/compute/v2/a0a0a0a0000000000000000000000000/os-hypervisors", tid, "" ); len( data["hypervisors"].( []interface{} ) ) != 3 {
+		fmt.Fprintf( os.Stderr, "FAIL:   hypervisor list: %v\n", data )
+		t.Fail()
+	}
+
+	s.Update( func( fx *Fixture ) {
+		fx.Servers = append( fx.Servers, Fx_server{ Id: "vm-0004", Name: "web2", Project: "cloudqos", Host: "compute2" } )
+	} )
+	if _, data = fetch( "GET", base + "/compute/v2/c1c1c1c1000000000000000000000000/servers/detail", tid, "" ); len( data["servers"].( []interface{} ) ) != 3 {
+		fmt.Fprintf( os.Stderr, "FAIL:   server list after update: %v\n", data )
+		t.Fail()
+	}
+
+	if status, _ = fetch( "GET", base + "/network/v2.0/ports", "", "" ); status != http.StatusUnauthorized {
+		fmt.Fprintf( os.Stderr, "FAIL:   request without a token accepted: %d\n", status )
+		t.Fail()
+	}
+
+	if ! t.Failed() {
+		fmt.Fprintf( os.Stderr, "OK:     nova/neutron: %d server requests, %d port requests\n", s.Count( "servers" ), s.Count( "ports" ) )
+	}
+}
@@ -0,0 +1,277 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	fixture
+	Abstract:	The description of the openstack environment served by the stand-in server.
+				Fixtures are json files (see testdata/basic.json); the structures here are
+				deliberately flatter than what openstack returns and are expanded into the
+				keystone/nova/neutron json by the server.
+
+				Projects are referenced by name everywhere in a fixture; the server translates
+				to the id when generating output.
+
+	Date:		17 October 2026
+
+*/
+
+package fakeos
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+type Fx_user struct {
+	Name		string
+	Id			string
+	Password	string
+	Roles		map[string][]string			// roles by project name
+}
+
+type Fx_project struct {
+	Name		string
+	Id			string
+}
+
+/*
+	A token which exists before the test starts (as if issued to a user by keystone).
+*/
+type Fx_token struct {
+	Id			string
+	User		string
+	Project		string
+	Expires		int64						// unix time; 0 is one hour after the server starts
+}
+
+type Fx_address struct {
+	Addr		string
+	Mac			string
+	Type		string						// fixed or floating
+	Network		string
+	Version		int							// 4 (default) or 6
+}
+
+type Fx_server struct {
+	Id			string
+	Name		string
+	Project		string
+	Host		string						// physical host
+	Addresses	[]Fx_address
+}
+
+type Fx_ip struct {
+	Ip			string
+	Subnet		string						// subnet id
+}
+
+type Fx_port struct {
+	Id			string
+	Mac			string
+	Project		string
+	Owner		string						// device_owner (compute:nova, network:router_interface, ...)
+	Device		string						// device_id (server or router id)
+	Host		string						// binding:host_id
+	Network		string
+	Ips			[]Fx_ip
+}
+
+type Fx_subnet struct {
+	Id			string
+	Name		string
+	Project		string
+	Network		string
+	Cidr		string
+	Gateway		string
+	Version		int
+}
+
+type Fx_router struct {
+	Id			string
+	Name		string
+	Project		string
+	Gateway_net	string						// external network id
+}
+
+type Fx_fip struct {
+	Id			string
+	Project		string
+	Floating	string						// floating address
+	Fixed		string						// fixed address it maps to
+	Port		string
+}
+
+type Fx_service struct {
+	Host		string
+	Binary		string						// nova-compute etc.
+	Status		string						// enabled/disabled; default enabled
+	State		string						// up/down; default up
+}
+
+type Fx_agent struct {
+	Host		string
+	Binary		string						// neutron-openvswitch-agent etc.
+	Alive		bool
+}
+
+type Fixture struct {
+	Region		string
+	Users		[]Fx_user
+	Projects	[]Fx_project
+	Tokens		[]Fx_token
+	Servers		[]Fx_server
+	Ports		[]Fx_port
+	Subnets		[]Fx_subnet
+	Routers		[]Fx_router
+	Floating_ips []Fx_fip
+	Services	[]Fx_service
+	Agents		[]Fx_agent
+}
+
+/*
+	Parse a fixture from a buffer and verify that the project references are good.
+*/
+func Parse_fixture( buf []byte ) ( fx *Fixture, err error ) {
+	fx = &Fixture{ }
+	if err = json.Unmarshal( buf, fx ); err != nil {
+		return nil, err
+	}
+
+	if err = fx.check(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+/*
+	Load a fixture from a json file.
+*/
+func Load_fixture( fname string ) ( *Fixture, error ) {
+	buf, err := ioutil.ReadFile( fname )
+	if err != nil {
+		return nil, err
+	}
+
+	fx, err := Parse_fixture( buf )
+	if err != nil {
+		return nil, fmt.Errorf( "%s: %s", fname, err )
+	}
+	return fx, nil
+}
+
+/*
+	Ensure that everything referencing a project references one that is defined. Project ids
+	default to the name.
+*/
+func (fx *Fixture) check( ) ( error ) {
+	known := make( map[string]bool )
+	for i := range fx.Projects {
+		if fx.Projects[i].Name == "" {
+			return fmt.Errorf( "project %d has no name", i )
+		}
+		if fx.Projects[i].Id == "" {
+			fx.Projects[i].Id = fx.Projects[i].Name
+		}
+		known[fx.Projects[i].Name] = true
+	}
+
+	chk := func( what string, name string, project string ) ( error ) {
+		if project != "" && ! known[project] {
+			return fmt.Errorf( "%s %s references unknown project: %s", what, name, project )
+		}
+		return nil
+	}
+
+	for _, u := range fx.Users {
+		for p := range u.Roles {
+			if err := chk( "user", u.Name, p ); err != nil {
+				return err
+			}
+		}
+	}
+	for _, t := range fx.Tokens {
+		if err := chk( "token", t.Id, t.Project ); err != nil {
+			return err
+		}
+	}
+	for _, s := range fx.Servers {
+		if err := chk( "server", s.Name, s.Project ); err != nil {
+			return err
+		}
+	}
+	for _, p := range fx.Ports {
+		if err := chk( "port", p.Id, p.Project ); err != nil {
+			return err
+		}
+	}
+	for _, s := range fx.Subnets {
+		if err := chk( "subnet", s.Id, s.Project ); err != nil {
+			return err
+		}
+	}
+	for _, r := range fx.Routers {
+		if err := chk( "router", r.Id, r.Project ); err != nil {
+			return err
+		}
+	}
+	for _, f := range fx.Floating_ips {
+		if err := chk( "floating ip", f.Floating, f.Project ); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Return the project with the name or id given; nil if not known.
+*/
+func (fx *Fixture) project( name string ) ( *Fx_project ) {
+	for i := range fx.Projects {
+		if fx.Projects[i].Name == name || fx.Projects[i].Id == name {
+			return &fx.Projects[i]
+		}
+	}
+
+	return nil
+}
+
+/*
+	Translate a project name to its id; an unknown name is returned as is.
+*/
+func (fx *Fixture) pid( name string ) ( string ) {
+	if p := fx.project( name ); p != nil {
+		return p.Id
+	}
+
+	return name
+}
+
+func (fx *Fixture) user( name string ) ( *Fx_user ) {
+	for i := range fx.Users {
+		if fx.Users[i].Name == name || fx.Users[i].Id == name {
+			return &fx.Users[i]
+		}
+	}
+
+	return nil
+}
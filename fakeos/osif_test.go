@@ -0,0 +1,185 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	osif_test
+	Abstract:	End to end tests of the osif manager (openstack inventory) against the stand-in
+				server: token validation, project name translation, default gateway lookup and
+				the lazy refresh of the project maps when an unknown VM is requested.
+	Date:		17 October 2026
+
+*/
+
+package fakeos_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/att/gopkgs/ipc"
+	"github.com/att/tegu/fakeos"
+	"github.com/att/tegu/managers"
+)
+
+/*
+	Send a request to the osif manager and wait (a while) for the response.
+*/
+func osif_req( t *testing.T, ch chan *ipc.Chmsg, mtype int, data interface{} ) ( *ipc.Chmsg ) {
+	rch := make( chan *ipc.Chmsg )
+	req := ipc.Mk_chmsg( )
+	req.Send_req( ch, rch, mtype, data, nil )
+
+	select {
+		case req = <- rch:
+			return req
+
+		case <- time.After( 15 * time.Second ):
+			t.Fatalf( "osif did not respond to request %d", mtype )
+	}
+
+	return nil
+}
+
+func strp( s string ) ( *string ) {
+	return &s
+}
+
+func Test_osif( t *testing.T ) {
+	fmt.Fprintf( os.Stderr, "\n----------- osif against fake openstack --------------\n" )
+
+	fx, err := fakeos.Load_fixture( "testdata/basic.json" )
+	if err != nil {
+		t.Fatal( err )
+	}
+	s := fakeos.Mk_server( fx )
+	defer s.Close()
+
+	dir, err := ioutil.TempDir( "", "fakeos" )
+	if err != nil {
+		t.Fatal( err )
+	}
+	defer os.RemoveAll( dir )
+	if wd, err := os.Getwd(); err == nil {
+		os.Chdir( dir )								// any log file created lands here rather than in the source
+		defer os.Chdir( wd )
+	}
+
+	cfg := filepath.Join( dir, "tegu.cfg" )
+	ioutil.WriteFile( cfg, []byte( fmt.Sprintf( "log_dir = stderr\n\n:osif\n\turl = %q\n\tusr = \"tegu\"\n\tpasswd = \"tegu-pw\"\n\tproject = \"admin\"\n" +
+		"\tostack_list = all\n\trequire_token = true\n\tverbose = 1\n", s.Url() ) ), 0644 )
+
+	osif_ch := make( chan *ipc.Chmsg, 128 )
+	if err = managers.Initialise( &cfg, strp( "test" ), make( chan *ipc.Chmsg, 128 ), make( chan *ipc.Chmsg, 128 ), osif_ch,
+			make( chan *ipc.Chmsg, 128 ), make( chan *ipc.Chmsg, 128 ), make( chan *ipc.Chmsg, 128 ) ); err != nil {
+		t.Fatal( err )
+	}
+	go managers.Osif_mgr( osif_ch )
+
+	m := osif_req( t, osif_ch, managers.REQ_PNAME2ID, strp( "cloudqos" ) )			// first response means initialisation is complete
+	if s.Count( "tokens" ) == 0 {
+		t.Skip( "openstack interface did not contact the stand-in server (config or ostack library unavailable)" )
+	}
+
+	if id, ok := m.Response_data.( *string ); ! ok || id == nil || *id != "c1c1c1c1000000000000000000000000" {
+		fmt.Fprintf( os.Stderr, "FAIL:   pname2id cloudqos: %v\n", m.Response_data )
+		t.Fail()
+	}
+
+	m = osif_req( t, osif_ch, managers.REQ_VALIDATE_HOST, strp( "tok-alice/cloudqos/db1" ) )
+	if m.State != nil || m.Response_data.( *string ) == nil || ! strings.HasPrefix( *(m.Response_data.( *string )), "c1c1c1c1000000000000000000000000/" ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   validate host with good token: %v %v\n", m.State, m.Response_data )
+		t.Fail()
+	}
+	if m = osif_req( t, osif_ch, managers.REQ_VALIDATE_HOST, strp( "tok-bob/cloudqos/db1" ) ); m.State == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   validate host accepted a token for another project\n" )
+		t.Fail()
+	}
+	if m = osif_req( t, osif_ch, managers.REQ_VALIDATE_HOST, strp( "tok-expired/cloudqos/db1" ) ); m.State == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   validate host accepted an expired token\n" )
+		t.Fail()
+	}
+
+	if m = osif_req( t, osif_ch, managers.REQ_HAS_ANY_ROLE, strp( "tok-alice/cloudqos tegu_mirror,admin" ) ); m.State != nil || m.Response_data != true {
+		fmt.Fprintf( os.Stderr, "FAIL:   has any role: %v %v\n", m.State, m.Response_data )
+		t.Fail()
+	}
+	if m = osif_req( t, osif_ch, managers.REQ_HAS_ANY_ROLE, strp( "tok-bob/webfarm tegu_mirror" ) ); m.Response_data == true {
+		fmt.Fprintf( os.Stderr, "FAIL:   has any role: accepted a role the user doesn't have\n" )
+		t.Fail()
+	}
+
+	if m = osif_req( t, osif_ch, managers.REQ_VALIDATE_TEGU_ADMIN, strp( "tok-admin" ) ); m.State != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   admin token rejected: %s\n", m.State )
+		t.Fail()
+	}
+	if m = osif_req( t, osif_ch, managers.REQ_VALIDATE_TEGU_ADMIN, strp( "tok-alice" ) ); m.State == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   non-admin token accepted as tegu admin\n" )
+		t.Fail()
+	}
+
+	m = osif_req( t, osif_ch, managers.REQ_GET_DEFGW, strp( "cloudqos/junk" ) )
+	if gw, ok := m.Response_data.( *string ); ! ok || gw == nil || ! strings.HasSuffix( *gw, "10.1.0.1" ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   default gateway for cloudqos: %v %v\n", m.State, m.Response_data )
+		t.Fail()
+	}
+
+	m = osif_req( t, osif_ch, managers.REQ_GET_HOSTINFO, strp( "c1c1c1c1000000000000000000000000/db1" ) )
+	if vm, ok := m.Response_data.( *managers.Net_vm ); ! ok || vm == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   hostinfo for db1: %v\n", m.State )
+		t.Fail()
+	} else {
+		_, id, ip4, _, _, phost, mac, _ := vm.Get_values()
+		if id == nil || *id != "vm-0001" || ip4 == nil || *ip4 != "10.1.0.5" || phost == nil || *phost != "compute1" || mac == nil || *mac != "fa:16:3e:00:01:05" {
+			fmt.Fprintf( os.Stderr, "FAIL:   hostinfo for db1: %s\n", vm.To_str() )
+			t.Fail()
+		}
+	}
+
+	// a VM created after the maps were built must be found by the lazy refresh
+	before := s.Count( "servers" )
+	s.Update( func( fx *fakeos.Fixture ) {
+		fx.Servers = append( fx.Servers, fakeos.Fx_server{ Id: "vm-0009", Name: "late1", Project: "cloudqos", Host: "compute2",
+			Addresses: []fakeos.Fx_address{ { Network: "private", Addr: "10.1.0.9", Mac: "fa:16:3e:00:01:09" } } } )
+		fx.Ports = append( fx.Ports, fakeos.Fx_port{ Id: "p-0009", Mac: "fa:16:3e:00:01:09", Project: "cloudqos", Owner: "compute:nova",
+			Device: "vm-0009", Host: "compute2", Network: "net-private", Ips: []fakeos.Fx_ip{ { Ip: "10.1.0.9", Subnet: "sn-private" } } } )
+	} )
+	m = osif_req( t, osif_ch, managers.REQ_GET_HOSTINFO, strp( "cloudqos/late1" ) )
+	if vm, ok := m.Response_data.( *managers.Net_vm ); ! ok || vm == nil || s.Count( "servers" ) <= before {
+		fmt.Fprintf( os.Stderr, "FAIL:   lazy refresh did not find new VM: %v (server requests %d -> %d)\n", m.State, before, s.Count( "servers" ) )
+		t.Fail()
+	}
+
+	m = osif_req( t, osif_ch, managers.REQ_CHOSTLIST, nil )
+	if hl, ok := m.Response_data.( *string ); ! ok || hl == nil || ! strings.Contains( *hl, "compute1" ) || ! strings.Contains( *hl, "compute2" ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   compute host list: %v %v\n", m.State, m.Response_data )
+		t.Fail()
+	}
+
+	if ! t.Failed() {
+		fmt.Fprintf( os.Stderr, "OK:     osif: %d token, %d validate, %d server and %d port requests\n",
+			s.Count( "tokens" ), s.Count( "validate" ), s.Count( "servers" ), s.Count( "ports" ) )
+	}
+}
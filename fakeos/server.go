@@ -0,0 +1,547 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	fakeos
+	Abstract:	A stand-in for openstack used only by tests. An http server (httptest) answers
+				the keystone v2.0 token, tenant and token validation requests, and the nova and
+				neutron requests made by the osif manager (via the ostack package):
+
+					POST /v2.0/tokens						authorise (password, or token rescope)
+					GET  /v2.0/tenants						projects the token's user belongs to
+					GET  /v2.0/tokens/<id>[?belongsTo=p]	validate a token (user, project, roles)
+					GET  /compute/v2/<tid>/servers/detail	servers (VMs) with addresses and host
+					GET  /compute/v2/<tid>/os-services		compute services
+					GET  /compute/v2/<tid>/os-hypervisors	hypervisors
+					GET  /network/v2.0/ports				ports (mac, fixed ips, owner, host)
+					GET  /network/v2.0/subnets				subnets (cidr, gateway)
+					GET  /network/v2.0/routers				routers
+					GET  /network/v2.0/floatingips			floating ips
+					GET  /network/v2.0/agents				network agents
+
+				The service catalogue returned on authorisation points at the compute and
+				network paths on the same server. Every request other than POST tokens must
+				carry a valid X-Auth-Token. Requests are counted (Count()) so that tests can
+				verify when data was (re)fetched, and the fixture can be changed while the
+				server runs (Update()).
+
+	Date:		17 October 2026
+
+*/
+
+package fakeos
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	A token issued by, or known to, the server.
+*/
+type token struct {
+	id			string
+	user		*Fx_user
+	project		*Fx_project				// nil if unscoped
+	expires		int64
+}
+
+type Server struct {
+	fx			*Fixture
+	srv			*httptest.Server
+	mu			sync.Mutex
+	tokens		map[string]*token
+	counts		map[string]int			// request counts by kind (tokens, tenants, validate, servers, ports...)
+	ntokens		int
+}
+
+/*
+	Start a stand-in server which serves the fixture.
+*/
+func Mk_server( fx *Fixture ) ( s *Server ) {
+	s = &Server {
+		fx:		fx,
+		tokens:	make( map[string]*token ),
+		counts:	make( map[string]int ),
+	}
+
+	now := time.Now().Unix()
+	for _, t := range fx.Tokens {
+		exp := t.Expires
+		if exp == 0 {
+			exp = now + 3600
+		}
+		s.tokens[t.Id] = &token{ id: t.Id, user: fx.user( t.User ), project: fx.project( t.Project ), expires: exp }
+	}
+
+	s.srv = httptest.NewServer( s )
+	return
+}
+
+/*
+	Return the keystone url (with trailing slant) to be used as osif:url.
+*/
+func (s *Server) Url( ) ( string ) {
+	return s.srv.URL + "/"
+}
+
+func (s *Server) Close( ) {
+	s.srv.Close()
+}
+
+/*
+	Return the number of requests of the kind (e.g. "servers", "tokens") that have been received.
+*/
+func (s *Server) Count( kind string ) ( int ) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.counts[kind]
+}
+
+/*
+	Invoke f to change the fixture while holding the server lock.
+*/
+func (s *Server) Update( f func( fx *Fixture ) ) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f( s.fx )
+	s.fx.check()
+}
+
+/*
+	Invalidate a token.
+*/
+func (s *Server) Revoke( id string ) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete( s.tokens, id )
+}
+
+// ---- json generation -------------------------------------------------------------------
+
+func exp_str( exp int64 ) ( string ) {
+	return time.Unix( exp, 0 ).UTC().Format( "2006-01-02T15:04:05Z" )
+}
+
+/*
+	Build the access block returned for authorisation and validation.
+*/
+func (s *Server) access( t *token, catalog bool ) ( map[string]interface{} ) {
+	tok := map[string]interface{} {
+		"id":		t.id,
+		"expires":	exp_str( t.expires ),
+		"issued_at": exp_str( t.expires - 3600 ),
+	}
+
+	roles := make( []map[string]string, 0 )
+	if t.project != nil {
+		tok["tenant"] = map[string]interface{}{ "id": t.project.Id, "name": t.project.Name, "enabled": true, "description": t.project.Name }
+		for _, r := range t.user.Roles[t.project.Name] {
+			roles = append( roles, map[string]string{ "name": r } )
+		}
+	}
+
+	a := map[string]interface{} {
+		"token":	tok,
+		"user":		map[string]interface{}{ "id": t.user.Id, "name": t.user.Name, "username": t.user.Name, "roles": roles },
+		"metadata":	map[string]interface{}{ "is_admin": 0, "roles": roles },
+	}
+
+	if catalog {
+		base := s.srv.URL
+		tid := ""
+		if t.project != nil {
+			tid = t.project.Id
+		}
+		ep := func( stype string, name string, url string ) ( map[string]interface{} ) {
+			return map[string]interface{} {
+				"type":	stype,
+				"name":	name,
+				"endpoints": []map[string]string{ { "region": s.fx.Region, "publicURL": url, "internalURL": url, "adminURL": url, "id": name } },
+				"endpoints_links": []string{ },
+			}
+		}
+		a["serviceCatalog"] = []map[string]interface{} {
+			ep( "compute", "nova", base + "/compute/v2/" + tid ),
+			ep( "network", "neutron", base + "/network/" ),
+			ep( "identity", "keystone", base + "/v2.0" ),
+		}
+	}
+
+	return map[string]interface{}{ "access": a }
+}
+
+func (s *Server) servers( tid string ) ( []map[string]interface{} ) {
+	list := make( []map[string]interface{}, 0 )
+	for _, v := range s.fx.Servers {
+		pid := s.fx.pid( v.Project )
+		if tid != "" && pid != tid {
+			continue
+		}
+
+		addrs := make( map[string][]map[string]interface{} )
+		for _, a := range v.Addresses {
+			ver := a.Version
+			if ver == 0 {
+				ver = 4
+			}
+			atype := a.Type
+			if atype == "" {
+				atype = "fixed"
+			}
+			addrs[a.Network] = append( addrs[a.Network], map[string]interface{} {
+				"addr":	a.Addr, "version": ver, "OS-EXT-IPS:type": atype, "OS-EXT-IPS-MAC:mac_addr": a.Mac,
+			} )
+		}
+
+		list = append( list, map[string]interface{} {
+			"id":		v.Id,
+			"name":		v.Name,
+			"tenant_id": pid,
+			"status":	"ACTIVE",
+			"addresses": addrs,
+			"OS-EXT-SRV-ATTR:host": v.Host,
+			"OS-EXT-SRV-ATTR:hypervisor_hostname": v.Host,
+		} )
+	}
+
+	return list
+}
+
+func (s *Server) ports( tid string ) ( []map[string]interface{} ) {
+	list := make( []map[string]interface{}, 0 )
+	for _, p := range s.fx.Ports {
+		pid := s.fx.pid( p.Project )
+		if tid != "" && pid != tid {
+			continue
+		}
+
+		ips := make( []map[string]string, 0, len( p.Ips ) )
+		for _, ip := range p.Ips {
+			ips = append( ips, map[string]string{ "ip_address": ip.Ip, "subnet_id": ip.Subnet } )
+		}
+		list = append( list, map[string]interface{} {
+			"id":				p.Id,
+			"mac_address":		p.Mac,
+			"tenant_id":		pid,
+			"device_owner":		p.Owner,
+			"device_id":		p.Device,
+			"network_id":		p.Network,
+			"binding:host_id":	p.Host,
+			"admin_state_up":	true,
+			"status":			"ACTIVE",
+			"fixed_ips":		ips,
+		} )
+	}
+
+	return list
+}
+
+func (s *Server) subnets( tid string ) ( []map[string]interface{} ) {
+	list := make( []map[string]interface{}, 0 )
+	for _, sn := range s.fx.Subnets {
+		pid := s.fx.pid( sn.Project )
+		if tid != "" && pid != tid {
+			continue
+		}
+
+		ver := sn.Version
+		if ver == 0 {
+			ver = 4
+		}
+		list = append( list, map[string]interface{} {
+			"id": sn.Id, "name": sn.Name, "tenant_id": pid, "network_id": sn.Network,
+			"cidr": sn.Cidr, "gateway_ip": sn.Gateway, "ip_version": ver, "enable_dhcp": true,
+		} )
+	}
+
+	return list
+}
+
+func (s *Server) routers( tid string ) ( []map[string]interface{} ) {
+	list := make( []map[string]interface{}, 0 )
+	for _, r := range s.fx.Routers {
+		pid := s.fx.pid( r.Project )
+		if tid != "" && pid != tid {
+			continue
+		}
+
+		var gw interface{} = nil
+		if r.Gateway_net != "" {
+			gw = map[string]string{ "network_id": r.Gateway_net }
+		}
+		list = append( list, map[string]interface{} {
+			"id": r.Id, "name": r.Name, "tenant_id": pid, "status": "ACTIVE", "admin_state_up": true, "external_gateway_info": gw,
+		} )
+	}
+
+	return list
+}
+
+func (s *Server) fips( tid string ) ( []map[string]interface{} ) {
+	list := make( []map[string]interface{}, 0 )
+	for _, f := range s.fx.Floating_ips {
+		pid := s.fx.pid( f.Project )
+		if tid != "" && pid != tid {
+			continue
+		}
+
+		list = append( list, map[string]interface{} {
+			"id": f.Id, "tenant_id": pid, "floating_ip_address": f.Floating, "fixed_ip_address": f.Fixed, "port_id": f.Port,
+		} )
+	}
+
+	return list
+}
+
+func (s *Server) services( ) ( []map[string]interface{} ) {
+	list := make( []map[string]interface{}, 0 )
+	for _, v := range s.fx.Services {
+		status := v.Status
+		if status == "" {
+			status = "enabled"
+		}
+		state := v.State
+		if state == "" {
+			state = "up"
+		}
+		list = append( list, map[string]interface{}{ "binary": v.Binary, "host": v.Host, "status": status, "state": state, "zone": "nova" } )
+	}
+
+	return list
+}
+
+func (s *Server) hypervisors( ) ( []map[string]interface{} ) {
+	list := make( []map[string]interface{}, 0 )
+	for i, v := range s.fx.Services {
+		if v.Binary == "nova-compute" {
+			list = append( list, map[string]interface{}{ "id": i + 1, "hypervisor_hostname": v.Host, "state": "up", "status": "enabled" } )
+		}
+	}
+
+	return list
+}
+
+func (s *Server) agents( ) ( []map[string]interface{} ) {
+	list := make( []map[string]interface{}, 0 )
+	for i, a := range s.fx.Agents {
+		list = append( list, map[string]interface{}{ "id": fmt.Sprintf( "agent-%d", i ), "host": a.Host, "binary": a.Binary, "alive": a.Alive, "admin_state_up": true } )
+	}
+
+	return list
+}
+
+// ---- request handling ------------------------------------------------------------------
+
+func reply( w http.ResponseWriter, status int, data interface{} ) {
+	w.Header().Set( "Content-Type", "application/json" )
+	w.WriteHeader( status )
+	json.NewEncoder( w ).Encode( data )
+}
+
+func fail( w http.ResponseWriter, status int, msg string ) {
+	reply( w, status, map[string]interface{}{ "error": map[string]interface{}{ "code": status, "message": msg, "title": http.StatusText( status ) } } )
+}
+
+/*
+	Authorisation request body. Either password credentials or an existing token may be given.
+*/
+type auth_req struct {
+	Auth struct {
+		TenantName			string		`json:"tenantName"`
+		TenantId			string		`json:"tenantId"`
+		PasswordCredentials	*struct {
+			Username	string		`json:"username"`
+			Password	string		`json:"password"`
+		}							`json:"passwordCredentials"`
+		Token				*struct {
+			Id			string		`json:"id"`
+		}							`json:"token"`
+	}								`json:"auth"`
+}
+
+func (s *Server) authorise( w http.ResponseWriter, r *http.Request ) {
+	body, _ := ioutil.ReadAll( r.Body )
+	ar := &auth_req{ }
+	if err := json.Unmarshal( body, ar ); err != nil {
+		fail( w, http.StatusBadRequest, "malformed request body" )
+		return
+	}
+
+	var u *Fx_user
+	switch {
+		case ar.Auth.PasswordCredentials != nil:
+			u = s.fx.user( ar.Auth.PasswordCredentials.Username )
+			if u == nil || u.Password != ar.Auth.PasswordCredentials.Password {
+				fail( w, http.StatusUnauthorized, "invalid user / password" )
+				return
+			}
+
+		case ar.Auth.Token != nil:
+			t := s.tokens[ar.Auth.Token.Id]
+			if t == nil || t.expires < time.Now().Unix() {
+				fail( w, http.StatusUnauthorized, "token is not valid" )
+				return
+			}
+			u = t.user
+
+		default:
+			fail( w, http.StatusBadRequest, "no credentials" )
+			return
+	}
+
+	var p *Fx_project
+	pname := ar.Auth.TenantName
+	if pname == "" {
+		pname = ar.Auth.TenantId
+	}
+	if pname != "" {
+		if p = s.fx.project( pname ); p == nil || len( u.Roles[p.Name] ) == 0 {
+			fail( w, http.StatusUnauthorized, fmt.Sprintf( "user %s has no role in project %s", u.Name, pname ) )
+			return
+		}
+	}
+
+	s.ntokens++
+	t := &token{ id: fmt.Sprintf( "fakeos-tok-%d", s.ntokens ), user: u, project: p, expires: time.Now().Unix() + 3600 }
+	s.tokens[t.id] = t
+	reply( w, http.StatusOK, s.access( t, true ) )
+}
+
+/*
+	Validate the token given on a request.
+*/
+func (s *Server) caller( r *http.Request ) ( *token ) {
+	t := s.tokens[r.Header.Get( "X-Auth-Token" )]
+	if t == nil || t.expires < time.Now().Unix() {
+		return nil
+	}
+
+	return t
+}
+
+func (s *Server) ServeHTTP( w http.ResponseWriter, r *http.Request ) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimRight( r.URL.Path, "/" )
+	toks := strings.Split( strings.TrimLeft( path, "/" ), "/" )
+
+	if path == "/v2.0/tokens" && r.Method == "POST" {
+		s.counts["tokens"]++
+		s.authorise( w, r )
+		return
+	}
+
+	ct := s.caller( r )
+	if ct == nil {
+		s.counts["unauthorised"]++
+		fail( w, http.StatusUnauthorized, "the request requires a valid token" )
+		return
+	}
+
+	tid := r.URL.Query().Get( "tenant_id" )				// neutron filter
+	switch {
+		case path == "/v2.0/tenants":
+			s.counts["tenants"]++
+			list := make( []map[string]interface{}, 0 )
+			for _, p := range s.fx.Projects {
+				if len( ct.user.Roles[p.Name] ) > 0 {
+					list = append( list, map[string]interface{}{ "id": p.Id, "name": p.Name, "enabled": true, "description": p.Name } )
+				}
+			}
+			reply( w, http.StatusOK, map[string]interface{}{ "tenants": list, "tenants_links": []string{ } } )
+
+		case len( toks ) == 3 && toks[0] == "v2.0" && toks[1] == "tokens":
+			s.counts["validate"]++
+			t := s.tokens[toks[2]]
+			if t == nil || t.expires < time.Now().Unix() {
+				fail( w, http.StatusNotFound, "token not found" )
+				return
+			}
+			if b := r.URL.Query().Get( "belongsTo" ); b != "" && (t.project == nil || (t.project.Id != b && t.project.Name != b)) {
+				fail( w, http.StatusNotFound, "token does not belong to project" )
+				return
+			}
+			if r.Method == "HEAD" {
+				w.WriteHeader( http.StatusOK )
+				return
+			}
+			reply( w, http.StatusOK, s.access( t, false ) )
+
+		case len( toks ) >= 4 && toks[0] == "compute":				// compute/v2/<tid>/<what>[/detail]
+			if toks[2] != "" && r.URL.Query().Get( "all_tenants" ) == "" {
+				tid = toks[2]
+			}
+			switch toks[3] {
+				case "servers":
+					s.counts["servers"]++
+					reply( w, http.StatusOK, map[string]interface{}{ "servers": s.servers( tid ) } )
+
+				case "os-services":
+					s.counts["services"]++
+					reply( w, http.StatusOK, map[string]interface{}{ "services": s.services() } )
+
+				case "os-hypervisors":
+					s.counts["hypervisors"]++
+					reply( w, http.StatusOK, map[string]interface{}{ "hypervisors": s.hypervisors() } )
+
+				default:
+					fail( w, http.StatusNotFound, "unknown compute request: " + path )
+			}
+
+		case len( toks ) >= 3 && toks[0] == "network" && toks[1] == "v2.0":
+			what := strings.TrimSuffix( toks[2], ".json" )
+			s.counts[what]++
+			switch what {
+				case "ports":
+					reply( w, http.StatusOK, map[string]interface{}{ "ports": s.ports( tid ) } )
+
+				case "subnets":
+					reply( w, http.StatusOK, map[string]interface{}{ "subnets": s.subnets( tid ) } )
+
+				case "routers":
+					reply( w, http.StatusOK, map[string]interface{}{ "routers": s.routers( tid ) } )
+
+				case "floatingips":
+					reply( w, http.StatusOK, map[string]interface{}{ "floatingips": s.fips( tid ) } )
+
+				case "agents":
+					reply( w, http.StatusOK, map[string]interface{}{ "agents": s.agents() } )
+
+				default:
+					fail( w, http.StatusNotFound, "unknown network request: " + path )
+			}
+
+		default:
+			s.counts["unknown"]++
+			fail( w, http.StatusNotFound, "unknown request: " + path )
+	}
+}
@@ -0,0 +1,97 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	drain
+	Abstract:	A window of time during which a link is not available (maintenance). A drained
+				link has no capacity during the window; the same drain may be attached to several
+				links (both directions of a link, or all links of a switch) and is removed from
+				all of them by id.
+
+	Date:		17 October 2026
+
+*/
+
+package gizmos
+
+import (
+	"fmt"
+)
+
+type Drain struct {
+	id			*string
+	commence	int64
+	conclude	int64
+}
+
+/*
+	Create a drain for the window.
+*/
+func Mk_drain( id *string, commence int64, conclude int64 ) ( d *Drain, err error ) {
+	if id == nil || *id == "" {
+		return nil, fmt.Errorf( "drain id missing" )
+	}
+	if conclude <= commence {
+		return nil, fmt.Errorf( "drain window is empty: %d to %d", commence, conclude )
+	}
+
+	d = &Drain {
+		id:			id,
+		commence:	commence,
+		conclude:	conclude,
+	}
+
+	return
+}
+
+func (d *Drain) Get_id( ) ( *string ) {
+	return d.id
+}
+
+func (d *Drain) Get_window( ) ( int64, int64 ) {
+	return d.commence, d.conclude
+}
+
+/*
+	Return true if the drain window and the window given have any time in common.
+*/
+func (d *Drain) Overlaps( commence int64, conclude int64 ) ( bool ) {
+	if d == nil {
+		return false
+	}
+
+	return commence < d.conclude && conclude > d.commence
+}
+
+/*
+	Return true if the drain window is completely before the time.
+*/
+func (d *Drain) Is_expired( now int64 ) ( bool ) {
+	return d.conclude <= now
+}
+
+func (d *Drain) To_json( ) ( string ) {
+	if d == nil {
+		return `{ "id": "null-drain" }`
+	}
+
+	return fmt.Sprintf( `{ "id": %q, "commence": %d, "conclude": %d }`, *d.id, d.commence, d.conclude )
+}
@@ -29,6 +29,8 @@
 				05 May 2014 : Added function to build a FL_host_json from raw data rather
 					than from json response data (supports running w/o floodlight).
 				29 Jul 2014 : Mlag support
+				17 Oct 2026 : Quoted the qdata key in the set queues json. These functions are now
+					used through the Sdn_ctlr interface (sdnc.go).
 ------------------------------------------------------------------------------------------------
 */
 
@@ -315,7 +317,7 @@ func SK_set_queues( flhost *string, qlist []string ) ( err error ) {
 		sep string = ""
 	)
 
-	json = `{ "ctype": "action_list",  "actions": [ { "atype": "setqueues", qdata: [`
+	json = `{ "ctype": "action_list",  "actions": [ { "atype": "setqueues", "qdata": [`
 
 	for i := 0; i < len( qlist ); i++ {
 		json += fmt.Sprintf( "%s%q", sep, qlist[i] )
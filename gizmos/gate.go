@@ -30,6 +30,7 @@
 
 	Mod:		17 Jun 2015 - Added inc_utilisation() function and support
 				to modifify underlying queues in the links.
+				17 Oct 2026 - Added Get_links() so reroute/drain can find the links a gate uses.
 */
 
 package gizmos
@@ -186,6 +187,22 @@ func (g *Gate) Get_sw_name( ) ( *string ) {
 	return nil
 }
 
+/*
+	Return the links attached to the gate's switch; these are the links that carry
+	the gate's capacity.
+*/
+func (g *Gate) Get_links( ) ( links []*Link ) {
+	if g == nil || g.gsw == nil {
+		return
+	}
+
+	for i := 0; g.gsw.Get_link( i ) != nil; i++ {
+		links = append( links, g.gsw.Get_link( i ) )
+	}
+
+	return
+}
+
 /*
 	Build an spq struct based on the gate info
 */
@@ -36,6 +36,7 @@
 	Mod:		29 Jun 2014 - Changes to support user link limits.
 				26 Mar 2015 - Added Get_address() function to return one address with
 					favourtism if host has both addresses defined.
+				17 Oct 2026 - Added Clone().
 */
 
 package gizmos
@@ -100,6 +101,32 @@ func (h *Host) Add_vmid( vmid *string ) {
 	h.vmid = vmid
 }
 
+/*
+	Create a copy of the host which is attached to the switches in swmap that correspond to the
+	switches this host is attached to; the host is also added to each of those switches. Switches
+	which are not in the map are skipped.  Used to build a copy of the network graph.
+*/
+func (h *Host) Clone( swmap map[*Switch]*Switch ) ( nh *Host ) {
+	if h == nil {
+		return nil
+	}
+
+	nh = Mk_host( h.mac, h.ip4, h.ip6 )
+	nh.vmid = h.vmid
+	for i := 0; i < h.cidx; i++ {
+		if nsw := swmap[h.conns[i]]; nsw != nil {
+			nh.Add_switch( nsw, h.ports[i] )
+			vmid := h.vmid
+			if vmid == nil {
+				vmid = &empty_str
+			}
+			nsw.Add_host( &nh.mac, vmid, h.ports[i] )
+		}
+	}
+
+	return
+}
+
 /*
 	allows more switches to be added
 */
@@ -29,12 +29,16 @@
 					bleat id to gizmos.
 				24 Jun 2014 : Added new constants for steering pledges.
 				17 Feb 2015 : Added mirroring
+				17 Oct 2026 : Added series pledge type.
+				17 Oct 2026 : Added path protection constants.
+				17 Oct 2026 : Added push state constants.
 */
 
 package gizmos
 
 
 import (
+	"fmt"
 	"os"
 	"github.com/att/gopkgs/bleater"
 )
@@ -46,8 +50,62 @@ const (
 	PT_STEERING
 	PT_MIRRORING
 	PT_OWBANDWIDTH							// one way bandwidth
+	PT_SERIES								// recurring bandwidth (series of bandwidth pledges)
 )
 
+const (
+	PROT_NONE		int = iota				// path protection for bandwidth pledges: single path
+	PROT_LINK								// two paths which share no link
+	PROT_SWITCH								// two paths which share no switch other than the endpoints
+)
+
+var prot_names = []string { "none", "link", "switch" }
+
+/*
+	Convert a protection name (none, link, switch) to one of the PROT_ constants.
+*/
+func Str2protect( s string ) ( int, error ) {
+	for i, n := range prot_names {
+		if s == n {
+			return i, nil
+		}
+	}
+
+	return PROT_NONE, fmt.Errorf( "unrecognised protection type: %s (expected link or switch)", s )
+}
+
+/*
+	Convert a PROT_ constant to its name.
+*/
+func Protect2str( v int ) ( string ) {
+	if v < 0 || v >= len( prot_names ) {
+		return prot_names[PROT_NONE]
+	}
+
+	return prot_names[v]
+}
+
+const (
+	PS_NONE			int = iota				// push state: not pushed, or nothing heard from the agent(s)
+	PS_PENDING								// pushed, waiting on the agent(s) to ack/nack
+	PS_INSTALLED							// all agent actions for the pledge were successful
+	PS_PARTIAL								// some, but not all, hosts reported success
+	PS_FAILED								// all hosts reported failure (or the action timed out)
+)
+
+var ps_names = []string { "none", "pending", "installed", "partial", "failed" }
+
+/*
+	Convert a PS_ constant to its name.
+*/
+func Push_state2str( v int ) ( string ) {
+	if v < 0 || v >= len( ps_names ) {
+		return ps_names[PS_NONE]
+	}
+
+	return ps_names[v]
+}
+
 var (
 	empty_str	string = ""					// these make &"" possible since that's not legal in go
 	zero_str	string = "0"
@@ -58,6 +58,10 @@
 				05 Sep 2014 - Pick up late binding port info if port is <0 rather than 0.
 				19 Oct 2014 - Comment change
 				18 Jun 2015 - Added nil pointer check.
+				17 Oct 2026 - Added Get_boundaries().
+				17 Oct 2026 - Added drain (maintenance) windows; a link has no capacity while drained.
+				17 Oct 2026 - Added Clone().
+				17 Oct 2026 - Drain edges are boundaries; drain check can be suspended (Set_drain_check).
 */
 
 package gizmos
@@ -66,6 +70,7 @@ import (
 	//"bufio"
 	"fmt"
 	//"os"
+	"sort"
 	"strings"
 	//"time"
 )
@@ -88,6 +93,8 @@ type Link struct {
 	sw2			*string				// human name for backward switch
 	mlag		*string				// mlag group this link belongs to
 	allotment	*Obligation			// the obligation that exsists for the link (obligations are timesliced)
+	drains		[]*Drain			// windows when the link is not available
+	no_drain	bool				// drains are ignored by Has_capacity when set
 
 	Cost		int					// the cost of traversing the link for shortest path computation
 }
@@ -165,6 +172,34 @@ func Mk_vlink( sw *string, p1 int, p2 int, capacity int64, bond ...*Link ) ( l *
 	return
 }
 
+/*
+	Create a copy of the link between the two switches given (forward and backward). The copy
+	references the obligation passed in (allowing the caller to preserve links which share an
+	obligation); drains are copied.  Used to build a copy of the network graph.
+*/
+func (l *Link) Clone( fwd *Switch, bwd *Switch, ob *Obligation ) ( nl *Link ) {
+	if l == nil {
+		return nil
+	}
+
+	nl = &Link {
+		forward:	fwd,
+		backward:	bwd,
+		port1:		l.port1,
+		port2:		l.port2,
+		lbport:		l.lbport,
+		id:			l.id,
+		sw1:		l.sw1,
+		sw2:		l.sw2,
+		mlag:		l.mlag,
+		allotment:	ob,
+		Cost:		l.Cost,
+	}
+	nl.drains = append( nl.drains, l.drains... )
+
+	return
+}
+
 /*
 	Destroys a link.
 */
@@ -329,6 +364,12 @@ func (l *Link) Has_capacity( commence int64, conclude int64, amt int64, usr *str
 	}
 
 	able = false
+	if d := l.Get_drain( commence, conclude ); d != nil && ! l.no_drain {
+		obj_sheep.Baa( 2, "no capacity on link %s: drained (%s) from %d to %d", *l.id, *d.Get_id(), d.commence, d.conclude )
+		err = fmt.Errorf( "no capacity on link %s: drained (%s) from %d to %d", *l.id, *d.Get_id(), d.commence, d.conclude )
+		return
+	}
+
 	if usr_max < 101 {
 		if amt > (l.allotment.Get_max_capacity() * int64( usr_max ))/100 {
 			obj_sheep.Baa( 1, "no capacity on link %s: %d is more than user allowed pctg (%d%%) of link capacity %d", *l.id, amt, usr_max, l.allotment.Get_max_capacity()  )
@@ -351,6 +392,80 @@ func (l *Link) Has_capacity( commence int64, conclude int64, amt int64, usr *str
 	return
 }
 
+/*
+	Attach a drain to the link. If a drain with the same id is already attached it is replaced.
+*/
+func (l *Link) Add_drain( d *Drain ) {
+	if l == nil || d == nil {
+		return
+	}
+
+	l.Rm_drain( d.Get_id() )
+	l.drains = append( l.drains, d )
+}
+
+/*
+	Remove the drain with the id from the link. Returns true if it was attached.
+*/
+func (l *Link) Rm_drain( id *string ) ( bool ) {
+	if l == nil || id == nil {
+		return false
+	}
+
+	for i, d := range l.drains {
+		if *d.Get_id() == *id {
+			l.drains = append( l.drains[:i], l.drains[i+1:]... )
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+	Return the first drain on the link which overlaps the window, or nil if the link is not
+	drained at any time in the window.
+*/
+func (l *Link) Get_drain( commence int64, conclude int64 ) ( *Drain ) {
+	for _, d := range l.drains {
+		if d.Overlaps( commence, conclude ) {
+			return d
+		}
+	}
+
+	return nil
+}
+
+/*
+	Turn the drain check in Has_capacity on or off.  Off allows the links that might be used
+	at some time (e.g. after a drain ends) to be found with the usual path finding.
+*/
+func (l *Link) Set_drain_check( on bool ) {
+	if l != nil {
+		l.no_drain = ! on
+	}
+}
+
+/*
+	Return the drains attached to the link.
+*/
+func (l *Link) Get_drains( ) ( []*Drain ) {
+	return l.drains
+}
+
+/*
+	Remove drains which ended before now.
+*/
+func (l *Link) Prune_drains( now int64 ) {
+	keep := l.drains[:0]
+	for _, d := range l.drains {
+		if ! d.Is_expired( now ) {
+			keep = append( keep, d )
+		}
+	}
+	l.drains = keep
+}
+
 /*
 	The new link capacity is set to the value passed in.
 	The capacity is the maximum bandwidth that the link can support. If the link's allotment is
@@ -388,6 +503,31 @@ func (l *Link) Dec_capacity( delta int64 ) {
 	}
 }
 
+/*
+	Return the times, after the after time and at or before the before time, where the
+	allocation on the link changes, or a drain starts or ends.
+*/
+func (l *Link) Get_boundaries( after int64, before int64 ) ( list []int64 ) {
+	if l == nil {
+		return nil
+	}
+
+	list = l.allotment.Get_boundaries( after, before )
+	for _, d := range l.drains {
+		c, e := d.Get_window( )
+		for _, t := range []int64{ c, e } {
+			if t > after && t <= before {
+				list = append( list, t )
+			}
+		}
+	}
+	if len( l.drains ) > 0 {
+		sort.Slice( list, func( i, j int ) bool { return list[i] < list[j] } )
+	}
+
+	return
+}
+
 /*
 	Return the link's allotment for the given time.
 */
@@ -586,6 +726,13 @@ func (l *Link) To_json( ) ( s string ) {
 		mlag = *l.mlag
 	}
 
-	s = fmt.Sprintf( `{ "id": %q, "sw1": %q, "sw1port": %d, "sw2": %q,  "sw2port": %d, "allotment": %s, "mlag": %q }`, *l.id, *l.sw1, l.port1, *l.sw2,  l.port2, l.allotment.To_json(), mlag )
+	drains := ""
+	sep := ""
+	for _, d := range l.drains {
+		drains += sep + d.To_json()
+		sep = ", "
+	}
+
+	s = fmt.Sprintf( `{ "id": %q, "sw1": %q, "sw1port": %d, "sw2": %q,  "sw2port": %d, "allotment": %s, "mlag": %q, "drains": [ %s ] }`, *l.id, *l.sw1, l.port1, *l.sw2,  l.port2, l.allotment.To_json(), mlag, drains )
 	return
 }
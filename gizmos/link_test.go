@@ -0,0 +1,94 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	link_test
+	Abstract:	Tests for link drain (maintenance) windows.
+	Date:		17 October 2026
+
+*/
+
+package gizmos
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_link_drain( t *testing.T ) {
+	failures := 0
+	base := time.Now().Unix() + 3600
+	s1 := "sw1"
+	s2 := "sw2"
+	l := Mk_link( &s1, &s2, 1000, 0, nil )
+
+	did := "maint"
+	d, err := Mk_drain( &did, base + 100, base + 200 )
+	if err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   unable to make drain: %s\n", err )
+		t.Fail()
+		return
+	}
+	l.Add_drain( d )
+
+	cases := []struct {
+		commence	int64
+		conclude	int64
+		able		bool
+	} {
+		{ base, base + 100, true },					// ends as the drain starts
+		{ base, base + 101, false },
+		{ base + 150, base + 160, false },
+		{ base + 199, base + 300, false },
+		{ base + 200, base + 300, true },			// starts as the drain ends
+	}
+
+	for _, c := range cases {
+		if able, _ := l.Has_capacity( c.commence, c.conclude, 10, nil, 100 ); able != c.able {
+			fmt.Fprintf( os.Stderr, "FAIL:   drained link capacity %d-%d: expected %v got %v\n", c.commence - base, c.conclude - base, c.able, able )
+			failures++
+		}
+	}
+
+	if ! l.Rm_drain( &did ) || l.Get_drain( base, base + 1000 ) != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   drain was not removed\n" )
+		failures++
+	}
+	if able, _ := l.Has_capacity( base + 150, base + 160, 10, nil, 100 ); ! able {
+		fmt.Fprintf( os.Stderr, "FAIL:   link has no capacity after drain removed\n" )
+		failures++
+	}
+
+	l.Add_drain( d )
+	l.Prune_drains( base + 200 )
+	if len( l.Get_drains() ) != 0 {
+		fmt.Fprintf( os.Stderr, "FAIL:   expired drain not pruned\n" )
+		failures++
+	}
+
+	if failures == 0 {
+		fmt.Fprintf( os.Stderr, "OK:     link drain tests pass\n" )
+	} else {
+		t.Fail()
+	}
+}
@@ -25,7 +25,7 @@
 	Date:		24 June 2014
 	Author:		E. Scott Daniels
 
-	Mods:		
+	Mods:		17 Oct 2026 - Added Json_mbox so that a middle box can be restored from a checkpoint.
 */
 
 package gizmos
@@ -52,6 +52,16 @@ type Mbox struct {
 	swport	int					// port that the box is attached to (may be -128 for late binding)
 }
 
+/*
+	Work struct used to decode the json generated by To_json() (checkpoint restore).
+*/
+type Json_mbox struct {
+	Id		*string
+	Mac		*string
+	Swid	*string
+	Swport	int
+}
+
 /*
 	Constructor; creates a middle box
 */
@@ -69,6 +79,17 @@ func Mk_mbox( id *string, mac *string, swid *string, swport int ) ( mb *Mbox ) {
 	return
 }
 
+/*
+	Create a middle box from the decoded json; nil is returned if any of the strings is missing.
+*/
+func Mk_mbox_from_json( jmb *Json_mbox ) ( mb *Mbox ) {
+	if jmb == nil || jmb.Id == nil || jmb.Mac == nil || jmb.Swid == nil {
+		return nil
+	}
+
+	return Mk_mbox( jmb.Id, jmb.Mac, jmb.Swid, jmb.Swport )
+}
+
 /*
 	Returns the id/name -- which ever was given when created.
 */
@@ -26,8 +26,15 @@
 				timestamps) and a maximum capacity. The obligation is subdivided
 				into time windows between the commence and conclude times with
 				each time winodw tracking an obligated capacity. By default, the
-				obligation spans from the epoch until well into the future (2025ish);
-				there is probably no reason for a user application to change this.
+				obligation spans from the epoch with no concluding time; the final
+				timeslice is open-ended and is split as reservations are added, so
+				there is no horizon beyond which a reservation cannot be made.
+
+				The time slices are kept as a linked list, and are indexed by a
+				balanced tree (ts_tree) which allows the slice containing a given
+				time, and the maximum allocation over a time window, to be found
+				in log time. Capacity checks thus do not slow as the number of
+				reservations (slices) grows.
 
 				The obligation now supports the concept of queues associated with
 				each timeslice.  This allows the user to further subdivide a slice
@@ -57,23 +64,33 @@
 				18 Jun 2015 : Corrected cause of potential core dump if queue ID passed in is
 					empty. Some cleanup of commented lines.
 				22 Jun 2015 : Corrected cause of core dump when updating utilisation on mlag.
+				17 Oct 2026 : Replaced the fixed 2025 end of the timeline with an open-ended
+					final timeslice (TS_INFINITE).
+				17 Oct 2026 : Slices are now indexed by a balanced tree to support fast capacity
+					checks. Slices wholly inside of a window are now considered by capacity and
+					queue number checks, and user fences are adjusted on every slice in the window.
+				17 Oct 2026 : Added Get_boundaries() to support searching for an available slot.
+				17 Oct 2026 : Added Clone_empty().
+				17 Oct 2026 : Added Get_usage() and Get_peak() to support link utilisation timelines.
 */
 
 package gizmos
 
 import (
 	"fmt"
+	"math"
 	"time"
 )
 
 const (
-	DEF_END_TS = 1735707600		// jan 1, 2025 -- it we're still being used then I'll be surprised!
+	TS_INFINITE int64 = math.MaxInt64		// conclude time of the open-ended final timeslice; an expiry of this value never expires
 )
 
 type Obligation struct {
 	Max_capacity	int64			// the total capacity that any one slice may have assigned
 	alarm_thresh	int64			// alarm if a timeslice reaches this amount
 	tslist			*Time_slice		// list of allotments based on time windows
+	index			*ts_tree		// balanced index of the slices in tslist
 }
 
 // -----------------------------------------------------------------------------------------------------------
@@ -84,7 +101,9 @@ type Obligation struct {
 func Mk_obligation( max_capacity int64, alarm_thresh int ) (ob *Obligation) {
 	ob = &Obligation { }
 	ob.Max_capacity = max_capacity
-	ob.tslist = Mk_time_slice( 0, DEF_END_TS, 0 )
+	ob.tslist = Mk_time_slice( 0, TS_INFINITE, 0 )
+	ob.index = mk_ts_tree( )
+	ob.index.insert( ob.tslist )
 
 	if alarm_thresh > 0 && alarm_thresh < 100 {
 		ob.alarm_thresh = (max_capacity * int64( alarm_thresh ))/100
@@ -94,6 +113,16 @@ func Mk_obligation( max_capacity int64, alarm_thresh int ) (ob *Obligation) {
 	return
 }
 
+/*
+	Create a new obligation with the same capacity and alarm threshold, but with nothing
+	allocated.
+*/
+func (ob *Obligation) Clone_empty( ) ( nob *Obligation ) {
+	nob = Mk_obligation( ob.Max_capacity, 0 )
+	nob.alarm_thresh = ob.alarm_thresh
+	return
+}
+
 /*
 	Destruction.
 */
@@ -106,6 +135,9 @@ func (ob *Obligation) Nuke() {
 		nxt = ts.Next
 		ts.Nuke()
 	}
+
+	ob.tslist = nil
+	ob.index = nil
 }
 
 /*
@@ -116,7 +148,7 @@ func (ob *Obligation) Get_max_capacity() ( int64 ) {
 }
 
 /*
-	Runs the list of timeslices looking for a queue id that is not used across all of the slices. Returns
+	Runs the timeslices in the window looking for a queue id that is not used across all of the slices. Returns
 	the id, or -1 if no id is available. Queue numbers 0 and 1 are reserved and thus are never returned.
 */
 func (ob *Obligation) suss_open_qnum( commence int64, conclude int64 ) ( int ) {
@@ -126,14 +158,13 @@ func (ob *Obligation) suss_open_qnum( commence int64, conclude int64 ) ( int ) {
 
 	used = make( []byte, 4096 )				// we could use a bit mask to save space, but right now I don't see the need
 
-	for ts := ob.tslist; ts != nil  && !ts.Is_after( conclude ); ts = ts.Next {		// !is_after means conclude is in ts, or before, not just before!
-		if ts.Includes ( commence ) || ts.Includes( conclude ) {					// our window overlaps in some manner
-			nqueues, qlist := ts.Get_qnums()
-			for i := 0; i < nqueues; i++ {
-				used[qlist[i]] = 1
-			}
+	ob.index.visit( commence, conclude, func( ts *Time_slice ) bool {			// mark numbers used by any slice overlapping our window
+		nqueues, qlist := ts.Get_qnums()
+		for i := 0; i < nqueues; i++ {
+			used[qlist[i]] = 1
 		}
-	}
+		return true
+	} )
 
 	for i := 2; i < len( used ); i++ {
 		if used[i] == 0 {
@@ -159,66 +190,55 @@ func (ob *Obligation) suss_open_qnum( commence int64, conclude int64 ) ( int ) {
 
 */
 func (ob *Obligation) inc_utilisation( commence int64, conclude int64, amt int64, qnum int, qid *string, qswdata *string, usr *Fence ) ( msg *string ) {
-	var (
-		ts1 *Time_slice = nil		// temp hold of timeslice for various reasons
-	)
 
 	obj_sheep.Baa( 2, "obligation: adjusting utilisation q=%d by %d", qnum, amt )
 	msg = nil
-	for ts := ob.tslist; ts != nil; ts = ts.Next {
-		if !ts.Is_before( commence ) {					// only consider slices that overlap or are after the given window
-
-			if ts.Includes( commence ) {					// starts in this block
-				ts1, ts = ts.Split( commence )				// split and leave ts set to the first slice of the given window
-				if ts == nil {								// if commence and start of ts matched, there is no split, so pick up the original slice again
-					ts = ts1
-				}
-			}
-
-			if  ts.Includes( conclude ) {					// our end is inside this block, split it off, and inc just the frist portion
-				ts1, _ = ts.Split( conclude+1 )				// split so that conclude time is in the slice, not first of next; we can safely ignore the latter slice
-				if ts1 != nil {								// if this slice already ends on conclude, ts1 will be nil, otherwise we advance to the new block
-					ts = ts1
-				}	
-				if qnum >= 0 {
-					ts.Add_queue( qnum, qid, qswdata, amt )	// adds the queue if qid does not exist, else it increases the amount
-				}
-
-				if usr != nil {								// adjust user based utilisation if usr fence (default values) given
-					ts.Inc_usr( usr, amt, ob.Max_capacity )
-				}
-				ts.Amt += amt								// increase just the early part; capaccity past the split point remains the same
-				if ts.Amt < 0 {								// if decrementing don't allow it to go neg
-					ts.Amt = 0
-				}
-				if ts.Amt >= ob.alarm_thresh {
-					tmsg := fmt.Sprintf( "high reservation allotment %d encroaches on limit (%d) from time %d until %d", ts.Amt, ob.Max_capacity, commence, conclude )
-					msg = &tmsg
-				}
-				return
-			}
-
-			ts.Amt += amt;			// this is either the slice split at the commence point, or a slice that is included in the entire commence/conclude widow
-			if ts.Amt < 0 {			// if decrementing don't allow it to go neg
-				ts.Amt = 0
-			}
-				if ts.Amt >= ob.alarm_thresh {
-					tmsg := fmt.Sprintf( "utilisation is %d which encroaches on limit (%d) from time %d until %d", ts.Amt, ob.Max_capacity, commence, conclude )
-					msg = &tmsg
-				}
-			if qnum >= 0 {
-				ts.Add_queue( qnum, qid, qswdata, amt )		// adds the queue if qid does not exist, else it increases the amount
-			}
-
-			ts1 = ts										// must hold last block in case we fall out of loop
-		}
+
+	ob.split_at( commence )							// ensure slices start and end on the window boundaries
+	if conclude != TS_INFINITE {					// an unbounded window runs through the tail, so there is nothing to split off
+		ob.split_at( conclude+1 )					// split so that conclude time is in the slice, not first of next
 	}
 
-	// if we get here, the concluding time is > the last tslice on the list; extend it's time (cap has already been increased)
-	ts1.Extend( conclude )
+	ob.index.visit( commence, conclude, func( ts *Time_slice ) bool {		// every slice visited is now completely inside of the window
+		if qnum >= 0 {
+			ts.Add_queue( qnum, qid, qswdata, amt )	// adds the queue if qid does not exist, else it increases the amount
+		}
+
+		if usr != nil {								// adjust user based utilisation if usr fence (default values) given
+			ts.Inc_usr( usr, amt, ob.Max_capacity )
+		}
+
+		ts.Amt += amt
+		if ts.Amt < 0 {								// if decrementing don't allow it to go neg
+			ts.Amt = 0
+		}
+
+		if ts.Amt >= ob.alarm_thresh {
+			tmsg := fmt.Sprintf( "utilisation is %d which encroaches on limit (%d) from time %d until %d", ts.Amt, ob.Max_capacity, commence, conclude )
+			msg = &tmsg
+		}
+		return true
+	} )
+
 	return
 }
 
+/*
+	Split the slice which contains the timestamp such that the timestamp becomes the commence
+	time of a slice. The new slice is added to the index.
+*/
+func (ob *Obligation) split_at( timestamp int64 ) {
+	ts := ob.index.find( timestamp )
+	if ts == nil {										// timestamp is before the head of the list (pruned)
+		return
+	}
+
+	_, ts2 := ts.Split( timestamp )
+	if ts2 != nil {
+		ob.index.insert( ts2 )
+	}
+}
+
 /*
 	Runs the list of time slices, and increases the amount used by amt.
 	This function assumes that the capacity has been vetted already and thus makes no checks
@@ -242,43 +262,51 @@ func (ob *Obligation) Dec_utilisation( commence int64, conclude int64, dec_cap i
 }
 
 /*
-	Runs the list of time slices and returns true if the capacity increase (amt) can
-	be satisifed across the given time window.
+	Returns true if the capacity increase (amt) can be satisifed across the given time
+	window. The largest allocation in the window is found using the index, so only the
+	user fence check (if usr is given) needs to look at each slice in the window.
 */
 func (ob *Obligation) Has_capacity( commence int64, conclude int64, amt int64, usr *string ) ( result bool, err error ) {
-	var (
-		ts *Time_slice
-	)
-		
-	ts = ob.tslist
-	if ts.Is_before( time.Now().Unix() ) {					// if first block is completely before the current time
+	if ob.tslist.Is_before( time.Now().Unix() ) {			// if first block is completely before the current time
 		ob.Prune( )											// prune out what we can
 	}
 
+	if max, found := ob.index.max_in( commence, conclude ); found && max + amt > ob.Max_capacity {
+		err = fmt.Errorf( "link lacks capacity: need %d have %d", max + amt, ob.Max_capacity )
+		return false, err
+	}
+
 	result = true
 	err = nil
-	for ts = ob.tslist; ts != nil; ts = ts.Next {
-		if ts.Is_after( conclude ) {						// reached the end of slices that could overlay the window
-			return
-		}
+	if usr != nil {											// must check user fence for each slice if user name given
+		ob.index.visit( commence, conclude, func( ts *Time_slice ) bool {
+			result, err = ts.Has_usr_capacity( usr, amt )
+			return result
+		} )
+	}
 
-		if ts.Overlaps( commence, conclude ) {
-			if ts.Amt + amt > ob.Max_capacity {		
-				err = fmt.Errorf( "link lacks capacity: need %d have %d", ts.Amt + amt, ob.Max_capacity )
-				result = false
-			} else {
-				if usr != nil {								// must check user fence if user name given
-					result, err = ts.Has_usr_capacity( usr, amt )
-				}
-			}
-		}
+	return
+}
 
-		if ! result {
-			return
-		}
+/*
+	Return the commence times of the slices which start after the after time and at or before
+	the before time, in chronological order.  The allocation can change only at these times, so
+	they are the only times (other than after) that need to be considered when looking for the
+	earliest window with enough capacity.
+*/
+func (ob *Obligation) Get_boundaries( after int64, before int64 ) ( list []int64 ) {
+	if ob == nil || before <= after {
+		return nil
 	}
 
-	return					// assume that the last block in the list ends earlier than the conclusion passed in
+	ob.index.visit( after + 1, before, func( ts *Time_slice ) bool {
+		if ts.commence > after {
+			list = append( list, ts.commence )
+		}
+		return true
+	} )
+
+	return
 }
 
 /*
@@ -344,6 +372,7 @@ func (ob *Obligation) Prune( ) {
 	)
 
 	now = time.Now().Unix();	
+	ob.index.prune_before( now )
 	for ts = ob.tslist; ts != nil && ts.Is_before( now ); ts = nxt {
 		nxt = ts.Next
 
@@ -362,10 +391,8 @@ func (ob *Obligation) Prune( ) {
 	return the obligation for the indicated time
 */
 func ( ob *Obligation ) Get_allocation( utime int64 ) ( int64 ) {
-	for ts := ob.tslist; ts != nil; ts = ts.Next {						// run the time slice list looking for the one that contains utime
-		if ts.Includes( utime ) {
-			return ts.Amt
-		}
+	if ts := ob.index.find( utime ); ts != nil {
+		return ts.Amt
 	}
 
 	return 0
@@ -375,19 +402,44 @@ func ( ob *Obligation ) Get_allocation( utime int64 ) ( int64 ) {
 	Returns the maximum amount obligated for any timeslice that hasn't expired.
 */
 func ( ob *Obligation ) Get_max_allocation( ) ( int64 ) {
-	var max int64 = 0
-	
-	now := time.Now().Unix();	
+	max, found := ob.index.max_in( time.Now().Unix(), TS_INFINITE )
+	if ! found || max < 0 {
+		return 0
+	}
 
-	for ts := ob.tslist; ts != nil; ts = ts.Next {						// run the time slice list looking for the one that contains utime
-		if ! ts.Is_before( now ) && ts.Amt > max {
-			max =  ts.Amt
-		}
+	return max
+}
+
+
+/*
+	Returns the maximum amount obligated for any timeslice which overlaps the window.
+*/
+func ( ob *Obligation ) Get_peak( commence int64, conclude int64 ) ( int64 ) {
+	max, found := ob.index.max_in( commence, conclude )
+	if ! found || max < 0 {
+		return 0
 	}
 
 	return max
 }
 
+/*
+	Returns the allocation, as a list of steps in chronological order, between the commence
+	and conclude times. Each step is a timeslice clipped to the window. The slices are not
+	changed, so timeslices before the current time which have not been pruned may be included.
+*/
+func ( ob *Obligation ) Get_usage( commence int64, conclude int64 ) ( list []*Usage ) {
+	if ob == nil || conclude < commence {
+		return nil
+	}
+
+	ob.index.visit( commence, conclude, func( ts *Time_slice ) bool {
+		list = append( list, mk_usage( ts, commence, conclude, ob.Max_capacity ) )
+		return true
+	} )
+
+	return
+}
 
 /*
 	Returns the queue number for the queue that has the given ID at the indicated time. If no
@@ -397,10 +449,8 @@ func (ob *Obligation) Get_queue( qid *string, tstamp int64 ) ( qnum int ) {
 
 	qnum = 0
 
-	for ts := ob.tslist; ts != nil && qnum == 0;  ts = ts.Next {
-		if ts.Includes( tstamp ) {
-			qnum, _ = ts.Get_queue_info( qid )				// ignore switch id info, we don't need that
-		}
+	if ts := ob.index.find( tstamp ); ts != nil {
+		qnum, _ = ts.Get_queue_info( qid )				// ignore switch id info, we don't need that
 	}
 
 	if qnum <= 0 {			// get_queue_info returns -1 if qid isn't known, flip to 0
@@ -411,12 +461,12 @@ func (ob *Obligation) Get_queue( qid *string, tstamp int64 ) ( qnum int ) {
 }
 
 /*
-	Compares the UNIX timestamp passed in with the range now through the final
-	timestamp supported by obligation and returns true if the timestamp is in
-	range.
+	Returns true if the UNIX timestamp passed in is not in the past. The obligation
+	timeline is open-ended so there is no upper bound; TS_INFINITE is valid and
+	indicates an unbounded expiry.
 */
 func Valid_obtime( usr_ts int64 ) bool {
-	return usr_ts <= DEF_END_TS  && usr_ts >= time.Now().Unix()
+	return usr_ts >= time.Now().Unix()
 }
 
 // ----------------- json and string things -------------------------------
@@ -430,10 +480,8 @@ func (ob *Obligation) Queues2str( usr_ts int64 ) ( string ) {
 		return ""
 	}
 
-	for ts := ob.tslist; ts != nil; ts = ts.Next {
-		if ts.Includes( usr_ts ) {
-			return ts.Queues2str( )
-		}
+	if ts := ob.index.find( usr_ts ); ts != nil {
+		return ts.Queues2str( )
 	}
 
 	return ""
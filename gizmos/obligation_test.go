@@ -0,0 +1,294 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	obligation_test
+	Abstract:	Tests which verify the obligation's indexed timeslices against a brute
+				force computation, and benchmarks which compare the indexed capacity
+				check with a walk of the timeslice list (the original implementation).
+				Run the benchmarks with:  go test -run XXX -bench Obligation
+	Date:		17 October 2026
+
+*/
+
+package gizmos
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+const (
+	bench_slices int = 20000		// number of reservations added to the benchmark obligation (about twice as many slices)
+)
+
+/*
+	Walk the slice list from the head as was done before the index was added.
+*/
+func list_max_in( ob *Obligation, commence int64, conclude int64 ) ( max int64 ) {
+	for ts := ob.tslist; ts != nil && !ts.Is_after( conclude ); ts = ts.Next {
+		if ts.Overlaps( commence, conclude ) && ts.Amt > max {
+			max = ts.Amt
+		}
+	}
+
+	return
+}
+
+/*
+	Build an obligation with n non-overlapping reservations starting at base.
+*/
+func mk_busy_obligation( base int64, n int ) ( ob *Obligation ) {
+	ob = Mk_obligation( 1000000, 0 )
+	for i := 0; i < n; i++ {
+		c := base + int64( i * 60 )
+		ob.Inc_utilisation( c, c + 29, int64( 1 + i % 100 ), nil )
+	}
+
+	return
+}
+
+/*
+	Apply random increments/decrements to an obligation and to a simple per-second array,
+	then verify that allocation and capacity checks agree.
+*/
+func Test_ob_index( t *testing.T ) {
+	fmt.Fprintf( os.Stderr, "\n------- obligation index tests ---------\n" );
+
+	base := time.Now().Unix() + 3600
+	span := 1000
+	expect := make( []int64, span )
+	ob := Mk_obligation( 100000, 0 )
+	rnd := rand.New( rand.NewSource( 42 ) )
+
+	for i := 0; i < 500; i++ {
+		c := rnd.Intn( span )
+		e := c + rnd.Intn( span - c )
+		amt := int64( rnd.Intn( 100 ) )
+
+		ob.Inc_utilisation( base + int64( c ), base + int64( e ), amt, nil )
+		for j := c; j <= e; j++ {
+			expect[j] += amt
+		}
+	}
+
+	errs := 0
+	for j := 0; j < span; j++ {
+		if got := ob.Get_allocation( base + int64( j ) ); got != expect[j] {
+			if errs < 10 {
+				fmt.Fprintf( os.Stderr, "FAIL:   allocation at +%d expected %d got %d\n", j, expect[j], got )
+			}
+			errs++
+		}
+	}
+
+	for i := 0; i < 200; i++ {
+		c := rnd.Intn( span )
+		e := c + rnd.Intn( span - c )
+		var max int64 = 0
+		for j := c; j <= e; j++ {
+			if expect[j] > max {
+				max = expect[j]
+			}
+		}
+
+		if got, _ := ob.index.max_in( base + int64( c ), base + int64( e ) ); got != max {
+			if errs < 10 {
+				fmt.Fprintf( os.Stderr, "FAIL:   max in +%d to +%d expected %d got %d\n", c, e, max, got )
+			}
+			errs++
+		}
+
+		able, _ := ob.Has_capacity( base + int64( c ), base + int64( e ), ob.Max_capacity - max, nil )
+		over, _ := ob.Has_capacity( base + int64( c ), base + int64( e ), ob.Max_capacity - max + 1, nil )
+		if !able || over {
+			if errs < 10 {
+				fmt.Fprintf( os.Stderr, "FAIL:   capacity in +%d to +%d incorrect: able=%v over=%v\n", c, e, able, over )
+			}
+			errs++
+		}
+	}
+
+	nslices := 0
+	for ts := ob.tslist; ts != nil; ts = ts.Next {
+		nslices++
+	}
+	if nslices != ob.index.nslices() {
+		fmt.Fprintf( os.Stderr, "FAIL:   index has %d slices, list has %d\n", ob.index.nslices(), nslices )
+		errs++
+	}
+
+	if errs == 0 {
+		fmt.Fprintf( os.Stderr, "OK:     obligation index agrees with brute force (%d slices)\n", nslices )
+	} else {
+		t.Fail()
+	}
+}
+
+/*
+	Verify that a slice wholly inside of the window is considered by the capacity check.
+*/
+func Test_ob_inside_window( t *testing.T ) {
+	base := time.Now().Unix() + 3600
+	ob := Mk_obligation( 1000, 0 )
+	ob.Inc_utilisation( base + 100, base + 200, 900, nil )
+
+	if able, _ := ob.Has_capacity( base, base + 300, 200, nil ); able {
+		fmt.Fprintf( os.Stderr, "FAIL:   window enclosing a full slice reported capacity\n" )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     window enclosing a full slice reported no capacity\n" )
+	}
+}
+
+/*
+	Verify that pruning past slices removes them from both the list and the index.
+*/
+func Test_ob_prune( t *testing.T ) {
+	now := time.Now().Unix()
+	ob := Mk_obligation( 1000, 0 )
+	ob.Inc_utilisation( now - 500, now - 400, 10, nil )
+	ob.Inc_utilisation( now - 300, now - 200, 10, nil )
+	ob.Inc_utilisation( now + 300, now + 400, 10, nil )
+	ob.Prune( )
+
+	nslices := 0
+	for ts := ob.tslist; ts != nil; ts = ts.Next {
+		nslices++
+	}
+
+	if nslices == 3 && ob.index.nslices() == 3 && ob.Get_allocation( now + 350 ) == 10 {
+		fmt.Fprintf( os.Stderr, "OK:     prune left 3 slices in list and index\n" )
+	} else {
+		fmt.Fprintf( os.Stderr, "FAIL:   prune left %d slices in list and %d in the index\n", nslices, ob.index.nslices() )
+		t.Fail()
+	}
+}
+
+/*
+	Capacity check near the end of a long obligation using the index.
+*/
+func Benchmark_Obligation_has_capacity( b *testing.B ) {
+	base := time.Now().Unix() + 3600
+	ob := mk_busy_obligation( base, bench_slices )
+	last := base + int64( bench_slices * 60 )
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := last - int64( (i % 1000) * 60 )
+		ob.Has_capacity( c, c + 600, 10, nil )
+	}
+}
+
+/*
+	The same capacity check walking the slice list from the head.
+*/
+func Benchmark_Obligation_list_walk( b *testing.B ) {
+	base := time.Now().Unix() + 3600
+	ob := mk_busy_obligation( base, bench_slices )
+	last := base + int64( bench_slices * 60 )
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := last - int64( (i % 1000) * 60 )
+		_ = list_max_in( ob, c, c + 600 ) + 10 > ob.Max_capacity
+	}
+}
+
+/*
+	Allocation lookup at a time near the end of a long obligation.
+*/
+func Benchmark_Obligation_get_allocation( b *testing.B ) {
+	base := time.Now().Unix() + 3600
+	ob := mk_busy_obligation( base, bench_slices )
+	last := base + int64( bench_slices * 60 )
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ob.Get_allocation( last - int64( i % 1000 ) )
+	}
+}
+
+/*
+	Adding reservations to an obligation that already has many slices.
+*/
+func Benchmark_Obligation_inc_utilisation( b *testing.B ) {
+	base := time.Now().Unix() + 3600
+	ob := mk_busy_obligation( base, bench_slices )
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := base + int64( (i % bench_slices) * 60 ) + 10
+		ob.Inc_utilisation( c, c + 5, 1, nil )
+	}
+}
+
+/*
+	Verify that the boundaries returned are the slice starts inside of the window.
+*/
+func Test_ob_boundaries( t *testing.T ) {
+	base := time.Now().Unix() + 3600
+	ob := Mk_obligation( 1000, 0 )
+	ob.Inc_utilisation( base + 100, base + 199, 500, nil )
+	ob.Inc_utilisation( base + 300, base + 399, 500, nil )
+
+	got := fmt.Sprintf( "%v", ob.Get_boundaries( base + 100, base + 1000 ) )
+	expect := fmt.Sprintf( "%v", []int64 { base + 200, base + 300, base + 400 } )
+	if got == expect {
+		fmt.Fprintf( os.Stderr, "OK:     obligation boundaries: %s\n", got )
+	} else {
+		fmt.Fprintf( os.Stderr, "FAIL:   obligation boundaries expected %s got %s\n", expect, got )
+		t.Fail()
+	}
+}
+
+/*
+	Verify the allocation steps and peak over a window which cuts two reservations.
+*/
+func Test_ob_usage( t *testing.T ) {
+	base := time.Now().Unix() + 3600
+	uname := "proj1"
+	usr := Mk_fence( &uname, 100, 0, 0 )
+	ob := Mk_obligation( 1000, 0 )
+	ob.Inc_utilisation( base + 100, base + 199, 500, usr )
+	ob.Inc_utilisation( base + 150, base + 299, 200, nil )
+
+	got := ""
+	for _, u := range ob.Get_usage( base + 50, base + 250 ) {
+		got += u.To_csv( ) + " "
+	}
+	expect := fmt.Sprintf( "%d,%d,0,1000,0, %d,%d,500,1000,50,proj1:500 %d,%d,700,1000,70,proj1:500 %d,%d,200,1000,20, ",
+		base + 50, base + 99, base + 100, base + 149, base + 150, base + 199, base + 200, base + 250 )
+	if got == expect {
+		fmt.Fprintf( os.Stderr, "OK:     obligation usage: %s\n", got )
+	} else {
+		fmt.Fprintf( os.Stderr, "FAIL:   obligation usage expected %s got %s\n", expect, got )
+		t.Fail()
+	}
+
+	if p := ob.Get_peak( base + 200, base + 1000 ); p != 200 {
+		fmt.Fprintf( os.Stderr, "FAIL:   obligation peak expected 200 got %d\n", p )
+		t.Fail()
+	}
+}
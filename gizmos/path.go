@@ -61,6 +61,9 @@
 				29 Jul 2014 - Mlag support
 				19 Oct 2014 - Support setting queues only on outbound direction of path.
 				29 Oct 2014 - Added Get_nlinks() function.
+				17 Oct 2026 - Added Has_capacity() and Is_inbound() to support modifying a reservation.
+				17 Oct 2026 - Added Get_boundaries() to support searching for an available slot.
+				17 Oct 2026 - Added backup marking and Get_links() for protected (disjoint) path pairs.
 */
 
 package gizmos
@@ -74,6 +77,7 @@ import (
 	//"html"
 	//"net/http"
 	"os"
+	"sort"
 	//"strings"
 	//"time"
 
@@ -94,6 +98,7 @@ type Path struct {
 	extflag	*string			// flag indicating whether external IP is source (-S) or dest (-D) needed by flow mod generator
 	is_reverse	bool		// set to indicate that the path was saved in reverse order
 	is_scramble bool		// if the path is not a true path, but a list of links involved in all possible paths between hosts
+	is_backup	bool		// path is the protection (backup) path of a disjoint pair; capacity reserved, but no flow-mods
 }
 
 // ---------------------------------------------------------------------------------------
@@ -146,6 +151,27 @@ func (p *Path) Set_reverse( state bool ) {
 	p.is_reverse = state
 }
 
+/*
+	Mark the path as the backup path of a protected (disjoint) pair.
+*/
+func (p *Path) Set_backup( state bool ) {
+	p.is_backup = state
+}
+
+/*
+	Returns true if the path is the backup path of a protected pair.
+*/
+func (p *Path) Is_backup( ) ( bool ) {
+	return p.is_backup
+}
+
+/*
+	Return the list of (switch to switch) links that make up the path. Endpoint links are not included.
+*/
+func (p *Path) Get_links( ) ( []*Link ) {
+	return p.links[0:p.lidx]
+}
+
 /*
 	Set the amount of bandwith that has been reserved along this path.
 */
@@ -171,6 +197,56 @@ func (p *Path) Get_bandwidth( ) ( int64 )  {
 	return p.bw_amt
 }
 
+/*
+	Returns true if the path carries traffic inbound to h1 of the reservation (built as the
+	reverse path). The path finder sets the external flag to -S for these paths.
+*/
+func (p *Path) Is_inbound( ) ( bool ) {
+	return p.extflag != nil && *p.extflag == "-S"
+}
+
+/*
+	Returns true if every link in the path can support an increase of amt over the time
+	window. On failure err describes the first link that could not support the increase.
+*/
+func (p *Path) Has_capacity( commence int64, conclude int64, amt int64, usr *string, usr_max int64 ) ( able bool, err error ) {
+	if p == nil {
+		return false, fmt.Errorf( "nil pointer" )
+	}
+
+	for i := 0; i < p.lidx; i++ {
+		if able, err = p.links[i].Has_capacity( commence, conclude, amt, usr, usr_max ); ! able {
+			return
+		}
+	}
+
+	return true, nil
+}
+
+/*
+	Return the times, after the after time and at or before the before time, where the
+	allocation of any link in the path changes. The list is in chronological order and has
+	no duplicates.
+*/
+func (p *Path) Get_boundaries( after int64, before int64 ) ( list []int64 ) {
+	if p == nil {
+		return nil
+	}
+
+	seen := make( map[int64]bool )
+	for i := 0; i < p.lidx; i++ {
+		for _, t := range p.links[i].Get_boundaries( after, before ) {
+			if ! seen[t] {
+				seen[t] = true
+				list = append( list, t )
+			}
+		}
+	}
+
+	sort.Slice( list, func( i, j int ) bool { return list[i] < list[j] } )
+	return
+}
+
 /*
 	Return the number of links in the path.
 */
@@ -37,6 +37,10 @@
 	Author:		E. Scott Daniels
 
 	Mods:		16 Aug 2015 - listed funcs provided by Pledge_base, and those that must be written per Pledge type
+				17 Oct 2026 - Added series (recurring bandwidth) pledges.
+				17 Oct 2026 - Added Get/Set_priority.
+				17 Oct 2026 - Report steering pledge json errors.
+				17 Oct 2026 - Added Get/Set_push_state and Retry_push.
 */
 
 package gizmos
@@ -55,6 +59,8 @@ type Pledge interface {
 	Concluded_recently( window int64 ) ( bool )
 	Commenced_recently( window int64 ) ( bool )
 	Get_id( ) ( *string )
+	Get_priority( ) ( int )
+	Get_push_state( ) ( int )
 	Get_window( ) ( int64, int64 )
 	Is_active( ) ( bool )
 	Is_active_soon( window int64 ) ( bool )
@@ -67,7 +73,10 @@ type Pledge interface {
 	Pause( bool )
 	Reset_pushed( )
 	Resume( bool )
+	Retry_push( limit int ) ( bool )
 	Set_expiry( expiry int64 )
+	Set_priority( v int )
+	Set_push_state( v int )
 	Set_pushed()
 
 	// The following must be implemented by each separate Pledge type
@@ -123,8 +132,13 @@ func Json2pledge( jstr *string ) ( p *Pledge, err error ) {
 	
 				case PT_STEERING:
 					mp := new( Pledge_steer )
-					mp.From_json( jstr )
+					err = mp.From_json( jstr )
 					pi = Pledge( mp )			// convert to interface type
+
+				case PT_SERIES:					// recurring bandwidth
+					sp := new( Pledge_series )
+					err = sp.From_json( jstr )
+					pi = Pledge( sp )
 	
 				default:
 					err = fmt.Errorf( "unknown pledge type in json: %d: %s", *jp.Ptype, *jstr )
@@ -33,6 +33,9 @@
 
 	Date:		16 Aug 2015
 	Author:		E. Scott Daniels / Robert Eby
+
+	Mods:		17 Oct 2026 - Added priority.
+				17 Oct 2026 - Added push state and push retry count.
 */
 
 package gizmos
@@ -43,6 +46,9 @@ type Pledge_base struct {
 	pushed		bool			// set when pledge has been pushed into openflow or openvswitch
 	paused		bool			// set if reservation has been paused
 	usrkey		*string			// a 'cookie' supplied by the user to prevent any other user from modifying
+	priority	int				// larger values may preempt smaller ones; 0 is the default
+	push_state	int				// PS_ constant reflecting what the agent(s) reported after the last push
+	push_tries	int				// number of times the push was retried after a failure
 }
 
 /*
@@ -141,6 +147,26 @@ func (p *Pledge_base) Is_pending( ) ( bool ) {
 	return p.window.is_pending()
 }
 
+/*
+	Returns the priority of the pledge.
+*/
+func (p *Pledge_base) Get_priority( ) ( int ) {
+	if p == nil {
+		return 0
+	}
+	return p.priority
+}
+
+/*
+	Returns the push state (PS_ constant) of the pledge.
+*/
+func (p *Pledge_base) Get_push_state( ) ( int ) {
+	if p == nil {
+		return PS_NONE
+	}
+	return p.push_state
+}
+
 /*
 	Returns true if the pushed flag has been set to true.
 */
@@ -208,6 +234,45 @@ func (p *Pledge_base) Set_expiry ( v int64 ) {
 	}
 }
 
+/*
+	Sets the priority of the pledge. Negative values are set to 0.
+*/
+func (p *Pledge_base) Set_priority( v int ) {
+	if p != nil {
+		if v < 0 {
+			v = 0
+		}
+		p.priority = v
+	}
+}
+
+/*
+	Sets the push state. When the state is installed the retry count is reset.
+*/
+func (p *Pledge_base) Set_push_state( v int ) {
+	if p != nil {
+		p.push_state = v
+		if v == PS_INSTALLED {
+			p.push_tries = 0
+		}
+	}
+}
+
+/*
+	Resets the pushed flag so that the pledge is pushed again, provided that the number
+	of retries has not reached the limit. Returns false if the limit was reached and the
+	pledge was left alone.
+*/
+func (p *Pledge_base) Retry_push( limit int ) ( bool ) {
+	if p == nil || p.push_tries >= limit {
+		return false
+	}
+
+	p.push_tries++
+	p.pushed = false
+	return true
+}
+
 /*
 	Sets the pushed flag to true.
 */
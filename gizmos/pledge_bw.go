@@ -41,6 +41,12 @@
 				01 Jun 2015 - Added equal() support
 				26 Jun 2015 - Return nil pledge if one bw value is <= 0.
 				16 Aug 2015 - Move common code into Pledge_base
+				17 Oct 2026 - Added Set_bandw() to support modifying a reservation in place.
+				17 Oct 2026 - Priority is cloned and saved in json/checkpoint.
+				17 Oct 2026 - Added path protection (disjoint path pairs) and the degraded state.
+				17 Oct 2026 - Push state included in json.
+				17 Oct 2026 - Clone copies the protocol and vlans; a clone of a future (preempted)
+					reservation isn't expired and is compared by Equals().
 */
 
 package gizmos
@@ -68,6 +74,8 @@ type Pledge_bw struct {
 	qid			*string		// name that we'll assign to the queue which allows us to look up the pledge's queues
 	path_list	[]*Path		// list of paths that represent the bandwith and can be used to send flowmods etc.
 	match_v6	bool		// true if we should force flow-mods to match on IPv6
+	protect		int			// PROT_ constant; if not none the path list has a disjoint backup path for each direction
+	degraded	bool		// protected, but the network has lost one of the paths
 }
 
 /*
@@ -90,6 +98,8 @@ type Json_pledge_bw struct {
 	Qid			*string
 	Usrkey		*string
 	Match_v6	bool
+	Priority	int
+	Protect		string
 	Ptype		int
 }
 
@@ -222,6 +232,24 @@ func (p *Pledge_bw) Get_bandw_in( ) ( int64 ) {
 	return p.bandw_in
 }
 
+/*
+	Sets new inbound and outbound bandwidth amounts. A value <= 0 leaves the current
+	amount unchanged. The pushed flag is reset so that flow-mods are sent again.
+*/
+func (p *Pledge_bw) Set_bandw( bandw_in int64, bandw_out int64 ) {
+	if p == nil {
+		return
+	}
+
+	if bandw_in > 0 {
+		p.bandw_in = bandw_in
+	}
+	if bandw_out > 0 {
+		p.bandw_out = bandw_out
+	}
+	p.pushed = false
+}
+
 /*
 	Returns pointers to both host strings that comprise the pledge.
 */
@@ -310,16 +338,24 @@ func (p *Pledge_bw) Clone( name string ) ( *Pledge_bw ) {
 			usrkey:		p.usrkey,
 			pushed:		p.pushed,
 			paused:		p.paused,
+			priority:	p.priority,
 		},
 		host1:		p.host1,
 		host2:		p.host2,
+		protocol:	p.protocol,
 		tpport1: 	p.tpport1,
 		tpport2: 	p.tpport2,
+		vlan1:		p.vlan1,
+		vlan2:		p.vlan2,
 		bandw_in:	p.bandw_in,
 		bandw_out:	p.bandw_out,
 		dscp:		p.dscp,
+		dscp_koe:	p.dscp_koe,
 		qid:		p.qid,
+		match_v6:	p.match_v6,
 		path_list:	p.path_list,
+		protect:	p.protect,
+		degraded:	p.degraded,
 	}
 
 	newpbw.window = p.window.clone()
@@ -428,6 +464,10 @@ func (p *Pledge_bw) From_json( jstr *string ) ( err error ){
 	p.qid = jp.Qid
 	p.bandw_out = jp.Bandwout
 	p.bandw_in = jp.Bandwin
+	p.priority = jp.Priority
+	if jp.Protect != "" {
+		p.protect, err = Str2protect( jp.Protect )
+	}
 
 	p.protocol = jp.Protocol
 	if p.protocol == nil {					// we don't tolerate nil ptrs
@@ -438,6 +478,37 @@ func (p *Pledge_bw) From_json( jstr *string ) ( err error ){
 }
 
 // --- functions that extend the interface -- bw-only functions ---------
+/*
+	Set the path protection; one of the PROT_ constants.
+*/
+func (p *Pledge_bw) Set_protect( v int ) {
+	p.protect = v
+}
+
+/*
+	Return the path protection (PROT_ constant).
+*/
+func (p *Pledge_bw) Get_protect( ) ( int ) {
+	if p == nil {
+		return PROT_NONE
+	}
+	return p.protect
+}
+
+/*
+	Set or clear the degraded state of a protected pledge.
+*/
+func (p *Pledge_bw) Set_degraded( state bool ) {
+	p.degraded = state
+}
+
+/*
+	Returns true if the pledge is protected but one of its paths has been lost.
+*/
+func (p *Pledge_bw) Is_degraded( ) ( bool ) {
+	return p.degraded
+}
+
 /*
 	Associates a queue ID with the pledge.
 */
@@ -533,8 +604,8 @@ func (p *Pledge_bw) To_json( ) ( json string ) {
 	state, _, diff := p.window.state_str()		// get state as a string
 	v1, v2 := p.bw_vlan2string( )
 
-	json = fmt.Sprintf( `{ "state": %q, "time": %d, "bandwin": %d, "bandwout": %d, "host1": "%s:%s%s", "host2": "%s:%s%s", "id": %q, "qid": %q, "dscp": %d, "dscp_koe": %v, "ptype": %d }`,
-				state, diff, p.bandw_in,  p.bandw_out, *p.host1, *p.tpport1, v1, *p.host2, *p.tpport2, v2, *p.id, *p.qid, p.dscp, p.dscp_koe, PT_BANDWIDTH )
+	json = fmt.Sprintf( `{ "state": %q, "time": %d, "bandwin": %d, "bandwout": %d, "host1": "%s:%s%s", "host2": "%s:%s%s", "id": %q, "qid": %q, "dscp": %d, "dscp_koe": %v, "priority": %d, "protect": %q, "degraded": %v, "push_state": %q, "ptype": %d }`,
+				state, diff, p.bandw_in,  p.bandw_out, *p.host1, *p.tpport1, v1, *p.host2, *p.tpport2, v2, *p.id, *p.qid, p.dscp, p.dscp_koe, p.priority, Protect2str( p.protect ), p.degraded, Push_state2str( p.push_state ), PT_BANDWIDTH )
 
 	return
 }
@@ -565,8 +636,8 @@ func (p *Pledge_bw) To_chkpt( ) ( chkpt string ) {
 	commence, expiry := p.window.get_values()
 	v1, v2 := p.bw_vlan2string( )
 
-	chkpt = fmt.Sprintf( `{ "host1": "%s:%s%s", "host2": "%s:%s%s", "commence": %d, "expiry": %d, "bandwin": %d, "bandwout": %d, "id": %q, "qid": %q, "usrkey": %q, "dscp": %d, "dscp_koe": %v, "ptype": %d }`,
-			*p.host1, *p.tpport1, v1, *p.host2, *p.tpport2, v2, commence, expiry, p.bandw_in, p.bandw_out, *p.id, *p.qid, *p.usrkey, p.dscp, p.dscp_koe, PT_BANDWIDTH )
+	chkpt = fmt.Sprintf( `{ "host1": "%s:%s%s", "host2": "%s:%s%s", "commence": %d, "expiry": %d, "bandwin": %d, "bandwout": %d, "id": %q, "qid": %q, "usrkey": %q, "dscp": %d, "dscp_koe": %v, "priority": %d, "protect": %q, "ptype": %d }`,
+			*p.host1, *p.tpport1, v1, *p.host2, *p.tpport2, v2, commence, expiry, p.bandw_in, p.bandw_out, *p.id, *p.qid, *p.usrkey, p.dscp, p.dscp_koe, p.priority, Protect2str( p.protect ), PT_BANDWIDTH )
 
 	return
 }
@@ -35,6 +35,9 @@
 	Mods:		18 Jun 2015 : Added set_qid() function.
 				29 Jun 2015 : Corrected bug in Equals().
 				16 Aug 2015 : Move common code into Pledge_base
+				17 Oct 2026 : Priority is cloned and saved in json/checkpoint.
+				17 Oct 2026 : Push state included in json.
+				17 Oct 2026 : Clone copies the protocol, vlan and phost so Equals() is safe on a clone.
 */
 
 package gizmos
@@ -81,6 +84,7 @@ type Json_pledge_bwow struct {
 	Qid			*string
 	Usrkey		*string
 	Match_v6	bool
+	Priority	int
 	Ptype		int
 }
 
@@ -266,14 +270,19 @@ func (p *Pledge_bwow) Clone( name string ) ( *Pledge_bwow ) {
 			usrkey:		p.usrkey,
 			pushed:		p.pushed,
 			paused:		p.paused,
+			priority:	p.priority,
 		},
 		src:		p.src,
 		dest:		p.dest,
+		protocol:	p.protocol,
 		src_tpport: 	p.src_tpport,
 		dest_tpport: 	p.dest_tpport,
+		src_vlan:	p.src_vlan,
 		bandw_out:	p.bandw_out,
 		dscp:		p.dscp,
 		qid:		p.qid,
+		phost:		p.phost,
+		match_v6:	p.match_v6,
 	}
 
 	ep := *p.epoint		// make copy
@@ -359,6 +368,7 @@ func (p *Pledge_bwow) From_json( jstr *string ) ( err error ){
 	p.usrkey = jp.Usrkey
 	p.qid = jp.Qid
 	p.bandw_out = jp.Bandwout
+	p.priority = jp.Priority
 
 	p.protocol = jp.Protocol
 	if p.protocol == nil {					// we don't tolerate nil ptrs
@@ -484,8 +494,8 @@ func (p *Pledge_bwow) To_json( ) ( json string ) {
 	state, _, diff := p.window.state_str()		// get state as a string
 	v1 := p.vlan2string( )
 
-	json = fmt.Sprintf( `{ "state": %q, "time": %d, "bandwout": %d, "src": "%s:%s%s", "dest": "%s:%s", "id": %q, "qid": %q, "dscp": %d, "ptype": %d }`,
-				state, diff,  p.bandw_out, *p.src, *p.src_tpport, v1, *p.dest, *p.dest_tpport, *p.id, *p.qid, p.dscp, PT_OWBANDWIDTH )
+	json = fmt.Sprintf( `{ "state": %q, "time": %d, "bandwout": %d, "src": "%s:%s%s", "dest": "%s:%s", "id": %q, "qid": %q, "dscp": %d, "priority": %d, "push_state": %q, "ptype": %d }`,
+				state, diff,  p.bandw_out, *p.src, *p.src_tpport, v1, *p.dest, *p.dest_tpport, *p.id, *p.qid, p.dscp, p.priority, Push_state2str( p.push_state ), PT_OWBANDWIDTH )
 
 	return
 }
@@ -512,8 +522,8 @@ func (p *Pledge_bwow) To_chkpt( ) ( chkpt string ) {
 	commence, expiry := p.window.get_values()
 	v1 := p.vlan2string( )
 
-	chkpt = fmt.Sprintf( `{ "src": "%s:%s%s", "dest": "%s:%s", "commence": %d, "expiry": %d, "bandwout": %d, "id": %q, "qid": %q, "usrkey": %q, "dscp": %d, "ptype": %d }`,
-			*p.src, *p.src_tpport, v1, *p.dest, *p.dest_tpport,  commence, expiry, p.bandw_out, *p.id, *p.qid, *p.usrkey, p.dscp, PT_OWBANDWIDTH )
+	chkpt = fmt.Sprintf( `{ "src": "%s:%s%s", "dest": "%s:%s", "commence": %d, "expiry": %d, "bandwout": %d, "id": %q, "qid": %q, "usrkey": %q, "dscp": %d, "priority": %d, "ptype": %d }`,
+			*p.src, *p.src_tpport, v1, *p.dest, *p.dest_tpport,  commence, expiry, p.bandw_out, *p.id, *p.qid, *p.usrkey, p.dscp, p.priority, PT_OWBANDWIDTH )
 
 	return
 }
@@ -31,6 +31,7 @@
 				26 May 2015 - Broken out of main pledge to allow for pledge to become an interface.
 				01 Jun 2015 - Added equal() support
 				16 Aug 2015 - Move common code into Pledge_base
+				17 Oct 2026 - Push state included in json.
 */
 
 package gizmos
@@ -274,8 +275,8 @@ func (p *Pledge_mirror) To_json( ) ( json string ) {
 
 	state, _, diff := p.window.state_str( )
 
-	json = fmt.Sprintf( `{ "state": %q, "time": %d, "host1": "%s", "host2": "%s", "id": %q, "ptype": %d }`,
-		state, diff, *p.host1, *p.host2, *p.id, PT_MIRRORING )
+	json = fmt.Sprintf( `{ "state": %q, "time": %d, "host1": "%s", "host2": "%s", "id": %q, "push_state": %q, "ptype": %d }`,
+		state, diff, *p.host1, *p.host2, *p.id, Push_state2str( p.push_state ), PT_MIRRORING )
 
 	return
 }
@@ -0,0 +1,448 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	pledge_series
+	Abstract:	A recurring bandwidth pledge -- provides the pledge interface. The series
+				itself reserves nothing; it holds a schedule, the duration of each occurrence,
+				and the values needed to create a bandwidth pledge for each occurrence. The
+				reservation manager asks the series for the occurrences which commence before
+				a horizon and admits each as an ordinary bandwidth pledge named <id>.<n> where
+				n is the occurrence number.  Occurrences that have been generated are not
+				generated again, and occurrences can be skipped (cancelled) before they have
+				been generated.
+
+				The window of the series spans from the start of the first occurrence to the
+				end of the last, or is unbounded if the schedule has no end.
+
+	Date:		17 October 2026
+
+*/
+
+package gizmos
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/att/gopkgs/clike"
+)
+
+type Pledge_series struct {
+				Pledge_base	// common fields
+	host1		*string
+	host2		*string
+	tpport1		*string
+	tpport2		*string
+	vlan1		*string
+	vlan2		*string
+	bandw_in	int64
+	bandw_out	int64
+	dscp		int
+	dscp_koe	bool
+	match_v6	bool
+	sched		*Schedule
+	duration	int64			// length of each occurrence (seconds)
+	expanded	int64			// occurrences commencing at or before this time have been generated
+	skip		map[int]bool	// occurrences cancelled before they were generated
+}
+
+/*
+	Work struct used to decode the checkpoint json.
+*/
+type Json_pledge_series struct {
+	Host1		*string
+	Host2		*string
+	Commence	int64
+	Expiry		int64
+	Bandwin		int64
+	Bandwout	int64
+	Dscp		int
+	Dscp_koe	bool
+	Match_v6	bool
+	Id			*string
+	Usrkey		*string
+	Rule		string
+	Sched_start	int64
+	Sched_until	int64
+	Duration	int64
+	Expanded	int64
+	Skip		[]int
+	Ptype		int
+}
+
+// ---- public -------------------------------------------------------------------
+
+/*
+	Constructor; creates a series pledge.  Occurrences commence according to the rule
+	(see schedule.go) at or after commence, and must conclude by expiry (which may be
+	TS_INFINITE). An error is returned if the values are not sane, or if the schedule
+	results in no occurrences.
+*/
+func Mk_series_pledge( host1 *string, host2 *string, p1 *string, p2 *string, commence int64, expiry int64, rule string, duration int64,
+		bandw_in int64, bandw_out int64, id *string, usrkey *string, dscp int, dscp_koe bool ) ( p *Pledge_series, err error ) {
+
+	p = nil
+
+	if duration < 1 {
+		err = fmt.Errorf( "invalid occurrence duration; must be greater than zero" )
+		return
+	}
+	if *host2 == "" || *host2 == "any" {
+		err = fmt.Errorf( "bad host2 name submitted: %s", *host2 )
+		return
+	}
+	if bandw_in < 1 || bandw_out < 1 {
+		err = fmt.Errorf( "invalid bandwidth; bw-in and bw-out must be greater than zero" )
+		return
+	}
+
+	now := time.Now().Unix()
+	if commence < now {
+		commence = now
+	}
+	until := TS_INFINITE
+	if expiry != TS_INFINITE {
+		until = expiry - duration						// last occurrence must finish by the expiry
+	}
+
+	sched, err := Mk_schedule( rule, commence, until )
+	if err != nil {
+		return
+	}
+
+	p = &Pledge_series {
+		Pledge_base:Pledge_base{
+			id: id,
+		},
+		host1:		host1,
+		host2:		host2,
+		tpport1:	p1,
+		tpport2:	p2,
+		bandw_in:	bandw_in,
+		bandw_out:	bandw_out,
+		dscp:		dscp,
+		dscp_koe:	dscp_koe,
+		sched:		sched,
+		duration:	duration,
+		skip:		make( map[int]bool ),
+	}
+
+	if *usrkey != "" {
+		p.usrkey = usrkey
+	} else {
+		p.usrkey = &empty_str
+	}
+
+	err = p.set_window( )
+	if err != nil {
+		p = nil
+	}
+
+	return
+}
+
+/*
+	Set the window from the schedule: the start of the first occurrence to the end of
+	the last.
+*/
+func (p *Pledge_series) set_window( ) ( err error ) {
+	first, ok := p.sched.First()
+	if !ok {
+		return fmt.Errorf( "schedule (%s) has no occurrences in the window", p.sched.Get_rule() )
+	}
+
+	last := p.sched.Last()
+	if last != TS_INFINITE {
+		last += p.duration
+	}
+
+	p.window, err = mk_pledge_window( first, last )
+	return
+}
+
+/*
+	Formats the vlan ids in the {n} form used on host names in the checkpoint.
+*/
+func (p *Pledge_series) vlan2string( ) ( v1 string, v2 string ) {
+	if p.vlan1 != nil && clike.Atoi( *p.vlan1 ) > 0 {
+		v1 = "{" + *p.vlan1 + "}"
+	}
+	if p.vlan2 != nil && clike.Atoi( *p.vlan2 ) > 0  {
+		v2 = "{" + *p.vlan2 + "}"
+	}
+
+	return v1, v2
+}
+
+/*
+	Return the name of the nth occurrence.
+*/
+func (p *Pledge_series) occ_name( n int ) ( *string ) {
+	name := fmt.Sprintf( "%s.%d", *p.id, n )
+	return &name
+}
+
+/*
+	Generate a bandwidth pledge for each occurrence that commences after the last time
+	we were called and at or before the horizon. Occurrences which have been skipped,
+	or which have already concluded, are not generated.
+*/
+func (p *Pledge_series) Next_occurrences( horizon int64 ) ( list []*Pledge_bw ) {
+	if p == nil || horizon <= p.expanded {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	nums, commence := p.sched.Occurrences( p.expanded, horizon )
+	for i := range nums {
+		if p.skip[nums[i]] || commence[i] + p.duration <= now {
+			continue
+		}
+
+		bp, err := Mk_bw_pledge( p.host1, p.host2, p.tpport1, p.tpport2, commence[i], commence[i] + p.duration, p.bandw_in, p.bandw_out, p.occ_name( nums[i] ), p.usrkey, p.dscp, p.dscp_koe )
+		if err != nil {
+			obj_sheep.Baa( 1, "series %s: unable to create occurrence %d: %s", *p.id, nums[i], err )
+			continue
+		}
+		bp.Set_vlan( p.vlan1, p.vlan2 )
+		bp.Set_matchv6( p.match_v6 )
+		list = append( list, bp )
+	}
+
+	p.expanded = horizon
+	return
+}
+
+/*
+	If the name is the name of an occurrence of this series, return the occurrence number.
+	Ok is false if the name is not an occurrence of this series.
+*/
+func (p *Pledge_series) Occurrence_num( name *string ) ( n int, ok bool ) {
+	if p == nil || name == nil || !strings.HasPrefix( *name, *p.id + "." ) {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi( (*name)[len( *p.id ) + 1:] )
+	return n, err == nil && n >= 0
+}
+
+/*
+	Cancel an occurrence which has not been generated.  Returns false if there is no such
+	occurrence or if the occurrence has already been generated (the caller must delete the
+	bandwidth pledge that was created for it).
+*/
+func (p *Pledge_series) Skip( n int ) ( bool ) {
+	if p == nil {
+		return false
+	}
+
+	commence, ok := p.sched.Nth( n )
+	if !ok || commence <= p.expanded {
+		return false
+	}
+
+	p.skip[n] = true
+	return true
+}
+
+/*
+	Set the vlan IDs used when generating occurrences.
+*/
+func (p *Pledge_series) Set_vlan( v1 *string, v2 *string ) {
+	if p == nil {
+		return
+	}
+
+	p.vlan1 = v1
+	p.vlan2 = v2
+}
+
+/*
+	Set match v6 flag used when generating occurrences.
+*/
+func (p *Pledge_series) Set_matchv6( state bool ) {
+	p.match_v6 = state
+}
+
+/*
+	Returns the schedule rule and the occurrence duration.
+*/
+func (p *Pledge_series) Get_schedule( ) ( string, int64 ) {
+	if p == nil {
+		return "", 0
+	}
+
+	return p.sched.Get_rule(), p.duration
+}
+
+// --------------- interface functions (required) ------------------------------------------------------
+
+/*
+	Returns pointers to both host strings.
+*/
+func (p *Pledge_series) Get_hosts( ) ( *string, *string ) {
+	if p == nil {
+		return &empty_str, &empty_str
+	}
+
+	return p.host1, p.host2
+}
+
+/*
+	Returns true if the host is one of the hosts in the series.
+*/
+func (p *Pledge_series) Has_host( hname *string ) ( bool ) {
+	return *p.host1 == *hname || *p.host2 == *hname
+}
+
+/*
+	Two series are equal if they are between the same hosts, with the same rule and duration,
+	and their windows overlap. Occurrences are checked for duplication individually.
+*/
+func (p *Pledge_series) Equals( op *Pledge ) ( bool ) {
+	if p == nil {
+		return false
+	}
+
+	ops, ok := (*op).( *Pledge_series )
+	if !ok {
+		return false
+	}
+
+	if !( Strings_equal( p.host1, ops.host1 ) && Strings_equal( p.host2, ops.host2 ) ||
+			Strings_equal( p.host1, ops.host2 ) && Strings_equal( p.host2, ops.host1 ) ) {
+		return false
+	}
+
+	return p.sched.Get_rule() == ops.sched.Get_rule() && p.duration == ops.duration && p.window.overlaps( ops.window )
+}
+
+/*
+	Destruction.
+*/
+func (p *Pledge_series) Nuke( ) {
+	p.host1 = nil
+	p.host2 = nil
+	p.id = nil
+	p.usrkey = nil
+	p.sched = nil
+	p.skip = nil
+}
+
+func (p *Pledge_series) To_str( ) ( s string ) {
+	return p.String()
+}
+
+/*
+	Stringer interface.
+*/
+func (p *Pledge_series) String( ) ( s string ) {
+	if p == nil {
+		return ""
+	}
+
+	state, caption, diff := p.window.state_str()
+	commence, expiry := p.window.get_values( )
+
+	//NEVER put the usrkey into the string!
+	s = fmt.Sprintf( "%s: togo=%ds %s h1=%s:%s h2=%s:%s id=%s st=%d ex=%d rule=%s dur=%d bwi=%d bwo=%d dscp=%d ptype=series", state, diff, caption,
+		*p.host1, *p.tpport1, *p.host2, *p.tpport2, *p.id, commence, expiry, p.sched.Get_rule(), p.duration, p.bandw_in, p.bandw_out, p.dscp )
+	return
+}
+
+/*
+	Generate a user safe json representation of the series.
+*/
+func (p *Pledge_series) To_json( ) ( json string ) {
+	if p == nil {
+		return "{ }"
+	}
+
+	state, _, diff := p.window.state_str()
+	json = fmt.Sprintf( `{ "state": %q, "time": %d, "bandwin": %d, "bandwout": %d, "host1": "%s:%s", "host2": "%s:%s", "id": %q, "repeat": %q, "duration": %d, "dscp": %d, "ptype": %d }`,
+				state, diff, p.bandw_in, p.bandw_out, *p.host1, *p.tpport1, *p.host2, *p.tpport2, *p.id, p.sched.Get_rule(), p.duration, p.dscp, PT_SERIES )
+
+	return
+}
+
+/*
+	Build the checkpoint string. Returns "expired" if the series has expired.
+*/
+func (p *Pledge_series) To_chkpt( ) ( chkpt string ) {
+	if p.Is_expired( ) {
+		return "expired"
+	}
+
+	commence, expiry := p.window.get_values()
+	v1, v2 := p.vlan2string( )
+	skip := make( []int, 0, len( p.skip ) )
+	for n := range p.skip {
+		skip = append( skip, n )
+	}
+	sort.Ints( skip )
+	jskip, _ := json.Marshal( skip )
+
+	chkpt = fmt.Sprintf( `{ "host1": "%s:%s%s", "host2": "%s:%s%s", "commence": %d, "expiry": %d, "bandwin": %d, "bandwout": %d, "dscp": %d, "dscp_koe": %v, "match_v6": %v, "id": %q, "usrkey": %q, "rule": %q, "sched_start": %d, "sched_until": %d, "duration": %d, "expanded": %d, "skip": %s, "ptype": %d }`,
+			*p.host1, *p.tpport1, v1, *p.host2, *p.tpport2, v2, commence, expiry, p.bandw_in, p.bandw_out, p.dscp, p.dscp_koe, p.match_v6, *p.id, *p.usrkey,
+			p.sched.Get_rule(), p.sched.start, p.sched.until, p.duration, p.expanded, jskip, PT_SERIES )
+
+	return
+}
+
+/*
+	Given a checkpoint json string, unpack it into the series.
+*/
+func (p *Pledge_series) From_json( jstr *string ) ( err error ) {
+	jp := new( Json_pledge_series )
+	err = json.Unmarshal( []byte( *jstr ), &jp )
+	if err != nil {
+		return
+	}
+
+	if jp.Ptype != PT_SERIES {
+		return fmt.Errorf( "json was not a series pledge type" )
+	}
+
+	p.host1, p.tpport1, p.vlan1 = Split_hpv( jp.Host1 )
+	p.host2, p.tpport2, p.vlan2 = Split_hpv( jp.Host2 )
+	p.window, _ = mk_pledge_window( jp.Commence, jp.Expiry )
+	p.id = jp.Id
+	p.usrkey = jp.Usrkey
+	p.bandw_in = jp.Bandwin
+	p.bandw_out = jp.Bandwout
+	p.dscp = jp.Dscp
+	p.dscp_koe = jp.Dscp_koe
+	p.match_v6 = jp.Match_v6
+	p.duration = jp.Duration
+	p.expanded = jp.Expanded
+	p.skip = make( map[int]bool )
+	for _, n := range jp.Skip {
+		p.skip[n] = true
+	}
+
+	p.sched, err = Mk_schedule( jp.Rule, jp.Sched_start, jp.Sched_until )
+	return
+}
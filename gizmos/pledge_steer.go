@@ -40,6 +40,9 @@
 				26 May 2015 - Broken out of pledge with conversion to interface
 				01 Jun 2015 - Added equal() support
 				16 Aug 2015 - Move common code into Pledge_base
+				17 Oct 2026 - Restore the middlebox list and match_v6 from a checkpoint; clone copies
+								the protocol, middleboxes and match_v6.
+				17 Oct 2026 - Push state included in json.
 */
 
 package gizmos
@@ -77,7 +80,7 @@ type Json_stpledge struct {
 	Id			*string
 	Usrkey		*string
 	Ptype		int
-	Mbox_list	[]*Mbox
+	Mbox_list	[]*Json_mbox
 	Match_v6	bool
 }
 
@@ -148,6 +151,12 @@ func (p *Pledge_steer) Clone( name string ) ( *Pledge_steer ) {
 		host2:		p.host2,
 		tpport1: 	p.tpport1,
 		tpport2: 	p.tpport2,
+		protocol:	p.protocol,
+		match_v6:	p.match_v6,
+	}
+
+	for i := 0; i < p.mbidx; i++ {
+		newp.Add_mbox( p.mbox_list[i] )
 	}
 
 	newp.window = p.window.clone()
@@ -194,9 +203,34 @@ func (p *Pledge_steer) From_json( jstr *string ) ( err error ){
 		p.protocol = &empty_str
 	}
 
+	p.match_v6 = jp.Match_v6
+	p.mbox_list = nil
+	p.mbidx = 0
+	for _, jmb := range jp.Mbox_list {
+		mb := Mk_mbox_from_json( jmb )
+		if mb == nil {
+			err = fmt.Errorf( "steering pledge %s has an incomplete middlebox in the json", *p.id )
+			return
+		}
+		p.Add_mbox( mb )
+	}
+
 	return
 }
 
+/*
+	Replace the middlebox at index n; returns false if n is out of bounds.
+	Used to update the switch/port information when restored from a checkpoint.
+*/
+func (p *Pledge_steer) Set_mbox( n int, mb *Mbox ) ( bool ) {
+	if p == nil || n < 0 || n >= p.mbidx || mb == nil {
+		return false
+	}
+
+	p.mbox_list[n] = mb
+	return true
+}
+
 /*
 	Set match v6 flag based on user input.
 */
@@ -317,8 +351,8 @@ func (p *Pledge_steer) To_json( ) ( json string ) {
 	if p.protocol != nil {
 		proto = *p.protocol
 	}
-	json = fmt.Sprintf( `{ "state": %q, "time": %d, "host1": "%s:%s", "host2": "%s:%s", "protocol": %q, "id": %q, "ptype": %d, "mbox_list": [ `,
-			state, diff, *p.host1, *p.tpport1, *p.host2, *p.tpport2, proto, *p.id, PT_STEERING )
+	json = fmt.Sprintf( `{ "state": %q, "time": %d, "host1": "%s:%s", "host2": "%s:%s", "protocol": %q, "id": %q, "push_state": %q, "ptype": %d, "mbox_list": [ `,
+			state, diff, *p.host1, *p.tpport1, *p.host2, *p.tpport2, proto, *p.id, Push_state2str( p.push_state ), PT_STEERING )
 
 	sep := ""
 	for i := 0; i < p.mbidx; i++ {
@@ -355,8 +389,8 @@ func (p *Pledge_steer) To_chkpt( ) ( chkpt string ) {
 	if p.protocol != nil {
 		proto = *p.protocol
 	}
-	chkpt = fmt.Sprintf( `{ "host1": "%s:%s", "host2": "%s:%s", "protocol": %q, "commence": %d, "expiry": %d, "id": %q, "usrkey": %q, "ptype": %d, "mbox_list": [ `,
-			*p.host1, *p.tpport1, *p.host2, *p.tpport2, proto, c, e, *p.id,  *p.usrkey, PT_STEERING )
+	chkpt = fmt.Sprintf( `{ "host1": "%s:%s", "host2": "%s:%s", "protocol": %q, "commence": %d, "expiry": %d, "id": %q, "usrkey": %q, "ptype": %d, "match_v6": %v, "mbox_list": [ `,
+			*p.host1, *p.tpport1, *p.host2, *p.tpport2, proto, c, e, *p.id,  *p.usrkey, PT_STEERING, p.match_v6 )
 
 	sep := ""
 	for i := 0; i < p.mbidx; i++ {
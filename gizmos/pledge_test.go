@@ -32,6 +32,7 @@ package gizmos
 import (
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -114,11 +115,19 @@ func Test_pw_excessive( t *testing.T ) {
 	fmt.Fprintf( os.Stderr, "\n------- pledge window tests ---------\n" );
 
 	p1_start := time.Now().Unix() + 3600				// ensure all start times are in future so window creates
-	p, err := mk_pledge_window( p1_start, p1_start + (20 * 86400 * 365) )			// too far in the future (expect false)
-	if p == nil {
-		fmt.Fprintf( os.Stderr, "OK:    window with excessive end time was not allocated as expected\n" )
+	p, err := mk_pledge_window( p1_start, p1_start + (20 * 86400 * 365) )			// far in the future; no longer an upper bound (expect ok)
+	if p != nil {
+		fmt.Fprintf( os.Stderr, "OK:    window with far future end time was allocated as expected\n" )
 	} else {
-		fmt.Fprintf( os.Stderr, "FAIL:  window with excessive end time did not fail: %s\n", err )
+		fmt.Fprintf( os.Stderr, "FAIL:  window with far future end time was not allocated: %s\n", err )
+		t.Fail()
+	}
+
+	p, err = mk_pledge_window( p1_start, TS_INFINITE )			// unbounded window (expect ok)
+	if p != nil && p.is_unbounded() {
+		fmt.Fprintf( os.Stderr, "OK:    unbounded window was allocated as expected\n" )
+	} else {
+		fmt.Fprintf( os.Stderr, "FAIL:  unbounded window was not allocated: %s\n", err )
 		t.Fail()
 	}
 }
@@ -128,10 +137,10 @@ func Test_pw_excessive( t *testing.T ) {
 func Test_ob_validtime( t *testing.T ) {
 	fmt.Fprintf( os.Stderr, "\n------- valid obligattion tests ---------\n" );
 
-	if Valid_obtime( 1735707600-1 ) { 				// expect pass, time just under bounds
-		fmt.Fprintf( os.Stderr, "OK:     max-1 time returned valid\n" )
+	if Valid_obtime( TS_INFINITE ) { 				// expect pass, no upper bound
+		fmt.Fprintf( os.Stderr, "OK:     infinite time returned valid\n" )
 	} else {
-		fmt.Fprintf( os.Stderr, "FAIL:   max-1 time didn't return valid\n" )
+		fmt.Fprintf( os.Stderr, "FAIL:   infinite time didn't return valid\n" )
 		t.Fail()
 	}
 
@@ -142,11 +151,11 @@ func Test_ob_validtime( t *testing.T ) {
 		t.Fail()
 	}
 
-	if Valid_obtime( 1735707600+1 ) {			// expect failure, time out of bounds
-		fmt.Fprintf( os.Stderr, "FAIL:   max+1 time returned valid\n" )
-		t.Fail()
+	if Valid_obtime( time.Now().Unix() + (20 * 86400 * 365) ) {			// expect pass, no fixed horizon
+		fmt.Fprintf( os.Stderr, "OK:     far future time returned valid\n" )
 	} else {
-		fmt.Fprintf( os.Stderr, "OK:     max+1 time returned invalid\n" )
+		fmt.Fprintf( os.Stderr, "FAIL:   far future time didn't return valid\n" )
+		t.Fail()
 	}
 
 	if Valid_obtime( time.Now().Unix() - 1 ) {			// expect failure, time out of bounds
@@ -157,6 +166,40 @@ func Test_ob_validtime( t *testing.T ) {
 	}
 }
 
+/*
+	Verify that an obligation accepts, reports and releases an allocation with no end time.
+*/
+func Test_ob_unbounded( t *testing.T ) {
+	fmt.Fprintf( os.Stderr, "\n------- unbounded obligation tests ---------\n" );
+
+	now := time.Now().Unix()
+	ob := Mk_obligation( 10000, 90 )
+	ob.Inc_utilisation( now + 60, TS_INFINITE, 1000, nil )
+
+	far := now + (50 * 86400 * 365)
+	if ob.Get_allocation( far ) == 1000 {
+		fmt.Fprintf( os.Stderr, "OK:     unbounded allocation is present far in the future\n" )
+	} else {
+		fmt.Fprintf( os.Stderr, "FAIL:   unbounded allocation expected 1000 far in the future, got %d\n", ob.Get_allocation( far ) )
+		t.Fail()
+	}
+
+	if ok, _ := ob.Has_capacity( now + 120, TS_INFINITE, 9500, nil ); ok {
+		fmt.Fprintf( os.Stderr, "FAIL:   unbounded obligation reported capacity for an over allocation\n" )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     unbounded obligation refused an over allocation\n" )
+	}
+
+	ob.Dec_utilisation( now + 60, TS_INFINITE, 1000, nil )
+	if ob.Get_allocation( far ) == 0 {
+		fmt.Fprintf( os.Stderr, "OK:     unbounded allocation was released\n" )
+	} else {
+		fmt.Fprintf( os.Stderr, "FAIL:   unbounded allocation expected 0 after release, got %d\n", ob.Get_allocation( far ) )
+		t.Fail()
+	}
+}
+
 func Test_bw_equals( t *testing.T ) {
 	h1 := "host1"
 	h2 := "host2"
@@ -230,3 +273,383 @@ func Test_bw_equals( t *testing.T ) {
 	}
 	fmt.Fprintf( os.Stderr, "\n" )
 }
+
+/*
+	Verify that a bandwidth pledge can have its bandwidth and expiry changed in place.
+*/
+func Test_bw_modify( t *testing.T ) {
+	h1 := "host1"
+	h2 := "host2"
+	p1 := ""
+	key := "cookie"
+	id1 := "r1"
+	failures := 0
+	now := time.Now().Unix()
+
+	fmt.Fprintf( os.Stderr, "\n----------- pledge modify tests --------------\n" )
+	bp, _ := Mk_bw_pledge( &h1, &h2, &p1, &p1, now+300, now+600, 10000, 20000, &id1, &key, 42, false )
+	bp.Set_pushed()
+
+	bp.Set_bandw( 0, 30000 )								// in unchanged
+	if bp.Get_bandw_in() != 10000 || bp.Get_bandw_out() != 30000 || bp.Is_pushed() {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   set bandwidth: expected 10000/30000 unpushed, got %d/%d pushed=%v\n", bp.Get_bandw_in(), bp.Get_bandw_out(), bp.Is_pushed() )
+	}
+
+	bp.Set_expiry( now + 900 )
+	if _, e := bp.Get_window(); e != now + 900 || *bp.Get_id() != id1 || !bp.Is_valid_cookie( &key ) {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   set expiry: expected %d with same id and cookie, got %d\n", now + 900, e )
+	}
+
+	if failures == 0 {
+		fmt.Fprintf( os.Stderr, "OK:     all pledge modify tests pass\n" )
+	} else {
+		t.Fail()
+	}
+}
+
+/*
+	Return midnight (UTC) of the first monday which is at least a day in the future.
+*/
+func next_monday( ) ( int64 ) {
+	t := time.Now().UTC().Add( 24 * time.Hour )
+	t = time.Date( t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC )
+	for t.Weekday() != time.Monday {
+		t = t.Add( 24 * time.Hour )
+	}
+
+	return t.Unix()
+}
+
+/*
+	Verify that schedules generate the expected occurrences and that bad rules are rejected.
+*/
+func Test_schedule( t *testing.T ) {
+	failures := 0
+	mon := next_monday()
+
+	fmt.Fprintf( os.Stderr, "\n----------- schedule tests --------------\n" )
+	tests := []struct {
+		rule	string
+		start	int64
+		expect	[]int64
+	} {
+		{ "FREQ=DAILY;BYHOUR=2;BYMINUTE=30;COUNT=3", mon, []int64 { mon + 9000, mon + 86400 + 9000, mon + 2*86400 + 9000 } },
+		{ "FREQ=WEEKLY/BYDAY=WE,MO/BYHOUR=22/INTERVAL=2", mon, []int64 { mon + 79200, mon + 2*86400 + 79200, mon + 14*86400 + 79200, mon + 16*86400 + 79200 } },
+		{ "freq=hourly;byminute=45,15;count=3", mon + 600, []int64 { mon + 900, mon + 2700, mon + 4500 } },
+		{ "FREQ=DAILY", mon + 3600, []int64 { mon + 3600, mon + 86400 + 3600 } },
+	}
+
+	for _, tst := range tests {
+		s, err := Mk_schedule( tst.rule, tst.start, TS_INFINITE )
+		if err != nil {
+			failures++
+			fmt.Fprintf( os.Stderr, "FAIL:   schedule %s not accepted: %s\n", tst.rule, err )
+			continue
+		}
+
+		_, got := s.Occurrences( tst.start - 1, tst.expect[len( tst.expect ) - 1] + 3600 )
+		if fmt.Sprintf( "%v", got ) != fmt.Sprintf( "%v", tst.expect ) {
+			failures++
+			fmt.Fprintf( os.Stderr, "FAIL:   schedule %s expected %v got %v\n", tst.rule, tst.expect, got )
+		}
+	}
+
+	for _, rule := range []string { "FREQ=MONTHLY", "FREQ=DAILY;BYDAY=MO", "BYHOUR=2", "FREQ=DAILY;BYHOUR=24", "FREQ=DAILY;UNTIL=20300101" } {
+		if _, err := Mk_schedule( rule, mon, TS_INFINITE ); err == nil {
+			failures++
+			fmt.Fprintf( os.Stderr, "FAIL:   bad schedule %s was accepted\n", rule )
+		}
+	}
+
+	s, _ := Mk_schedule( "FREQ=DAILY;COUNT=4", mon, TS_INFINITE )
+	if last := s.Last(); last != mon + 3*86400 {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   last occurrence of counted schedule expected %d got %d\n", mon + 3*86400, last )
+	}
+	s, _ = Mk_schedule( "FREQ=DAILY", mon, mon + 2*86400 + 10 )
+	if last := s.Last(); last != mon + 2*86400 {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   last occurrence of bounded schedule expected %d got %d\n", mon + 2*86400, last )
+	}
+
+	if failures == 0 {
+		fmt.Fprintf( os.Stderr, "OK:     all schedule tests pass\n" )
+	} else {
+		t.Fail()
+	}
+}
+
+/*
+	Verify that a series generates each occurrence once, that occurrences can be skipped
+	before they are generated, and that the series survives a checkpoint.
+*/
+func Test_series( t *testing.T ) {
+	h1 := "host1"
+	h2 := "host2"
+	p1 := ""
+	key := "cookie"
+	id := "s1"
+	failures := 0
+	mon := next_monday()
+
+	fmt.Fprintf( os.Stderr, "\n----------- series pledge tests --------------\n" )
+	sp, err := Mk_series_pledge( &h1, &h2, &p1, &p1, mon, TS_INFINITE, "FREQ=DAILY;BYHOUR=2;COUNT=5", 3600, 1000, 2000, &id, &key, 42, false )
+	if err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   unable to create series: %s\n", err )
+		t.Fail()
+		return
+	}
+
+	if c, e := sp.Get_window(); c != mon + 7200 || e != mon + 4*86400 + 10800 {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   series window expected %d-%d got %d-%d\n", mon + 7200, mon + 4*86400 + 10800, c, e )
+	}
+
+	occ := sp.Next_occurrences( mon + 2*86400 + 7200 )
+	if len( occ ) != 3 || *occ[2].Get_id() != "s1.2" {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   expected 3 occurrences through day 2, got %d\n", len( occ ) )
+	} else {
+		c, e := occ[1].Get_window()
+		if c != mon + 86400 + 7200 || e != c + 3600 || occ[1].Get_bandw_in() != 1000 || !occ[1].Is_valid_cookie( &key ) {
+			failures++
+			fmt.Fprintf( os.Stderr, "FAIL:   occurrence 1 is not correct: %s\n", occ[1] )
+		}
+	}
+
+	if sp.Skip( 1 ) || !sp.Skip( 3 ) || sp.Skip( 5 ) {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   skip allowed a generated or non-existant occurrence, or refused a future one\n" )
+	}
+
+	chkpt := sp.To_chkpt()
+	gp, err := Json2pledge( &chkpt )
+	if err != nil {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   series did not restore from checkpoint: %s\n", err )
+	} else {
+		rp, ok := (*gp).( *Pledge_series )
+		if !ok || rp.To_chkpt() != chkpt {
+			failures++
+			fmt.Fprintf( os.Stderr, "FAIL:   restored series differs:\n\t%s\n\t%s\n", chkpt, (*gp).To_chkpt() )
+		} else {
+			sp = rp
+		}
+	}
+
+	occ = sp.Next_occurrences( TS_INFINITE - 1 )
+	if len( occ ) != 1 || *occ[0].Get_id() != "s1.4" {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   expected only occurrence 4 after skipping 3, got %d\n", len( occ ) )
+	}
+
+	if n, ok := sp.Occurrence_num( occ[0].Get_id() ); !ok || n != 4 {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   occurrence number not recognised from name\n" )
+	}
+
+	if failures == 0 {
+		fmt.Fprintf( os.Stderr, "OK:     all series pledge tests pass\n" )
+	} else {
+		t.Fail()
+	}
+}
+
+/*
+	Verify that priority is kept by clone and through a checkpoint, and that the default is 0.
+*/
+func Test_priority( t *testing.T ) {
+	h1 := "host1"
+	h2 := "host2"
+	p1 := ""
+	key := "cookie"
+	id1 := "r1"
+	id2 := "r2"
+	failures := 0
+	now := time.Now().Unix()
+
+	fmt.Fprintf( os.Stderr, "\n----------- pledge priority tests --------------\n" )
+	bp, _ := Mk_bw_pledge( &h1, &h2, &p1, &p1, now+300, now+600, 10000, 20000, &id1, &key, 42, false )
+	if bp.Get_priority() != 0 {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   default priority expected 0 got %d\n", bp.Get_priority() )
+	}
+
+	bp.Set_priority( 5 )
+	if cp := bp.Clone( "r1.yank" ); cp.Get_priority() != 5 {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   clone priority expected 5 got %d\n", cp.Get_priority() )
+	}
+
+	chkpt := bp.To_chkpt()
+	if gp, err := Json2pledge( &chkpt ); err != nil || (*gp).Get_priority() != 5 {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   bw priority not restored from checkpoint: %s\n", chkpt )
+	}
+
+	op, _ := Mk_bwow_pledge( &h1, &h2, &p1, &p1, now+300, now+600, 10000, &id2, &key, 42 )
+	op.Set_priority( -3 )
+	if op.Get_priority() != 0 {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   negative priority expected to be set to 0, got %d\n", op.Get_priority() )
+	}
+	op.Set_priority( 2 )
+	chkpt = op.To_chkpt()
+	if gp, err := Json2pledge( &chkpt ); err != nil || (*gp).Get_priority() != 2 {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   oneway priority not restored from checkpoint: %s\n", chkpt )
+	}
+
+	if failures == 0 {
+		fmt.Fprintf( os.Stderr, "OK:     all pledge priority tests pass\n" )
+	} else {
+		t.Fail()
+	}
+}
+
+/*
+	Checkpoint round trip for a steering pledge: the middle boxes (with switch and port) and
+	the ipv6 flag must survive so that the reservation can be restored after a restart.
+*/
+func Test_steer_chkpt( t *testing.T ) {
+	h1 := "tenant/vm1"
+	h2 := "tenant/vm2"
+	p1 := "0"
+	p2 := "80"
+	proto := "tcp:80"
+	key := "cookie"
+	id := "st1"
+	failures := 0
+	now := time.Now().Unix()
+
+	fmt.Fprintf( os.Stderr, "\n----------- steering pledge checkpoint tests --------------\n" )
+	sp, err := Mk_steer_pledge( &h1, &h2, &p1, &p2, now, now+600, &id, &key, &proto )
+	if err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   unable to make steering pledge: %s\n", err )
+		t.Fail()
+		return
+	}
+
+	mbids := []string{ "fw", "ids", "cache" }
+	macs := []string{ "fa:16:3e:00:00:01", "fa:16:3e:00:00:02", "fa:16:3e:00:00:03" }
+	swids := []string{ "phost1", "phost2", "phost1" }
+	for i := range mbids {
+		sp.Add_mbox( Mk_mbox( &mbids[i], &macs[i], &swids[i], -128 + i ) )
+	}
+	sp.Set_matchv6( true )
+
+	chkpt := sp.To_chkpt()
+	gp, err := Json2pledge( &chkpt )
+	if err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   steering checkpoint did not parse: %s: %s\n", err, chkpt )
+		t.Fail()
+		return
+	}
+
+	rp, ok := (*gp).( *Pledge_steer )
+	if !ok {
+		fmt.Fprintf( os.Stderr, "FAIL:   checkpoint did not restore a steering pledge: %s\n", chkpt )
+		t.Fail()
+		return
+	}
+
+	if rp.Get_mbox_count() != len( mbids ) {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   expected %d middleboxes after restore, got %d\n", len( mbids ), rp.Get_mbox_count() )
+	} else {
+		for i := range mbids {
+			mid, mac, swid, port := rp.Get_mbox( i ).Get_values()
+			if *mid != mbids[i] || *mac != macs[i] || *swid != swids[i] || port != -128 + i {
+				failures++
+				fmt.Fprintf( os.Stderr, "FAIL:   middlebox %d not restored: %s\n", i, *rp.Get_mbox( i ).To_json() )
+			}
+		}
+	}
+
+	rh1, rh2, rp1, rp2, c, e, _, _ := rp.Get_values()
+	oc, oe := sp.Get_window()
+	if *rh1 != h1 || *rh2 != h2 || *rp1 != p1 || *rp2 != p2 || c != oc || e != oe {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   hosts, ports or window not restored: %s\n", rp.To_str() )
+	}
+	if *rp.Get_proto() != proto || !rp.Get_matchv6() || !rp.Is_valid_cookie( &key ) {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   protocol, match_v6 or cookie not restored: %s\n", chkpt )
+	}
+
+	if rc := rp.To_chkpt(); rc != chkpt {						// a second round trip must be identical
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   second checkpoint differs:\n\t%s\n\t%s\n", chkpt, rc )
+	}
+
+	if cp := rp.Clone( "st1.yank" ); cp.Get_mbox_count() != len( mbids ) || *cp.Get_proto() != proto {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   clone did not copy middleboxes or protocol\n" )
+	}
+
+	if failures == 0 {
+		fmt.Fprintf( os.Stderr, "OK:     steering pledge checkpoint round trip\n" )
+	} else {
+		t.Fail()
+	}
+}
+
+/*
+	Verify push state handling: the state shows in the json, the retry limit is honoured,
+	and an installed state resets the retry count.
+*/
+func Test_push_state( t *testing.T ) {
+	h1 := "host1"
+	h2 := "host2"
+	p1 := ""
+	key := "cookie"
+	id := "r1"
+	failures := 0
+	now := time.Now().Unix()
+
+	fmt.Fprintf( os.Stderr, "\n----------- pledge push state tests --------------\n" )
+	bp, _ := Mk_bw_pledge( &h1, &h2, &p1, &p1, now, now+600, 10000, 20000, &id, &key, 42, false )
+	var gp Pledge = bp
+	if gp.Get_push_state() != PS_NONE || ! strings.Contains( gp.To_json(), `"push_state": "none"` ) {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   initial push state expected none: %s\n", gp.To_json() )
+	}
+
+	gp.Set_pushed()
+	gp.Set_push_state( PS_PARTIAL )
+	if ! strings.Contains( gp.To_json(), `"push_state": "partial"` ) {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   partial push state not in json: %s\n", gp.To_json() )
+	}
+
+	for i := 0; i < 2; i++ {
+		if ! gp.Retry_push( 2 ) || gp.Is_pushed() {
+			failures++
+			fmt.Fprintf( os.Stderr, "FAIL:   retry %d expected to reset the pushed flag\n", i+1 )
+		}
+		gp.Set_pushed()
+	}
+	if gp.Retry_push( 2 ) || ! gp.Is_pushed() {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   retry beyond the limit expected to leave the pledge alone\n" )
+	}
+
+	gp.Set_push_state( PS_INSTALLED )
+	if ! gp.Retry_push( 2 ) {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   installed state expected to reset the retry count\n" )
+	}
+
+	if Push_state2str( 99 ) != "none" {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   out of range push state expected to map to none\n" )
+	}
+
+	if failures == 0 {
+		fmt.Fprintf( os.Stderr, "OK:     all pledge push state tests pass\n" )
+	} else {
+		t.Fail()
+	}
+}
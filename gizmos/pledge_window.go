@@ -31,6 +31,8 @@
 	Author:		E. Scott Daniels
 
 	Mods:		28 Jul 2015 : Added upper bounds check for expiry time.
+				17 Oct 2026 : Removed the upper bound; an expiry of TS_INFINITE makes the
+					window unbounded.
 */
 
 package gizmos
@@ -49,7 +51,8 @@ type pledge_window struct {
 /*
 	Make a new pledge_window. If the commence time is earlier than now, it is adjusted
 	to be now.  If the expry time is before the adjusted commence time, then a nil
-	pointer and error are returned.
+	pointer and error are returned. An expiry of TS_INFINITE creates a window that
+	never expires.
 */
 func mk_pledge_window( commence int64, expiry int64 ) ( pw *pledge_window, err error ) {
 	now := time.Now().Unix()
@@ -66,12 +69,6 @@ func mk_pledge_window( commence int64, expiry int64 ) ( pw *pledge_window, err e
 		return
 	}
 
-	if ! Valid_obtime( expiry ) {			// if expiry is less than max obligation time
-		err = fmt.Errorf( "bad expiry submitted, too far in the future: expiry=%d", expiry );
-		obj_sheep.Baa( 2, "pledge: %s", err )
-		return
-	}
-
 	pw = &pledge_window {
 		commence: commence,
 		expiry: expiry,
@@ -141,20 +138,35 @@ func (p *pledge_window) state_str( ) ( state string, caption string, diff int64
 			caption = "from now"
 		} else {
 			state = "ACTIVE"
-			diff = p.expiry -  now
-			caption = "remaining"
+			if p.is_unbounded( ) {
+				caption = "unbounded"				// diff of 0 as there is no meaningful time remaining
+			} else {
+				diff = p.expiry -  now
+				caption = "remaining"
+			}
 		}
 	}
 
 	return state, caption, diff
 }
 
+/*
+	Returns true if the window has no expiry time.
+*/
+func (p *pledge_window) is_unbounded( ) ( bool ) {
+	if p == nil {
+		return false
+	}
+
+	return p.expiry == TS_INFINITE
+}
+
 /*
 	Extend the expiry time by n seconds. N may be negative and will not set the
-	expiry time earlier than now.
+	expiry time earlier than now. An unbounded window is not changed.
 */
 func (p *pledge_window) extend_by( n int64 ) {
-	if p == nil {
+	if p == nil || p.is_unbounded( ) {
 		return
 	}
 
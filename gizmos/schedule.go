@@ -0,0 +1,339 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	schedule
+	Abstract:	Manages a recurrence rule which generates the commence times of the occurrences
+				of a recurring reservation.  The rule is a subset of an iCalendar RRULE with the
+				parts separated by semicolons or slants (slants allow the rule to be given on
+				a tegu API request where semicolons separate requests). For example:
+
+					FREQ=DAILY;BYHOUR=2;BYMINUTE=30
+					FREQ=WEEKLY/BYDAY=MO,WE,FR/BYHOUR=22/COUNT=10
+
+				Supported parts are:
+					FREQ		HOURLY, DAILY or WEEKLY (required)
+					INTERVAL	every nth hour/day/week (default 1)
+					BYDAY		comma separated list of MO,TU,WE,TH,FR,SA,SU (weekly only)
+					BYHOUR		comma separated list of hours (0-23; not hourly)
+					BYMINUTE	comma separated list of minutes (0-59)
+					COUNT		maximum number of occurrences
+
+				The first occurrence is at or after the start of the schedule and no occurrence
+				commences after the until time.  Values not given (hour, minute, day) are taken
+				from the start time.  All times are UTC.
+
+	Date:		17 October 2026
+
+*/
+
+package gizmos
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SCHED_HOURLY int = iota
+	SCHED_DAILY
+	SCHED_WEEKLY
+)
+
+var sched_days = map[string]int { "MO": 0, "TU": 1, "WE": 2, "TH": 3, "FR": 4, "SA": 5, "SU": 6 }
+
+type Schedule struct {
+	rule		string			// the rule as it was given to us
+	freq		int				// one of the SCHED_ constants
+	interval	int64
+	days		[]int64			// selected days of the week (0 == monday) for weekly
+	offsets		[]int64			// seconds into the hour/day at which occurrences commence (sorted)
+	count		int				// max number of occurrences; 0 == no limit
+	start		int64			// first possible commence time
+	until		int64			// last possible commence time (TS_INFINITE if not bounded)
+}
+
+/*
+	Parse a comma separated list of integers which must be in the range lo..hi.
+*/
+func sched_ilist( what string, val string, lo int, hi int ) ( list []int64, err error ) {
+	for _, v := range strings.Split( val, "," ) {
+		n, err := strconv.Atoi( v )
+		if err != nil || n < lo || n > hi {
+			return nil, fmt.Errorf( "bad %s value in schedule: %s", what, v )
+		}
+		list = append( list, int64( n ) )
+	}
+
+	return
+}
+
+/*
+	Constructor. Parses the rule and creates a schedule whose occurrences commence between
+	start and until (inclusive).  Until may be TS_INFINITE.
+*/
+func Mk_schedule( rule string, start int64, until int64 ) ( s *Schedule, err error ) {
+	var (
+		hours	[]int64
+		minutes	[]int64
+	)
+
+	if until < start {
+		return nil, fmt.Errorf( "schedule ends before it starts" )
+	}
+
+	s = &Schedule {
+		rule:		rule,
+		freq:		-1,
+		interval:	1,
+		start:		start,
+		until:		until,
+	}
+
+	parts := strings.FieldsFunc( strings.ToUpper( rule ), func( c rune ) bool { return c == ';' || c == '/' } )
+	for _, part := range parts {
+		kv := strings.SplitN( part, "=", 2 )
+		if len( kv ) != 2 {
+			return nil, fmt.Errorf( "bad schedule part, expected key=value: %s", part )
+		}
+
+		switch kv[0] {
+			case "FREQ":
+				switch kv[1] {
+					case "HOURLY":	s.freq = SCHED_HOURLY
+					case "DAILY":	s.freq = SCHED_DAILY
+					case "WEEKLY":	s.freq = SCHED_WEEKLY
+
+					default:
+						return nil, fmt.Errorf( "unsupported schedule frequency: %s", kv[1] )
+				}
+
+			case "INTERVAL":
+				n, err := strconv.Atoi( kv[1] )
+				if err != nil || n < 1 {
+					return nil, fmt.Errorf( "bad schedule interval: %s", kv[1] )
+				}
+				s.interval = int64( n )
+
+			case "COUNT":
+				n, err := strconv.Atoi( kv[1] )
+				if err != nil || n < 1 {
+					return nil, fmt.Errorf( "bad schedule count: %s", kv[1] )
+				}
+				s.count = n
+
+			case "BYDAY":
+				for _, d := range strings.Split( kv[1], "," ) {
+					dn, ok := sched_days[d]
+					if !ok {
+						return nil, fmt.Errorf( "bad day in schedule: %s", d )
+					}
+					s.days = append( s.days, int64( dn ) )
+				}
+
+			case "BYHOUR":
+				if hours, err = sched_ilist( "hour", kv[1], 0, 23 ); err != nil {
+					return nil, err
+				}
+
+			case "BYMINUTE":
+				if minutes, err = sched_ilist( "minute", kv[1], 0, 59 ); err != nil {
+					return nil, err
+				}
+
+			default:
+				return nil, fmt.Errorf( "unsupported schedule part: %s", kv[0] )
+		}
+	}
+
+	if s.freq < 0 {
+		return nil, fmt.Errorf( "schedule must include FREQ" )
+	}
+	if s.days != nil && s.freq != SCHED_WEEKLY {
+		return nil, fmt.Errorf( "BYDAY is only supported with FREQ=WEEKLY" )
+	}
+	if hours != nil && s.freq == SCHED_HOURLY {
+		return nil, fmt.Errorf( "BYHOUR is not supported with FREQ=HOURLY" )
+	}
+
+	st := time.Unix( start, 0 ).UTC()
+	if minutes == nil {
+		minutes = []int64 { int64( st.Minute() ) }
+	}
+	if s.freq == SCHED_HOURLY {
+		hours = []int64 { 0 }
+	} else {
+		if hours == nil {
+			hours = []int64 { int64( st.Hour() ) }
+		}
+	}
+	if s.freq == SCHED_WEEKLY && s.days == nil {
+		s.days = []int64 { int64( (st.Weekday() + 6) % 7 ) }		// go has sunday as 0; we want monday
+	}
+	sort.Slice( s.days, func( i, j int ) bool { return s.days[i] < s.days[j] } )
+
+	for _, h := range hours {
+		for _, m := range minutes {
+			s.offsets = append( s.offsets, h * 3600 + m * 60 )
+		}
+	}
+	sort.Slice( s.offsets, func( i, j int ) bool { return s.offsets[i] < s.offsets[j] } )
+
+	return
+}
+
+/*
+	Invoke the function for each occurrence, in order, passing the occurrence number (0 based)
+	and the commence time, until there are no more occurrences or the function returns false.
+	Occurrences which commence after the before time are not visited.
+*/
+func (s *Schedule) walk( before int64, fn func( n int, commence int64 ) bool ) {
+	var (
+		anchor	int64		// start of the first hour/day/week
+		period	int64		// length of one hour/day/week
+		days	[]int64
+	)
+
+	if s == nil {
+		return
+	}
+	if before > s.until {
+		before = s.until
+	}
+
+	st := time.Unix( s.start, 0 ).UTC()
+	switch s.freq {
+		case SCHED_HOURLY:
+			anchor = s.start - int64( st.Minute() * 60 + st.Second() )
+			period = 3600
+			days = []int64 { 0 }
+
+		case SCHED_DAILY:
+			anchor = s.start - int64( st.Hour() * 3600 + st.Minute() * 60 + st.Second() )
+			period = 86400
+			days = []int64 { 0 }
+
+		default:
+			anchor = s.start - int64( st.Hour() * 3600 + st.Minute() * 60 + st.Second() )
+			anchor -= int64( (st.Weekday() + 6) % 7 ) * 86400		// back to monday
+			period = 86400 * 7
+			days = s.days
+	}
+
+	n := 0
+	for base := anchor; base <= before; base += period * s.interval {
+		for _, d := range days {
+			for _, o := range s.offsets {
+				t := base + d * 86400 + o
+				if t < s.start {
+					continue
+				}
+				if t > before || (s.count > 0 && n >= s.count) {
+					return
+				}
+
+				if ! fn( n, t ) {
+					return
+				}
+				n++
+			}
+		}
+	}
+}
+
+/*
+	Return the commence times of the occurrences which commence after the after time and
+	at or before the before time.  The occurrence numbers are returned in the parallel array.
+*/
+func (s *Schedule) Occurrences( after int64, before int64 ) ( nums []int, commence []int64 ) {
+	s.walk( before, func( n int, t int64 ) bool {
+		if t > after {
+			nums = append( nums, n )
+			commence = append( commence, t )
+		}
+		return true
+	} )
+
+	return
+}
+
+/*
+	Return the commence time of the first occurrence; ok is false if there are none.
+*/
+func (s *Schedule) First( ) ( commence int64, ok bool ) {
+	s.walk( TS_INFINITE, func( n int, t int64 ) bool {
+		commence = t
+		ok = true
+		return false
+	} )
+
+	return
+}
+
+/*
+	Return the commence time of the nth (0 based) occurrence; ok is false if there is no such occurrence.
+*/
+func (s *Schedule) Nth( n int ) ( commence int64, ok bool ) {
+	s.walk( TS_INFINITE, func( i int, t int64 ) bool {
+		if i == n {
+			commence = t
+			ok = true
+			return false
+		}
+		return true
+	} )
+
+	return
+}
+
+/*
+	Return the commence time of the last occurrence, or TS_INFINITE if the schedule is not
+	bounded by either a count or an until time.
+*/
+func (s *Schedule) Last( ) ( commence int64 ) {
+	if s == nil {
+		return 0
+	}
+	if s.count == 0 && s.until == TS_INFINITE {
+		return TS_INFINITE
+	}
+
+	s.walk( TS_INFINITE, func( n int, t int64 ) bool {
+		commence = t
+		return true
+	} )
+
+	return
+}
+
+/*
+	Return the rule which was used to create the schedule.
+*/
+func (s *Schedule) Get_rule( ) ( string ) {
+	if s == nil {
+		return ""
+	}
+
+	return s.rule
+}
@@ -0,0 +1,143 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	sdnc
+	Abstract:	Interface to an SDN controller. The controller provides the physical topology
+				(links) and the hosts attached to switches, and accepts queue settings and flow
+				installation requests.  Two implementations exist: the original floodlight/skoogi
+				REST interface (FL_* and SK_* functions in flight_if.go) and an OpenDaylight/ONOS
+				style REST interface (sdnc_rest.go). Mk_sdn_ctlr() creates the one named in the
+				config file (default:sdn_type).
+
+				Links and hosts are returned in the floodlight structures (FL_link_json and
+				FL_host_json) as that is what the network graph builder has always consumed.
+
+	Date:		17 October 2026
+
+*/
+
+package gizmos
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	SDNC_FLOODLIGHT	string = "floodlight"
+	SDNC_REST		string = "rest"				// OpenDaylight/ONOS style REST
+)
+
+/*
+	A flow to install. If the switch id is empty the controller is asked to reserve the path
+	between the two hosts (the original skoogi phostadd); otherwise the flow is installed on
+	the switch and sends matching traffic out of port using the queue.
+*/
+type Flow struct {
+	Src			string				// source host (IP address)
+	Dst			string				// destination host (IP address)
+	Expiry		int64				// UNIX timestamp when the flow should be removed
+	Queue		int					// queue number on the output port
+	Swid		string				// switch (dpid) the flow is installed on
+	Port		int					// output port
+}
+
+/*
+	The functions which tegu needs from an SDN controller.
+*/
+type Sdn_ctlr interface {
+	Get_links( ) ( []FL_link_json, error )		// topology discovery
+	Get_hosts( ) ( []FL_host_json, error )		// host discovery
+	Set_queues( qlist []string ) ( error )		// queue setting
+	Install_flow( f *Flow ) ( error )			// flow installation
+	String( ) ( string )
+}
+
+/*
+	Create the controller interface for the type given (floodlight or rest). The host is the
+	host:port where the controller listens; user and password are used for basic authentication
+	by the rest controller and are ignored by floodlight.
+*/
+func Mk_sdn_ctlr( kind string, host_port string, user string, pw string ) ( sdnc Sdn_ctlr, err error ) {
+	if host_port == "" {
+		return nil, fmt.Errorf( "no sdn host given" )
+	}
+
+	switch strings.ToLower( kind ) {
+		case "", SDNC_FLOODLIGHT, "skoogi":
+			sdnc = &Floodlight_ctlr{ host_port: host_port }
+
+		case SDNC_REST, "onos", "odl", "opendaylight":
+			sdnc = Mk_rest_ctlr( host_port, user, pw )
+
+		default:
+			err = fmt.Errorf( "unknown sdn controller type: %s; expected floodlight or rest", kind )
+	}
+
+	return
+}
+
+// ---------------------- floodlight/skoogi -----------------------------------------------
+
+/*
+	Floodlight (with the skoogi extension) controller. The work is done by the functions in
+	flight_if.go.
+*/
+type Floodlight_ctlr struct {
+	host_port	string
+}
+
+func (fc *Floodlight_ctlr) Get_links( ) ( []FL_link_json, error ) {
+	llist := FL_links( &fc.host_port )
+	if llist == nil {
+		return nil, fmt.Errorf( "unable to get links from floodlight: %s", fc.host_port )
+	}
+
+	return llist, nil
+}
+
+func (fc *Floodlight_ctlr) Get_hosts( ) ( []FL_host_json, error ) {
+	hlist := FL_hosts( &fc.host_port )
+	if hlist == nil {
+		return nil, fmt.Errorf( "unable to get hosts from floodlight: %s", fc.host_port )
+	}
+
+	return hlist, nil
+}
+
+func (fc *Floodlight_ctlr) Set_queues( qlist []string ) ( error ) {
+	uri := "http://" + fc.host_port
+	return SK_set_queues( &uri, qlist )
+}
+
+func (fc *Floodlight_ctlr) Install_flow( f *Flow ) ( error ) {
+	uri := "http://" + fc.host_port
+	if f.Swid == "" {
+		return SK_reserve( &uri, f.Src, f.Dst, f.Expiry, f.Queue )
+	}
+
+	return SK_ie_flowmod( &uri, f.Src, f.Dst, f.Expiry, f.Queue, f.Swid, f.Port )
+}
+
+func (fc *Floodlight_ctlr) String( ) ( string ) {
+	return "floodlight:" + fc.host_port
+}
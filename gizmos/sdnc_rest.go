@@ -0,0 +1,242 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	sdnc_rest
+	Abstract:	SDN controller interface for controllers with an OpenDaylight/ONOS style
+				northbound REST api:
+					GET  /onos/v1/links				topology
+					GET  /onos/v1/hosts				hosts and their attachment points
+					POST /onos/v1/flows/<device>	install a flow on a device
+
+				Ports are strings in the json; non-numeric ports (e.g. LOCAL) are skipped.
+				Links are reported once per direction, so they are returned as unidirectional.
+				Queues are not managed through this api (they are set on the switches by the
+				agents), so Set_queues() returns an error.
+
+	Date:		17 October 2026
+
+*/
+
+package gizmos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Rest_ctlr struct {
+	base		string				// http://host:port
+	user		string
+	pw			string
+	client		*http.Client
+}
+
+// ---- json returned by the controller ---------------------------------------------------
+type rest_point_json struct {
+	Device		string
+	Port		string
+}
+
+type rest_link_json struct {
+	Src			rest_point_json
+	Dst			rest_point_json
+	Type		string
+	State		string
+}
+
+type rest_location_json struct {
+	ElementId	string
+	Port		string
+}
+
+type rest_host_json struct {
+	Mac				string
+	IpAddresses		[]string
+	Locations		[]rest_location_json
+	Location		*rest_location_json			// older versions give a single location
+}
+
+/*
+	Create a rest controller interface. Host_port may include the scheme (http:// or https://).
+*/
+func Mk_rest_ctlr( host_port string, user string, pw string ) ( *Rest_ctlr ) {
+	base := host_port
+	if ! strings.HasPrefix( base, "http://" ) && ! strings.HasPrefix( base, "https://" ) {
+		base = "http://" + base
+	}
+
+	return &Rest_ctlr {
+		base:	strings.TrimRight( base, "/" ),
+		user:	user,
+		pw:		pw,
+		client:	&http.Client{ Timeout: 30 * time.Second },
+	}
+}
+
+/*
+	Send a request to the controller and return the response body. A non-2xx status is
+	returned as an error.
+*/
+func (rc *Rest_ctlr) send( method string, path string, body []byte ) ( rbody []byte, err error ) {
+	req, err := http.NewRequest( method, rc.base + path, bytes.NewReader( body ) )
+	if err != nil {
+		return
+	}
+	if body != nil {
+		req.Header.Set( "Content-Type", "application/json" )
+	}
+	req.Header.Set( "Accept", "application/json" )
+	if rc.user != "" {
+		req.SetBasicAuth( rc.user, rc.pw )
+	}
+
+	resp, err := rc.client.Do( req )
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	rbody, err = ioutil.ReadAll( resp.Body )
+	if err == nil && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		err = fmt.Errorf( "sdn controller returned %s for %s %s: %s", resp.Status, method, path, rbody )
+	}
+
+	return
+}
+
+/*
+	Convert a port string to an integer; ok is false if the port is not numeric.
+*/
+func rest_port( p string ) ( port int, ok bool ) {
+	port, err := strconv.Atoi( p )
+	return port, err == nil
+}
+
+func (rc *Rest_ctlr) Get_links( ) ( llist []FL_link_json, err error ) {
+	jdata, err := rc.send( "GET", "/onos/v1/links", nil )
+	if err != nil {
+		return
+	}
+
+	resp := struct { Links []rest_link_json } { }
+	if err = json.Unmarshal( jdata, &resp ); err != nil {
+		return
+	}
+
+	llist = make( []FL_link_json, 0, len( resp.Links ) )
+	for _, l := range resp.Links {
+		if l.State != "" && l.State != "ACTIVE" {
+			continue
+		}
+		sp, ok1 := rest_port( l.Src.Port )
+		dp, ok2 := rest_port( l.Dst.Port )
+		if ! ok1 || ! ok2 {
+			continue
+		}
+
+		llist = append( llist, FL_link_json {
+			Src_switch:	l.Src.Device,
+			Src_port:	sp,
+			Dst_switch:	l.Dst.Device,
+			Dst_port:	dp,
+			Type:		"internal",
+			Direction:	"unidirectional",
+		} )
+	}
+
+	return
+}
+
+func (rc *Rest_ctlr) Get_hosts( ) ( hlist []FL_host_json, err error ) {
+	jdata, err := rc.send( "GET", "/onos/v1/hosts", nil )
+	if err != nil {
+		return
+	}
+
+	resp := struct { Hosts []rest_host_json } { }
+	if err = json.Unmarshal( jdata, &resp ); err != nil {
+		return
+	}
+
+	hlist = make( []FL_host_json, 0, len( resp.Hosts ) )
+	for _, h := range resp.Hosts {
+		fh := FL_host_json{ EntityClass: "DefaultEntityClass", Mac: []string{ h.Mac } }
+		for _, ip := range h.IpAddresses {
+			if strings.Index( ip, ":" ) >= 0 {
+				fh.Ipv6 = append( fh.Ipv6, ip )
+			} else {
+				fh.Ipv4 = append( fh.Ipv4, ip )
+			}
+		}
+
+		locs := h.Locations
+		if h.Location != nil {
+			locs = append( locs, *h.Location )
+		}
+		for _, loc := range locs {
+			if p, ok := rest_port( loc.Port ); ok {
+				fh.AttachmentPoint = append( fh.AttachmentPoint, FL_attachment_json{ SwitchDPID: loc.ElementId, Port: p } )
+			}
+		}
+
+		hlist = append( hlist, fh )
+	}
+
+	return
+}
+
+func (rc *Rest_ctlr) Set_queues( qlist []string ) ( error ) {
+	return fmt.Errorf( "queues cannot be set through the sdn controller rest api: %s", rc.base )
+}
+
+/*
+	Install a flow which matches IPv4 traffic from src to dst and sends it out of the port
+	using the queue. The flow times out at the expiry time.
+*/
+func (rc *Rest_ctlr) Install_flow( f *Flow ) ( err error ) {
+	if f.Swid == "" {
+		return fmt.Errorf( "a switch is required to install a flow with the sdn controller rest api" )
+	}
+
+	timeout := f.Expiry - time.Now().Unix()
+	if timeout <= 0 {
+		return fmt.Errorf( "flow has already expired: %d", f.Expiry )
+	}
+
+	jstr := fmt.Sprintf( `{ "priority": 40000, "timeout": %d, "isPermanent": false, "deviceId": %q, `, timeout, f.Swid ) +
+		fmt.Sprintf( `"treatment": { "instructions": [ { "type": "QUEUE", "queueId": %d }, { "type": "OUTPUT", "port": "%d" } ] }, `, f.Queue, f.Port ) +
+		fmt.Sprintf( `"selector": { "criteria": [ { "type": "ETH_TYPE", "ethType": "0x800" }, { "type": "IPV4_SRC", "ip": "%s/32" }, { "type": "IPV4_DST", "ip": "%s/32" } ] } }`, f.Src, f.Dst )
+
+	obj_sheep.Baa( 2, "rest_ctlr: installing flow on %s: %s", f.Swid, jstr )
+	_, err = rc.send( "POST", "/onos/v1/flows/" + f.Swid, []byte( jstr ) )
+	return
+}
+
+func (rc *Rest_ctlr) String( ) ( string ) {
+	return "rest:" + rc.base
+}
@@ -0,0 +1,186 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	sdnc_test
+	Abstract:	Tests for the sdn controller interface. A local http server stands in for the
+				controller: it serves canned floodlight and onos topology and records the
+				requests which push queues and flows.
+	Date:		17 October 2026
+
+*/
+
+package gizmos
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+	Stand-in controller. Posts are recorded as "path?query body".
+*/
+type stand_in struct {
+	mu		sync.Mutex
+	posts	[]string
+	auth	string
+}
+
+func (si *stand_in) ServeHTTP( w http.ResponseWriter, r *http.Request ) {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	if u, p, ok := r.BasicAuth(); ok {
+		si.auth = u + ":" + p
+	}
+
+	if r.Method == "POST" {
+		body, _ := ioutil.ReadAll( r.Body )
+		si.posts = append( si.posts, r.URL.Path + "?" + r.URL.RawQuery + " " + string( body ) )
+		w.Write( []byte( "ok" ) )
+		return
+	}
+
+	switch r.URL.Path {
+		case "/wm/topology/links/json":
+			w.Write( []byte( `[ { "Src-switch": "00:01", "Src-port": 1, "Dst-switch": "00:02", "Dst-port": 2, "Type": "internal", "Direction": "bidirectional" } ]` ) )
+
+		case "/wm/device/":
+			w.Write( []byte( `[ { "entityClass": "DefaultEntityClass", "mac": [ "fa:16:3e:00:00:01" ], "ipv4": [ "10.0.0.1" ],
+				"attachmentPoint": [ { "switchDPID": "00:01", "port": 5 } ] } ]` ) )
+
+		case "/onos/v1/links":
+			w.Write( []byte( `{ "links": [
+				{ "src": { "device": "of:01", "port": "1" }, "dst": { "device": "of:02", "port": "2" }, "type": "DIRECT", "state": "ACTIVE" },
+				{ "src": { "device": "of:02", "port": "2" }, "dst": { "device": "of:01", "port": "1" }, "type": "DIRECT", "state": "ACTIVE" },
+				{ "src": { "device": "of:02", "port": "3" }, "dst": { "device": "of:03", "port": "1" }, "type": "DIRECT", "state": "INACTIVE" },
+				{ "src": { "device": "of:02", "port": "LOCAL" }, "dst": { "device": "of:03", "port": "2" }, "type": "DIRECT", "state": "ACTIVE" } ] }` ) )
+
+		case "/onos/v1/hosts":
+			w.Write( []byte( `{ "hosts": [ { "id": "fa:16:3e:00:00:02/None", "mac": "fa:16:3e:00:00:02", "ipAddresses": [ "10.0.0.2", "fe80::1" ],
+				"locations": [ { "elementId": "of:02", "port": "7" } ] } ] }` ) )
+
+		default:
+			http.NotFound( w, r )
+	}
+}
+
+func (si *stand_in) get_posts( ) ( []string ) {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	return append( []string{ }, si.posts... )
+}
+
+func Test_sdnc_floodlight( t *testing.T ) {
+	fmt.Fprintf( os.Stderr, "\n----------- sdn controller tests --------------\n" )
+
+	si := &stand_in{ }
+	srv := httptest.NewServer( si )
+	defer srv.Close()
+
+	sdnc, err := Mk_sdn_ctlr( "floodlight", strings.TrimPrefix( srv.URL, "http://" ), "", "" )
+	if err != nil {
+		t.Fatal( err )
+	}
+
+	links, err := sdnc.Get_links()
+	if err != nil || len( links ) != 1 || links[0].Src_switch != "00:01" || links[0].Dst_port != 2 {
+		fmt.Fprintf( os.Stderr, "FAIL:   floodlight links: err=%v %v\n", err, links )
+		t.Fail()
+	}
+
+	hosts, err := sdnc.Get_hosts()
+	if err != nil || len( hosts ) != 1 || hosts[0].Ipv4[0] != "10.0.0.1" || hosts[0].AttachmentPoint[0].Port != 5 {
+		fmt.Fprintf( os.Stderr, "FAIL:   floodlight hosts: err=%v %v\n", err, hosts )
+		t.Fail()
+	}
+
+	sdnc.Set_queues( []string{ "q1" } )
+	sdnc.Install_flow( &Flow{ Src: "10.0.0.1", Dst: "10.0.0.2", Expiry: 100, Queue: 2 } )
+	sdnc.Install_flow( &Flow{ Src: "10.0.0.1", Dst: "10.0.0.2", Expiry: 100, Queue: 2, Swid: "00:01", Port: 5 } )
+
+	posts := si.get_posts()
+	if len( posts ) != 3 ||
+		! strings.Contains( posts[0], `"qdata": ["q1" ]` ) ||
+		! strings.Contains( posts[1], "action=phostadd&host1=10.0.0.1&host2=10.0.0.2" ) ||
+		! strings.Contains( posts[2], "action=iefmadd" ) || ! strings.Contains( posts[2], "swid=0001&port=5" ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   floodlight pushes: %v\n", posts )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     floodlight controller: %d links, %d hosts, %d pushes\n", len( links ), len( hosts ), len( posts ) )
+	}
+}
+
+func Test_sdnc_rest( t *testing.T ) {
+	si := &stand_in{ }
+	srv := httptest.NewServer( si )
+	defer srv.Close()
+
+	if _, err := Mk_sdn_ctlr( "bogus", "localhost:8181", "", "" ); err == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   unknown controller type was accepted\n" )
+		t.Fail()
+	}
+
+	sdnc, err := Mk_sdn_ctlr( "onos", srv.URL, "onos", "rocks" )
+	if err != nil {
+		t.Fatal( err )
+	}
+
+	links, err := sdnc.Get_links()				// inactive link and the one on the LOCAL port are dropped
+	if err != nil || len( links ) != 2 || links[0].Src_switch != "of:01" || links[0].Src_port != 1 || links[1].Dst_port != 1 {
+		fmt.Fprintf( os.Stderr, "FAIL:   rest links: err=%v %v\n", err, links )
+		t.Fail()
+	}
+
+	hosts, err := sdnc.Get_hosts()
+	if err != nil || len( hosts ) != 1 || hosts[0].Mac[0] != "fa:16:3e:00:00:02" || len( hosts[0].Ipv4 ) != 1 || len( hosts[0].Ipv6 ) != 1 ||
+		hosts[0].AttachmentPoint[0].SwitchDPID != "of:02" || hosts[0].AttachmentPoint[0].Port != 7 {
+		fmt.Fprintf( os.Stderr, "FAIL:   rest hosts: err=%v %v\n", err, hosts )
+		t.Fail()
+	}
+
+	if sdnc.Set_queues( []string{ "q1" } ) == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   rest controller accepted a set queues request\n" )
+		t.Fail()
+	}
+	if sdnc.Install_flow( &Flow{ Src: "10.0.0.1", Dst: "10.0.0.2", Expiry: time.Now().Unix() + 60, Queue: 2 } ) == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   rest controller accepted a flow without a switch\n" )
+		t.Fail()
+	}
+
+	err = sdnc.Install_flow( &Flow{ Src: "10.0.0.1", Dst: "10.0.0.2", Expiry: time.Now().Unix() + 60, Queue: 2, Swid: "of:01", Port: 1 } )
+	posts := si.get_posts()
+	if err != nil || len( posts ) != 1 || ! strings.HasPrefix( posts[0], "/onos/v1/flows/of:01" ) ||
+		! strings.Contains( posts[0], `"queueId": 2` ) || ! strings.Contains( posts[0], `"ip": "10.0.0.2/32"` ) || si.auth != "onos:rocks" {
+		fmt.Fprintf( os.Stderr, "FAIL:   rest flow install: err=%v auth=%s %v\n", err, si.auth, posts )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     rest controller: %d links, %d hosts, flow pushed: %s\n", len( links ), len( hosts ), posts[0] )
+	}
+}
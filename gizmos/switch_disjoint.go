@@ -0,0 +1,247 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	switch_disjoint
+	Abstract:	Path finding which returns a pair of disjoint paths between a switch and the
+				switch that houses a target host (protected reservations).  The pair with the
+				lowest total cost is found in the manner of Suurballe: the graph reachable from
+				the starting switch is turned into a flow network where each link can carry one
+				path, and each switch either one (switch disjoint) or two (link disjoint) paths.
+				Two shortest augmenting paths are found (Bellman-Ford as the residual graph has
+				negative costs) and the resulting flow is decomposed into the two paths. Links
+				which cannot support the requested capacity are left out of the graph.
+
+				A physical link is represented by a link in each direction. The two paths never
+				use both directions of a physical link because doing so is never cheaper than
+				using neither.
+
+	Date:		17 October 2026
+
+*/
+
+package gizmos
+
+import (
+	"fmt"
+)
+
+const (
+	dj_big int = 2147483647
+)
+
+/*
+	An arc in the flow network. Rev is the index of the paired arc in the adjacency list
+	of the 'to' node; link is nil for arcs which don't represent a network link.
+*/
+type dj_arc struct {
+	to		int
+	rev		int
+	cap		int
+	cost	int
+	flow	int
+	link	*Link
+}
+
+type dj_graph struct {
+	adj		[][]*dj_arc
+}
+
+func ( g *dj_graph ) add_arc( from int, to int, cap int, cost int, link *Link ) {
+	a := &dj_arc{ to: to, rev: len( g.adj[to] ), cap: cap, cost: cost, link: link }
+	b := &dj_arc{ to: from, rev: len( g.adj[from] ), cap: 0, cost: -cost }
+	g.adj[from] = append( g.adj[from], a )
+	g.adj[to] = append( g.adj[to], b )
+}
+
+/*
+	Find the least cost path from src to dst in the residual graph and push one unit of
+	flow along it. Returns false if there is no path.
+*/
+func ( g *dj_graph ) augment( src int, dst int ) ( bool ) {
+	n := len( g.adj )
+	dist := make( []int, n )
+	prev := make( []*dj_arc, n )			// arc used to reach the node (from the node's point of view, the reverse arc)
+	inq := make( []bool, n )
+	for i := range dist {
+		dist[i] = dj_big
+	}
+
+	dist[src] = 0
+	q := []int{ src }
+	inq[src] = true
+	for len( q ) > 0 {							// queue based Bellman-Ford
+		u := q[0]
+		q = q[1:]
+		inq[u] = false
+		for _, a := range g.adj[u] {
+			if a.cap - a.flow > 0 && dist[u] + a.cost < dist[a.to] {
+				dist[a.to] = dist[u] + a.cost
+				prev[a.to] = g.adj[a.to][a.rev]
+				if ! inq[a.to] {
+					q = append( q, a.to )
+					inq[a.to] = true
+				}
+			}
+		}
+	}
+
+	if dist[dst] == dj_big {
+		return false
+	}
+
+	for v := dst; v != src; {
+		back := prev[v]									// arc from v back to its predecessor
+		fwd := g.adj[back.to][back.rev]
+		fwd.flow++
+		back.flow--
+		v = back.to
+	}
+
+	return true
+}
+
+/*
+	Follow arcs carrying flow from src to dst, consuming the flow, and return the links used
+	and the last switch reached before dst.
+*/
+func ( g *dj_graph ) take_path( src int, dst int, nodes []*Switch ) ( links []*Link, ep *Switch ) {
+	for u := src; u != dst; {
+		moved := false
+		for _, a := range g.adj[u] {
+			if a.flow > 0 {
+				a.flow--
+				if a.link != nil {
+					links = append( links, a.link )
+				}
+				if a.to == dst {
+					ep = nodes[u / 2]
+				}
+				u = a.to
+				moved = true
+				break
+			}
+		}
+		if ! moved {
+			return nil, nil
+		}
+	}
+
+	return
+}
+
+/*
+	Starting at switch s find two paths to the switch (or switches) which house the target host
+	(or are the target switch) which do not share a link, or if by_switch is true, do not share
+	any switch other than the first and last. Only links that can support the additional capacity
+	(inc_cap) over the window are considered; usr and usr_max are as described for Path_to().
+
+	The links of each path are returned in order from s, along with the switch at the far end of
+	each path. The first path is the shorter (or equal) of the two. Cap_trip is set if one or more
+	links were not considered because of capacity.  If two disjoint paths cannot be found an error
+	is returned.
+*/
+func (s *Switch) Disjoint_paths_to( target *string, commence int64, conclude int64, inc_cap int64, usr *string, usr_max int64, by_switch bool ) ( paths [][]*Link, eps []*Switch, cap_trip bool, err error ) {
+	if s == nil || target == nil {
+		return nil, nil, false, fmt.Errorf( "no starting switch or target" )
+	}
+
+	idx := make( map[*Switch]int )					// collect the switches reachable from s
+	nodes := []*Switch{ s }
+	idx[s] = 0
+	for i := 0; i < len( nodes ); i++ {
+		sw := nodes[i]
+		for j := 0; j < sw.lidx; j++ {
+			fsw := sw.links[j].Get_forward_sw()
+			if fsw == nil {
+				continue
+			}
+			if _, ok := idx[fsw]; ! ok {
+				idx[fsw] = len( nodes )
+				nodes = append( nodes, fsw )
+			}
+		}
+	}
+
+	sink := len( nodes ) * 2						// each switch is an in (2i) and out (2i+1) node
+	g := &dj_graph{ adj: make( [][]*dj_arc, sink + 1 ) }
+	ntargets := 0
+	for i, sw := range nodes {
+		is_target := sw.Has_host( target ) || *sw.Get_id() == *target
+		through := 2
+		if by_switch && sw != s && ! is_target {
+			through = 1
+		}
+		g.add_arc( 2*i, 2*i + 1, through, 0, nil )
+
+		if is_target {
+			g.add_arc( 2*i + 1, sink, 2, 0, nil )
+			ntargets++
+		}
+
+		for j := 0; j < sw.lidx; j++ {
+			l := sw.links[j]
+			fsw := l.Get_forward_sw()
+			if fsw == nil || fsw == sw {
+				continue
+			}
+			if has_room, lerr := l.Has_capacity( commence, conclude, inc_cap, usr, usr_max ); ! has_room {
+				obj_sheep.Baa( 2, "switch/disjoint: no capacity on link: %s", lerr )
+				cap_trip = true
+				continue
+			}
+
+			cost := l.Cost
+			if cost < 1 {
+				cost = 1
+			}
+			g.add_arc( 2*i + 1, 2 * idx[fsw], 1, cost, l )
+		}
+	}
+
+	if ntargets == 0 {
+		return nil, nil, cap_trip, fmt.Errorf( "target not reachable from %s", *s.Get_id() )
+	}
+
+	if ! g.augment( 0, sink ) {
+		return nil, nil, cap_trip, fmt.Errorf( "no path to target" )
+	}
+	if ! g.augment( 0, sink ) {
+		return nil, nil, cap_trip, fmt.Errorf( "no disjoint pair of paths to target" )
+	}
+
+	for k := 0; k < 2; k++ {
+		links, ep := g.take_path( 0, sink, nodes )
+		if ep == nil {
+			return nil, nil, cap_trip, fmt.Errorf( "internal mishap: unable to decompose disjoint paths" )
+		}
+		paths = append( paths, links )
+		eps = append( eps, ep )
+	}
+
+	if len( paths[1] ) < len( paths[0] ) {			// shorter path first; it's the primary
+		paths[0], paths[1] = paths[1], paths[0]
+		eps[0], eps[1] = eps[1], eps[0]
+	}
+
+	obj_sheep.Baa( 2, "switch/disjoint: found disjoint paths from %s with %d and %d links", *s.Get_id(), len( paths[0] ), len( paths[1] ) )
+	return
+}
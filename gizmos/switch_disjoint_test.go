@@ -0,0 +1,141 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	switch_disjoint_test
+	Abstract:	Tests for the disjoint path pair finder.
+	Date:		17 October 2026
+
+*/
+
+package gizmos
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+/*
+	Build a graph from a list of switch pairs; each pair is connected in both directions
+	with the given capacity. The host is attached to the switch named by hsw.
+*/
+func mk_dj_graph( pairs [][2]string, capacity int64, hsw string, host string ) ( map[string]*Switch ) {
+	sws := make( map[string]*Switch )
+	get := func( name string ) ( *Switch ) {
+		if sws[name] == nil {
+			n := name
+			sws[name] = Mk_switch( &n )
+		}
+		return sws[name]
+	}
+
+	for _, pr := range pairs {
+		a := get( pr[0] )
+		b := get( pr[1] )
+
+		l := Mk_link( a.Get_id(), b.Get_id(), capacity, 0, nil )
+		l.Set_forward( b )
+		l.Set_backward( a )
+		a.Add_link( l )
+
+		l = Mk_link( b.Get_id(), a.Get_id(), capacity, 0, nil )
+		l.Set_forward( a )
+		l.Set_backward( b )
+		b.Add_link( l )
+	}
+
+	vmid := "vm"
+	get( hsw ).Add_host( &host, &vmid, 1 )
+	return sws
+}
+
+/*
+	Return the switch names along the path (excluding the start) as a string.
+*/
+func dj_path2str( links []*Link ) ( string ) {
+	s := ""
+	for _, l := range links {
+		s += *l.Get_forward_sw().Get_id() + " "
+	}
+	return s
+}
+
+func Test_disjoint( t *testing.T ) {
+	failures := 0
+	host := "fa:16:3e:00:00:99"
+	now := time.Now().Unix()
+
+	fmt.Fprintf( os.Stderr, "\n----------- disjoint path tests --------------\n" )
+
+	// diamond with a cross link; shortest path is a-b-d, the pair must use a-c-d for the second path
+	sws := mk_dj_graph( [][2]string{ { "a", "b" }, { "a", "c" }, { "b", "d" }, { "c", "d" }, { "b", "c" } }, 1000, "d", host )
+	for _, by_sw := range []bool{ false, true } {
+		paths, eps, _, err := sws["a"].Disjoint_paths_to( &host, now, now + 600, 100, nil, 100, by_sw )
+		if err != nil {
+			failures++
+			fmt.Fprintf( os.Stderr, "FAIL:   diamond by_switch=%v: %s\n", by_sw, err )
+			continue
+		}
+		used := make( map[string]bool )
+		for k := range paths {
+			if *eps[k].Get_id() != "d" || len( paths[k] ) != 2 {
+				failures++
+				fmt.Fprintf( os.Stderr, "FAIL:   diamond by_switch=%v: unexpected path %d: %s\n", by_sw, k, dj_path2str( paths[k] ) )
+			}
+			for _, l := range paths[k] {
+				if used[*l.Get_id()] {
+					failures++
+					fmt.Fprintf( os.Stderr, "FAIL:   diamond by_switch=%v: link used twice: %s\n", by_sw, *l.Get_id() )
+				}
+				used[*l.Get_id()] = true
+			}
+		}
+	}
+
+	// bowtie: every path goes through m, so a link disjoint pair exists but a switch disjoint pair does not
+	bowtie := [][2]string{ { "a", "b" }, { "a", "c" }, { "b", "m" }, { "c", "m" }, { "m", "e" }, { "m", "f" }, { "e", "d" }, { "f", "d" } }
+	sws = mk_dj_graph( bowtie, 1000, "d", host )
+	if paths, _, _, err := sws["a"].Disjoint_paths_to( &host, now, now + 600, 100, nil, 100, false ); err != nil {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   bowtie link disjoint: %s\n", err )
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     bowtie link disjoint: [%s] [%s]\n", dj_path2str( paths[0] ), dj_path2str( paths[1] ) )
+	}
+	if _, _, _, err := sws["a"].Disjoint_paths_to( &host, now, now + 600, 100, nil, 100, true ); err == nil {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   bowtie switch disjoint: expected an error\n" )
+	}
+
+	// links without enough capacity are not used; the diamond cannot provide a pair needing 2000
+	sws = mk_dj_graph( [][2]string{ { "a", "b" }, { "a", "c" }, { "b", "d" }, { "c", "d" } }, 1000, "d", host )
+	if _, _, cap_trip, err := sws["a"].Disjoint_paths_to( &host, now, now + 600, 2000, nil, 100, false ); err == nil || ! cap_trip {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   expected capacity failure, got err=%v cap_trip=%v\n", err, cap_trip )
+	}
+
+	if failures == 0 {
+		fmt.Fprintf( os.Stderr, "OK:     all disjoint path tests pass\n" )
+	} else {
+		t.Fail()
+	}
+}
@@ -47,6 +47,9 @@
 					greater than zero.
 				18 Jun 2015 - Allow a queue to be added only if the amount is positive.
 				22 Jun 2015 - Added check for nil qid pointer on add.
+				17 Oct 2026 - Support for an open-ended (unbounded) final slice.
+				17 Oct 2026 - Overlaps() now recognises a slice that is wholly inside of the window.
+					Split() on the final second of a slice now splits rather than doing nothing.
 */
 
 package gizmos
@@ -114,11 +117,15 @@ func (ts *Time_slice) Nuke( ) {
 	200 is requested, the existing block will span 100 to 199, and the inserted
 	block will span 200 to 500.
 
-	If the split point is exactly equal to the start/end timestamp for to, then
-	no action is taken and only ts1 is returned (ts2 will be nil).
+	If the split point is exactly equal to the start timestamp, then no action
+	is taken and only ts1 is returned (ts2 will be nil). A split point equal to
+	the end timestamp results in a one second slice being inserted.
 
 	If the split point is not inside of the timeslice referenced then two
 	nil pointers are returned.
+
+	Splitting an unbounded slice leaves the inserted block unbounded, so the
+	tail of the list is always open-ended and grows only as it is split.
 */
 func (ts *Time_slice) Split( split_pt int64 ) ( ts1, ts2 *Time_slice ) {
 	ts1 = nil
@@ -129,7 +136,7 @@ func (ts *Time_slice) Split( split_pt int64 ) ( ts1, ts2 *Time_slice ) {
 	}
 
 	ts1 = ts
-	if ts.commence == split_pt  || ts.conclude == split_pt {
+	if ts.commence == split_pt {
 		return;	
 	}
 
@@ -162,14 +169,22 @@ func (ts *Time_slice) Split( split_pt int64 ) ( ts1, ts2 *Time_slice ) {
 /*
 	change the concluding time. we will vet it to ensure that it's not
 	before the commence time and is the last block as that is the only
-	one allowed to have it's time extended.
+	one allowed to have it's time extended. An unbounded slice is never
+	shortened.
 */
 func (ts *Time_slice) Extend( timestamp int64 ) {
-	if( timestamp > ts.commence && ts.Next == nil ) {
+	if( timestamp > ts.commence && ts.Next == nil && ! ts.Is_unbounded() ) {
 		ts.conclude = timestamp
 	}
 }
 
+/*
+	Returns true if the slice has no concluding time (it is the open-ended tail).
+*/
+func (ts *Time_slice) Is_unbounded( ) ( bool ) {
+	return ts.conclude == TS_INFINITE
+}
+
 /*
 	Check a timestamp against this timeslice true if the timestamp is contained within the timeslice.
 */
@@ -193,10 +208,11 @@ func (ts *Time_slice) Is_after( timestamp int64 ) (  bool ) {
 }
 
 /*
-	Return true if the time window passed in overlaps with this slice.
+	Return true if the time window passed in overlaps with this slice. This includes
+	the case where the slice lies completely inside of the window.
 */
 func (ts *Time_slice) Overlaps( wstart int64, wend int64 ) ( bool ) {
-	return ts.Includes( wstart ) || ts.Includes( wend )	
+	return ts.commence <= wend && ts.conclude >= wstart
 }
 
 /*
@@ -307,6 +323,10 @@ func (ts *Time_slice) Inc_usr(	usr *Fence, amt int64, cap int64  ) {
 
 func (ts *Time_slice) To_str( ) ( string ) {
 	s := time.Unix( ts.commence, 0 )
+	if ts.Is_unbounded( ) {
+		return fmt.Sprintf( "from %s onward: %d", s.Format( time.RFC822Z ), ts.Amt )
+	}
+
 	e := time.Unix( ts.conclude, 0 )
 	return fmt.Sprintf( "from %s to %s: %d", s.Format( time.RFC822Z ), e.Format( time.RFC822Z ), ts.Amt )
 }
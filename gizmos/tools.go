@@ -41,6 +41,7 @@
 					passed in.
 				27 May 2015 - Added Split_hpv().
 				26 Aug 2015 - Added IsMAC(), IsUUID(), IsIPv4()
+				17 Oct 2026 - Str2start_end() accepts "unbounded" as the end time.
 */
 
 package gizmos
@@ -65,6 +66,18 @@ import (
 )
 
 
+/*
+	Convert an expiry token to a timestamp. The token is either a number, which is
+	added to base, or the string "unbounded" which yields TS_INFINITE.
+*/
+func str2expiry( tok string, base int64 ) ( int64 ) {
+	if tok == "unbounded" {
+		return TS_INFINITE
+	}
+
+	return base + clike.Atoll( tok )
+}
+
 /*
 	Split a string into a start/end UNIX time stamp assuming the string is in one of the
 	following formats:
@@ -72,6 +85,9 @@ import (
 		timestamp	start == now	end == timestamp
 		ts1-ts2		start == ts1	end == ts2  (start may be adjusted to now if old)
 
+	In any format the end time may be given as "unbounded" (e.g. +unbounded or ts1-unbounded)
+	which sets the end to TS_INFINITE.
+
 	If the end time value is before the start time value it is set to the start time value.
 */
 func Str2start_end( tok string ) ( startt int64, endt int64 ) {
@@ -79,19 +95,19 @@ func Str2start_end( tok string ) ( startt int64, endt int64 ) {
 
 	if tok[0:1] == "+"	{
 		startt = now
-		endt  = startt + clike.Atoll( tok )
+		endt  = str2expiry( tok[1:], startt )
 	} else {
 		idx := strings.Index( tok, "-" )			// separate start-end times
 		if idx > 0 {
 			startt = clike.Atoll( tok[0:idx] )
-			endt = clike.Atoll( tok[idx+1:] )
-
 			if startt < now {
 				startt = now
 			}
+
+			endt = str2expiry( tok[idx+1:], 0 )
 		} else {
 			startt = now
-			endt = clike.Atoll( tok )
+			endt = str2expiry( tok, 0 )
 		}
 	}
 
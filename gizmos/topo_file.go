@@ -0,0 +1,372 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	topo_file
+	Abstract:	Manages the static physical topology file used by tegu-lite. The file is
+				watched (its modification time and size are checked when Check() is invoked)
+				and, when it changes, it is read and validated before it is accepted. If the
+				new contents are not valid the last good set of links is kept and the errors
+				are available from the validation result.
+
+				Two formats are accepted. The original is a json array of links in floodlight
+				syntax (FL_link_json). The richer format is a json object:
+					{
+						"switches": [ { "id": "s1", "ports": [ 1, 2, 3 ] }, ... ],
+						"links": [ { "src": "s1", "src_port": 1, "dst": "s2", "dst_port": 4,
+									 "capacity": "10G", "mlag": "m1", "bidirectional": true }, ... ]
+					}
+				The switches list is optional; when a switch is listed the ports used by its
+				links must be in its port list. Capacity may be a number or a string with a
+				K, M or G suffix; links without a capacity get the configured default.
+
+				Validation rejects: duplicate links (the same source and destination switch
+				given more than once, including as the reverse of a bidirectional link),
+				ports which are not positive (other than -128, late binding) or not declared
+				for the switch, and capacities
+				which differ between the two directions of a link.
+
+	Date:		17 October 2026
+
+*/
+
+package gizmos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/att/gopkgs/clike"
+)
+
+/*
+	A link in the richer topology format.
+*/
+type Topo_link_json struct {
+	Src				string
+	Src_port		int
+	Dst				string
+	Dst_port		int
+	Capacity		interface{}			// number, or string with a K/M/G suffix
+	Mlag			*string
+	Bidirectional	bool
+}
+
+type Topo_switch_json struct {
+	Id		string
+	Ports	[]int
+}
+
+type Topo_json struct {
+	Switches	[]Topo_switch_json
+	Links		[]Topo_link_json
+}
+
+/*
+	The result of validating the topology file.
+*/
+type Topo_check struct {
+	fname		string
+	checked		int64				// time the file was last read
+	accepted	bool				// true if the contents were accepted
+	nlinks		int					// number of links in the file (before bidirectional expansion)
+	errors		[]string
+}
+
+/*
+	Tracks the topology file and the last good set of links read from it.
+*/
+type Topo_file struct {
+	fname		string
+	mtime		time.Time			// modification time and size when last read
+	size		int64
+	good		[]FL_link_json		// last links which passed validation
+	loaded		int64				// time good was loaded
+	result		*Topo_check			// result of the last validation
+}
+
+/*
+	Create a topology file manager. The file is not read until Check() is invoked.
+*/
+func Mk_topo_file( fname string ) ( *Topo_file ) {
+	return &Topo_file {
+		fname:	fname,
+	}
+}
+
+/*
+	Convert a capacity value from the richer format to an integer. Returns 0 if no value.
+*/
+func topo_capacity( v interface{} ) ( c int64, err error ) {
+	switch cv := v.( type ) {
+		case nil:
+			return 0, nil
+
+		case float64:
+			c = int64( cv )
+
+		case string:
+			c = int64( clike.Atof( cv ) )
+
+		default:
+			return 0, fmt.Errorf( "capacity is not a number or string: %v", v )
+	}
+
+	if c < 0 {
+		err = fmt.Errorf( "capacity is negative: %v", v )
+	}
+	return
+}
+
+/*
+	Parse the file contents in either format. The richer format is converted to floodlight
+	links. Switch port lists (richer format only) are returned in ports.
+*/
+func Parse_topo( buf []byte ) ( links []FL_link_json, ports map[string]map[int]bool, err error ) {
+	buf = bytes.TrimSpace( buf )
+	if len( buf ) == 0 {
+		return nil, nil, fmt.Errorf( "topology is empty" )
+	}
+
+	if buf[0] == '[' {
+		links = make( []FL_link_json, 0 )
+		err = json.Unmarshal( buf, &links )
+		return
+	}
+
+	tj := &Topo_json{ }
+	if err = json.Unmarshal( buf, tj ); err != nil {
+		return
+	}
+
+	ports = make( map[string]map[int]bool )
+	for _, sw := range tj.Switches {
+		if ports[sw.Id] == nil {
+			ports[sw.Id] = make( map[int]bool )
+		}
+		for _, p := range sw.Ports {
+			ports[sw.Id][p] = true
+		}
+	}
+
+	links = make( []FL_link_json, 0, len( tj.Links ) )
+	for i, tl := range tj.Links {
+		c, cerr := topo_capacity( tl.Capacity )
+		if cerr != nil {
+			return nil, nil, fmt.Errorf( "link %d (%s-%s): %s", i, tl.Src, tl.Dst, cerr )
+		}
+
+		dir := "unidirectional"
+		if tl.Bidirectional {
+			dir = "bidirectional"
+		}
+		links = append( links, FL_link_json {
+			Src_switch:	tl.Src,
+			Src_port:	tl.Src_port,
+			Dst_switch:	tl.Dst,
+			Dst_port:	tl.Dst_port,
+			Type:		"internal",
+			Direction:	dir,
+			Capacity:	c,
+			Mlag:		tl.Mlag,
+		} )
+	}
+
+	return
+}
+
+/*
+	Validate the links; a list of problems is returned (empty if the links are good).
+	Ports are checked against the switch port lists in ports, which may be nil.
+*/
+func Validate_topo( links []FL_link_json, ports map[string]map[int]bool ) ( errs []string ) {
+	seen := make( map[[2]string]int )		// src/dst -> index of the entry which defined it
+	caps := make( map[[2]string]int64 )		// capacity by src/dst
+
+	swid := func( s string ) ( string ) {		// switch without the @interface
+		return strings.SplitN( s, "@", 2 )[0]
+	}
+	chk_port := func( i int, sw string, port int ) {
+		if port == -128 {										// late binding
+			return
+		}
+		if port <= 0 {
+			errs = append( errs, fmt.Sprintf( "link %d: port %d on switch %s is not valid", i, port, sw ) )
+			return
+		}
+		if plist := ports[swid( sw )]; plist != nil && ! plist[port] {
+			errs = append( errs, fmt.Sprintf( "link %d: port %d is not a known port on switch %s", i, port, sw ) )
+		}
+	}
+	add := func( i int, src string, dst string, capacity int64 ) {
+		key := [2]string{ src, dst }
+		if j, ok := seen[key]; ok {
+			errs = append( errs, fmt.Sprintf( "link %d: duplicate link %s-%s (first defined by link %d)", i, src, dst, j ) )
+			return
+		}
+		seen[key] = i
+		caps[key] = capacity
+	}
+
+	for i, l := range links {
+		if l.Src_switch == "" || l.Dst_switch == "" {
+			errs = append( errs, fmt.Sprintf( "link %d: source or destination switch missing", i ) )
+			continue
+		}
+		if swid( l.Src_switch ) == swid( l.Dst_switch ) {
+			errs = append( errs, fmt.Sprintf( "link %d: source and destination are the same switch: %s", i, l.Src_switch ) )
+			continue
+		}
+		if l.Capacity < 0 {
+			errs = append( errs, fmt.Sprintf( "link %d: capacity is negative: %d", i, l.Capacity ) )
+		}
+
+		chk_port( i, l.Src_switch, l.Src_port )
+		chk_port( i, l.Dst_switch, l.Dst_port )
+
+		add( i, l.Src_switch, l.Dst_switch, l.Capacity )
+		if l.Direction == "bidirectional" {
+			add( i, l.Dst_switch, l.Src_switch, l.Capacity )
+		}
+	}
+
+	for i, l := range links {								// explicit links in each direction must agree
+		if l.Direction == "bidirectional" {
+			continue
+		}
+		key := [2]string{ l.Src_switch, l.Dst_switch }
+		rkey := [2]string{ l.Dst_switch, l.Src_switch }
+		if rc, ok := caps[rkey]; ok && seen[key] == i && seen[rkey] > i && rc != l.Capacity {
+			errs = append( errs, fmt.Sprintf( "link %d: asymmetric capacity: %s-%s is %d but %s-%s is %d", i, l.Src_switch, l.Dst_switch, l.Capacity, l.Dst_switch, l.Src_switch, rc ) )
+		}
+	}
+
+	return
+}
+
+/*
+	Check the file and, if it has changed since it was last read, read and validate it. The
+	return value is true if new links were accepted (the caller should rebuild the graph).
+	When the file cannot be read or is not valid the last good links are kept; the caller
+	can tell that the file was checked, but rejected, because checked is true.
+*/
+func (tf *Topo_file) Check( ) ( changed bool, checked bool ) {
+	if tf == nil {
+		return false, false
+	}
+
+	fi, err := os.Stat( tf.fname )
+	if err == nil && tf.result != nil && fi.ModTime().Equal( tf.mtime ) && fi.Size() == tf.size {
+		return false, false
+	}
+
+	checked = true
+	tc := &Topo_check {
+		fname:		tf.fname,
+		checked:	time.Now().Unix(),
+	}
+
+	if err != nil {
+		if tf.result != nil && len( tf.result.errors ) == 1 && tf.result.errors[0] == err.Error() {
+			return false, false							// same failure as last time
+		}
+		tc.errors = append( tc.errors, err.Error() )
+	} else {
+		tf.mtime = fi.ModTime()
+		tf.size = fi.Size()
+
+		var buf []byte
+		buf, err = ioutil.ReadFile( tf.fname )
+		if err == nil {
+			var links []FL_link_json
+			var ports map[string]map[int]bool
+			links, ports, err = Parse_topo( buf )
+			if err == nil {
+				tc.nlinks = len( links )
+				tc.errors = Validate_topo( links, ports )
+				if len( tc.errors ) == 0 {
+					tc.accepted = true
+					tf.good = links
+					tf.loaded = tc.checked
+					changed = true
+				}
+			}
+		}
+		if err != nil {
+			tc.errors = append( tc.errors, err.Error() )
+		}
+	}
+
+	tf.result = tc
+	return
+}
+
+/*
+	Return the last good set of links; nil if the file has never been valid.
+*/
+func (tf *Topo_file) Get_links( ) ( []FL_link_json ) {
+	if tf == nil {
+		return nil
+	}
+
+	return tf.good
+}
+
+/*
+	Return the result of the last validation; nil if the file has not been checked.
+*/
+func (tf *Topo_file) Get_result( ) ( *Topo_check ) {
+	return tf.result
+}
+
+func (tc *Topo_check) Is_accepted( ) ( bool ) {
+	return tc != nil && tc.accepted
+}
+
+func (tc *Topo_check) Get_errors( ) ( []string ) {
+	return tc.errors
+}
+
+/*
+	Generate json which describes the state of the topology file: the last validation and
+	the links in use.
+*/
+func (tf *Topo_file) To_json( ) ( string ) {
+	if tf == nil {
+		return `{ "file": null }`
+	}
+
+	rstr := "null"
+	if tc := tf.result; tc != nil {
+		elist := make( []string, len( tc.errors ) )
+		for i, e := range tc.errors {
+			elist[i] = fmt.Sprintf( "%q", e )
+		}
+		rstr = fmt.Sprintf( `{ "checked": %d, "accepted": %v, "links": %d, "errors": [ %s ] }`, tc.checked, tc.accepted, tc.nlinks, strings.Join( elist, ", " ) )
+	}
+
+	return fmt.Sprintf( `{ "file": %q, "in_use": { "loaded": %d, "links": %d }, "last_check": %s }`, tf.fname, tf.loaded, len( tf.good ), rstr )
+}
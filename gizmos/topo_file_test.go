@@ -0,0 +1,126 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	topo_file_test
+	Abstract:	Tests for the static topology file parsing, validation and reload.
+	Date:		17 October 2026
+
+*/
+
+package gizmos
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_topo_validate( t *testing.T ) {
+	failures := 0
+
+	fmt.Fprintf( os.Stderr, "\n----------- topology file tests --------------\n" )
+
+	good := `{ "switches": [ { "id": "s1", "ports": [ 1, 2 ] } ],
+		"links": [ { "src": "s1", "src_port": 1, "dst": "s2", "dst_port": 3, "capacity": 1000, "mlag": "m1", "bidirectional": true },
+				   { "src": "s1", "src_port": 2, "dst": "s3", "dst_port": 1, "capacity": "500" },
+				   { "src": "s3", "src_port": 1, "dst": "s1", "dst_port": 2, "capacity": 500 } ] }`
+	links, ports, err := Parse_topo( []byte( good ) )
+	if err != nil || len( links ) != 3 || links[0].Direction != "bidirectional" || links[1].Capacity != 500 || *links[0].Mlag != "m1" {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   parse of object format: err=%v links=%v\n", err, links )
+	} else if errs := Validate_topo( links, ports ); len( errs ) != 0 {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   good topology was rejected: %v\n", errs )
+	}
+
+	legacy := `[ { "Src-switch": "a", "Src-port": 1, "Dst-switch": "b", "Dst-port": 2, "Direction": "bidirectional", "Capacity": 100 } ]`
+	if links, _, err = Parse_topo( []byte( legacy ) ); err != nil || len( links ) != 1 || links[0].Dst_port != 2 {
+		failures++
+		fmt.Fprintf( os.Stderr, "FAIL:   parse of floodlight format: err=%v links=%v\n", err, links )
+	}
+
+	bad := []struct {
+		name	string
+		topo	string
+	} {
+		{ "duplicate", `{ "links": [ { "src": "a", "src_port": 1, "dst": "b", "dst_port": 1, "bidirectional": true }, { "src": "b", "src_port": 1, "dst": "a", "dst_port": 1 } ] }` },
+		{ "unknown port", `{ "switches": [ { "id": "a", "ports": [ 1 ] } ], "links": [ { "src": "a", "src_port": 7, "dst": "b", "dst_port": 1 } ] }` },
+		{ "missing port", `{ "links": [ { "src": "a", "dst": "b", "dst_port": 1 } ] }` },
+		{ "asymmetric", `{ "links": [ { "src": "a", "src_port": 1, "dst": "b", "dst_port": 1, "capacity": 100 }, { "src": "b", "src_port": 1, "dst": "a", "dst_port": 1, "capacity": 200 } ] }` },
+	}
+	for _, b := range bad {
+		links, ports, err = Parse_topo( []byte( b.topo ) )
+		errs := Validate_topo( links, ports )
+		if err != nil || len( errs ) != 1 {
+			failures++
+			fmt.Fprintf( os.Stderr, "FAIL:   %s: expected one error, got err=%v errs=%v\n", b.name, err, errs )
+		} else {
+			fmt.Fprintf( os.Stderr, "OK:     %s: %s\n", b.name, errs[0] )
+		}
+	}
+
+	if failures == 0 {
+		fmt.Fprintf( os.Stderr, "OK:     all topology validation tests pass\n" )
+	} else {
+		t.Fail()
+	}
+}
+
+/*
+	Verify that a bad file does not replace the last good topology.
+*/
+func Test_topo_reload( t *testing.T ) {
+	f, err := ioutil.TempFile( "", "topo" )
+	if err != nil {
+		t.Fatal( err )
+	}
+	fname := f.Name()
+	f.Close()
+	defer os.Remove( fname )
+
+	write := func( s string, age int ) {
+		ioutil.WriteFile( fname, []byte( s ), 0644 )
+		mt := time.Now().Add( time.Duration( -age ) * time.Second )		// force a different mod time for each write
+		os.Chtimes( fname, mt, mt )
+	}
+
+	tf := Mk_topo_file( fname )
+	write( `[ { "Src-switch": "a", "Src-port": 1, "Dst-switch": "b", "Dst-port": 2, "Direction": "bidirectional" } ]`, 20 )
+	if changed, _ := tf.Check(); ! changed || len( tf.Get_links() ) != 1 {
+		fmt.Fprintf( os.Stderr, "FAIL:   initial topology was not accepted: %s\n", tf.To_json() )
+		t.Fail()
+	}
+	if changed, checked := tf.Check(); changed || checked {
+		fmt.Fprintf( os.Stderr, "FAIL:   unchanged file was checked again\n" )
+		t.Fail()
+	}
+
+	write( `[ { "Src-switch": "a", "Src-port": 1, "Dst-switch": "b" `, 10 )
+	if changed, checked := tf.Check(); changed || ! checked || tf.Get_result().Is_accepted() || len( tf.Get_links() ) != 1 {
+		fmt.Fprintf( os.Stderr, "FAIL:   bad topology was accepted, or last good was lost: %s\n", tf.To_json() )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     bad topology rejected, last good kept: %s\n", tf.To_json() )
+	}
+}
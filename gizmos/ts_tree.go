@@ -0,0 +1,299 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	ts_tree
+	Abstract:	A balanced search tree (treap) which indexes the time slices of an
+				obligation. The slices of an obligation never overlap and are
+				contiguous, so the tree is keyed on the commence time of each slice.
+				Each node is augmented with the largest amount allocated to any slice
+				in its subtree and with the time span that the subtree covers. This
+				allows the slice that contains a timestamp to be found, and the
+				maximum allocation across a time window to be computed, in log time
+				rather than by walking the list of slices from the head.
+
+				The tree only indexes the slices; the slices remain linked via their
+				next/prev pointers and the obligation is responsible for inserting new
+				slices into the tree when a slice is split.  Any change to the amount
+				allocated to a slice must be made via visit() so that the augmented
+				values are recomputed.
+
+	Date:		17 October 2026
+
+*/
+
+package gizmos
+
+import (
+	"math/rand"
+)
+
+type ts_node struct {
+	ts		*Time_slice
+	pri		uint32			// random priority which keeps the tree balanced
+	max		int64			// largest amount in any slice in this subtree
+	first	int64			// commence time of the leftmost slice in the subtree
+	last	int64			// conclude time of the rightmost slice in the subtree
+	left	*ts_node
+	right	*ts_node
+}
+
+type ts_tree struct {
+	root	*ts_node
+	count	int				// number of slices in the tree
+}
+
+// -----------------------------------------------------------------------------------------------------------
+
+/*
+	constructor
+*/
+func mk_ts_tree( ) ( t *ts_tree ) {
+	return &ts_tree { }
+}
+
+/*
+	Recompute the augmented values of the node from its children.
+*/
+func (n *ts_node) fix( ) {
+	n.max = n.ts.Amt
+	n.first = n.ts.commence
+	n.last = n.ts.conclude
+
+	if n.left != nil {
+		n.first = n.left.first
+		if n.left.max > n.max {
+			n.max = n.left.max
+		}
+	}
+
+	if n.right != nil {
+		n.last = n.right.last
+		if n.right.max > n.max {
+			n.max = n.right.max
+		}
+	}
+}
+
+func rotate_right( n *ts_node ) ( *ts_node ) {
+	l := n.left
+	n.left = l.right
+	n.fix()
+	l.right = n
+	l.fix()
+
+	return l
+}
+
+func rotate_left( n *ts_node ) ( *ts_node ) {
+	r := n.right
+	n.right = r.left
+	n.fix()
+	r.left = n
+	r.fix()
+
+	return r
+}
+
+/*
+	Insert the node into the subtree rooted at n, returning the new root of the subtree.
+*/
+func insert_node( n *ts_node, nn *ts_node ) ( *ts_node ) {
+	if n == nil {
+		return nn
+	}
+
+	if nn.ts.commence < n.ts.commence {
+		n.left = insert_node( n.left, nn )
+		if n.left.pri > n.pri {
+			return rotate_right( n )
+		}
+	} else {
+		n.right = insert_node( n.right, nn )
+		if n.right.pri > n.pri {
+			return rotate_left( n )
+		}
+	}
+
+	n.fix()
+	return n
+}
+
+/*
+	Splits the subtree at n into two trees: the first containing all slices which conclude
+	before the timestamp, and the second containing the remainder.
+*/
+func split_before( n *ts_node, timestamp int64 ) ( before *ts_node, after *ts_node ) {
+	if n == nil {
+		return nil, nil
+	}
+
+	if n.ts.Is_before( timestamp ) {				// this node, and all to the left, are before
+		before, after = split_before( n.right, timestamp )
+		n.right = before
+		n.fix()
+		return n, after
+	}
+
+	before, after = split_before( n.left, timestamp )
+	n.left = after
+	n.fix()
+	return before, n
+}
+
+/*
+	Count the nodes in the subtree.
+*/
+func (n *ts_node) size( ) ( int ) {
+	if n == nil {
+		return 0
+	}
+
+	return 1 + n.left.size() + n.right.size()
+}
+
+/*
+	Returns the largest amount allocated to a slice in the subtree that overlaps the window.
+	Found is false if no slice in the subtree overlaps the window.
+*/
+func (n *ts_node) max_in( wstart int64, wend int64 ) ( max int64, found bool ) {
+	if n == nil || n.last < wstart || n.first > wend {
+		return 0, false
+	}
+
+	if wstart <= n.first && n.last <= wend {		// subtree is completely inside of the window
+		return n.max, true
+	}
+
+	if n.ts.Overlaps( wstart, wend ) {
+		max = n.ts.Amt
+		found = true
+	}
+
+	if m, ok := n.left.max_in( wstart, wend ); ok && (!found || m > max) {
+		max = m
+		found = true
+	}
+
+	if m, ok := n.right.max_in( wstart, wend ); ok && (!found || m > max) {
+		max = m
+		found = true
+	}
+
+	return
+}
+
+/*
+	Invoke the function for each slice, in chronological order, that overlaps the window. Visiting
+	stops when the function returns false. Augmented values are recomputed for every node visited
+	so the function may change the amount allocated to the slice.
+*/
+func (n *ts_node) visit( wstart int64, wend int64, fn func( ts *Time_slice ) bool ) ( bool ) {
+	if n == nil || n.last < wstart || n.first > wend {
+		return true
+	}
+
+	ok := n.left.visit( wstart, wend, fn )
+	if ok && n.ts.Overlaps( wstart, wend ) {
+		ok = fn( n.ts )
+	}
+	if ok {
+		ok = n.right.visit( wstart, wend, fn )
+	}
+
+	n.fix()
+	return ok
+}
+
+// -----------------------------------------------------------------------------------------------------------
+
+/*
+	Add a slice to the tree.
+*/
+func (t *ts_tree) insert( ts *Time_slice ) {
+	if ts == nil {
+		return
+	}
+
+	nn := &ts_node {
+		ts:		ts,
+		pri:	rand.Uint32(),
+	}
+	nn.fix()
+
+	t.root = insert_node( t.root, nn )
+	t.count++
+}
+
+/*
+	Return the slice that contains the timestamp, or nil if no slice does.
+*/
+func (t *ts_tree) find( timestamp int64 ) ( *Time_slice ) {
+	n := t.root
+	for n != nil {
+		switch {
+			case timestamp < n.ts.commence:
+				n = n.left
+
+			case timestamp > n.ts.conclude:
+				n = n.right
+
+			default:
+				return n.ts
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns the largest amount allocated to any slice overlapping the window.  Found is false
+	if no slice overlaps the window.
+*/
+func (t *ts_tree) max_in( wstart int64, wend int64 ) ( max int64, found bool ) {
+	return t.root.max_in( wstart, wend )
+}
+
+/*
+	Invoke the function for each slice overlapping the window, in chronological order,
+	until the function returns false. Returns false if visiting was stopped by the function.
+*/
+func (t *ts_tree) visit( wstart int64, wend int64, fn func( ts *Time_slice ) bool ) ( bool ) {
+	return t.root.visit( wstart, wend, fn )
+}
+
+/*
+	Remove all slices that conclude before the timestamp. The slices themselves are not
+	altered; the caller is expected to unlink and nuke them.
+*/
+func (t *ts_tree) prune_before( timestamp int64 ) {
+	var gone *ts_node
+
+	gone, t.root = split_before( t.root, timestamp )
+	t.count -= gone.size()
+}
+
+/*
+	Return the number of slices in the tree.
+*/
+func (t *ts_tree) nslices( ) ( int ) {
+	return t.count
+}
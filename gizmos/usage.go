@@ -0,0 +1,129 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	usage
+	Abstract:	One step of an obligation's allocation over time: the amount allocated between
+				two timestamps, the capacity, and what each user (fence) has consumed. A list
+				of steps, built from the obligation's timeslices, describes how full a link is
+				over a time range.
+
+	Date:		17 October 2026
+
+*/
+
+package gizmos
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Usage struct {
+	commence	int64
+	conclude	int64
+	amt			int64				// allocated during the step
+	capacity	int64				// max capacity of the obligation
+	users		[]*Fence			// copies of the user fences, sorted by name
+}
+
+/*
+	Create a step from the timeslice; the window is clipped to commence/conclude.
+*/
+func mk_usage( ts *Time_slice, commence int64, conclude int64, capacity int64 ) ( u *Usage ) {
+	u = &Usage {
+		commence:	ts.commence,
+		conclude:	ts.conclude,
+		amt:		ts.Amt,
+		capacity:	capacity,
+	}
+
+	if u.commence < commence {
+		u.commence = commence
+	}
+	if u.conclude > conclude {
+		u.conclude = conclude
+	}
+
+	for _, f := range ts.limits {
+		u.users = append( u.users, f.Copy( f.Name ) )
+	}
+	sort.Slice( u.users, func( i, j int ) bool { return *u.users[i].Name < *u.users[j].Name } )
+
+	return
+}
+
+/*
+	Return the window, allocated amount and capacity of the step.
+*/
+func (u *Usage) Get_values( ) ( commence int64, conclude int64, amt int64, capacity int64 ) {
+	return u.commence, u.conclude, u.amt, u.capacity
+}
+
+/*
+	Return the user fences (copies) which have allocations during the step.
+*/
+func (u *Usage) Get_users( ) ( []*Fence ) {
+	return u.users
+}
+
+/*
+	Return the allocation as a percentage of capacity.
+*/
+func (u *Usage) Get_pctg( ) ( int64 ) {
+	if u.capacity <= 0 {
+		return 0
+	}
+
+	return (u.amt * 100) / u.capacity
+}
+
+func (u *Usage) To_json( ) ( string ) {
+	if u == nil {
+		return `{ }`
+	}
+
+	ulist := make( []string, len( u.users ) )
+	for i, f := range u.users {
+		ulist[i] = fmt.Sprintf( `{ "name": %q, "used": %d, "max": %d }`, *f.Name, f.Get_value(), f.Get_limit_max() )
+	}
+
+	return fmt.Sprintf( `{ "commence": %d, "conclude": %d, "allocated": %d, "max_capacity": %d, "pctg": %d, "users": [ %s ] }`,
+		u.commence, u.conclude, u.amt, u.capacity, u.Get_pctg(), strings.Join( ulist, ", " ) )
+}
+
+/*
+	Generate a comma separated record for the step; users are listed in the last field as
+	name:used pairs separated with semicolons.
+*/
+func (u *Usage) To_csv( ) ( string ) {
+	if u == nil {
+		return ""
+	}
+
+	ulist := make( []string, len( u.users ) )
+	for i, f := range u.users {
+		ulist[i] = fmt.Sprintf( "%s:%d", *f.Name, f.Get_value() )
+	}
+
+	return fmt.Sprintf( "%d,%d,%d,%d,%d,%s", u.commence, u.conclude, u.amt, u.capacity, u.Get_pctg(), strings.Join( ulist, ";" ) )
+}
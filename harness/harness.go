@@ -0,0 +1,371 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	harness
+	Abstract:	An in-process integration harness for tests. It starts the managers as main/tegu.go
+				does (http api, reservation, osif, network, agent, flow-queue managers and the reconciler) using
+				a generated config file which names:
+					- a static topology file (default:static_phys_graph)
+					- a static inventory (osif:inventory = static)
+					- ephemeral ports for the http api and the agent manager
+				A simulated agent (sim_agent.go) connects to the agent manager and records the
+				actions sent, so that a test can post reserve, steer and mirror requests and
+				then check the bw_fmod, bwow_fmod, setqueues and mirrorwiz actions which result.
+
+				The managers keep their state in package globals and cannot be stopped, so only
+				one harness may be started in a process; tests in a package should share it.
+
+	Date:		17 October 2026
+
+	Mods:		17 Oct 2026 - The topology is copied to the scratch directory so that tests can
+					change it (Set_topo).
+				17 Oct 2026 - Added V2() to send requests to the v2 api and return the response headers.
+*/
+
+package harness
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/att/gopkgs/ipc"
+	"github.com/att/tegu/managers"
+)
+
+var (
+	started	bool						// managers can only be started once per process
+	smu		sync.Mutex
+)
+
+type Harness struct {
+	Dir			string					// scratch directory with the config and checkpoint files
+	Topo		string					// copy of the topology file in Dir; see Set_topo()
+	Api_port	string
+	Agent_port	string
+	Agent		*Sim_agent
+
+	nw_ch		chan *ipc.Chmsg
+	rmgr_ch		chan *ipc.Chmsg
+	osif_ch		chan *ipc.Chmsg
+	fq_ch		chan *ipc.Chmsg
+	am_ch		chan *ipc.Chmsg
+	rc_ch		chan *ipc.Chmsg
+}
+
+/*
+	Find a port that is currently free on the loopback interface.
+*/
+func free_port( ) ( string, error ) {
+	l, err := net.Listen( "tcp", "127.0.0.1:0" )
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+
+	_, port, err := net.SplitHostPort( l.Addr().String() )
+	return port, err
+}
+
+/*
+	Wait for something to be listening on the port.
+*/
+func wait4port( port string, timeout time.Duration ) ( error ) {
+	limit := time.Now().Add( timeout )
+	for {
+		c, err := net.DialTimeout( "tcp", "127.0.0.1:" + port, time.Second )
+		if err == nil {
+			c.Close()
+			return nil
+		}
+		if time.Now().After( limit ) {
+			return fmt.Errorf( "nothing listening on port %s: %s", port, err )
+		}
+
+		time.Sleep( 100 * time.Millisecond )
+	}
+}
+
+/*
+	Start the managers using the topology and inventory files given. The mac2phost map is given
+	to the simulated agent and must include the mac of every VM and gateway in the inventory.
+	Cfg_extra is appended to the generated config file and can be used to add, or override,
+	settings (e.g. ":resmgr\n\thto_limit = 0").
+
+	This blocks until the network manager reports that it has what it needs to accept
+	reservations (as main does), or returns an error if that doesn't happen within the timeout.
+*/
+func Mk_harness( topo_fname string, inv_fname string, mac2phost map[string]string, cfg_extra string, timeout time.Duration ) ( h *Harness, err error ) {
+	smu.Lock()
+	defer smu.Unlock()
+
+	if started {
+		return nil, fmt.Errorf( "the managers have already been started in this process" )
+	}
+
+	h = &Harness{ }
+	if topo_fname, err = filepath.Abs( topo_fname ); err != nil {
+		return nil, err
+	}
+	if inv_fname, err = filepath.Abs( inv_fname ); err != nil {
+		return nil, err
+	}
+	if h.Api_port, err = free_port( ); err != nil {
+		return nil, err
+	}
+	if h.Agent_port, err = free_port( ); err != nil {
+		return nil, err
+	}
+	if h.Dir, err = ioutil.TempDir( "", "tegu_harness" ); err != nil {
+		return nil, err
+	}
+
+	tbuf, err := ioutil.ReadFile( topo_fname )
+	if err != nil {
+		return nil, err
+	}
+	h.Topo = filepath.Join( h.Dir, "topo.json" )
+	if err = ioutil.WriteFile( h.Topo, tbuf, 0644 ); err != nil {
+		return nil, err
+	}
+
+	cfg := fmt.Sprintf( `
+log_dir = stderr
+static_phys_graph = %q
+queue_type = "endpoint"
+pri_dscp = "40 41 42"
+
+:network
+	refresh = 15
+	topo_check = 0
+
+:osif
+	inventory = static
+	inventory_file = %q
+	usr = "tegu"
+
+:agent
+	port = %s
+	refresh = 2
+
+:resmgr
+	chkpt_dir = %q
+
+:httpmgr
+	priv_auth = none
+
+:mirror
+	enable = true
+%s
+`, h.Topo, inv_fname, h.Agent_port, h.Dir, cfg_extra )
+
+	cfg_fname := filepath.Join( h.Dir, "tegu.cfg" )
+	if err = ioutil.WriteFile( cfg_fname, []byte( cfg ), 0644 ); err != nil {
+		return nil, err
+	}
+
+	h.nw_ch = make( chan *ipc.Chmsg, 128 )
+	h.fq_ch = make( chan *ipc.Chmsg, 1024 )
+	h.am_ch = make( chan *ipc.Chmsg, 1024 )
+	h.rmgr_ch = make( chan *ipc.Chmsg, 1024 )
+	h.osif_ch = make( chan *ipc.Chmsg, 1024 )
+	h.rc_ch = make( chan *ipc.Chmsg, 128 )
+
+	version := "harness"
+	if err = managers.Initialise( &cfg_fname, &version, h.nw_ch, h.rmgr_ch, h.osif_ch, h.fq_ch, h.am_ch, h.rc_ch ); err != nil {
+		return nil, err
+	}
+	started = true
+
+	super_cookie := "harness"
+	go managers.Http_api( &h.Api_port, h.nw_ch, h.rmgr_ch )
+	go managers.Res_manager( h.rmgr_ch, &super_cookie )
+	go managers.Osif_mgr( h.osif_ch )
+	go managers.Network_mgr( h.nw_ch, &empty_str )
+	go managers.Agent_mgr( h.am_ch )
+	go managers.Fq_mgr( h.fq_ch, &empty_str )
+	go managers.Reconciler( h.rc_ch )
+
+	if err = wait4port( h.Agent_port, timeout ); err != nil {
+		return nil, err
+	}
+	if h.Agent, err = Mk_sim_agent( "127.0.0.1:" + h.Agent_port, mac2phost ); err != nil {
+		return nil, err
+	}
+
+	if err = h.wait4network( timeout ); err != nil {
+		return nil, err
+	}
+
+	req := ipc.Mk_chmsg( )
+	req.Send_req( h.rmgr_ch, nil, managers.REQ_ALLUP, nil, nil )		// same all clear that main sends
+	managers.Set_accept_state( true )
+
+	err = wait4port( h.Api_port, timeout )
+	return
+}
+
+var empty_str string = ""
+
+/*
+	Ping the network manager until it reports state 2 (has the agent supplied mac2phost data).
+*/
+func (h *Harness) wait4network( timeout time.Duration ) ( error ) {
+	my_ch := make( chan *ipc.Chmsg )
+	limit := time.Now().Add( timeout )
+	state := 0
+	for {
+		req := ipc.Mk_chmsg( )
+		req.Send_req( h.nw_ch, my_ch, managers.REQ_STATE, nil, nil )
+		req = <- my_ch
+
+		if state, _ = req.Response_data.( int ); state == 2 {
+			return nil
+		}
+		if time.Now().After( limit ) {
+			return fmt.Errorf( "network did not initialise; state=%d", state )
+		}
+
+		time.Sleep( 250 * time.Millisecond )
+	}
+}
+
+/*
+	Send a request to a manager and wait for the response. Chan is one of nw, rm, osif, fq, am or rc.
+*/
+func (h *Harness) Request( mgr string, mtype int, data interface{} ) ( *ipc.Chmsg ) {
+	var ch chan *ipc.Chmsg
+
+	switch mgr {
+		case "nw":		ch = h.nw_ch
+		case "rm":		ch = h.rmgr_ch
+		case "osif":	ch = h.osif_ch
+		case "fq":		ch = h.fq_ch
+		case "am":		ch = h.am_ch
+		case "rc":		ch = h.rc_ch
+		default:		return nil
+	}
+
+	my_ch := make( chan *ipc.Chmsg )
+	req := ipc.Mk_chmsg( )
+	req.Send_req( ch, my_ch, mtype, data, nil )
+	return <- my_ch
+}
+
+func (h *Harness) send( method string, path string, token string, body string ) ( status int, resp string, err error ) {
+	status, _, resp, err = h.send_hdr( method, path, token, body )
+	return
+}
+
+/*
+	Send the request and return the response headers along with the status and body.
+*/
+func (h *Harness) send_hdr( method string, path string, token string, body string ) ( status int, hdr http.Header, resp string, err error ) {
+	req, err := http.NewRequest( method, "http://127.0.0.1:" + h.Api_port + path, bytes.NewBufferString( body ) )
+	if err != nil {
+		return 0, nil, "", err
+	}
+	if token != "" {
+		req.Header.Set( "X-Auth-Tegu", token )
+	}
+
+	r, err := http.DefaultClient.Do( req )
+	if err != nil {
+		return 0, nil, "", err
+	}
+	defer r.Body.Close()
+
+	buf, err := ioutil.ReadAll( r.Body )
+	return r.StatusCode, r.Header, string( buf ), err
+}
+
+/*
+	Post one or more newline separated requests (reserve, ow_reserve, steer, listres...) to
+	/tegu/api and return the response body.
+*/
+func (h *Harness) Post( body string ) ( status int, resp string, err error ) {
+	return h.send( "POST", "/tegu/api", "", body )
+}
+
+/*
+	Post a json mirror request to /tegu/mirrors/. The token must carry a mirror or admin role.
+*/
+func (h *Harness) Post_mirror( token string, body string ) ( status int, resp string, err error ) {
+	return h.send( "POST", "/tegu/mirrors/", token, body )
+}
+
+/*
+	Delete a mirror by name.
+*/
+func (h *Harness) Delete_mirror( token string, name string ) ( status int, resp string, err error ) {
+	return h.send( "DELETE", "/tegu/mirrors/" + name + "/", token, "" )
+}
+
+/*
+	Send a request to the v2 (json) api; path is relative to /tegu/v2 (e.g. /reservations/name).
+	The token, if not empty, is sent in the X-Auth-Tegu header.
+*/
+func (h *Harness) V2( method string, path string, token string, body string ) ( status int, hdr http.Header, resp string, err error ) {
+	return h.send_hdr( method, "/tegu/v2" + path, token, body )
+}
+
+/*
+	Replace the topology with the json given and ask the network manager to check it. The
+	network graph is rebuilt (and reservations on links which went away are rerouted) about a
+	second after the topology is accepted.
+*/
+func (h *Harness) Set_topo( topo string ) ( error ) {
+	if err := ioutil.WriteFile( h.Topo, []byte( topo ), 0644 ); err != nil {
+		return err
+	}
+
+	_, resp, err := h.Post( "topocheck" )
+	if err == nil && ! Resp_ok( resp ) {
+		err = fmt.Errorf( "topocheck failed: %s", resp )
+	}
+	return err
+}
+
+/*
+	Return true if the response from the api reports a status of OK for every request.
+*/
+func Resp_ok( resp string ) ( bool ) {
+	return strings.Contains( resp, `"status": "OK"` ) && ! strings.Contains( resp, `"status": "ERROR"` )
+}
+
+/*
+	Close the simulated agent and remove the scratch directory. The managers continue to run
+	(they cannot be stopped) until the process exits.
+*/
+func (h *Harness) Close( ) {
+	if h.Agent != nil {
+		h.Agent.Close()
+	}
+	os.RemoveAll( h.Dir )
+}
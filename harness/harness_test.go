@@ -0,0 +1,1491 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	harness_test
+	Abstract:	End to end tests: requests are posted to the http api of an in-process tegu and
+				the actions received by the simulated agent are checked. The managers can be
+				started only once, so all tests share a single harness.
+	Date:		17 October 2026
+	Mods:		17 Oct 2026 - Added push state (agent ack/nack) tests.
+				17 Oct 2026 - Added reconciler (audit) test; repair is on and periodic audits off.
+				17 Oct 2026 - Added capability routing test with a second, limited, agent.
+				17 Oct 2026 - Added silent agent failover test; heartbeats every second.
+				17 Oct 2026 - Added findslot across a drain window test.
+				17 Oct 2026 - Added waitlist tests (queue, admit when capacity is freed, abandon).
+				17 Oct 2026 - Added priority preemption test.
+				17 Oct 2026 - Added reroute test (link removed from the topology).
+				17 Oct 2026 - Added simulation test with a oneway reservation in place.
+				17 Oct 2026 - Added steering reservation restore from checkpoint test.
+				17 Oct 2026 - Added graph export (dot/graphml) test.
+				17 Oct 2026 - Added agent disconnect failover test.
+				17 Oct 2026 - Added legacy PUT (handled as POST) test.
+				17 Oct 2026 - Added waitlist admit after link capacity is increased test.
+
+*/
+
+package harness
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/att/gopkgs/ipc"
+	"github.com/att/tegu/gizmos"
+	"github.com/att/tegu/managers"
+)
+
+var (
+	th_once	sync.Once
+	th		*Harness
+	th_err	error
+)
+
+/*
+	Macs of the VMs and gateway in testdata/inventory.json and where they live.
+*/
+var test_mac2phost = map[string]string {
+	"fa:16:3e:00:00:01":	"netnode1",
+	"fa:16:3e:00:00:02":	"compute1",
+	"fa:16:3e:00:00:03":	"compute2",
+	"fa:16:3e:00:00:04":	"compute2",
+	"fa:16:3e:00:00:05":	"compute1",
+}
+
+func get_harness( t *testing.T ) ( *Harness ) {
+	if testing.Short() {
+		t.Skip( "integration harness tests are not run in short mode" )
+	}
+
+	th_once.Do( func() {
+		fmt.Fprintf( os.Stderr, "\n----------- integration harness: starting managers --------------\n" )
+		th, th_err = Mk_harness( "testdata/topo.json", "testdata/inventory.json", test_mac2phost, ":reconciler\n\trepair = true\n\tinterval = 0\n:agent\n\theartbeat = 1\n\thb_timeout = 3\n", 60 * time.Second )
+	} )
+	if th_err != nil {
+		t.Fatalf( "unable to start the harness: %s", th_err )
+	}
+
+	th.Agent.Reset()
+	return th
+}
+
+/*
+	Find the first action whose fdata, qdata, hosts or data (as key=value) contains all of the
+	strings given.
+*/
+func find_action( list []Sim_action, want ...string ) ( *Sim_action ) {
+	for i := range list {
+		s := strings.Join( list[i].Fdata, " " ) + " " + strings.Join( list[i].Qdata, " " ) + " " + strings.Join( list[i].Hosts, " " )
+		for k, v := range list[i].Data {
+			s += " " + k + "=" + v
+		}
+
+		found := true
+		for _, w := range want {
+			if ! strings.Contains( s, w ) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return &list[i]
+		}
+	}
+
+	return nil
+}
+
+/*
+	Count the actions which contain all of the strings given (see find_action).
+*/
+func count_actions( list []Sim_action, want ...string ) ( n int ) {
+	for i := range list {
+		if find_action( list[i:i+1], want... ) != nil {
+			n++
+		}
+	}
+
+	return
+}
+
+func dump_actions( list []Sim_action ) {
+	for _, a := range list {
+		fmt.Fprintf( os.Stderr, "\t%s: data=%v fdata=%v qdata=%v hosts=%v\n", a.Atype, a.Data, a.Fdata, a.Qdata, a.Hosts )
+	}
+}
+
+func Test_startup( t *testing.T ) {
+	h := get_harness( t )
+
+	if _, err := h.Agent.Wait_for( "map_mac2phost", 1, 5 * time.Second ); err != nil {		// must have had one to get this far
+		fmt.Fprintf( os.Stderr, "FAIL:   %s\n", err )
+		t.Fail()
+	}
+
+	_, resp, err := h.Post( "ping" )
+	if err != nil || ! Resp_ok( resp ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   ping: %v %s\n", err, resp )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     harness started: api=%s agent=%s\n", h.Api_port, h.Agent_port )
+	}
+}
+
+func Test_reserve( t *testing.T ) {
+	h := get_harness( t )
+
+	_, resp, err := h.Post( "reserve 10M +120 lab/vm1,lab/vm2 cookie voice" )
+	if err != nil || ! Resp_ok( resp ) {
+		t.Fatalf( "reserve failed: %v %s", err, resp )
+	}
+
+	fmods, err := h.Agent.Wait_for( "bw_fmod", 2, 10 * time.Second )		// one for each endpoint
+	if err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   %s\n", err )
+		dump_actions( h.Agent.Get_actions( "" ) )
+		t.FailNow()
+	}
+
+	if find_action( fmods, "smac=fa:16:3e:00:00:02", "dmac=fa:16:3e:00:00:03", "compute1" ) == nil ||
+		find_action( fmods, "smac=fa:16:3e:00:00:03", "dmac=fa:16:3e:00:00:02", "compute2" ) == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   bw_fmod actions did not name both endpoints on their hosts\n" )
+		dump_actions( fmods )
+		t.Fail()
+	}
+
+	queues, err := h.Agent.Wait_for( "setqueues", 1, 10 * time.Second )
+	if err != nil || find_action( queues, "compute1" ) == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   setqueues for the reservation: %v\n", err )
+		dump_actions( h.Agent.Get_actions( "" ) )
+		t.Fail()
+	}
+
+	if ! t.Failed() {
+		fmt.Fprintf( os.Stderr, "OK:     reserve: %d bw_fmod and %d setqueues actions\n", len( fmods ), len( queues ) )
+	}
+}
+
+/*
+	PUT was once the same as POST; requests other than reservation (modify) must still work,
+	alone or mixed with a modify.
+*/
+func Test_put_legacy( t *testing.T ) {
+	h := get_harness( t )
+
+	var rs struct {
+		Reqstate []struct {
+			Status	string
+			Comment	string
+			Details struct { Id string }
+		}
+	}
+
+	_, resp, err := h.send( "PUT", "/tegu/api", "", "reserve 10M +120 lab/vm1:6206,lab/vm2:6206 cookie voice" )
+	if err != nil || ! Resp_ok( resp ) {
+		t.Fatalf( "PUT reserve failed: %v %s", err, resp )
+	}
+	if err = json.Unmarshal( []byte( resp ), &rs ); err != nil || len( rs.Reqstate ) != 1 || rs.Reqstate[0].Details.Id == "" {
+		t.Fatalf( "unexpected PUT reserve response: %v %s", err, resp )
+	}
+	id := rs.Reqstate[0].Details.Id
+	defer h.Post( "cancelres " + id + " cookie" )
+	fmt.Fprintf( os.Stderr, "OK:     PUT reserve created %s\n", id )
+
+	_, resp, err = h.send( "PUT", "/tegu/api", "", "reservation bandw=20M " + id + " cookie\nlistres" )
+	if err != nil || ! Resp_ok( resp ) || ! strings.Contains( resp, "was modified" ) || strings.Count( resp, id ) < 2 {
+		fmt.Fprintf( os.Stderr, "FAIL:   PUT of reservation with listres: %v %s\n", err, resp )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     PUT of reservation mixed with listres\n" )
+	}
+
+	_, resp, err = h.send( "PUT", "/tegu/api", "", "reservation bandw=30M " + id + " cookie" )
+	if err != nil || ! Resp_ok( resp ) || ! strings.Contains( resp, "successfully modified" ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   PUT of reservation alone: %v %s\n", err, resp )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     PUT of reservation alone\n" )
+	}
+}
+
+func Test_ow_reserve( t *testing.T ) {
+	h := get_harness( t )
+
+	_, resp, err := h.Post( "ow_reserve 5M +120 lab/vm2,lab/vm1 cookie voice" )
+	if err != nil || ! Resp_ok( resp ) {
+		t.Fatalf( "ow_reserve failed: %v %s", err, resp )
+	}
+
+	fmods, err := h.Agent.Wait_for( "bwow_fmod", 1, 10 * time.Second )
+	if err != nil || find_action( fmods, "smac=fa:16:3e:00:00:03", "dmac=fa:16:3e:00:00:02", "compute2" ) == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   oneway reservation: %v\n", err )
+		dump_actions( h.Agent.Get_actions( "" ) )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     ow_reserve: %d bwow_fmod actions\n", len( fmods ) )
+	}
+}
+
+func Test_steer( t *testing.T ) {
+	h := get_harness( t )
+
+	_, resp, err := h.Post( "steer +120 lab vm1 vm2 fw1 cookie" )
+	if err != nil || ! Resp_ok( resp ) {
+		t.Fatalf( "steer failed: %v %s", err, resp )
+	}
+
+	fmods, err := h.Agent.Wait_for( "flowmod", 1, 10 * time.Second )
+	if err != nil || find_action( fmods, "fa:16:3e:00:00:04" ) == nil {				// traffic must be pushed through the middlebox
+		fmt.Fprintf( os.Stderr, "FAIL:   steering: %v\n", err )
+		dump_actions( h.Agent.Get_actions( "" ) )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     steer: %d flowmod actions\n", len( fmods ) )
+	}
+}
+
+/*
+	Checkpoint a steering reservation and load the record as resmgr does when it starts. The id
+	in the record is changed so that it isn't a duplicate of the live reservation; the restored
+	reservation must be listed and its flow-mods pushed again.
+*/
+func Test_steer_restore( t *testing.T ) {
+	h := get_harness( t )
+
+	id, err := reserve_id( h, "steer +300 lab vm1 vm2 fw1 cookie" )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	if _, err = h.Agent.Wait_for( "flowmod", 1, 10 * time.Second ); err != nil {
+		t.Fatalf( "steering flow-mods not pushed: %s", err )
+	}
+
+	cookie := "cookie"
+	msg := h.Request( "rm", managers.REQ_GET, []*string{ &id, &cookie } )
+	if msg.State != nil || msg.Response_data == nil {
+		t.Fatalf( "unable to fetch steering reservation %s: %v", id, msg.State )
+	}
+	p := msg.Response_data.( *gizmos.Pledge )
+
+	rid := id + "-restored"
+	rec := strings.Replace( (*p).To_chkpt(), fmt.Sprintf( `"id": %q`, id ), fmt.Sprintf( `"id": %q`, rid ), 1 )
+	fname := h.Dir + "/steer.ckpt"
+	if err = ioutil.WriteFile( fname, []byte( rec + "\n" ), 0644 ); err != nil {
+		t.Fatalf( "unable to write checkpoint: %s", err )
+	}
+
+	h.Agent.Reset()
+	if msg = h.Request( "rm", managers.REQ_LOAD, &fname ); msg.State != nil {
+		t.Fatalf( "checkpoint load failed: %s", msg.State )
+	}
+
+	if ! res_listed( h, rid ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   steer restore: %s not listed after checkpoint load\n", rid )
+		t.Fail()
+	}
+
+	fmods, err := h.Agent.Wait_for( "flowmod", 1, 10 * time.Second )
+	if err != nil || find_action( fmods, "fa:16:3e:00:00:04" ) == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   steer restore: flow-mods not pushed for restored reservation: %v\n", err )
+		dump_actions( h.Agent.Get_actions( "" ) )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     steer restore: %d flowmod actions after checkpoint load\n", len( fmods ) )
+	}
+}
+
+func Test_mirror( t *testing.T ) {
+	h := get_harness( t )
+
+	if status, _, _ := h.Post_mirror( "tok-user", `{ "end_time": "+120", "output": "lab/tap1", "port": [ "lab/vm1" ] }` ); status == 200 || status == 201 {
+		fmt.Fprintf( os.Stderr, "FAIL:   mirror accepted with a token without a mirror role\n" )
+		t.Fail()
+	}
+
+	status, resp, err := h.Post_mirror( "tok-mirror", `{ "end_time": "+120", "output": "10.0.0.5", "port": [ "lab/vm1" ], "name": "mirror-h1" }` )
+	if err != nil || status >= 300 {
+		t.Fatalf( "mirror request failed: %d %v %s", status, err, resp )
+	}
+
+	var mirrors []struct{ Name string }							// the name is given back with a suffix
+	if err = json.Unmarshal( []byte( resp ), &mirrors ); err != nil || len( mirrors ) != 1 {
+		t.Fatalf( "unexpected mirror response: %v %s", err, resp )
+	}
+
+	wiz, err := h.Agent.Wait_for( "mirrorwiz", 1, 10 * time.Second )
+	if err != nil || find_action( wiz, "add", "fa:16:3e:00:00:02", "10.0.0.5" ) == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   mirror add: %v\n", err )
+		dump_actions( h.Agent.Get_actions( "" ) )
+		t.Fail()
+	}
+
+	h.Agent.Reset()
+	if status, resp, err = h.Delete_mirror( "tok-mirror", mirrors[0].Name ); err != nil || status >= 300 {
+		fmt.Fprintf( os.Stderr, "FAIL:   mirror delete: %d %v %s\n", status, err, resp )
+		t.Fail()
+	} else if wiz, err = h.Agent.Wait_for( "mirrorwiz", 1, 10 * time.Second ); err != nil || find_action( wiz, "del" ) == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   mirror delete did not reach the agent: %v\n", err )
+		dump_actions( h.Agent.Get_actions( "" ) )
+		t.Fail()
+	}
+
+	if ! t.Failed() {
+		fmt.Fprintf( os.Stderr, "OK:     mirror: add and delete sent to the agent\n" )
+	}
+}
+
+/*
+	Post a reservation request and return the id of the reservation.
+*/
+func reserve_id( h *Harness, req string ) ( string, error ) {
+	var rs struct {
+		Reqstate []struct {
+			Details struct { Id string }
+		}
+	}
+
+	_, resp, err := h.Post( req )
+	if err != nil || ! Resp_ok( resp ) {
+		return "", fmt.Errorf( "%s failed: %v %s", req, err, resp )
+	}
+	if err = json.Unmarshal( []byte( resp ), &rs ); err != nil || len( rs.Reqstate ) != 1 || rs.Reqstate[0].Details.Id == "" {
+		return "", fmt.Errorf( "unexpected reserve response: %v %s", err, resp )
+	}
+
+	return rs.Reqstate[0].Details.Id, nil
+}
+
+/*
+	Wait for listres to show the reservation with one of the push states given. The last
+	state seen is returned with an error if none of the states was seen before the timeout.
+*/
+func wait_push_state( h *Harness, id string, timeout time.Duration, want ...string ) ( state string, err error ) {
+	var rs struct {
+		Reqstate []struct {
+			Details struct {
+				Reservations []struct {
+					Id			string
+					Push_state	string
+				}
+			}
+		}
+	}
+
+	limit := time.Now().Add( timeout )
+	for {
+		if _, resp, err := h.Post( "listres" ); err == nil && json.Unmarshal( []byte( resp ), &rs ) == nil && len( rs.Reqstate ) == 1 {
+			for _, r := range rs.Reqstate[0].Details.Reservations {
+				if r.Id == id {
+					state = r.Push_state
+				}
+			}
+		}
+
+		for _, w := range want {
+			if state == w {
+				return state, nil
+			}
+		}
+		if time.Now().After( limit ) {
+			return state, fmt.Errorf( "timeout waiting for %s push state %v; last seen: %q", id, want, state )
+		}
+
+		time.Sleep( 250 * time.Millisecond )
+	}
+}
+
+func Test_push_installed( t *testing.T ) {
+	h := get_harness( t )
+
+	id, err := reserve_id( h, "reserve 10M +120 lab/vm1:1001,lab/vm2:1001 cookie voice" )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+
+	if state, err := wait_push_state( h, id, 10 * time.Second, "installed" ); err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   %s\n", err )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     push state of %s after acks: %s\n", id, state )
+	}
+}
+
+func Test_push_retry( t *testing.T ) {
+	h := get_harness( t )
+
+	h.Agent.Set_fail( "compute2", true )				// vm2 endpoint flow-mods are nacked
+	defer h.Agent.Set_fail( "compute2", false )
+
+	id, err := reserve_id( h, "reserve 10M +120 lab/vm1:2002,lab/vm2:2002 cookie voice" )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+
+	state, err := wait_push_state( h, id, 10 * time.Second, "partial", "failed" )
+	if err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   %s\n", err )
+		t.FailNow()
+	}
+
+	n := count_actions( h.Agent.Get_actions( "bw_fmod" ), "2002" )			// other reservations are pushed too; count just ours
+	limit := time.Now().Add( 10 * time.Second )
+	for count_actions( h.Agent.Get_actions( "bw_fmod" ), "2002" ) <= n {
+		if time.Now().After( limit ) {
+			fmt.Fprintf( os.Stderr, "FAIL:   failed push was not retried\n" )
+			t.FailNow()
+		}
+		time.Sleep( 250 * time.Millisecond )
+	}
+	fmt.Fprintf( os.Stderr, "OK:     push state of %s with a failing host: %s; push retried\n", id, state )
+
+	h.Agent.Set_fail( "compute2", false )
+	if state, err = wait_push_state( h, id, 15 * time.Second, "installed" ); err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   retry after the host recovered: %s\n", err )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     push state of %s after the host recovered: %s\n", id, state )
+	}
+}
+
+type audit_rpt struct {
+	Running	bool
+	Last	struct {
+		State		string
+		Start		int64
+		Drift		[]struct { Kind, Host, Res, Detail string }
+		Repushed	[]string
+		Deleted		int
+	}
+}
+
+/*
+	Post an audit run and wait for the report of an audit which started after the request.
+*/
+func run_audit( h *Harness, timeout time.Duration ) ( rpt *audit_rpt, err error ) {
+	var rs struct {
+		Reqstate []struct { Details audit_rpt }
+	}
+
+	start := time.Now().Unix()
+	if _, resp, err := h.Post( "audit run" ); err != nil || ! Resp_ok( resp ) {
+		return nil, fmt.Errorf( "audit run failed: %v %s", err, resp )
+	}
+
+	limit := time.Now().Add( timeout )
+	for {
+		time.Sleep( 250 * time.Millisecond )
+		if _, resp, err := h.Post( "audit" ); err == nil && json.Unmarshal( []byte( resp ), &rs ) == nil && len( rs.Reqstate ) == 1 {
+			rpt = &rs.Reqstate[0].Details
+			if ! rpt.Running && rpt.Last.Start >= start && rpt.Last.State != "running" {
+				return rpt, nil
+			}
+		}
+
+		if time.Now().After( limit ) {
+			return rpt, fmt.Errorf( "timeout waiting for audit to complete" )
+		}
+	}
+}
+
+func has_drift( rpt *audit_rpt, kind string, host string, res string ) ( bool ) {
+	for _, d := range rpt.Last.Drift {
+		if d.Kind == kind && (host == "" || d.Host == host) && (res == "" || d.Res == res) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func Test_reconcile( t *testing.T ) {
+	h := get_harness( t )
+
+	id, err := reserve_id( h, "reserve 10M +300 lab/vm1:3003,lab/vm2:3003 cookie voice" )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	if _, err := wait_push_state( h, id, 10 * time.Second, "installed" ); err != nil {
+		t.Fatalf( "%s", err )
+	}
+
+	h.Agent.Clear_flows( )														// as if ovs were restarted everywhere
+	h.Agent.Add_flow( "compute1", "0xb0ff", "fa:16:3e:00:00:99", "fa:16:3e:00:00:98" )	// left from a long gone reservation
+	n := count_actions( h.Agent.Get_actions( "bw_fmod" ), "3003" )
+
+	rpt, err := run_audit( h, 20 * time.Second )
+	if err != nil {
+		t.Fatalf( "first audit: %s", err )
+	}
+	if ! has_drift( rpt, "missing_flow", "", id ) || ! has_drift( rpt, "stale_flow", "compute1", "" ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   first audit did not report missing and stale flow-mods: %+v\n", rpt.Last )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     first audit reported %d differences\n", len( rpt.Last.Drift ) )
+	}
+	if rpt.Last.Deleted != 0 {
+		fmt.Fprintf( os.Stderr, "FAIL:   stale flow-mod deleted on first sighting\n" )
+		t.Fail()
+	}
+
+	repushed := false
+	for _, r := range rpt.Last.Repushed {
+		repushed = repushed || r == id
+	}
+	if ! repushed {
+		fmt.Fprintf( os.Stderr, "FAIL:   %s not in the repushed list: %v\n", id, rpt.Last.Repushed )
+		t.Fail()
+	} else {
+		limit := time.Now().Add( 10 * time.Second )
+		for count_actions( h.Agent.Get_actions( "bw_fmod" ), "3003" ) <= n && time.Now().Before( limit ) {
+			time.Sleep( 100 * time.Millisecond )
+		}
+		if count_actions( h.Agent.Get_actions( "bw_fmod" ), "3003" ) <= n {
+			fmt.Fprintf( os.Stderr, "FAIL:   %s was not pushed again\n", id )
+			t.Fail()
+		} else {
+			fmt.Fprintf( os.Stderr, "OK:     %s pushed again after its flow-mods went missing\n", id )
+		}
+	}
+
+	if _, err := wait_push_state( h, id, 10 * time.Second, "installed" ); err != nil {
+		t.Fatalf( "%s", err )
+	}
+	if rpt, err = run_audit( h, 20 * time.Second ); err != nil {
+		t.Fatalf( "second audit: %s", err )
+	}
+	if has_drift( rpt, "missing_flow", "", id ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   flow-mods still missing after repush: %+v\n", rpt.Last )
+		t.Fail()
+	}
+	if rpt.Last.Deleted < 1 {
+		fmt.Fprintf( os.Stderr, "FAIL:   stale flow-mod not deleted on second sighting: %+v\n", rpt.Last )
+		t.Fail()
+	}
+
+	limit := time.Now().Add( 10 * time.Second )
+	for {
+		stale := false
+		for _, f := range h.Agent.Get_flows( "compute1" ) {
+			stale = stale || strings.Contains( f, "fa:16:3e:00:00:99" )
+		}
+		if ! stale {
+			fmt.Fprintf( os.Stderr, "OK:     stale flow-mod deleted after the second audit\n" )
+			break
+		}
+		if time.Now().After( limit ) {
+			fmt.Fprintf( os.Stderr, "FAIL:   stale flow-mod still in the flow table: %v\n", h.Agent.Get_flows( "compute1" ) )
+			t.Fail()
+			break
+		}
+		time.Sleep( 100 * time.Millisecond )
+	}
+}
+
+type agent_list_entry struct {
+	Id			string
+	Version		string
+	Actions		[]string
+	Hosts		[]string
+	Healthy		bool
+	Requeued	int
+}
+
+/*
+	Wait for listagents to show n agents; the last list seen is returned with an error if
+	the count isn't reached before the timeout.
+*/
+func wait_agents( h *Harness, n int, timeout time.Duration ) ( list []agent_list_entry, err error ) {
+	var rs struct {
+		Reqstate []struct { Details []agent_list_entry }
+	}
+
+	limit := time.Now().Add( timeout )
+	for {
+		if _, resp, err := h.Post( "listagents" ); err == nil && json.Unmarshal( []byte( resp ), &rs ) == nil && len( rs.Reqstate ) == 1 {
+			list = rs.Reqstate[0].Details
+			if len( list ) == n {
+				return list, nil
+			}
+		}
+
+		if time.Now().After( limit ) {
+			return list, fmt.Errorf( "timeout waiting for %d agents; listagents shows %d", n, len( list ) )
+		}
+		time.Sleep( 100 * time.Millisecond )
+	}
+}
+
+func Test_agent_routing( t *testing.T ) {
+	h := get_harness( t )
+
+	a2, err := Mk_sim_agent_caps( "127.0.0.1:" + h.Agent_port, test_mac2phost, []string{ "bw_fmod", "bwow_fmod" }, []string{ "compute1" } )
+	if err != nil {
+		t.Fatalf( "unable to connect second agent: %s", err )
+	}
+	defer func() {
+		a2.Close( )
+		if _, err := wait_agents( h, 1, 5 * time.Second ); err != nil {
+			fmt.Fprintf( os.Stderr, "FAIL:   second agent not dropped: %s\n", err )
+			t.Fail()
+		}
+	}()
+
+	list, err := wait_agents( h, 2, 5 * time.Second )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	limited := 0
+	for _, a := range list {
+		if len( a.Hosts ) == 1 && a.Hosts[0] == "compute1" && len( a.Actions ) == 2 {
+			limited++
+		}
+	}
+	if limited != 1 {
+		fmt.Fprintf( os.Stderr, "FAIL:   listagents did not show the limited agent's capabilities: %+v\n", list )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     listagents shows both agents: %+v\n", list )
+	}
+
+	for i := 0; i < 3; i++ {											// enough to cycle the round robin
+		id, err := reserve_id( h, fmt.Sprintf( "reserve 10M +120 lab/vm1:%d,lab/vm2:%d cookie voice", 5005 + i, 5005 + i ) )
+		if err != nil {
+			t.Fatalf( "%s", err )
+		}
+		if _, err := wait_push_state( h, id, 10 * time.Second, "installed" ); err != nil {
+			fmt.Fprintf( os.Stderr, "FAIL:   %s\n", err )
+			t.Fail()
+		}
+	}
+
+	for _, a := range a2.Get_actions( "" ) {
+		for _, host := range a.Hosts {
+			if host != "compute1" || (a.Atype != "bw_fmod" && a.Atype != "bwow_fmod") {
+				fmt.Fprintf( os.Stderr, "FAIL:   limited agent received an action it cannot handle\n" )
+				dump_actions( []Sim_action{ a } )
+				t.Fail()
+			}
+		}
+	}
+
+	fmods := h.Agent.Get_actions( "bw_fmod" )
+	for i := 0; i < 3; i++ {
+		port := fmt.Sprintf( "%d", 5005 + i )
+		if find_action( fmods, port, "compute2" ) == nil {
+			fmt.Fprintf( os.Stderr, "FAIL:   compute2 flow-mod for port %s not sent to the capable agent\n", port )
+			t.Fail()
+		}
+		if find_action( fmods, port, "compute1" ) == nil && find_action( a2.Get_actions( "bw_fmod" ), port, "compute1" ) == nil {
+			fmt.Fprintf( os.Stderr, "FAIL:   compute1 flow-mod for port %s not sent to either agent\n", port )
+			t.Fail()
+		}
+	}
+
+	if ! t.Failed() {
+		fmt.Fprintf( os.Stderr, "OK:     routing: limited agent received %d bw_fmods, all for compute1\n", len( a2.Get_actions( "bw_fmod" ) ) )
+	}
+}
+
+/*
+	Wait for listagents to show the agent with the given capability count with the health
+	state given.
+*/
+func wait_health( h *Harness, nactions int, healthy bool, timeout time.Duration ) ( ae *agent_list_entry, err error ) {
+	limit := time.Now().Add( timeout )
+	for {
+		list, _ := wait_agents( h, 2, time.Second )
+		for i := range list {
+			if len( list[i].Actions ) == nactions && list[i].Healthy == healthy {
+				return &list[i], nil
+			}
+		}
+
+		if time.Now().After( limit ) {
+			return nil, fmt.Errorf( "timeout waiting for agent health to be %v: %+v", healthy, list )
+		}
+		time.Sleep( 100 * time.Millisecond )
+	}
+}
+
+func Test_agent_failover( t *testing.T ) {
+	h := get_harness( t )
+
+	hung_acts := []string{ "bw_fmod", "bwow_fmod", "intermed_queues" }		// short and long running request types
+	a2, err := Mk_sim_agent_caps( "127.0.0.1:" + h.Agent_port, test_mac2phost, hung_acts, nil )
+	if err != nil {
+		t.Fatalf( "unable to connect second agent: %s", err )
+	}
+	defer func() {
+		a2.Close( )
+		wait_agents( h, 1, 5 * time.Second )
+	}()
+
+	if _, err := wait_agents( h, 2, 5 * time.Second ); err != nil {
+		t.Fatalf( "%s", err )
+	}
+	a2.Set_silent( true )
+	a2.Reset( )
+	h.Agent.Reset( )
+
+	id, err := reserve_id( h, "reserve 10M +120 lab/vm1:6006,lab/vm2:6006 cookie voice" )		// short requests go to one agent,
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	ipc.Mk_chmsg( ).Send_req( h.am_ch, nil, managers.REQ_INTERMEDQ, nil, nil )				// long running to the other (no response)
+
+	limit := time.Now().Add( 5 * time.Second )
+	for len( a2.Get_actions( "" ) ) == 0 && time.Now().Before( limit ) {
+		time.Sleep( 100 * time.Millisecond )
+	}
+	hung := a2.Get_actions( "" )
+	if len( hung ) == 0 {
+		t.Fatalf( "silent agent received no actions" )
+	}
+
+	ae, err := wait_health( h, len( hung_acts ), false, 10 * time.Second )
+	if err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   %s\n", err )
+		t.FailNow()
+	}
+	fmt.Fprintf( os.Stderr, "OK:     silent agent %s marked unhealthy\n", ae.Id )
+
+	limit = time.Now().Add( 10 * time.Second )
+	for _, a := range hung {
+		for {
+			n := 0
+			for _, ra := range h.Agent.Get_actions( a.Atype ) {
+				if strings.Join( ra.Hosts, " " ) == strings.Join( a.Hosts, " " ) && strings.Join( ra.Fdata, " " ) == strings.Join( a.Fdata, " " ) {
+					n++
+				}
+			}
+			if n > 0 {
+				break
+			}
+			if time.Now().After( limit ) {
+				fmt.Fprintf( os.Stderr, "FAIL:   action sent to the silent agent was not replayed to the other\n" )
+				dump_actions( []Sim_action{ a } )
+				t.Fail()
+				break
+			}
+			time.Sleep( 100 * time.Millisecond )
+		}
+	}
+	if ae, _ = wait_health( h, len( hung_acts ), false, time.Second ); ae == nil || ae.Requeued < len( hung ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   listagents did not count %d requeued actions: %+v\n", len( hung ), ae )
+		t.Fail()
+	}
+
+	if _, err := wait_push_state( h, id, 10 * time.Second, "installed" ); err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   %s\n", err )
+		t.Fail()
+	}
+	if ! t.Failed() {
+		fmt.Fprintf( os.Stderr, "OK:     %d actions from the silent agent were replayed; %s installed\n", len( hung ), id )
+	}
+
+	a2.Set_silent( false )
+	if _, err := wait_health( h, len( hung_acts ), true, 10 * time.Second ); err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   agent not marked healthy after it was heard from again: %s\n", err )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     agent marked healthy after it was heard from again\n" )
+	}
+}
+
+/*
+	An agent which disconnects with actions not acked should have them replayed on another
+	agent straight away; the silent agent timeout (3s) must not be needed.
+*/
+func Test_agent_disconnect( t *testing.T ) {
+	h := get_harness( t )
+
+	a2, err := Mk_sim_agent_caps( "127.0.0.1:" + h.Agent_port, test_mac2phost, []string{ "bw_fmod", "intermed_queues" }, nil )
+	if err != nil {
+		t.Fatalf( "unable to connect second agent: %s", err )
+	}
+	closed := false
+	defer func() {
+		if ! closed {
+			a2.Close( )
+		}
+		wait_agents( h, 1, 5 * time.Second )
+	}()
+
+	if _, err := wait_agents( h, 2, 5 * time.Second ); err != nil {
+		t.Fatalf( "%s", err )
+	}
+	a2.Set_silent( true )											// actions it gets are never acked
+	a2.Reset( )
+	h.Agent.Reset( )
+
+	id, err := reserve_id( h, "reserve 10M +120 lab/vm1:6106,lab/vm2:6106 cookie voice" )		// short requests go to one agent,
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	defer h.Post( "cancelres " + id + " cookie" )
+	ipc.Mk_chmsg( ).Send_req( h.am_ch, nil, managers.REQ_INTERMEDQ, nil, nil )				// long running to the other (no response)
+
+	limit := time.Now().Add( 5 * time.Second )
+	for len( a2.Get_actions( "" ) ) == 0 && time.Now().Before( limit ) {
+		time.Sleep( 50 * time.Millisecond )
+	}
+	lost := a2.Get_actions( "" )
+	if len( lost ) == 0 {
+		t.Fatalf( "second agent received no actions" )
+	}
+
+	a2.Close( )
+	closed = true
+
+	limit = time.Now().Add( 1500 * time.Millisecond )				// well inside the silent agent timeout
+	for _, a := range lost {
+		for {
+			n := 0
+			for _, ra := range h.Agent.Get_actions( a.Atype ) {
+				if strings.Join( ra.Hosts, " " ) == strings.Join( a.Hosts, " " ) && strings.Join( ra.Fdata, " " ) == strings.Join( a.Fdata, " " ) {
+					n++
+				}
+			}
+			if n > 0 {
+				break
+			}
+			if time.Now().After( limit ) {
+				fmt.Fprintf( os.Stderr, "FAIL:   action sent to the disconnected agent was not replayed to the other\n" )
+				dump_actions( []Sim_action{ a } )
+				t.FailNow()
+			}
+			time.Sleep( 50 * time.Millisecond )
+		}
+	}
+	fmt.Fprintf( os.Stderr, "OK:     %d actions from the disconnected agent were replayed\n", len( lost ) )
+}
+
+func Test_findslot_drain( t *testing.T ) {
+	h := get_harness( t )
+
+	now := time.Now().Unix()
+	dstart := now + 3600											// in the future so that other tests aren't affected
+	dend := now + 7200
+	if _, resp, err := h.Post( fmt.Sprintf( "drain id=fs_drain switch=tor1 %d-%d", dstart, dend ) ); err != nil || ! Resp_ok( resp ) {
+		t.Fatalf( "drain failed: %v %s", err, resp )
+	}
+	defer h.Post( "undrain fs_drain" )
+
+	var rs struct {
+		Reqstate []struct {
+			Details struct {
+				Slots []struct { Commence int64 }
+			}
+		}
+	}
+
+	_, resp, err := h.Post( fmt.Sprintf( "findslot start=%d horizon=7200 count=1 10M 1200 lab/vm1,lab/vm2", dstart - 600 ) )		// can't fit before the drain
+	if err != nil || ! Resp_ok( resp ) || json.Unmarshal( []byte( resp ), &rs ) != nil || len( rs.Reqstate ) != 1 {
+		t.Fatalf( "findslot failed: %v %s", err, resp )
+	}
+
+	slots := rs.Reqstate[0].Details.Slots
+	if len( slots ) != 1 || slots[0].Commence != dend {
+		fmt.Fprintf( os.Stderr, "FAIL:   expected a slot starting when the drain ends (%d): %s\n", dend, resp )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     findslot across a drain: first window starts when the drain ends\n" )
+	}
+}
+
+/*
+	Reserve the largest multiple of 10M that still fits between vm1 and vm2 on the port given.
+	Earlier tests leave reservations on the links, so the amount can't be known in advance;
+	what's left after this reservation is always less than 10M.
+*/
+func fill_links( h *Harness, port int, window string ) ( id string, err error ) {
+	for amt := 100; amt > 0; amt -= 10 {
+		if id, err = reserve_id( h, fmt.Sprintf( "reserve %dM %s lab/vm1:%d,lab/vm2:%d cookie voice", amt, window, port, port ) ); err == nil {
+			return id, nil
+		}
+	}
+
+	return "", err
+}
+
+/*
+	Wait for listres to show the waitlist entry with one of the states given. The last state
+	seen is returned with an error if none was seen before the timeout.
+*/
+func wait_wait_state( h *Harness, id string, timeout time.Duration, want ...string ) ( state string, err error ) {
+	var rs struct {
+		Reqstate []struct {
+			Details struct {
+				Waitlist []struct {
+					Id		string
+					State	string
+				}
+			}
+		}
+	}
+
+	limit := time.Now().Add( timeout )
+	for {
+		if _, resp, err := h.Post( "listres" ); err == nil && json.Unmarshal( []byte( resp ), &rs ) == nil && len( rs.Reqstate ) == 1 {
+			for _, w := range rs.Reqstate[0].Details.Waitlist {
+				if w.Id == id {
+					state = w.State
+				}
+			}
+		}
+
+		for _, w := range want {
+			if state == w {
+				return state, nil
+			}
+		}
+		if time.Now().After( limit ) {
+			return state, fmt.Errorf( "timeout waiting for %s waitlist state %v; last seen: %q", id, want, state )
+		}
+
+		time.Sleep( 250 * time.Millisecond )
+	}
+}
+
+func Test_waitlist_admit( t *testing.T ) {
+	h := get_harness( t )
+
+	big, err := fill_links( h, 7007, "+300" )						// leaves too little for the next
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	qid, err := reserve_id( h, "reserve queue=true 10M +300 lab/vm1:7008,lab/vm2:7008 cookie voice" )
+	if err != nil {
+		h.Post( "cancelres " + big + " cookie" )
+		t.Fatalf( "%s", err )
+	}
+	defer h.Post( "cancelres " + qid + " cookie" )
+
+	if state, err := wait_wait_state( h, qid, 5 * time.Second, "queued" ); err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   reservation not queued: %s (%s)\n", err, state )
+		t.Fail()
+	}
+
+	if _, resp, err := h.Post( "cancelres " + big + " cookie" ); err != nil || ! Resp_ok( resp ) {		// frees the capacity
+		t.Fatalf( "cancel failed: %v %s", err, resp )
+	}
+
+	if _, err := wait_wait_state( h, qid, 10 * time.Second, "admitted" ); err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   queued reservation not admitted after capacity was freed: %s\n", err )
+		t.Fail()
+	} else if _, err := wait_push_state( h, qid, 10 * time.Second, "installed" ); err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   admitted reservation not pushed: %s\n", err )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     queued reservation %s admitted and pushed after %s was cancelled\n", qid, big )
+	}
+}
+
+/*
+	A queued reservation should be admitted when the topology gives the links more capacity;
+	nothing is cancelled and no link is added. The link capacity is halved after half of it has
+	been reserved, the queued reservation is rejected for lack of link capacity, and restoring
+	the capacity should admit it. (The project's fence is set from the link capacity when a time
+	slice is first used, so the capacity is reduced after the first reservation to leave the
+	link capacity as the only limit.)
+*/
+func Test_waitlist_capacity( t *testing.T ) {
+	h := get_harness( t )
+
+	orig, err := ioutil.ReadFile( h.Topo )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	restored := false
+	defer func( ) {													// runs after the reservations are cancelled
+		if restored {
+			return
+		}
+		if err := h.Set_topo( string( orig ) ); err != nil {
+			fmt.Fprintf( os.Stderr, "FAIL:   unable to restore the topology: %s\n", err )
+			t.Fail()
+		}
+		time.Sleep( 2 * time.Second )
+	}( )
+
+	start := time.Now().Unix() + 86400								// a window no other test uses
+	window := fmt.Sprintf( "%d-%d", start, start + 300 )
+	big, err := reserve_id( h, "reserve 50M " + window + " lab/vm1:7107,lab/vm2:7107 cookie voice" )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	defer h.Post( "cancelres " + big + " cookie" )
+
+	if err := h.Set_topo( strings.Replace( string( orig ), "10000000000", "5000000000", -1 ) ); err != nil {	// same links, half the capacity
+		t.Fatalf( "%s", err )
+	}
+	limit := time.Now().Add( 10 * time.Second )
+	for {
+		if _, gr, err := h.Post( "graph" ); err == nil && strings.Contains( gr, `"max_capacity": 50000000,` ) && ! strings.Contains( gr, `"max_capacity": 100000000,` ) {
+			break
+		}
+		if time.Now().After( limit ) {
+			t.Fatalf( "link capacity was not reduced" )
+		}
+		time.Sleep( 250 * time.Millisecond )
+	}
+
+	qid, err := reserve_id( h, "reserve queue=true 10M " + window + " lab/vm1:7108,lab/vm2:7108 cookie voice" )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	defer h.Post( "cancelres " + qid + " cookie" )
+	if state, err := wait_wait_state( h, qid, 5 * time.Second, "queued" ); err != nil {
+		t.Fatalf( "reservation not queued: %s (%s)", err, state )
+	}
+
+	if err := h.Set_topo( string( orig ) ); err != nil {
+		t.Fatalf( "%s", err )
+	}
+	restored = true
+
+	if _, err := wait_wait_state( h, qid, 10 * time.Second, "admitted" ); err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   queued reservation not admitted after link capacity was increased: %s\n", err )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     queued reservation %s admitted after link capacity was increased\n", qid )
+	}
+}
+
+func Test_waitlist_abandon( t *testing.T ) {
+	h := get_harness( t )
+
+	big, err := fill_links( h, 7009, "+300" )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	defer h.Post( "cancelres " + big + " cookie" )
+
+	qid, err := reserve_id( h, "reserve queue=true 10M +20 lab/vm1:7010,lab/vm2:7010 cookie voice" )		// window closes before capacity is freed
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	if _, err := wait_wait_state( h, qid, 5 * time.Second, "queued" ); err != nil {
+		t.Fatalf( "reservation not queued: %s", err )
+	}
+
+	time.Sleep( 6 * time.Second )										// abandoned once the window is within 15s of closing
+	h.Request( "rm", managers.REQ_RETRYWAIT, nil )
+
+	if _, err := wait_wait_state( h, qid, 5 * time.Second, "abandoned" ); err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   queued reservation not abandoned when its window closed: %s\n", err )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     queued reservation %s abandoned as its window closed\n", qid )
+	}
+}
+
+/*
+	Return true if listres shows the reservation.
+*/
+func res_listed( h *Harness, id string ) ( bool ) {
+	var rs struct {
+		Reqstate []struct {
+			Details struct {
+				Reservations []struct {
+					Id	string
+				}
+			}
+		}
+	}
+
+	if _, resp, err := h.Post( "listres" ); err == nil && json.Unmarshal( []byte( resp ), &rs ) == nil && len( rs.Reqstate ) == 1 {
+		for _, r := range rs.Reqstate[0].Details.Reservations {
+			if r.Id == id {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func Test_preempt( t *testing.T ) {
+	h := get_harness( t )
+
+	start := time.Now().Unix() + 10000						// well clear of anything the other tests reserved
+	window := fmt.Sprintf( "%d-%d", start, start + 300 )
+
+	low, err := reserve_id( h, "reserve priority=1 50M " + window + " lab/vm1:7011,lab/vm2:7011 cookie voice" )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	high, err := reserve_id( h, "reserve priority=3 50M " + window + " lab/vm1:7012,lab/vm2:7012 cookie voice" )	// links are now full
+	if err != nil {
+		h.Post( "cancelres " + low + " cookie" )
+		t.Fatalf( "%s", err )
+	}
+	defer h.Post( "cancelres " + high + " cookie" )
+
+	mid, err := reserve_id( h, "reserve priority=2 40M " + window + " lab/vm1:7013,lab/vm2:7013 cookie voice" )	// only low is a lower priority
+	if err != nil {
+		h.Post( "cancelres " + low + " cookie" )
+		t.Fatalf( "priority reservation was not admitted by preemption: %s", err )
+	}
+	defer h.Post( "cancelres " + mid + " cookie" )
+
+	if state, err := wait_wait_state( h, low, 5 * time.Second, "preempted" ); err != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   preempted reservation %s not on the waitlist: %s (%s)\n", low, err, state )
+		t.Fail()
+	} else if ! res_listed( h, high ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   higher priority reservation %s was removed\n", high )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     %s (priority 1) preempted and waitlisted; %s (priority 3) left in place\n", low, high )
+	}
+}
+
+/*
+	Request the graph with the options given (e.g. format=dot) and return the graph text.
+*/
+func graph_out( h *Harness, opts string ) ( string, error ) {
+	var rs struct {
+		Reqstate []struct {
+			Details string
+		}
+	}
+
+	_, resp, err := h.Post( "graph " + opts )
+	if err != nil || ! Resp_ok( resp ) || json.Unmarshal( []byte( resp ), &rs ) != nil || len( rs.Reqstate ) != 1 {
+		return "", fmt.Errorf( "graph %s failed: %v %s", opts, err, resp )
+	}
+
+	return rs.Reqstate[0].Details, nil
+}
+
+/*
+	Return the edges of the DOT graph which are highlighted for the reservation.
+*/
+func hot_edges( h *Harness, id string ) ( edges []string, err error ) {
+	dot, err := graph_out( h, "format=dot res=" + id )
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split( dot, "\n" ) {
+		if strings.Contains( line, "color=red" ) {
+			edges = append( edges, strings.TrimSpace( line ) )
+		}
+	}
+	return edges, nil
+}
+
+func Test_reroute( t *testing.T ) {
+	h := get_harness( t )
+
+	orig, err := ioutil.ReadFile( h.Topo )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	defer func( ) {
+		if err := h.Set_topo( string( orig ) ); err != nil {
+			fmt.Fprintf( os.Stderr, "FAIL:   unable to restore the topology: %s\n", err )
+			t.Fail()
+		}
+		time.Sleep( 2 * time.Second )								// let the rebuild finish before the next test
+	}( )
+
+	id, err := reserve_id( h, "reserve 10M +300 lab/vm1:7014,lab/vm2:7014 cookie voice" )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	defer h.Post( "cancelres " + id + " cookie" )
+
+	if _, err := wait_push_state( h, id, 10 * time.Second, "installed" ); err != nil {
+		t.Fatalf( "reservation not pushed: %s", err )
+	}
+	h.Agent.Reset()
+
+	topo := `[
+	{ "Src-switch": "tor1", "Src-port": 2, "Dst-switch": "compute2@em1", "Dst-port": -128, "Direction": "bidirectional", "Capacity": 10000000000 },
+	{ "Src-switch": "tor1", "Src-port": 3, "Dst-switch": "netnode1@em1", "Dst-port": -128, "Direction": "bidirectional", "Capacity": 10000000000 },
+	{ "Src-switch": "tor2", "Src-port": 1, "Dst-switch": "compute1@em1", "Dst-port": -128, "Direction": "bidirectional", "Capacity": 10000000000 },
+	{ "Src-switch": "tor2", "Src-port": 2, "Dst-switch": "compute2@em1", "Dst-port": -128, "Direction": "bidirectional", "Capacity": 10000000000 }
+]`
+	if err := h.Set_topo( topo ); err != nil {						// compute1 loses tor1, and can only be reached through tor2
+		t.Fatalf( "%s", err )
+	}
+
+	var edges []string
+	limit := time.Now().Add( 10 * time.Second )
+	for {
+		edges, err = hot_edges( h, id )
+		moved := err == nil && len( edges ) > 0
+		for _, e := range edges {
+			if ! strings.Contains( e, `"tor2"` ) {				// old path edges remain until res_mgr sets the new paths
+				moved = false
+			}
+		}
+		if moved || time.Now().After( limit ) {
+			break
+		}
+		time.Sleep( 250 * time.Millisecond )
+	}
+
+	tor2 := 0
+	for _, e := range edges {
+		if strings.Contains( e, `"tor2"` ) {
+			tor2++
+		}
+	}
+	if err != nil || tor2 == 0 || len( edges ) != tor2 {
+		fmt.Fprintf( os.Stderr, "FAIL:   reservation not moved to the paths through tor2: %v %v\n", err, edges )
+		t.FailNow()
+	}
+	fmt.Fprintf( os.Stderr, "OK:     reservation %s moved to %d link(s) through tor2\n", id, tor2 )
+
+	fmods, err := h.Agent.Wait_for( "bw_fmod", 2, 10 * time.Second )	// res_mgr pushes the rerouted reservation (REQ_REROUTE)
+	if err != nil || find_action( fmods, "7014" ) == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   rerouted reservation not pushed again: %v\n", err )
+		dump_actions( fmods )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     rerouted reservation pushed again\n" )
+	}
+}
+
+/*
+	Run a simulation and return the names of the reservations which would fail and the number
+	of existing reservations which were replayed.
+*/
+func simulate( h *Harness, req string ) ( failed []string, replayed int, err error ) {
+	var rs struct {
+		Reqstate []struct {
+			Details struct {
+				Replayed	int
+				Failed		[]struct {
+					Name	string
+				}
+			}
+		}
+	}
+
+	_, resp, err := h.Post( "simulate " + req )
+	if err != nil || ! Resp_ok( resp ) || json.Unmarshal( []byte( resp ), &rs ) != nil || len( rs.Reqstate ) != 1 {
+		return nil, 0, fmt.Errorf( "simulate failed: %v %s", err, resp )
+	}
+
+	for _, f := range rs.Reqstate[0].Details.Failed {
+		failed = append( failed, f.Name )
+	}
+	return failed, rs.Reqstate[0].Details.Replayed, nil
+}
+
+func Test_simulate_oneway( t *testing.T ) {
+	h := get_harness( t )
+
+	start := time.Now().Unix() + 20000						// nothing else reserved in the window
+	window := fmt.Sprintf( "%d-%d", start, start + 300 )
+
+	if failed, _, err := simulate( h, "reserve=50M," + window + ",lab/vm1,lab/vm2" ); err != nil || len( failed ) != 0 {
+		t.Fatalf( "simulated reservation should fit on an idle link: %v %v", err, failed )
+	}
+
+	id, err := reserve_id( h, "ow_reserve 60M " + window + " lab/vm1,lab/vm2 cookie voice" )	// gate on compute1 takes most of its uplink
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	defer h.Post( "cancelres " + id + " cookie" )
+
+	failed, replayed, err := simulate( h, "reserve=50M," + window + ",lab/vm1,lab/vm2" )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	if len( failed ) != 1 || ! strings.HasPrefix( failed[0], "sim_" ) || replayed == 0 {
+		fmt.Fprintf( os.Stderr, "FAIL:   oneway reservation not replayed; simulated reservation should not fit: replayed=%d failed=%v\n", replayed, failed )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     oneway reservation %s replayed; simulated reservation rejected\n", id )
+	}
+}
+
+type gml_doc struct {
+	Graph struct {
+		Nodes []struct {
+			Id		string	`xml:"id,attr"`
+		} `xml:"node"`
+		Edges []struct {
+			Id		string	`xml:"id,attr"`
+			Source	string	`xml:"source,attr"`
+			Target	string	`xml:"target,attr"`
+			Data	[]struct {
+				Key		string	`xml:"key,attr"`
+				Value	string	`xml:",chardata"`
+			} `xml:"data"`
+		} `xml:"edge"`
+	} `xml:"graph"`
+}
+
+/*
+	Return true if the DOT edge, or the GraphML source and target, only connect the switches given.
+*/
+func edge_within( ends []string, sw ...string ) ( bool ) {
+	for _, e := range ends {
+		found := false
+		for _, s := range sw {
+			if e == s {
+				found = true
+				break
+			}
+		}
+		if ! found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func Test_graph_export( t *testing.T ) {
+	h := get_harness( t )
+
+	orig, err := ioutil.ReadFile( h.Topo )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	defer func( ) {
+		if err := h.Set_topo( string( orig ) ); err != nil {
+			fmt.Fprintf( os.Stderr, "FAIL:   unable to restore the topology: %s\n", err )
+			t.Fail()
+		}
+		time.Sleep( 2 * time.Second )								// let the rebuild finish before the next test
+	}( )
+
+	topo := strings.TrimSuffix( strings.TrimSpace( string( orig ) ), "]" ) + `,
+	{ "Src-switch": "spine1", "Src-port": 1, "Dst-switch": "tor1", "Dst-port": 10, "Direction": "bidirectional", "Capacity": 10000000000, "Mlag": "tor1-up" },
+	{ "Src-switch": "spine2", "Src-port": 1, "Dst-switch": "tor1", "Dst-port": 11, "Direction": "bidirectional", "Capacity": 10000000000, "Mlag": "tor1-up" }
+]`
+	if err := h.Set_topo( topo ); err != nil {						// tor1 gains two uplinks in the same mlag group
+		t.Fatalf( "%s", err )
+	}
+
+	var dot string
+	limit := time.Now().Add( 10 * time.Second )
+	for {
+		dot, err = graph_out( h, "format=dot" )
+		if ( err == nil && strings.Contains( dot, `"spine2"` ) ) || time.Now().After( limit ) {
+			break
+		}
+		time.Sleep( 250 * time.Millisecond )
+	}
+	if ! strings.HasPrefix( dot, "digraph tegu {" ) || ! strings.HasSuffix( strings.TrimSpace( dot ), "}" ) {
+		t.Fatalf( "dot output is not a digraph: %v %s", err, dot )
+	}
+	if n := strings.Count( dot, "mlag=tor1-up\"" ); n != 2 || strings.Count( dot, "mlag=tor1-up.REV\"" ) != 2 {
+		fmt.Fprintf( os.Stderr, "FAIL:   graph export: expected two links each way in mlag tor1-up:\n%s\n", dot )
+		t.Fail()
+	}
+
+	id, err := reserve_id( h, "reserve 10M +300 lab/vm1:7015,lab/vm2:7015 cookie voice" )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	defer h.Post( "cancelres " + id + " cookie" )
+
+	edges, err := hot_edges( h, id )										// reservation path: compute1 - tor1 - compute2
+	if err != nil || len( edges ) == 0 {
+		t.Fatalf( "no highlighted edges for %s: %v", id, err )
+	}
+	for _, e := range edges {
+		ends := strings.SplitN( strings.SplitN( e, " [", 2 )[0], " -> ", 2 )
+		for i := range ends {
+			ends[i] = strings.Trim( ends[i], `"` )
+		}
+		if ! edge_within( ends, "compute1", "tor1", "compute2" ) {
+			fmt.Fprintf( os.Stderr, "FAIL:   graph export: dot edge highlighted which isn't on the reservation path: %s\n", e )
+			t.Fail()
+		}
+	}
+
+	gml, err := graph_out( h, "format=graphml res=" + id )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	dec := xml.NewDecoder( strings.NewReader( gml ) )					// every token must parse for the document to be well formed
+	for err == nil {
+		_, err = dec.Token()
+	}
+	if err != io.EOF {
+		t.Fatalf( "graphml is not well formed: %s\n%s", err, gml )
+	}
+
+	var doc gml_doc
+	if err = xml.Unmarshal( []byte( gml ), &doc ); err != nil {
+		t.Fatalf( "unable to unmarshal graphml: %s", err )
+	}
+
+	nodes := make( map[string]bool )
+	for _, n := range doc.Graph.Nodes {
+		nodes[n.Id] = true
+	}
+
+	nmlag := 0
+	nhot := 0
+	for _, e := range doc.Graph.Edges {
+		if ! nodes[e.Source] || ! nodes[e.Target] {
+			fmt.Fprintf( os.Stderr, "FAIL:   graph export: graphml edge references an unknown node: %s -> %s\n", e.Source, e.Target )
+			t.Fail()
+		}
+
+		for _, d := range e.Data {
+			switch {
+				case d.Key == "mlag" && strings.HasPrefix( d.Value, "tor1-up" ):
+					nmlag++
+					if ! edge_within( []string{ e.Source, e.Target }, "spine1", "spine2", "tor1" ) {
+						fmt.Fprintf( os.Stderr, "FAIL:   graph export: graphml mlag on the wrong link: %s\n", e.Id )
+						t.Fail()
+					}
+
+				case d.Key == "highlight" && d.Value == "true":
+					nhot++
+					if ! edge_within( []string{ e.Source, e.Target }, "compute1", "tor1", "compute2" ) {
+						fmt.Fprintf( os.Stderr, "FAIL:   graph export: graphml edge highlighted which isn't on the reservation path: %s\n", e.Id )
+						t.Fail()
+					}
+			}
+		}
+	}
+
+	if nmlag != 4 || nhot != len( edges ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   graph export: graphml has %d mlag links (expected 4) and %d highlighted (dot had %d)\n", nmlag, nhot, len( edges ) )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     graph export: %d mlag links; %d links highlighted for %s\n", nmlag, nhot, id )
+	}
+
+	if _, err = graph_out( h, "format=dot res=no-such-res" ); err == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   graph export: unknown reservation was not rejected\n" )
+		t.Fail()
+	}
+}
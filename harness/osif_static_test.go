@@ -0,0 +1,293 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	osif_static_test
+	Abstract:	Tests for the static inventory backend of the osif manager. The backend is driven
+				directly (not through the manager) using the functions behind REQ_VALIDATE_HOST,
+				REQ_GET_HOSTINFO, REQ_GET_DEFGW and REQ_IP2MACMAP. The harness is started only
+				so that the managers' loggers are set up.
+	Date:		17 October 2026
+
+*/
+
+package harness
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/att/gopkgs/ipc"
+	"github.com/att/tegu/gizmos"
+	"github.com/att/tegu/managers"
+)
+
+func static_inv( t *testing.T ) ( managers.Host_inventory ) {
+	get_harness( t )
+
+	si, err := managers.Mk_static_inventory( "testdata/static_inv.json", nil )
+	if err != nil {
+		t.Fatalf( "unable to load static inventory fixture: %s", err )
+	}
+
+	return si
+}
+
+/*
+	Send the request to the backend function and return the response message.
+*/
+func static_req( f func( *ipc.Chmsg ), data interface{} ) ( *ipc.Chmsg ) {
+	msg := ipc.Mk_chmsg( )
+	msg.Response_ch = make( chan *ipc.Chmsg, 1 )				// backend responds on the channel before returning
+	msg.Req_data = data
+	f( msg )
+
+	return <- msg.Response_ch
+}
+
+func Test_static_validate_host( t *testing.T ) {
+	si := static_inv( t )
+
+	tests := []struct {
+		raw		string
+		tok_req	bool
+		want	string				// empty if an error is expected
+	} {
+		{ "tok-lab/lab/vm1", true, "lab-id/vm1" },
+		{ "tok-lab/lab-id/vm1", true, "lab-id/vm1" },
+		{ "tok-lab//vm1", true, "lab-id/vm1" },
+		{ "tok-dev//vm1", true, "dev/vm1" },
+		{ "!/lab/vm1", true, "!lab-id/vm1" },
+		{ "lab/vm1", false, "lab-id/vm1" },
+		{ "vm1", false, "vm1" },
+		{ "tok-dev/lab/vm1", true, "" },						// token issued for another project
+		{ "tok-lab/nosuch/vm1", true, "" },
+		{ "tok-bad/lab/vm1", true, "" },
+		{ "tok-bad//vm1", true, "" },
+		{ "lab/vm1", true, "" },								// token required
+		{ "/lab/vm1", true, "" },
+	}
+
+	for _, tc := range tests {
+		raw := tc.raw
+		got, err := si.Validate_host( &raw, tc.tok_req )
+		if tc.want == "" {
+			if err == nil {
+				fmt.Fprintf( os.Stderr, "FAIL:   validate %s (token required=%v): expected error, got %s\n", tc.raw, tc.tok_req, gizmos.Safe_string( got ) )
+				t.Fail()
+			}
+			continue
+		}
+
+		if err != nil || got == nil || *got != tc.want {
+			fmt.Fprintf( os.Stderr, "FAIL:   validate %s (token required=%v): expected %s, got %s %v\n", tc.raw, tc.tok_req, tc.want, gizmos.Safe_string( got ), err )
+			t.Fail()
+		}
+	}
+}
+
+func Test_static_hostinfo( t *testing.T ) {
+	si := static_inv( t )
+
+	for _, h := range []string{ "lab/vm1", "lab-id/vm1", "lab/10.1.0.2", "lab/vm1-id", "lab/fd00::2" } {	// name, ip4, id and ip6 all find the host
+		msg := static_req( si.Get_hostinfo, &h )
+		vm, ok := msg.Response_data.( *managers.Net_vm )
+		if msg.State != nil || ! ok || vm == nil {
+			fmt.Fprintf( os.Stderr, "FAIL:   hostinfo %s: %v\n", h, msg.State )
+			t.Fail()
+			continue
+		}
+
+		name, id, ip4, ip6, phost, gw, mac, fip := vm.Get_values( )			// phost is returned before the gateway (as network uses them)
+		got := fmt.Sprintf( "%s %s %s %s %s %s %s %s", gizmos.Safe_string( name ), gizmos.Safe_string( id ), gizmos.Safe_string( ip4 ), gizmos.Safe_string( ip6 ),
+			gizmos.Safe_string( phost ), gizmos.Safe_string( gw ), gizmos.Safe_string( mac ), gizmos.Safe_string( fip ) )
+		want := "lab-id/vm1 vm1-id lab-id/10.1.0.2 lab-id/fd00::2 compute1 lab-id/10.1.0.1 fa:16:3e:01:00:02 lab-id/135.1.1.2"
+		if got != want {
+			fmt.Fprintf( os.Stderr, "FAIL:   hostinfo %s:\n\tgot:  %s\n\twant: %s\n", h, got, want )
+			t.Fail()
+		}
+		if gwm := vm.Get_gwmap( ); gizmos.Safe_string( gwm["fa:16:3e:01:00:01"] ) != "lab-id/10.1.0.1" {
+			fmt.Fprintf( os.Stderr, "FAIL:   hostinfo %s: gateway map not set: %v\n", h, gwm )
+			t.Fail()
+		}
+	}
+
+	h := "lab/10.1.0.1"													// gateways can be looked up by address
+	msg := static_req( si.Get_hostinfo, &h )
+	if vm, ok := msg.Response_data.( *managers.Net_vm ); msg.State != nil || ! ok || vm == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   hostinfo %s: gateway not found: %v\n", h, msg.State )
+		t.Fail()
+	} else {
+		name, _, _, _, phost, _, mac, _ := vm.Get_values( )
+		if gizmos.Safe_string( name ) != "lab-id/10.1.0.1" || gizmos.Safe_string( phost ) != "netnode1" || gizmos.Safe_string( mac ) != "fa:16:3e:01:00:01" {
+			fmt.Fprintf( os.Stderr, "FAIL:   hostinfo %s: unexpected gateway: %s %s %s\n", h, gizmos.Safe_string( name ), gizmos.Safe_string( phost ), gizmos.Safe_string( mac ) )
+			t.Fail()
+		}
+	}
+
+	for _, h := range []string{ "lab/nosuch", "dev/vm2", "nosuch/vm1", "vm1", "lab/", "/vm1" } {
+		msg := static_req( si.Get_hostinfo, &h )
+		if msg.State == nil || msg.Response_data != nil {
+			fmt.Fprintf( os.Stderr, "FAIL:   hostinfo %s: expected error, got %v\n", h, msg.Response_data )
+			t.Fail()
+		}
+	}
+
+	h = "!/10.1.0.2"													// unvalidated address only; nothing to return, but not an error
+	if msg := static_req( si.Get_hostinfo, &h ); msg.State != nil || msg.Response_data != nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   hostinfo %s: expected no data and no error: %v %v\n", h, msg.Response_data, msg.State )
+		t.Fail()
+	}
+}
+
+func Test_static_defgw( t *testing.T ) {
+	si := static_inv( t )
+
+	for _, p := range []string{ "lab", "lab-id", "lab/vm1" } {
+		msg := static_req( si.Get_defgw, &p )
+		if gw, ok := msg.Response_data.( *string ); msg.State != nil || ! ok || gizmos.Safe_string( gw ) != "lab-id/10.1.0.1" {
+			fmt.Fprintf( os.Stderr, "FAIL:   defgw %s: %v %v\n", p, msg.Response_data, msg.State )
+			t.Fail()
+		}
+	}
+
+	for _, p := range []string{ "dev", "nosuch", "!/vm1", "" } {			// dev has no gateway
+		msg := static_req( si.Get_defgw, &p )
+		if gw, ok := msg.Response_data.( *string ); msg.State == nil || ! ok || gw != nil {
+			fmt.Fprintf( os.Stderr, "FAIL:   defgw %q: expected error and a nil *string: %v %v\n", p, msg.Response_data, msg.State )
+			t.Fail()
+		}
+	}
+
+	if msg := static_req( si.Get_defgw, nil ); msg.State == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   defgw: missing request data not rejected\n" )
+		t.Fail()
+	}
+}
+
+func Test_static_ip2mac( t *testing.T ) {
+	si := static_inv( t )
+
+	m, err := si.Get_ip2mac( )
+	if err != nil {
+		t.Fatalf( "ip2mac: %s", err )
+	}
+
+	want := map[string]string {
+		"lab-id/10.1.0.1":	"fa:16:3e:01:00:01",
+		"lab-id/10.1.0.2":	"fa:16:3e:01:00:02",
+		"lab-id/fd00::2":	"fa:16:3e:01:00:02",
+		"lab-id/10.1.0.3":	"fa:16:3e:01:00:03",
+		"dev/10.2.0.2":		"fa:16:3e:02:00:02",
+	}
+	for ip, mac := range want {
+		if gizmos.Safe_string( m[ip] ) != mac {
+			fmt.Fprintf( os.Stderr, "FAIL:   ip2mac %s: expected %s, got %s\n", ip, mac, gizmos.Safe_string( m[ip] ) )
+			t.Fail()
+		}
+	}
+	if len( m ) != len( want ) {											// host without a mac must not be in the map
+		fmt.Fprintf( os.Stderr, "FAIL:   ip2mac: expected %d entries, got %d: %v\n", len( want ), len( m ), m )
+		t.Fail()
+	}
+}
+
+func Test_static_malformed( t *testing.T ) {
+	get_harness( t )
+
+	fname := tmp_inv_file( t )
+	defer os.Remove( fname )
+
+	bad := map[string]string {
+		"not json":				`{ "projects": [ `,
+		"project without name":	`{ "projects": [ { "id": "x" } ] }`,
+		"duplicate project":	`{ "projects": [ { "name": "a" }, { "name": "b", "id": "a" } ] }`,
+		"host without ip4":		`{ "projects": [ { "name": "a", "hosts": [ { "name": "vm1" } ] } ] }`,
+		"duplicate host":		`{ "projects": [ { "name": "a", "hosts": [ { "name": "vm1", "ip4": "10.0.0.2" }, { "name": "vm2", "ip4": "10.0.0.2" } ] } ] }`,
+		"gateway without ip":	`{ "projects": [ { "name": "a", "gateways": [ { "mac": "fa:16:3e:00:00:01" } ] } ] }`,
+		"bad cidr":				`{ "projects": [ { "name": "a", "gateways": [ { "ip": "10.0.0.1", "cidr": "10.0.0.0" } ] } ] }`,
+		"empty token":			`{ "projects": [ { "name": "a" } ], "tokens": [ { "project": "a" } ] }`,
+		"token unknown project":	`{ "projects": [ { "name": "a" } ], "tokens": [ { "token": "t", "project": "b" } ] }`,
+	}
+	for what, contents := range bad {
+		if err := ioutil.WriteFile( fname, []byte( contents ), 0644 ); err != nil {
+			t.Fatalf( "%s", err )
+		}
+		if si, err := managers.Mk_static_inventory( fname, nil ); err == nil || si != nil {
+			fmt.Fprintf( os.Stderr, "FAIL:   malformed inventory (%s) was accepted\n", what )
+			t.Fail()
+		}
+	}
+
+	if _, err := managers.Mk_static_inventory( fname + ".missing", nil ); err == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   missing inventory file was accepted\n" )
+		t.Fail()
+	}
+
+	good, err := ioutil.ReadFile( "testdata/static_inv.json" )				// a bad reload must keep the last good inventory
+	if err == nil {
+		err = ioutil.WriteFile( fname, good, 0644 )
+	}
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	si, err := managers.Mk_static_inventory( fname, nil )
+	if err != nil {
+		t.Fatalf( "unable to load the fixture copy: %s", err )
+	}
+
+	if err = ioutil.WriteFile( fname, []byte( bad["duplicate host"] ), 0644 ); err != nil {
+		t.Fatalf( "%s", err )
+	}
+	si.Refresh( )
+	h := "lab/vm2"
+	if msg := static_req( si.Get_hostinfo, &h ); msg.State != nil || msg.Response_data == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   last good inventory not kept after a malformed reload: %v\n", msg.State )
+		t.Fail()
+	}
+
+	if err = ioutil.WriteFile( fname, []byte( `{ "projects": [ { "name": "new", "hosts": [ { "name": "vm9", "ip4": "10.9.0.9" } ] } ] }` ), 0644 ); err != nil {
+		t.Fatalf( "%s", err )
+	}
+	si.Refresh( )															// now valid; must be picked up
+	h = "new/vm9"
+	if msg := static_req( si.Get_hostinfo, &h ); msg.State != nil || msg.Response_data == nil {
+		fmt.Fprintf( os.Stderr, "FAIL:   corrected inventory not loaded on refresh: %v\n", msg.State )
+		t.Fail()
+	} else {
+		fmt.Fprintf( os.Stderr, "OK:     static inventory: malformed files rejected; last good kept until corrected\n" )
+	}
+}
+
+/*
+	Return the name of a temporary file which the caller must remove.
+*/
+func tmp_inv_file( t *testing.T ) ( string ) {
+	f, err := ioutil.TempFile( "", "static_inv" )
+	if err != nil {
+		t.Fatalf( "%s", err )
+	}
+	f.Close( )
+
+	return f.Name()
+}
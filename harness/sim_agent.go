@@ -0,0 +1,443 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	sim_agent
+	Abstract:	A simulated tegu_agent. It connects to the agent manager port, unpacks each
+				action_list sent by tegu and records every action it receives rather than
+				running the OVS scripts. Responses are generated as the real agent would:
+					map_mac2phost		the mac to physical host list given when created
+					dump_state			the flow-mods in the simulated flow table for each host
+					all others			an ack listing each host as successful, or a nack
+										if any host was set to fail with Set_fail()
+				As with the real agent, actions without an id are not acked, and a hello
+				message listing the actions supported and the hosts reached is sent when
+				the session is established. Heartbeats from tegu are answered (the real agent
+				sends its own on a timer) unless the agent was made silent with Set_silent()
+				to simulate a hung agent: actions are still recorded but nothing is sent.
+
+				A simple flow table is kept for each host so that the reconciler can be tested:
+				bw_fmod adds a flow-mod in each direction, bwow_fmod adds one, and a flowmod
+				action with a delete removes those matching its cookie and macs.
+
+	Date:		17 October 2026
+	Mods:		17 Oct 2026 - Ack/nack every action with per host status.
+				17 Oct 2026 - Added the simulated flow table and dump_state.
+				17 Oct 2026 - Send hello on connect; Mk_sim_agent_caps() for limited agents.
+				17 Oct 2026 - Answer heartbeats; added Set_silent().
+
+*/
+
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	An action as tegu sends it (matches json_action in main/tegu_agent.go).
+*/
+type Sim_action struct {
+	Atype	string
+	Aid		uint32
+	Data	map[string]string
+	Qdata	[]string
+	Fdata	[]string
+	Hosts	[]string
+	Dscps	string
+}
+
+type sim_request struct {
+	Ctype	string
+	Actions	[]Sim_action
+}
+
+type sim_hstatus struct {
+	Host	string
+	State	int
+	Edata	[]string
+}
+
+/*
+	Actions supported by the real agent; the default capabilities of a simulated agent.
+*/
+var Sim_all_actions = []string{ "bw_fmod", "bwow_fmod", "dump_state", "flowmod", "intermed_queues", "map_mac2phost", "mirrorwiz", "setqueues" }
+
+type sim_caps struct {
+	Actions		[]string
+	Hosts		[]string
+	Parallel	int
+}
+
+type sim_response struct {
+	Ctype	string
+	Rtype	string
+	Rdata	[]string
+	State	int
+	Vinfo	string
+	Rid		uint32
+	Hstatus	[]sim_hstatus
+	Caps	*sim_caps	`json:",omitempty"`
+}
+
+type Sim_agent struct {
+	conn		net.Conn
+	mu			sync.Mutex
+	actions		[]Sim_action				// everything received, in order
+	mac2phost	map[string]string
+	fail		map[string]bool				// hosts which nack actions
+	silent		bool						// nothing is sent when set
+	flows		map[string]map[string]string	// simulated flow table: host -> cookie/smac/dmac -> dump record
+	done		chan bool
+}
+
+/*
+	Connect to the agent manager at host:port. The mac2phost map (mac -> physical host) is
+	returned in response to map_mac2phost actions.
+*/
+func Mk_sim_agent( host_port string, mac2phost map[string]string ) ( sa *Sim_agent, err error ) {
+	return Mk_sim_agent_caps( host_port, mac2phost, Sim_all_actions, nil )
+}
+
+/*
+	Connect to the agent manager and announce that only the actions listed are supported and
+	only the hosts listed can be reached (any host if hosts is empty).
+*/
+func Mk_sim_agent_caps( host_port string, mac2phost map[string]string, actions []string, hosts []string ) ( sa *Sim_agent, err error ) {
+	conn, err := net.DialTimeout( "tcp", host_port, 5 * time.Second )
+	if err != nil {
+		return nil, err
+	}
+
+	sa = &Sim_agent {
+		conn:		conn,
+		mac2phost:	mac2phost,
+		fail:		make( map[string]bool ),
+		flows:		make( map[string]map[string]string ),
+		done:		make( chan bool ),
+	}
+
+	sa.respond( &sim_response{ Ctype: "hello", Caps: &sim_caps{ Actions: actions, Hosts: hosts, Parallel: 1 } } )
+	go sa.listen( )
+	return
+}
+
+/*
+	Read requests from tegu until the session is closed.
+*/
+func (sa *Sim_agent) listen( ) {
+	defer close( sa.done )
+
+	dec := json.NewDecoder( sa.conn )
+	for {
+		req := &sim_request{ }
+		if err := dec.Decode( req ); err != nil {
+			return
+		}
+
+		if req.Ctype == "heartbeat" {
+			sa.respond( &sim_response{ Ctype: "heartbeat" } )
+			continue
+		}
+		if req.Ctype != "action_list" {
+			continue
+		}
+
+		for _, a := range req.Actions {
+			sa.mu.Lock()
+			sa.actions = append( sa.actions, a )
+			sa.mu.Unlock()
+
+			switch a.Atype {
+				case "map_mac2phost":
+					rdata := make( []string, 0, len( sa.mac2phost ) )
+					for mac, phost := range sa.mac2phost {
+						rdata = append( rdata, phost + " " + mac )
+					}
+					sort.Strings( rdata )
+					sa.respond( &sim_response{ Ctype: "response", Rtype: a.Atype, Rdata: rdata, Rid: a.Aid } )
+
+				case "dump_state":
+					resp := sa.mk_ack( &a )
+					resp.Ctype = "response"
+					resp.State = 0
+					resp.Rdata = sa.dump( &a )
+					sa.respond( resp )
+
+				default:
+					sa.apply( &a )
+					if a.Aid != 0 {
+						sa.respond( sa.mk_ack( &a ) )
+					}
+			}
+		}
+	}
+}
+
+/*
+	Build the ack, or nack if any of the action's hosts are set to fail.
+*/
+func (sa *Sim_agent) mk_ack( a *Sim_action ) ( resp *sim_response ) {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	resp = &sim_response{ Ctype: "ack", Rtype: a.Atype, Rid: a.Aid, Hstatus: []sim_hstatus{ } }
+	for _, h := range a.Hosts {
+		hs := sim_hstatus{ Host: h }
+		if sa.fail[h] {
+			hs.State = 1
+			hs.Edata = []string{ "sim_agent: forced failure" }
+			resp.Ctype = "nack"
+			resp.State++
+		}
+		resp.Hstatus = append( resp.Hstatus, hs )
+	}
+
+	return
+}
+
+/*
+	Update the simulated flow table for the flow-mod actions. Actions on a failing host are
+	not applied.
+*/
+func (sa *Sim_agent) apply( a *Sim_action ) {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	for _, h := range a.Hosts {
+		if sa.fail[h] {
+			continue
+		}
+
+		switch a.Atype {
+			case "bw_fmod":
+				sa.add_flow( h, "0xb0ff", a.Data["smac"], a.Data["dmac"] )
+				sa.add_flow( h, "0xb0ff", a.Data["dmac"], a.Data["smac"] )
+
+			case "bwow_fmod":
+				sa.add_flow( h, "0xf00d", a.Data["smac"], a.Data["dmac"] )
+
+			case "flowmod":
+				for _, f := range a.Fdata {
+					sa.del_flows( h, f )
+				}
+		}
+	}
+}
+
+/*
+	Add a flow-mod to the host's table; caller must hold the lock.
+*/
+func (sa *Sim_agent) add_flow( host string, cookie string, smac string, dmac string ) {
+	if smac == "" {
+		smac = "-"
+	}
+	if dmac == "" {
+		dmac = "-"
+	}
+
+	match := "priority=200"
+	if smac != "-" {
+		match += ",dl_src=" + smac
+	}
+	if dmac != "-" {
+		match += ",dl_dst=" + dmac
+	}
+
+	if sa.flows[host] == nil {
+		sa.flows[host] = make( map[string]string )
+	}
+	sa.flows[host][cookie + " " + smac + " " + dmac] = fmt.Sprintf( "flow %s %s 0 %s %s %s", host, cookie, smac, dmac, match )
+}
+
+/*
+	Remove the flow-mods matched by a send_ovs_fmod delete:
+		[-T table] --match [-s smac] [-d dmac] --action del cookie bridge
+*/
+func (sa *Sim_agent) del_flows( host string, fdata string ) {
+	smac := ""
+	dmac := ""
+	cookie := ""
+	toks := strings.Fields( fdata )
+	for i := 0; i < len( toks ) - 1; i++ {
+		switch toks[i] {
+			case "-s":		smac = toks[i+1]
+			case "-d":		dmac = toks[i+1]
+			case "del":		cookie = toks[i+1]
+		}
+	}
+	if cookie == "" {
+		return
+	}
+
+	for k := range sa.flows[host] {
+		ktoks := strings.Fields( k )
+		if ktoks[0] == cookie && (smac == "" || ktoks[1] == smac) && (dmac == "" || ktoks[2] == dmac) {
+			delete( sa.flows[host], k )
+		}
+	}
+}
+
+/*
+	Generate the dump_state records for the action's hosts.
+*/
+func (sa *Sim_agent) dump( a *Sim_action ) ( rdata []string ) {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	rdata = make( []string, 0 )
+	for _, h := range a.Hosts {
+		if sa.fail[h] {
+			continue
+		}
+		for _, rec := range sa.flows[h] {
+			rdata = append( rdata, rec )
+		}
+	}
+	sort.Strings( rdata )
+
+	return
+}
+
+/*
+	Empty the simulated flow tables (as if ovs were restarted on every host).
+*/
+func (sa *Sim_agent) Clear_flows( ) {
+	sa.mu.Lock()
+	sa.flows = make( map[string]map[string]string )
+	sa.mu.Unlock()
+}
+
+/*
+	Add a flow-mod to a host's simulated flow table without tegu having asked for it.
+*/
+func (sa *Sim_agent) Add_flow( host string, cookie string, smac string, dmac string ) {
+	sa.mu.Lock()
+	sa.add_flow( host, cookie, smac, dmac )
+	sa.mu.Unlock()
+}
+
+/*
+	Return the dump records for the flow-mods in the host's simulated flow table.
+*/
+func (sa *Sim_agent) Get_flows( host string ) ( list []string ) {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	list = make( []string, 0, len( sa.flows[host] ) )
+	for _, rec := range sa.flows[host] {
+		list = append( list, rec )
+	}
+	sort.Strings( list )
+
+	return
+}
+
+/*
+	Cause actions on the host to be nacked (or acked again if state is false).
+*/
+func (sa *Sim_agent) Set_fail( host string, state bool ) {
+	sa.mu.Lock()
+	sa.fail[host] = state
+	sa.mu.Unlock()
+}
+
+/*
+	When state is true the agent stops sending anything to tegu (heartbeats, acks and responses)
+	as though it were hung.
+*/
+func (sa *Sim_agent) Set_silent( state bool ) {
+	sa.mu.Lock()
+	sa.silent = state
+	sa.mu.Unlock()
+}
+
+func (sa *Sim_agent) respond( resp *sim_response ) {
+	sa.mu.Lock()
+	silent := sa.silent
+	sa.mu.Unlock()
+	if silent {
+		return
+	}
+
+	resp.Vinfo = "sim_agent"
+	if buf, err := json.Marshal( resp ); err == nil {
+		sa.conn.Write( buf )
+	}
+}
+
+/*
+	Return the actions of the given type received so far; all actions if atype is empty.
+*/
+func (sa *Sim_agent) Get_actions( atype string ) ( list []Sim_action ) {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	list = make( []Sim_action, 0 )
+	for _, a := range sa.actions {
+		if atype == "" || a.Atype == atype {
+			list = append( list, a )
+		}
+	}
+
+	return
+}
+
+/*
+	Wait until at least n actions of the type have been received, or the timeout expires.
+	The actions received are returned with an error if the count wasn't reached.
+*/
+func (sa *Sim_agent) Wait_for( atype string, n int, timeout time.Duration ) ( list []Sim_action, err error ) {
+	limit := time.Now().Add( timeout )
+	for {
+		if list = sa.Get_actions( atype ); len( list ) >= n {
+			return list, nil
+		}
+		if time.Now().After( limit ) {
+			return list, fmt.Errorf( "timeout waiting for %d %s actions; received %d", n, atype, len( list ) )
+		}
+
+		time.Sleep( 50 * time.Millisecond )
+	}
+}
+
+/*
+	Discard the actions recorded so far.
+*/
+func (sa *Sim_agent) Reset( ) {
+	sa.mu.Lock()
+	sa.actions = nil
+	sa.mu.Unlock()
+}
+
+/*
+	Drop the session with tegu.
+*/
+func (sa *Sim_agent) Close( ) {
+	sa.conn.Close()
+	<- sa.done
+}
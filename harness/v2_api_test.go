@@ -0,0 +1,340 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	v2_api_test
+	Abstract:	Tests for the v2 (json) api: each route is driven through the http listener
+				of the shared harness and the status code, Location header and json error
+				bodies are checked. Reservations are made in windows well in the future so
+				that they don't collide with those made by other tests. The harness runs with
+				priv_auth set to none, so the hosts authorisation check isn't exercised.
+	Date:		17 October 2026
+
+*/
+
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+	Check the status of a v2 response; when an error status is expected the body must be
+	a json error. Returns false (test marked failed) if the status isn't what was wanted.
+*/
+func v2_check( t *testing.T, what string, status int, resp string, err error, want int ) ( bool ) {
+	if err != nil || status != want {
+		fmt.Fprintf( os.Stderr, "FAIL:   v2 %s: expected status %d, got %d %v: %s\n", what, want, status, err, resp )
+		t.Fail()
+		return false
+	}
+
+	if want >= http.StatusBadRequest {
+		var e struct { Error string }
+		if json.Unmarshal( []byte( resp ), &e ) != nil || e.Error == "" {
+			fmt.Fprintf( os.Stderr, "FAIL:   v2 %s: error body is not json, or has no error: %s\n", what, resp )
+			t.Fail()
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+	Return the id from the json of a reservation.
+*/
+func v2_id( resp string ) ( string ) {
+	var r struct { Id string }
+	json.Unmarshal( []byte( resp ), &r )
+	return r.Id
+}
+
+/*
+	Check that the Location header references the named thing in the collection.
+*/
+func v2_location( t *testing.T, h *Harness, what string, hdr http.Header, collection string, id string ) {
+	want := fmt.Sprintf( "http://127.0.0.1:%s/tegu/v2/%s/%s", h.Api_port, collection, id )
+	if id == "" || hdr.Get( "Location" ) != want {
+		fmt.Fprintf( os.Stderr, "FAIL:   v2 %s: expected location %s, got %q\n", what, want, hdr.Get( "Location" ) )
+		t.Fail()
+	}
+}
+
+/*
+	Start of a window which nothing else uses; off is added so that tests don't share.
+*/
+func v2_start( off int64 ) ( string ) {
+	return fmt.Sprintf( "%d", time.Now().Unix() + 40000 + off )
+}
+
+func Test_v2_reservations( t *testing.T ) {
+	h := get_harness( t )
+
+	start := v2_start( 0 )
+	body := `{ "bandwidth_in": "10M", "start_time": "` + start + `", "end_time": "+300", "host1": "lab/vm1:7030", "host2": "lab/vm2:7030", "cookie": "c2", "priority": 2 }`
+
+	bad := map[string]string {
+		"bad json":				`{ "host1": `,
+		"missing host1":		`{ "bandwidth_in": "10M", "end_time": "+300" }`,
+		"missing bandwidth":	`{ "end_time": "+300", "host1": "lab/vm1" }`,
+		"missing end":			`{ "bandwidth_in": "10M", "host1": "lab/vm1" }`,
+		"end before start":		`{ "bandwidth_in": "10M", "start_time": "` + start + `", "end_time": "1000", "host1": "lab/vm1" }`,
+		"zero bandwidth":		`{ "bandwidth_in": "0", "end_time": "+300", "host1": "lab/vm1" }`,
+		"bad dscp":				`{ "bandwidth_in": "10M", "end_time": "+300", "host1": "lab/vm1", "dscp": "bogus" }`,
+		"bad protect":			`{ "bandwidth_in": "10M", "end_time": "+300", "host1": "lab/vm1", "host2": "lab/vm2", "protect": "bogus" }`,
+		"oneway series":		`{ "bandwidth_out": "10M", "end_time": "+86400", "host1": "lab/vm1", "oneway": true, "repeat": "FREQ=DAILY", "duration": 600 }`,
+	}
+	for what, b := range bad {
+		status, _, resp, err := h.V2( "POST", "/reservations", "", b )
+		v2_check( t, "post " + what, status, resp, err, http.StatusBadRequest )
+	}
+
+	status, hdr, resp, err := h.V2( "POST", "/reservations", "", body )
+	if ! v2_check( t, "post reservation", status, resp, err, http.StatusCreated ) {
+		t.FailNow()
+	}
+	id := v2_id( resp )
+	v2_location( t, h, "post reservation", hdr, "reservations", id )
+	defer h.V2( "DELETE", "/reservations/" + id + "?cookie=c2", "", "" )
+
+	status, _, resp, err = h.V2( "POST", "/reservations", "", body )		// same reservation is a duplicate
+	v2_check( t, "post duplicate", status, resp, err, http.StatusConflict )
+
+	status, _, resp, err = h.V2( "GET", "/reservations", "", "" )
+	if v2_check( t, "list", status, resp, err, http.StatusOK ) && ( ! json.Valid( []byte( resp ) ) || ! strings.Contains( resp, id ) ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   v2 list: %s not in the list: %s\n", id, resp )
+		t.Fail()
+	}
+
+	status, _, resp, err = h.V2( "GET", "/reservations/" + id + "?cookie=c2", "", "" )
+	if v2_check( t, "get", status, resp, err, http.StatusOK ) && ( v2_id( resp ) != id || ! strings.Contains( resp, `"priority": 2` ) ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   v2 get: unexpected reservation: %s\n", resp )
+		t.Fail()
+	}
+	status, _, resp, err = h.V2( "GET", "/reservations/" + id + "?cookie=wrong", "", "" )
+	v2_check( t, "get with wrong cookie", status, resp, err, http.StatusUnauthorized )
+	status, _, resp, err = h.V2( "GET", "/reservations/no-such-res", "", "" )
+	v2_check( t, "get unknown", status, resp, err, http.StatusNotFound )
+
+	status, _, resp, err = h.V2( "PUT", "/reservations/" + id, "", `{ "bandwidth_in": "20M", "bandwidth_out": "20M", "cookie": "c2" }` )
+	if v2_check( t, "put", status, resp, err, http.StatusOK ) {
+		status, _, resp, err = h.V2( "GET", "/reservations/" + id + "?cookie=c2", "", "" )
+		if ! strings.Contains( resp, `"bandwin": 20000000` ) || ! strings.Contains( resp, `"bandwout": 20000000` ) {
+			fmt.Fprintf( os.Stderr, "FAIL:   v2 put: bandwidth not modified: %d %v %s\n", status, err, resp )
+			t.Fail()
+		}
+	}
+	status, _, resp, err = h.V2( "PUT", "/reservations/" + id, "", `{ "bandwidth_in": "20M", "cookie": "wrong" }` )
+	v2_check( t, "put with wrong cookie", status, resp, err, http.StatusUnauthorized )
+	status, _, resp, err = h.V2( "PUT", "/reservations/" + id, "", `{ "bandwidth_in": "0", "cookie": "c2" }` )
+	v2_check( t, "put zero bandwidth", status, resp, err, http.StatusBadRequest )
+	status, _, resp, err = h.V2( "PUT", "/reservations/" + id, "", `{ "cookie": ` )
+	v2_check( t, "put bad json", status, resp, err, http.StatusBadRequest )
+	status, _, resp, err = h.V2( "PUT", "/reservations/no-such-res", "", `{ "bandwidth_in": "20M", "cookie": "c2" }` )
+	v2_check( t, "put unknown", status, resp, err, http.StatusNotFound )
+	status, _, resp, err = h.V2( "PUT", "/reservations", "", `{ "bandwidth_in": "20M" }` )
+	v2_check( t, "put without name", status, resp, err, http.StatusMethodNotAllowed )
+
+	status, _, resp, err = h.V2( "DELETE", "/reservations/" + id + "?cookie=wrong", "", "" )
+	v2_check( t, "delete with wrong cookie", status, resp, err, http.StatusUnauthorized )
+	status, _, resp, err = h.V2( "DELETE", "/reservations/no-such-res?cookie=c2", "", "" )
+	v2_check( t, "delete unknown", status, resp, err, http.StatusNotFound )
+	status, _, resp, err = h.V2( "DELETE", "/reservations", "", "" )
+	v2_check( t, "delete without name", status, resp, err, http.StatusMethodNotAllowed )
+	status, _, resp, err = h.V2( "DELETE", "/reservations/" + id + "?cookie=c2", "", "" )
+	if v2_check( t, "delete", status, resp, err, http.StatusNoContent ) {
+		fmt.Fprintf( os.Stderr, "OK:     v2 reservation %s created, fetched, modified and deleted\n", id )
+	}
+}
+
+/*
+	Oneway, recurring (series) and protected reservations.
+*/
+func Test_v2_reservation_kinds( t *testing.T ) {
+	h := get_harness( t )
+
+	start := v2_start( 2000 )
+	status, hdr, resp, err := h.V2( "POST", "/reservations", "", `{ "bandwidth_out": "10M", "start_time": "` + start + `", "end_time": "+300", "host1": "lab/vm1:7031", "host2": "lab/vm2:7031", "cookie": "c2", "oneway": true }` )
+	if v2_check( t, "post oneway", status, resp, err, http.StatusCreated ) {
+		id := v2_id( resp )
+		v2_location( t, h, "post oneway", hdr, "reservations", id )
+		status, _, resp, err = h.V2( "DELETE", "/reservations/" + id + "?cookie=c2", "", "" )
+		v2_check( t, "delete oneway", status, resp, err, http.StatusNoContent )
+	}
+
+	start = v2_start( 4000 )
+	series := `{ "bandwidth_in": "10M", "start_time": "` + start + `", "end_time": "+86400", "host1": "lab/vm1:7032", "host2": "lab/vm2:7032", "cookie": "c2", "repeat": "FREQ=HOURLY/COUNT=3", "duration": 600 }`
+	status, hdr, resp, err = h.V2( "POST", "/reservations", "", series )
+	if v2_check( t, "post series", status, resp, err, http.StatusCreated ) {
+		id := v2_id( resp )
+		v2_location( t, h, "post series", hdr, "reservations", id )
+		status, _, resp, err = h.V2( "DELETE", "/reservations/" + id + "?cookie=c2", "", "" )
+		v2_check( t, "delete series", status, resp, err, http.StatusNoContent )
+	}
+	status, _, resp, err = h.V2( "POST", "/reservations", "", strings.Replace( series, `"duration": 600`, `"duration": 0`, 1 ) )
+	v2_check( t, "post series without duration", status, resp, err, http.StatusBadRequest )
+
+	start = v2_start( 6000 )			// there is only one switch between the hosts; no disjoint pair exists
+	status, _, resp, err = h.V2( "POST", "/reservations", "", `{ "bandwidth_in": "10M", "start_time": "` + start + `", "end_time": "+300", "host1": "lab/vm1:7033", "host2": "lab/vm2:7033", "cookie": "c2", "protect": "switch" }` )
+	v2_check( t, "post protected", status, resp, err, http.StatusConflict )
+}
+
+/*
+	Queued reservations are accepted (202) when there is no capacity; a higher priority
+	reservation preempts.
+*/
+func Test_v2_queue_priority( t *testing.T ) {
+	h := get_harness( t )
+
+	start := v2_start( 8000 )
+	window := fmt.Sprintf( "%s-%d", start, time.Now().Unix() + 40000 + 8300 )		// end may be a second later than start+300; it doesn't matter
+	fill, err := fill_links( h, 7034, window )
+	if err != nil {
+		t.Fatalf( "unable to fill the links: %s", err )
+	}
+	defer h.Post( "cancelres " + fill + " cookie" )
+
+	body := `{ "bandwidth_in": "10M", "start_time": "` + start + `", "end_time": "+300", "host1": "lab/vm1:7035", "host2": "lab/vm2:7035", "cookie": "c2" }`
+	status, _, resp, err := h.V2( "POST", "/reservations", "", body )
+	v2_check( t, "post without capacity", status, resp, err, http.StatusConflict )
+
+	status, hdr, resp, err := h.V2( "POST", "/reservations", "", strings.Replace( body, `"cookie"`, `"queue": true, "cookie"`, 1 ) )
+	if v2_check( t, "post queued", status, resp, err, http.StatusAccepted ) {
+		if hdr.Get( "Location" ) != "" {
+			fmt.Fprintf( os.Stderr, "FAIL:   v2 post queued: location given for a reservation which wasn't created: %s\n", hdr.Get( "Location" ) )
+			t.Fail()
+		}
+		if id := v2_id( resp ); id != "" {
+			h.V2( "DELETE", "/reservations/" + id + "?cookie=c2", "", "" )
+		}
+	}
+
+	status, hdr, resp, err = h.V2( "POST", "/reservations", "", strings.Replace( body, `"cookie"`, `"priority": 3, "cookie"`, 1 ) )
+	if v2_check( t, "post preempting", status, resp, err, http.StatusCreated ) {
+		id := v2_id( resp )
+		v2_location( t, h, "post preempting", hdr, "reservations", id )
+		h.V2( "DELETE", "/reservations/" + id + "?cookie=c2", "", "" )
+		fmt.Fprintf( os.Stderr, "OK:     v2 queued reservation accepted; priority reservation %s preempted %s\n", id, fill )
+	}
+}
+
+func Test_v2_slots( t *testing.T ) {
+	h := get_harness( t )
+
+	var sr struct {
+		Slots []struct {
+			Commence	int64
+			Expiry		int64
+		}
+		Reservation	*struct { Id string }
+	}
+
+	start := v2_start( 10000 )
+	body := `{ "bandwidth_in": "10M", "duration": 300, "start_time": "` + start + `", "horizon": 3600, "count": 2, "host1": "lab/vm1:7036", "host2": "lab/vm2:7036", "cookie": "c2" }`
+
+	status, _, resp, err := h.V2( "POST", "/slots", "", body )
+	if v2_check( t, "slots", status, resp, err, http.StatusOK ) {
+		if json.Unmarshal( []byte( resp ), &sr ) != nil || len( sr.Slots ) != 2 || sr.Slots[0].Expiry - sr.Slots[0].Commence != 300 || sr.Reservation != nil {
+			fmt.Fprintf( os.Stderr, "FAIL:   v2 slots: unexpected windows: %s\n", resp )
+			t.Fail()
+		}
+	}
+
+	status, _, resp, err = h.V2( "POST", "/slots", "", strings.Replace( body, `"cookie"`, `"book": true, "cookie"`, 1 ) )
+	if v2_check( t, "slots book", status, resp, err, http.StatusOK ) {
+		sr.Reservation = nil
+		if json.Unmarshal( []byte( resp ), &sr ) != nil || sr.Reservation == nil || sr.Reservation.Id == "" {
+			fmt.Fprintf( os.Stderr, "FAIL:   v2 slots book: no reservation booked: %s\n", resp )
+			t.Fail()
+		} else {
+			h.V2( "DELETE", "/reservations/" + sr.Reservation.Id + "?cookie=c2", "", "" )
+			fmt.Fprintf( os.Stderr, "OK:     v2 slots: %d windows; booked %s\n", len( sr.Slots ), sr.Reservation.Id )
+		}
+	}
+
+	status, _, resp, err = h.V2( "POST", "/slots", "", `{ "bandwidth_in": "10M", "host1": "lab/vm1" }` )
+	v2_check( t, "slots without duration", status, resp, err, http.StatusBadRequest )
+	status, _, resp, err = h.V2( "POST", "/slots", "", `{ "duration": ` )
+	v2_check( t, "slots bad json", status, resp, err, http.StatusBadRequest )
+	status, _, resp, err = h.V2( "GET", "/slots", "", "" )
+	v2_check( t, "slots get", status, resp, err, http.StatusMethodNotAllowed )
+}
+
+func Test_v2_steering( t *testing.T ) {
+	h := get_harness( t )
+
+	start := v2_start( 12000 )
+	status, _, resp, err := h.V2( "POST", "/steering", "", `{ "end_time": "+300", "project": "lab", "endpoint1": "vm1", "endpoint2": "vm2", "cookie": "c2" }` )
+	v2_check( t, "steer without middleboxes", status, resp, err, http.StatusBadRequest )
+	status, _, resp, err = h.V2( "POST", "/steering", "", `{ "project": ` )
+	v2_check( t, "steer bad json", status, resp, err, http.StatusBadRequest )
+
+	status, hdr, resp, err := h.V2( "POST", "/steering", "", `{ "start_time": "` + start + `", "end_time": "+300", "project": "lab", "endpoint1": "vm1", "endpoint2": "vm2", "middleboxes": [ "fw1" ], "cookie": "c2" }` )
+	if ! v2_check( t, "steer", status, resp, err, http.StatusCreated ) {
+		t.FailNow()
+	}
+	id := v2_id( resp )
+	v2_location( t, h, "steer", hdr, "steering", id )
+
+	status, _, resp, err = h.V2( "GET", "/steering/" + id + "?cookie=c2", "", "" )
+	if v2_check( t, "steer get", status, resp, err, http.StatusOK ) && v2_id( resp ) != id {
+		fmt.Fprintf( os.Stderr, "FAIL:   v2 steer get: unexpected reservation: %s\n", resp )
+		t.Fail()
+	}
+	status, _, resp, err = h.V2( "GET", "/steering/" + id + "?cookie=wrong", "", "" )
+	v2_check( t, "steer get with wrong cookie", status, resp, err, http.StatusUnauthorized )
+
+	status, _, resp, err = h.V2( "DELETE", "/steering/" + id + "?cookie=c2", "", "" )
+	if v2_check( t, "steer delete", status, resp, err, http.StatusNoContent ) {
+		fmt.Fprintf( os.Stderr, "OK:     v2 steering reservation %s created, fetched and deleted\n", id )
+	}
+	status, _, resp, err = h.V2( "PUT", "/steering/" + id, "", `{}` )
+	v2_check( t, "steer put", status, resp, err, http.StatusMethodNotAllowed )
+}
+
+func Test_v2_hosts( t *testing.T ) {
+	h := get_harness( t )
+
+	status, _, resp, err := h.V2( "GET", "/hosts", "", "" )
+	if v2_check( t, "hosts", status, resp, err, http.StatusOK ) && ( ! json.Valid( []byte( resp ) ) || ! strings.Contains( resp, "fa:16:3e:00:00:02" ) ) {
+		fmt.Fprintf( os.Stderr, "FAIL:   v2 hosts: vm1 not listed: %s\n", resp )
+		t.Fail()
+	}
+
+	status, _, resp, err = h.V2( "GET", "/hosts?project=lab", "", "" )
+	v2_check( t, "hosts for project", status, resp, err, http.StatusOK )
+	status, _, resp, err = h.V2( "GET", "/hosts?project=nosuch", "", "" )
+	v2_check( t, "hosts for unknown project", status, resp, err, http.StatusBadRequest )
+	status, _, resp, err = h.V2( "POST", "/hosts", "", "{}" )
+	v2_check( t, "hosts post", status, resp, err, http.StatusMethodNotAllowed )
+
+	status, _, resp, err = h.V2( "GET", "/nosuch", "", "" )
+	if v2_check( t, "unknown resource", status, resp, err, http.StatusNotFound ) {
+		fmt.Fprintf( os.Stderr, "OK:     v2 hosts listed; unknown resources and methods rejected\n" )
+	}
+}
@@ -133,6 +133,7 @@
 				06 Jul 2015 : Version bump
 				29 Jul 2015 : Tracker bug fixes (263,266) version bump.
 				03 Sep 2015 : Correct panic in network.go.
+				17 Oct 2026 : Start the reconciler (flow-mod and queue audit).
 
 	Version number "logic":
 				3.0		- QoS-Lite version of Tegu
@@ -182,6 +183,7 @@ func main() {
 		osif_ch chan *ipc.Chmsg		// openstack interface
 		fq_ch chan *ipc.Chmsg		// flow queue manager
 		am_ch chan *ipc.Chmsg		// agent manager channel
+		rc_ch chan *ipc.Chmsg		// reconciler
 
 		wgroup	sync.WaitGroup
 	)
@@ -221,8 +223,9 @@ func main() {
 	am_ch = make( chan *ipc.Chmsg, 1024 )			// agent manager channel
 	rmgr_ch = make( chan *ipc.Chmsg, 1024 );			// buffered to allow fq to send errors; should be more than fq buffer size to prevent deadlock
 	osif_ch = make( chan *ipc.Chmsg, 1024 )
+	rc_ch = make( chan *ipc.Chmsg, 128 )
 
-	err := managers.Initialise( cfg_file, &version, nw_ch, rmgr_ch, osif_ch, fq_ch, am_ch )		// specific things that must be initialised with data from main so init() doesn't work
+	err := managers.Initialise( cfg_file, &version, nw_ch, rmgr_ch, osif_ch, fq_ch, am_ch, rc_ch )		// specific things that must be initialised with data from main so init() doesn't work
 	if err != nil {
 		sheep.Baa( 0, "ERR: unable to initialise: %s\n", err );
 		os.Exit( 1 )
@@ -234,6 +237,7 @@ func main() {
 	go managers.Network_mgr( nw_ch, fl_host )						// manage the network graph
 	go managers.Agent_mgr( am_ch )
 	go managers.Fq_mgr( fq_ch, fl_host );
+	go managers.Reconciler( rc_ch )									// audit of installed flow-mods and queues
 
 	my_chan := make( chan *ipc.Chmsg )								// channel and request block to ping net, and then to send all sys up
 	req := ipc.Mk_chmsg( )
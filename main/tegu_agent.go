@@ -24,11 +24,14 @@
 	Abstract:	An agent that connects to tegu and receives requests to act on.
 
 				Command line flags:
+					-driver type -- how ovs is driven: script (default) or go
 					-h host:port -- tegu host an port (default localhost:29055)
+					-hb n        -- seconds between heartbeats sent to tegu (default 30, 0 disables)
 					-i id	     -- ID number for this agent
 					-k key	     -- ssh key file for the ssh broker
 					-l directory -- logfile directory
 					-p n		 -- number of parallel ssh to run (default 10)
+					-reach hosts -- hosts (space or comma separated) this agent can reach (default any)
 					-no-rsync    -- turn off rsync feature
 					-rdir dir    -- rsync remote directory
 					-rlist list  -- list of files to sync to remote hosts
@@ -59,6 +62,20 @@
 				25 Jun 2015 : Now puts stderr out from a mirror command on failure or bleat level 2+.
 				16 Jul 2015 : Version bump to reflect link with ssh_broker library bug fix.
 				02 Sep 2015 : Pick up new agent script.
+				17 Oct 2026 : Added the go ovs driver (-driver go) for bw_fmod, bwow_fmod and setqueues;
+					the scripts are still used for everything else, and as a fallback.
+				17 Oct 2026 : Every action (except map_mac2phost) is acked/nacked with the status on
+					each host; bandwidth flow-mods no longer send a response. (bump to 2.4)
+				17 Oct 2026 : Added the dump_state action which lists the tegu flow-mods and the queues
+					on each host for the reconciler. (bump to 2.5)
+				17 Oct 2026 : A hello message announcing the version, supported actions, hosts reached
+					and parallelism is sent each time a connection to tegu is made. (bump to 2.6)
+				17 Oct 2026 : A heartbeat is sent to tegu every -hb seconds. Once tegu has sent a
+					heartbeat the session is dropped, and reestablished, if tegu goes silent for
+					three intervals while we are idle. (bump to 2.7)
+				17 Oct 2026 : Heartbeats are not sent while the main loop has been busy with a request for
+					more than three intervals so that tegu sees a hung agent as silent and moves its
+					actions to another agent.
 
 	NOTE:		There are three types of generic error/warning messages which have
 				the same message IDs (007, 008, 009) and thus are generated through
@@ -74,6 +91,9 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/att/gopkgs/bleater"
@@ -81,16 +101,25 @@ import (
 	"github.com/att/gopkgs/jsontools"
 	"github.com/att/gopkgs/ssh_broker"
 	"github.com/att/gopkgs/token"
+	"github.com/att/tegu/agent/ovs"
 )
 
 // globals
 var (
-	version		string = "v2.3/19025"
+	version		string = "v2.7/1a176"
 	sheep *bleater.Bleater
 	shell_cmd	string = "/bin/ksh"
 
 	running_sim	bool = false	// prevent queueing more if one is running (set up intermediate)
 	running_map bool = false	// map phost
+
+	ovs_drv		*ovs.Driver = nil	// native ovs driver; nil when everything is sent to the scripts
+	supported	[]string = []string{ "bw_fmod", "bwow_fmod", "dump_state", "flowmod", "intermed_queues", "map_mac2phost", "mirrorwiz", "setqueues" }
+	async_ch	chan []byte			// acks from actions run asynchronously (intermed_queues) are written here
+
+	last_tegu	int64				// time tegu was last heard from (atomic)
+	tegu_hb		int32				// set (atomic) once tegu has sent a heartbeat; older tegus don't
+	busy_since	int64				// time (atomic) processing of the current request started; 0 when idle
 )
 
 
@@ -124,6 +153,26 @@ type agent_msg struct {
 	State	int				// if an ack/nack some state information
 	Vinfo	string			// agent version info for debugging
 	Rid		uint32			// original request id
+	Hstatus	[]host_status	// per host status (ack/nack)
+	Caps	*agent_caps `json:",omitempty"`	// capabilities (hello only)
+}
+
+/*
+	Capabilities sent to tegu in the hello message.
+*/
+type agent_caps struct {
+	Actions		[]string		// action types handled by handle_blob()
+	Hosts		[]string		// hosts we can reach; empty if any
+	Parallel	int				// number of commands we run in parallel
+}
+
+/*
+	Status of an action on one host.
+*/
+type host_status struct {
+	Host	string
+	State	int				// 0 == success
+	Edata	[]string		// stderr (or a reason) when the action failed
 }
 //--- generic message functions ---------------------------------------------------------------------
 
@@ -145,11 +194,61 @@ func msg_009( cname string, host string ) {
 
 //----------------------------------------------------------------------------------------------------
 
+/*
+	Build the hello message which announces our version and capabilities to tegu.
+*/
+func mk_hello( reach []string, parallel int ) ( jout []byte ) {
+	msg := agent_msg {
+		Ctype:	"hello",
+		Vinfo:	version,
+		Caps:	&agent_caps{ Actions: supported, Hosts: reach, Parallel: parallel },
+	}
+
+	jout, _ = json.Marshal( msg )
+	return
+}
+
+/*
+	Send a heartbeat to tegu every hb seconds. The session is closed if tegu has sent heartbeats
+	and has been silent for three intervals; the main loop reconnects when the disconnect is
+	seen. The check is skipped while a request is being processed as the main loop isn't
+	reading tegu input then. This runs as a goroutine so that a long running request doesn't
+	stop the heartbeat, however if the main loop has been busy for more than three intervals
+	it is assumed to be hung and heartbeats are not sent until it finishes; tegu will then see
+	the agent as silent and send its unacked actions to another agent.
+*/
+func heartbeat( smgr *connman.Cmgr, hb int64 ) {
+	msg := agent_msg{ Ctype: "heartbeat", Vinfo: version }
+	jout, _ := json.Marshal( msg )
+
+	for {
+		time.Sleep( time.Duration( hb ) * time.Second )
+
+		now := time.Now().Unix()
+		if bs := atomic.LoadInt64( &busy_since ); bs != 0 {
+			if now - bs > hb * 3 {
+				sheep.Baa( 1, "busy with a request for %d seconds; heartbeat not sent", now - bs )
+			} else {
+				smgr.Write( "c0", jout )
+			}
+			atomic.StoreInt64( &last_tegu, now )
+			continue
+		}
+		smgr.Write( "c0", jout )
+
+		if atomic.LoadInt32( &tegu_hb ) != 0 && now - atomic.LoadInt64( &last_tegu ) > hb * 3 {
+			sheep.Baa( 0, "WRN: tegu has not been heard from in %d seconds; dropping the session  [TGUAGN011]", now - atomic.LoadInt64( &last_tegu ) )
+			atomic.StoreInt64( &last_tegu, now )
+			smgr.Close( "c0" )
+		}
+	}
+}
+
 /*
 	Establishes a connection with tegu. This blocks until a connection is established
-	and tries every few seconds until successful.
+	and tries every few seconds until successful. Once connected the hello message is sent.
 */
-func connect2tegu( smgr *connman.Cmgr, host_port *string, data_chan chan *connman.Sess_data ) {
+func connect2tegu( smgr *connman.Cmgr, host_port *string, data_chan chan *connman.Sess_data, hello []byte ) {
 
 	burble := 0		// limit our complaining to once a minute or so
 
@@ -157,6 +256,11 @@ func connect2tegu( smgr *connman.Cmgr, host_port *string, data_chan chan *connma
 		err := smgr.Connect( *host_port, "c0", data_chan )
 		if err == nil {
 			sheep.Baa( 1, "connection with tegu established: %s", *host_port )
+			atomic.StoreInt64( &last_tegu, time.Now().Unix() )
+			atomic.StoreInt32( &tegu_hb, 0 )				// until this tegu shows it sends them
+			if hello != nil {
+				smgr.Write( "c0", hello )
+			}
 			return
 		}
 
@@ -204,6 +308,91 @@ func  buf_into_array( buf bytes.Buffer, a []string, sidx int ) ( idx int ) {
 		}
 }
 
+/*
+	Return the newline separated records in the buffer as an array.
+*/
+func buf_lines( buf bytes.Buffer ) ( []string ) {
+	a := make( []string, 1024 )
+	return a[0:buf_into_array( buf, a, 0 )]
+}
+
+// --------------- ack/nack support ------------------------------------------------------------------------------
+
+/*
+	Create a status map for the hosts that an action is run on. Each host is assumed to be
+	successful until a failure is recorded.
+*/
+func mk_hstatus( hosts []string ) ( hsm map[string]*host_status ) {
+	hsm = make( map[string]*host_status, len( hosts ) )
+	for _, h := range hosts {
+		hsm[h] = &host_status{ Host: h }
+	}
+
+	return
+}
+
+/*
+	Record a failure for the host. If the host runs several commands for the action only the
+	first failure is kept.
+*/
+func hs_fail( hsm map[string]*host_status, host string, edata []string ) {
+	hs := hsm[host]
+	if hs == nil {
+		hs = &host_status{ Host: host }
+		hsm[host] = hs
+	}
+
+	if hs.State == 0 {
+		hs.State = 1
+		hs.Edata = edata
+	}
+}
+
+/*
+	Convert the status map into a list; hosts are listed in the order given.
+*/
+func hs_list( hosts []string, hsm map[string]*host_status ) ( list []host_status ) {
+	list = make( []host_status, 0, len( hsm ) )
+	for _, h := range hosts {
+		if hs := hsm[h]; hs != nil {
+			list = append( list, *hs )
+			delete( hsm, h )
+		}
+	}
+	for _, hs := range hsm {					// any the broker named differently
+		list = append( list, *hs )
+	}
+
+	return
+}
+
+/*
+	Build the ack (or nack if any host failed) for the action. Tegu versions which don't
+	assign action ids don't expect an ack, so nil is returned if the action has no id.
+*/
+func mk_ack( act *json_action, hstatus []host_status ) ( jout []byte ) {
+	if act.Aid == 0 {
+		return nil
+	}
+
+	msg := agent_msg {
+		Ctype:		"ack",
+		Rtype:		act.Atype,
+		Rid:		act.Aid,
+		Vinfo:		version,
+		Hstatus:	hstatus,
+	}
+	for i := range hstatus {
+		if hstatus[i].State != 0 {
+			msg.Ctype = "nack"
+			msg.State++						// number of hosts which failed
+		}
+	}
+
+	jout, _ = json.Marshal( msg )
+	return
+}
+
 // --------------- request support (command execution) ----------------------------------------------------------
 
 /*
@@ -262,7 +451,7 @@ func build_opt( value string, opt string ) ( parm string ) {
 	eliminates the need for Tegu to understand/know things like command line parms, bridge names and
 	such.  Parms in the map are converted to script command line options.
  */
-func (act *json_action ) do_bw_fmod( cmd_type string, broker *ssh_broker.Broker, path *string, timeout time.Duration ) ( jout []byte, err error ) {
+func (act *json_action ) do_bw_fmod( cmd_type string, broker *ssh_broker.Broker, path *string, timeout time.Duration ) ( hs host_status ) {
     var (
 		cmd_str string
     )
@@ -292,63 +481,15 @@ func (act *json_action ) do_bw_fmod( cmd_type string, broker *ssh_broker.Broker,
 
 
 	sheep.Baa( 1, "via broker on %s: %s", act.Hosts[0], cmd_str )
+	hs = run_bw_cmd( act.Hosts[0], cmd_str, broker, timeout )			// for now, there will only ever be one host for these commands
 
-	msg := agent_msg{}				// build response to send back
-	msg.Ctype = "response"
-	msg.Rtype = cmd_type
-	msg.Rid = act.Aid				// response id so tegu can map back to requestor
-	msg.Vinfo = version
-	msg.State = 0					// assume success
-
-	ssh_rch := make( chan *ssh_broker.Broker_msg, 256 )					// channel for ssh results
-																		// do NOT close the channel here; only senders should close
-
-	err = broker.NBRun_cmd( act.Hosts[0], cmd_str, 0, ssh_rch )			// for now, there will only ever be one host for these commands
-	if err != nil {
-		sheep.Baa( 1, "WRN: error submitting bandwidth command  to %s: %s", act.Hosts[0], err )
-		jout, _ = json.Marshal( msg )
-		return
-	}
-
-	rdata := make( []string, 8192 )								// response output converted to strings
-	edata := make( []string, 8192 )
-	ridx := 0													// index of next insert point into rdata
-	wait4 := 1
-	timer_pop := false
-	for wait4 > 0 && !timer_pop {								// wait for response back on the channel or the timer to pop
-		select {
-			case <- time.After( timeout * time.Second ):		// timeout if we don't get something back soonish
-				sheep.Baa( 1, "WRN: timeout waiting for response from %s; cmd: %s", act.Hosts[0], cmd_str )
-				timer_pop = true
-
-			case resp := <- ssh_rch:					// response from broker
-				wait4--
-				stdout, stderr, _, err := resp.Get_results()
-				host, _, _ := resp.Get_info()
-				eidx := buf_into_array( stderr, edata, 0 )			// capture error messages, if any
-				msg.Edata = edata[0:eidx]
-				if err != nil {
-					msg.State = 1
-					sheep.Baa( 1, "WRN: error running command: host=%s: %s", host, err )
-				} else {
-					ridx = buf_into_array( stdout, rdata, ridx )			// capture what came back for return
-				}
-				if err != nil || sheep.Would_baa( 2 ) {
-					dump_stderr( stderr, "bw_fmod " + host )			// always dump stderr on error, or in chatty mode
-				}
-		}
-	}
-
-	msg.Rdata = rdata[0:ridx]										// return just what was filled in
-
-	if msg.State > 0 {
-		sheep.Baa( 1, "bw_fmod (%s) failed: stdout: %d lines;  stderr: %d lines", cmd_type, len( msg.Rdata ), len( msg.Edata )  )
+	if hs.State > 0 {
+		sheep.Baa( 1, "bw_fmod (%s) failed: stderr: %d lines", cmd_type, len( hs.Edata ) )
 		sheep.Baa( 0, "ERR: %s unable to execute: %s	[TGUAGN000]", cmd_type, cmd_str )
 	} else {
-		sheep.Baa( 1, "bw_fmod cmd (%s) completed: stdout: %d lines;  stderr: %d lines", cmd_type, len( msg.Rdata ), len( msg.Edata )  )
+		sheep.Baa( 1, "bw_fmod cmd (%s) completed", cmd_type )
 	}
 
-	jout, err = json.Marshal( msg )
 	return
 }
 
@@ -357,7 +498,7 @@ func (act *json_action ) do_bw_fmod( cmd_type string, broker *ssh_broker.Broker,
 	eliminates the need for Tegu to understand/know things like command line parms, bridge names and
 	such.  Parms in the map are converted to script command line options.
  */
-func (act *json_action ) do_bwow_fmod( cmd_type string, broker *ssh_broker.Broker, path *string, timeout time.Duration ) ( jout []byte, err error ) {
+func (act *json_action ) do_bwow_fmod( cmd_type string, broker *ssh_broker.Broker, path *string, timeout time.Duration ) ( hs host_status ) {
     var (
 		cmd_str string
     )
@@ -382,63 +523,57 @@ func (act *json_action ) do_bwow_fmod( cmd_type string, broker *ssh_broker.Broke
 
 
 	sheep.Baa( 1, "via broker on %s: %s", act.Hosts[0], cmd_str )
+	hs = run_bw_cmd( act.Hosts[0], cmd_str, broker, timeout )			// oneway fmods are only ever applied to one host so [0] is ok
 
-	msg := agent_msg{}				// build response to send back
-	msg.Ctype = "response"
-	msg.Rtype = cmd_type
-	msg.Rid = act.Aid				// response id so tegu can map back to requestor
-	msg.Vinfo = version
-	msg.State = 0					// assume success
+	if hs.State > 0 {
+		sheep.Baa( 1, "bwow_fmod (%s) failed: stderr: %d lines", cmd_type, len( hs.Edata ) )
+		sheep.Baa( 0, "ERR: %s unable to execute: %s	[TGUAGN000]", cmd_type, cmd_str )
+	} else {
+		sheep.Baa( 1, "bwow_fmod cmd (%s) completed", cmd_type )
+	}
+
+	return
+}
+
+/*
+	Run a bandwidth flow-mod command on the host and wait for the result. The status on the
+	host is returned; stderr is captured on failure.
+*/
+func run_bw_cmd( host string, cmd_str string, broker *ssh_broker.Broker, timeout time.Duration ) ( hs host_status ) {
+	hs = host_status{ Host: host }
 
 	ssh_rch := make( chan *ssh_broker.Broker_msg, 256 )					// channel for ssh results
 																		// do NOT close the channel here; only senders should close
-	err = broker.NBRun_cmd( act.Hosts[0], cmd_str, 0, ssh_rch )			// oneway fmods are only ever applied to one host so [0] is ok
+	err := broker.NBRun_cmd( host, cmd_str, 0, ssh_rch )
 	if err != nil {
-		sheep.Baa( 1, "WRN: error submitting bwow command  to %s: %s", act.Hosts[0], err )
-		jout, _ = json.Marshal( msg )
+		sheep.Baa( 1, "WRN: error submitting bandwidth command  to %s: %s", host, err )
+		hs.State = 1
+		hs.Edata = []string{ err.Error() }
 		return
 	}
 
-	// TODO: this can be moved to a function
-	rdata := make( []string, 8192 )								// response output converted to strings
-	edata := make( []string, 8192 )
-	ridx := 0													// index of next insert point into rdata
-	wait4 := 1
-	timer_pop := false
-	for wait4 > 0 && !timer_pop {								// wait for response back on the channel or the timer to pop
-		select {
-			case <- time.After( timeout * time.Second ):		// timeout if we don't get something back soonish
-				sheep.Baa( 1, "WRN: timeout waiting for response from %s; cmd: %s", act.Hosts[0], cmd_str )
-				timer_pop = true
+	select {
+		case <- time.After( timeout * time.Second ):		// timeout if we don't get something back soonish
+			sheep.Baa( 1, "WRN: timeout waiting for response from %s; cmd: %s", host, cmd_str )
+			hs.State = 1
+			hs.Edata = []string{ "timeout waiting for the command to complete" }
 
-			case resp := <- ssh_rch:					// response from broker
-				wait4--
-				stdout, stderr, _, err := resp.Get_results()
-				host, _, _ := resp.Get_info()
-				eidx := buf_into_array( stderr, edata, 0 )			// capture error messages, if any
-				msg.Edata = edata[0:eidx]
-				if err != nil {
-					msg.State = 1
-					sheep.Baa( 1, "WRN: error running command: host=%s: %s", host, err )
-				} else {
-					ridx = buf_into_array( stdout, rdata, ridx )			// capture what came back for return
-				}
-				if err != nil || sheep.Would_baa( 2 ) {
-					dump_stderr( stderr, "bw_fmod " + host )			// always dump stderr on error, or in chatty mode
+		case resp := <- ssh_rch:							// response from broker
+			stdout, stderr, _, err := resp.Get_results()
+			rhost, _, _ := resp.Get_info()
+			sheep.Baa( 2, "bandwidth command on %s: stdout: %d lines", rhost, len( buf_lines( stdout ) ) )
+			if err != nil {
+				sheep.Baa( 1, "WRN: error running command: host=%s: %s", rhost, err )
+				hs.State = 1
+				if hs.Edata = buf_lines( stderr ); len( hs.Edata ) == 0 {
+					hs.Edata = []string{ err.Error() }
 				}
-		}
-	}
-
-	msg.Rdata = rdata[0:ridx]										// return just what was filled in
-
-	if msg.State > 0 {
-		sheep.Baa( 1, "bwow_fmod (%s) failed: stdout: %d lines;  stderr: %d lines", cmd_type, len( msg.Rdata ), len( msg.Edata )  )
-		sheep.Baa( 0, "ERR: %s unable to execute: %s	[TGUAGN000]", cmd_type, cmd_str )
-	} else {
-		sheep.Baa( 1, "bwow_fmod cmd (%s) completed: stdout: %d lines;  stderr: %d lines", cmd_type, len( msg.Rdata ), len( msg.Edata )  )
+			}
+			if err != nil || sheep.Would_baa( 2 ) {
+				dump_stderr( stderr, "bw_fmod " + rhost )			// always dump stderr on error, or in chatty mode
+			}
 	}
 
-	jout, err = json.Marshal( msg )
 	return
 }
 
@@ -519,7 +654,8 @@ func do_map_mac2phost( req json_action, broker *ssh_broker.Broker, path *string,
 	a significant amount of time on each host (10s of seconds) and so we submit the
 	command to the broker for each host in non-blocking mode to allow them to
 	run concurrently. Once submitted, we collect the results (reporting errors)
-	as the broker writes the response back on the channel.
+	as the broker writes the response back on the channel. As this runs asynchronously
+	the ack is written to the async channel for the main loop to send.
 */
 func do_intermedq( req json_action, broker *ssh_broker.Broker, path *string, timeout time.Duration ) {
 
@@ -531,6 +667,8 @@ func do_intermedq( req json_action, broker *ssh_broker.Broker, path *string, tim
 	ssh_rch := make( chan *ssh_broker.Broker_msg, 256 )		// channel for ssh results
 															// do NOT close the channel here; only senders should close
 
+	hsm := mk_hstatus( req.Hosts )
+	pending := make( map[string]bool )						// hosts we are waiting on
 	wait4 := 0												// number of responses to wait for
 	for i := range req.Hosts {
 		cmd_str := fmt.Sprintf( `PATH=%s:$PATH setup_ovs_intermed -d "%s"`, *path, req.Dscps )
@@ -539,7 +677,9 @@ func do_intermedq( req json_action, broker *ssh_broker.Broker, path *string, tim
 		err := broker.NBRun_cmd( req.Hosts[i], cmd_str, wait4, ssh_rch )
 		if err != nil {
 			msg_007( req.Hosts[i], cmd_str, err )
+			hs_fail( hsm, req.Hosts[i], []string{ err.Error() } )
 		} else {
+			pending[req.Hosts[i]] = true
 			wait4++
 		}
 	}
@@ -557,8 +697,10 @@ func do_intermedq( req json_action, broker *ssh_broker.Broker, path *string, tim
 				_, stderr, elapsed, err := resp.Get_results()
 				host, _, _ := resp.Get_info()
 				sheep.Baa( 2, "setup-intermed: received response from %s elap=%d err=%v, waiting for %d more", host, elapsed, err != nil, wait4 )
+				delete( pending, host )
 				if err != nil {
 					msg_009( "setup_intermed", host )
+					hs_fail( hsm, host, buf_lines( stderr ) )
 					errcount++
 				}
 				if err != nil || sheep.Would_baa( 2 ) {
@@ -567,9 +709,17 @@ func do_intermedq( req json_action, broker *ssh_broker.Broker, path *string, tim
 		}
 	}
 
+	for host := range pending {								// no response before the timer popped
+		hs_fail( hsm, host, []string{ "timeout waiting for the command to complete" } )
+	}
+
 	endt := time.Now().Unix()
 	sheep.Baa( 1, "setup-intermed: timeout=%v %ds elapsed for %d hosts %d errors", timer_pop, endt - startt, len( req.Hosts ), errcount )
 	running_sim = false
+
+	if jout := mk_ack( &req, hs_list( req.Hosts, hsm ) ); jout != nil {
+		async_ch <- jout
+	}
 }
 
 /*
@@ -582,13 +732,16 @@ func do_intermedq( req json_action, broker *ssh_broker.Broker, path *string, tim
 		endKat
 
 	We'll use the brokers 'send script for execution' feature rather to execute our script.
+
+	The status of the command on each host is returned.
 */
-func do_setqueues( req json_action, broker *ssh_broker.Broker, path *string, timeout time.Duration ) {
+func do_setqueues( req json_action, broker *ssh_broker.Broker, path *string, timeout time.Duration ) ( hstatus []host_status ) {
     var (
         err error
     )
 
 	startt := time.Now().Unix()
+	hsm := mk_hstatus( req.Hosts )
 
     fname := fmt.Sprintf( "/tmp/tegu_setq_%d_%x_%02d.data", os.Getpid(), time.Now().Unix(), rand.Intn( 10 ) )
     sheep.Baa( 3, "adjusting queues: creating %s will contain %d items", fname, len( req.Qdata ) );
@@ -596,7 +749,10 @@ func do_setqueues( req json_action, broker *ssh_broker.Broker, path *string, tim
     f, err := os.Create( fname )
     if err != nil {
         sheep.Baa( 0, "ERR: unable to create data file: %s: %s	[TGUAGN002]", fname, err )
-        return
+		for _, h := range req.Hosts {
+			hs_fail( hsm, h, []string{ "agent unable to create data file: " + err.Error() } )
+		}
+        return hs_list( req.Hosts, hsm )
     }
 
 	fmt.Fprintf( f, "#!/usr/bin/env ksh\ncat <<endKat | PATH=%s:$PATH create_ovs_queues\n", *path )
@@ -609,12 +765,16 @@ func do_setqueues( req json_action, broker *ssh_broker.Broker, path *string, tim
     err = f.Close( )
     if err != nil {
         sheep.Baa( 0, "ERR: unable to create data file (close): %s: %s	[TGUAGN003]", fname, err )
-        return
+		for _, h := range req.Hosts {
+			hs_fail( hsm, h, []string{ "agent unable to create data file: " + err.Error() } )
+		}
+        return hs_list( req.Hosts, hsm )
     }
 
 	ssh_rch := make( chan *ssh_broker.Broker_msg, 256 )		// channel for ssh results
 															// do NOT close the channel here; only senders should close
 
+	pending := make( map[string]bool )						// hosts we are waiting on
 	wait4 := 0												// number of responses to wait for
 	for i := range req.Hosts {
     	sheep.Baa( 1, "via broker on %s: create_ovs_queues embedded in %s", req.Hosts[i], fname )
@@ -622,7 +782,9 @@ func do_setqueues( req json_action, broker *ssh_broker.Broker, path *string, tim
 		err := broker.NBRun_on_host( req.Hosts[i], fname, "", wait4, ssh_rch )		// sends the file as input to be executed on the host
 		if err != nil {
 			msg_007( req.Hosts[i], "create_ovs_queues", err )
+			hs_fail( hsm, req.Hosts[i], []string{ err.Error() } )
 		} else {
+			pending[req.Hosts[i]] = true
 			wait4++
 		}
 	}
@@ -640,8 +802,10 @@ func do_setqueues( req json_action, broker *ssh_broker.Broker, path *string, tim
 				_, stderr, elapsed, err := resp.Get_results()
 				host, _, _ := resp.Get_info()
 				sheep.Baa( 2, "create-q: received response from %s elap=%d err=%v, waiting for %d more", host, elapsed, err != nil, wait4 )
+				delete( pending, host )
 				if err != nil {
         			sheep.Baa( 0, "ERR: unable to execute set queue command on %s: data=%s: %s  [TGUAGN004]", host, fname, err )
+					hs_fail( hsm, host, buf_lines( stderr ) )
 					errcount++
 				}  else {
         			sheep.Baa( 1, "queues adjusted succesfully on: %s", host )
@@ -652,24 +816,31 @@ func do_setqueues( req json_action, broker *ssh_broker.Broker, path *string, tim
 		}
 	}
 
+	for host := range pending {								// no response before the timer popped
+		hs_fail( hsm, host, []string{ "timeout waiting for the command to complete" } )
+	}
+
 	endt := time.Now().Unix()
 	sheep.Baa( 1, "create-q: timeout=%v %ds elapsed %d hosts %d errors", timer_pop, endt - startt, len( req.Hosts ), errcount )
 
 	if errcount == 0 {							// ditch the script we built earlier if all successful
 		os.Remove( fname )
 	} else {
-		sheep.Baa( 1, "create-q: %d errors, generated script file kept: %s", fname )
+		sheep.Baa( 1, "create-q: %d errors, generated script file kept: %s", errcount, fname )
 	}
 
+	return hs_list( req.Hosts, hsm )
 }
 
 /*
 	Extracts the information from the action passed in and causes the fmod command
-	to be executed.
+	to be executed. The status on each host is returned; a host fails if any of the
+	flow-mods could not be set there.
 */
-func do_fmod( req json_action, broker *ssh_broker.Broker, path *string, timeout time.Duration ) ( err error ){
+func do_fmod( req json_action, broker *ssh_broker.Broker, path *string, timeout time.Duration ) ( hstatus []host_status ) {
 
 	startt := time.Now().Unix()
+	hsm := mk_hstatus( req.Hosts )
 
 	errcount := 0
 	for f := range req.Fdata {
@@ -678,6 +849,7 @@ func do_fmod( req json_action, broker *ssh_broker.Broker, path *string, timeout
 		ssh_rch := make( chan *ssh_broker.Broker_msg, 256 )		// channel for ssh results
 																// do NOT close the channel here; only senders should close
 
+		pending := make( map[string]bool )						// hosts we are waiting on
 		wait4 := 0												// number of responses to wait for
 		for i := range req.Hosts {
 			sheep.Baa( 1, "via broker on %s send fmod: %s", req.Hosts[i], cstr )
@@ -685,13 +857,15 @@ func do_fmod( req json_action, broker *ssh_broker.Broker, path *string, timeout
 			err := broker.NBRun_cmd( req.Hosts[i], cstr, wait4, ssh_rch )		// sends the file as input to be executed on the host
 			if err != nil {
 				msg_007( req.Hosts[i], cstr, err )
+				hs_fail( hsm, req.Hosts[i], []string{ err.Error() } )
+				errcount++
 			} else {
+				pending[req.Hosts[i]] = true
 				wait4++
 			}
 		}
 
 		timer_pop := false
-		errcount := 0
 		for wait4 > 0 && !timer_pop {							// collect responses logging any errors
 			select {
 				case <- time.After( timeout * time.Second ):		// timeout
@@ -703,8 +877,10 @@ func do_fmod( req json_action, broker *ssh_broker.Broker, path *string, timeout
 					_, stderr, elapsed, err := resp.Get_results()
 					host, _, _ := resp.Get_info()
 					sheep.Baa( 1, "send-fmod: received response from %s elap=%d err=%v, waiting for %d more", host, elapsed, err != nil, wait4 )
+					delete( pending, host )
 					if err != nil {
 						sheep.Baa( 0, "ERR: unable to execute send-fmod command on %s: data=%s  %s	[TGUAGN004]", host, cstr, err )
+						hs_fail( hsm, host, buf_lines( stderr ) )
 						errcount++
 					}  else {
 						sheep.Baa( 1, "flow mod set on: %s", host )
@@ -714,19 +890,25 @@ func do_fmod( req json_action, broker *ssh_broker.Broker, path *string, timeout
 					}
 			}
 		}
+
+		for host := range pending {								// no response before the timer popped
+			hs_fail( hsm, host, []string{ "timeout waiting for the command to complete" } )
+			errcount++
+		}
 	}
 
 	endt := time.Now().Unix()
 	sheep.Baa( 1, "fmod: %ds elapsed %d fmods %d errors", endt - startt, len( req.Fdata ),  errcount )
 
-	return
+	return hs_list( req.Hosts, hsm )
 }
 
 /*
  *  Invoke the tegu_add_mirror or tegu_del_mirror command on a remote host in order to add/remove a mirror.
  */
-func do_mirrorwiz( req json_action, broker *ssh_broker.Broker, path *string ) {
+func do_mirrorwiz( req json_action, broker *ssh_broker.Broker, path *string ) ( hstatus []host_status ) {
 	startt := time.Now().UnixNano()
+	hsm := mk_hstatus( req.Hosts[0:1] )
 
 	cstr := ""
 	switch (req.Qdata[0]) {
@@ -745,6 +927,11 @@ func do_mirrorwiz( req json_action, broker *ssh_broker.Broker, path *string ) {
 		_, stderr, err := broker.Run_cmd( req.Hosts[0], cstr )
 		if err != nil {
 			sheep.Baa( 0, "ERR: send mirror cmd failed host=%s: %s	[TGUAGN005]", req.Hosts[0], err )
+			edata := []string{ err.Error() }
+			if stderr != nil {
+				edata = append( edata, buf_lines( *stderr )... )
+			}
+			hs_fail( hsm, req.Hosts[0], edata )
 		} else {
         	sheep.Baa( 2, "mirror cmd succesfully sent: %s", cstr )
 		}
@@ -753,9 +940,219 @@ func do_mirrorwiz( req json_action, broker *ssh_broker.Broker, path *string ) {
 		}
 	} else {
 		sheep.Baa( 0, "Unrecognized mirror command: " + req.Qdata[0] )
+		hs_fail( hsm, req.Hosts[0], []string{ "unrecognised mirror command: " + req.Qdata[0] } )
 	}
 	endt := time.Now().UnixNano()
 	sheep.Baa( 1, "do_mirrorwiz: %d ms elapsed", (endt - startt) / 1000 )
+
+	return hs_list( req.Hosts[0:1], hsm )
+}
+
+// --------------- native ovs driver support ----------------------------------------------------------
+
+/*
+	Implements the ovs.Runner interface using the ssh broker. The commands are written to a
+	script file which is sent to the host for execution in the same manner as set queues.
+	The script stops at the first command that fails.
+*/
+type broker_runner struct {
+	broker	*ssh_broker.Broker
+	timeout	time.Duration
+}
+
+func (br *broker_runner) Run( host string, cmds []*ovs.Cmd ) ( stdout []string, stderr []string, err error ) {
+	fname := fmt.Sprintf( "/tmp/tegu_ovs_%d_%x_%02d.ksh", os.Getpid(), time.Now().UnixNano(), rand.Intn( 100 ) )
+	f, err := os.Create( fname )
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fmt.Fprintf( f, "#!/usr/bin/env ksh\nset -e\nsudo=\"\"\nif (( $(id -u) != 0 ))\nthen\n\tsudo=sudo\nfi\n" )
+	for i := range cmds {
+		fmt.Fprintf( f, "timeout 20 $sudo %s\n", cmds[i].To_str() )
+	}
+	if err = f.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	ssh_rch := make( chan *ssh_broker.Broker_msg, 1 )			// do NOT close; only senders should close
+	if err = br.broker.NBRun_on_host( host, fname, "", 0, ssh_rch ); err != nil {
+		os.Remove( fname )
+		return nil, nil, err
+	}
+
+	select {
+		case <- time.After( br.timeout * time.Second ):
+			err = fmt.Errorf( "timeout waiting for ovs commands to complete on %s", host )
+
+		case resp := <- ssh_rch:
+			var obuf, ebuf bytes.Buffer
+			obuf, ebuf, _, err = resp.Get_results()
+			stdout = make( []string, 1024 )
+			stdout = stdout[0:buf_into_array( obuf, stdout, 0 )]
+			stderr = make( []string, 1024 )
+			stderr = stderr[0:buf_into_array( ebuf, stderr, 0 )]
+	}
+
+	if err == nil {
+		os.Remove( fname )
+	} else {
+		sheep.Baa( 1, "ovs driver: commands failed on %s, generated script file kept: %s", host, fname )
+	}
+	return
+}
+
+/*
+	Pass a bandwidth action to the driver. If the driver cannot build the flow-mods from the
+	data the action is run using the script and its status is returned, otherwise the flow-mods
+	are queued to be sent when the driver is flushed and queued is true.
+*/
+func drive_bw( act *json_action, broker *ssh_broker.Broker, path *string ) ( queued bool, hs host_status ) {
+	var err error
+
+	if act.Atype == "bw_fmod" {
+		err = ovs_drv.Bw_fmods( act.Hosts[0], act.Data )
+	} else {
+		err = ovs_drv.Bwow_fmods( act.Hosts[0], act.Data )
+	}
+	if err == nil {
+		return true, hs
+	}
+
+	sheep.Baa( 1, "WRN: ovs driver cannot handle %s (%s); using script", act.Atype, err )
+	if act.Atype == "bw_fmod" {
+		return false, act.do_bw_fmod( act.Atype, broker, path, 15 )
+	}
+	return false, act.do_bwow_fmod( act.Atype, broker, path, 15 )
+}
+
+/*
+	Send the flow-mods queued in the driver, one bundle per host, and build an ack/nack for each
+	of the actions. If the bundle fails on a host (e.g. an older OVS without bundle support)
+	the actions for that host are rerun using the scripts.
+*/
+func flush_driver( acts []*json_action, broker *ssh_broker.Broker, path *string ) ( resp [][]byte ) {
+	resp = make( [][]byte, 0, len( acts ) )
+
+	for _, host := range ovs_drv.Pending() {
+		_, stderr, err := ovs_drv.Flush( host )
+		if err != nil {
+			sheep.Baa( 1, "WRN: ovs driver: flow-mod bundle failed on %s: %s; using scripts", host, err )
+			if sheep.Would_baa( 2 ) {
+				for i := range stderr {
+					sheep.Baa( 2, "ovs driver %s stderr: %s", host, stderr[i] )
+				}
+			}
+		} else {
+			sheep.Baa( 1, "ovs driver: flow-mod bundle applied on %s", host )
+		}
+
+		for _, act := range acts {
+			if act.Hosts[0] != host {
+				continue
+			}
+
+			hs := host_status{ Host: host }
+			if err != nil {
+				if act.Atype == "bw_fmod" {
+					hs = act.do_bw_fmod( act.Atype, broker, path, 15 )
+				} else {
+					hs = act.do_bwow_fmod( act.Atype, broker, path, 15 )
+				}
+			}
+			if p := mk_ack( act, []host_status{ hs } ); p != nil {
+				resp = append( resp, p )
+			}
+		}
+	}
+
+	return
+}
+
+/*
+	Set queues using the driver. Hosts that fail are retried using the script. The status
+	on each host is returned.
+*/
+func drive_setqueues( req json_action, broker *ssh_broker.Broker, path *string ) ( hstatus []host_status ) {
+	failed := make( []string, 0, len( req.Hosts ) )
+
+	hstatus = make( []host_status, 0, len( req.Hosts ) )
+	for _, host := range req.Hosts {
+		_, stderr, err := ovs_drv.Set_queues( host, req.Qdata )
+		if err != nil {
+			sheep.Baa( 1, "WRN: ovs driver: set queues failed on %s: %s; using script", host, err )
+			for i := range stderr {
+				sheep.Baa( 2, "ovs driver %s stderr: %s", host, stderr[i] )
+			}
+			failed = append( failed, host )
+		} else {
+			sheep.Baa( 1, "ovs driver: queues adjusted succesfully on: %s", host )
+			hstatus = append( hstatus, host_status{ Host: host } )
+		}
+	}
+
+	if len( failed ) > 0 {
+		req.Hosts = failed
+		hstatus = append( hstatus, do_setqueues( req, broker, path, 30 )... )
+	}
+
+	return
+}
+
+/*
+	List the tegu flow-mods (those with one of the cookies in the action's data) and, if requested,
+	the queues on each host. There is no script for this, so the ovs driver is used even when
+	the scripts drive everything else. The hosts are run concurrently and the records from
+	all hosts are returned in a single response along with the status on each host.
+*/
+func do_dump_state( req json_action, broker *ssh_broker.Broker ) ( jout []byte ) {
+	var (
+		mu		sync.Mutex
+		wg		sync.WaitGroup
+	)
+
+	drv := ovs_drv
+	if drv == nil {
+		drv = ovs.Mk_driver( &broker_runner{ broker: broker, timeout: 30 } )
+	}
+
+	startt := time.Now().Unix()
+	cookies := strings.Fields( req.Data["cookies"] )
+	queues := req.Data["queues"] == "true"
+	hsm := mk_hstatus( req.Hosts )
+	rdata := make( []string, 0, 1024 )
+
+	for _, host := range req.Hosts {
+		wg.Add( 1 )
+		go func( host string ) {
+			defer wg.Done()
+
+			recs, stderr, err := drv.Dump_state( host, cookies, queues )
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				sheep.Baa( 1, "WRN: unable to dump flow-mods/queues on %s: %s  [TGUAGN010]", host, err )
+				hs_fail( hsm, host, append( []string{ err.Error() }, stderr... ) )
+				return
+			}
+			rdata = append( rdata, recs... )
+		}( host )
+	}
+	wg.Wait()
+
+	sheep.Baa( 1, "dump_state: %ds elapsed %d hosts %d records", time.Now().Unix() - startt, len( req.Hosts ), len( rdata ) )
+
+	msg := agent_msg {
+		Ctype:		"response",
+		Rtype:		req.Atype,
+		Rdata:		rdata,
+		Rid:		req.Aid,
+		Vinfo:		version,
+		Hstatus:	hs_list( req.Hosts, hsm ),
+	}
+	jout, _ = json.Marshal( msg )
+	return
 }
 
 /*
@@ -764,76 +1161,103 @@ func do_mirrorwiz( req json_action, broker *ssh_broker.Broker, path *string ) {
 	function will then split out the actions and invoke the proper do_* function to
 	exeute the action.
 
-	Returns a list of responses that should be written back to tegu, or nil if none of the
-	requests produced responses.
+	Returns a list of responses (acks/nacks and map_mac2phost data) that should be written back
+	to tegu, or nil if none of the requests produced responses.
 */
 func handle_blob( jblob []byte, broker *ssh_broker.Broker, path *string ) ( resp [][]byte ) {
 	var (
 		req	json_request		// unpacked request struct
-		ridx int = 0
+		queued []*json_action	// actions waiting on the ovs driver to be flushed
 	)
 
-	resp = make( [][]byte, 128 )
+	resp = make( [][]byte, 0, 128 )
 
     err := json.Unmarshal( jblob, &req )           // unpack the json
 	if err != nil {
 		sheep.Baa( 0, "ERR: unable to unpack request: %s	[TGUAGN006]", err )
 		sheep.Baa( 0, "got: %s", jblob )
-		return
+		return nil
+	}
+
+	if req.Ctype == "heartbeat" {					// nothing to do; just note that tegu sends them
+		atomic.StoreInt32( &tegu_hb, 1 )
+		return nil
 	}
 
 	if req.Ctype != "action_list" {
 		sheep.Baa( 0, "unknown request type received from tegu: %s", req.Ctype )
-		return
+		return nil
 	}
 
 	for i := range req.Actions {
+		var hstatus []host_status		// status on each host; ack/nack generated if set
+
 		switch( req.Actions[i].Atype ) {
 			case "setqueues":								// set queues
-					do_setqueues( req.Actions[i], broker, path, 30 )
+					if ovs_drv != nil {
+						hstatus = drive_setqueues( req.Actions[i], broker, path )
+					} else {
+						hstatus = do_setqueues( req.Actions[i], broker, path, 30 )
+					}
 
 			case "flowmod":									// set a flow mod
-					do_fmod( req.Actions[i], broker, path, 30 )
+					hstatus = do_fmod( req.Actions[i], broker, path, 30 )
 
 			case "map_mac2phost":							// run script to generate mac to physical host mappings
 					p, err := do_map_mac2phost( req.Actions[i], broker, path, 15 )
 					if err == nil {
-						resp[ridx] = p
-						ridx++
+						resp = append( resp, p )
 					}
 
 			case "intermed_queues":													// setup intermediate queues
 					if ! running_sim {												// it's not good to start overlapping setup scripts
-						go do_intermedq(  req.Actions[i], broker, path, 3600 )		// this can run asynch since there isn't any output
+						go do_intermedq(  req.Actions[i], broker, path, 3600 )		// this can run asynch; the ack is sent when it finishes
 					} else {
 						sheep.Baa( 1, "handle blob: setqueues still running, not restarted" )
+						hstatus = []host_status{ }									// the running one will do the work; ack with no hosts
 					}
 
 			case "mirrorwiz":
-					do_mirrorwiz(req.Actions[i], broker, path)
+					hstatus = do_mirrorwiz( req.Actions[i], broker, path )
 
-			case "bw_fmod":									// new bandwidth flow-mod
-					p, err := req.Actions[i].do_bw_fmod( req.Actions[i].Atype, broker, path, 15 )
-					if err == nil {
-						resp[ridx] = p
-						ridx++
-					}
+			case "dump_state":								// list flow-mods and queues for the reconciler; status is in the response
+					resp = append( resp, do_dump_state( req.Actions[i], broker ) )
 
-			case "bwow_fmod":									// generate oneway bandwidth flow-mods
-					p, err := req.Actions[i].do_bwow_fmod( req.Actions[i].Atype, broker, path, 15 )
-					if err == nil {
-						resp[ridx] = p
-						ridx++
+			case "bw_fmod", "bwow_fmod":					// bandwidth and oneway bandwidth flow-mods
+					var hs host_status
+
+					if ovs_drv != nil {
+						var q bool
+						if q, hs = drive_bw( &req.Actions[i], broker, path ); q {
+							queued = append( queued, &req.Actions[i] )		// ack built when the driver is flushed
+							continue
+						}
+					} else {
+						if req.Actions[i].Atype == "bw_fmod" {
+							hs = req.Actions[i].do_bw_fmod( req.Actions[i].Atype, broker, path, 15 )
+						} else {
+							hs = req.Actions[i].do_bwow_fmod( req.Actions[i].Atype, broker, path, 15 )
+						}
 					}
+					hstatus = []host_status{ hs }
 
 			default:
 				sheep.Baa( 0, "unknown action type received from tegu: %s", req.Actions[i].Atype )
+				hstatus = []host_status{ { Host: "", State: 1, Edata: []string{ "unknown action type: " + req.Actions[i].Atype } } }
+		}
+
+		if hstatus != nil {
+			if p := mk_ack( &req.Actions[i], hstatus ); p != nil {
+				resp = append( resp, p )
+			}
 		}
 	}
 
-	if ridx > 0 {
-		resp = resp[0:ridx]
-	} else {
+	if len( queued ) > 0 {										// send everything the driver collected; one bundle per host
+		resp = append( resp, flush_driver( queued, broker, path )... )
+	}
+
+	if len( resp ) == 0 {
 		resp = nil
 	}
 
@@ -843,7 +1267,7 @@ func handle_blob( jblob []byte, broker *ssh_broker.Broker, path *string ) ( resp
 
 func usage( version string ) {
 	fmt.Fprintf( os.Stdout, "tegu_agent %s\n", version )
-	fmt.Fprintf( os.Stdout, "usage: tegu_agent -i id [-h host:port] [-l log-dir] [-p n] [-v | -V level] [-k key] [-no-rsync] [-rdir dir] [-rlist list] [-u user]\n" )
+	fmt.Fprintf( os.Stdout, "usage: tegu_agent -i id [-h host:port] [-hb seconds] [-l log-dir] [-p n] [-reach hosts] [-v | -V level] [-k key] [-no-rsync] [-rdir dir] [-rlist list] [-u user] [-driver script|go]\n" )
 }
 
 func main() {
@@ -881,9 +1305,12 @@ func main() {
 	rdir := flag.String( "rdir", def_rdir, "rsync remote directory" )
 	rlist := flag.String( "rlist", def_rlist, "rsync file list" )
 	tegu_host := flag.String( "h", "localhost:29055", "tegu_host:port" )
+	hb := flag.Int64( "hb", 30, "heartbeat seconds" )
 	user	:= flag.String( "u", def_user, "ssh user-name" )
 	verbose := flag.Bool( "v", false, "verbose" )
 	vlevel := flag.Int( "V", 1, "verbose-level" )
+	driver := flag.String( "driver", "script", "ovs driver: script or go" )
+	reach := flag.String( "reach", "", "hosts this agent can reach (default any)" )
 	flag.Parse()									// actually parse the commandline
 
 	if *needs_help {
@@ -926,7 +1353,10 @@ func main() {
 	sess_mgr := make( chan *connman.Sess_data, 1024 )		// session management to create tegu connections with and drive the session listener(s)
 	smgr := connman.NewManager( "", sess_mgr );				// get a manager, but no listen port opened
 
-	connect2tegu( smgr, tegu_host, sess_mgr )				// establish initial connection
+	_, reach_toks := token.Tokenise_populated( *reach, " ," )
+	hello := mk_hello( reach_toks, *parallel )				// sent on each connection to announce what we can do
+
+	connect2tegu( smgr, tegu_host, sess_mgr, hello )		// establish initial connection
 
 	ntoks, key_toks := token.Tokenise_populated( *key_files, " ," )		// allow space or , seps and drop nil tokens
 	if ntoks <= 0 {
@@ -949,9 +1379,29 @@ func main() {
 	sheep.Baa( 1, "successfully created ssh_broker for user: %s, command path: %s", *user, *rdir )
 	broker.Start_initiators( *parallel )
 
+	switch *driver {
+		case "go":
+			ovs_drv = ovs.Mk_driver( &broker_runner{ broker: broker, timeout: 30 } )
+			sheep.Baa( 1, "using the go ovs driver for bandwidth flow-mods and queues" )
+
+		case "script":
+
+		default:
+			sheep.Baa( 0, "CRI: unknown ovs driver type: %s (expected script or go)", *driver )
+			os.Exit( 1 )
+	}
+
+	async_ch = make( chan []byte, 16 )
+	if *hb > 0 {
+		go heartbeat( smgr, *hb )
+	}
+
 
 	for {
-		select {									// wait on input from any channel -- just one now, but who knows
+		select {									// wait on input from any channel
+			case jout := <- async_ch:				// ack from an action that ran asynchronously
+				smgr.Write( "c0", jout )			// c0 is the session id given in connect2tegu
+
 			case sreq := <- sess_mgr:				// data from the network
 				switch( sreq.State ) {
 					case connman.ST_ACCEPTED:		// shouldn't happen
@@ -961,11 +1411,12 @@ func main() {
 
 					case connman.ST_DISC:
 						sheep.Baa( 1, "session to tegu was lost" )
-						connect2tegu( smgr, tegu_host, sess_mgr )			// blocks until connected and reports on the conn_ch channel when done
+						connect2tegu( smgr, tegu_host, sess_mgr, hello )	// blocks until connected and reports on the conn_ch channel when done
 						broker.Reset( )				// reset the broker each time we pick up a new tegu connection
 
 					case connman.ST_DATA:
 						sheep.Baa( 3, "data: [%s]  %d bytes received", sreq.Id, len( sreq.Buf ) )
+						atomic.StoreInt64( &busy_since, time.Now().Unix() )
 						jc.Add_bytes( sreq.Buf )
 						jblob := jc.Get_blob()		// get next blob if ready
 						for ; jblob != nil ; {
@@ -978,6 +1429,8 @@ func main() {
 
 							jblob = jc.Get_blob()	// get next blob if more than one in the cache
 						}
+						atomic.StoreInt64( &last_tegu, time.Now().Unix() )
+						atomic.StoreInt64( &busy_since, 0 )
 				}
 		}			// end select
 	}
@@ -36,6 +36,16 @@
 				29 Oct 2014 : Corrected potential core dump if agent msg received is less than
 					100 bytes.
 				17 Jun 2105 : Added oneway reservation support.
+				17 Oct 2026 : Actions are given an id and tracked until the agent acks/nacks them; results
+					for actions which push a pledge are reported to res_mgr (REQ_PUSH_STATE).
+				17 Oct 2026 : Added dump_state action for the reconciler.
+				17 Oct 2026 : Agents announce their version and capabilities (hello); actions are routed
+					only to agents which support the action type and reach the hosts. Added REQ_LISTAGENTS.
+					The initial requests for a new agent, when others are connected, wait for its hello.
+				17 Oct 2026 : Heartbeats are sent to agents which said hello and an agent not heard from
+					within hb_timeout seconds is marked unhealthy; its unacked actions are sent to another
+					agent. An alert is written when no healthy agent supports an action type.
+				17 Oct 2026 : The unacked actions of an agent which disconnects are sent to another agent.
 */
 
 package managers
@@ -44,13 +54,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/att/gopkgs/bleater"
 	"github.com/att/gopkgs/clike"
 	"github.com/att/gopkgs/connman"
 	"github.com/att/gopkgs/ipc"
 	"github.com/att/gopkgs/jsontools"
+	"github.com/att/tegu/gizmos"
 )
 
 // ----- structs used to bundle into json commands
@@ -63,6 +76,7 @@ type action struct {			// specific action
 	Dscps	string				// space separated list of dscp values
 	Fdata	[]string			// flowmod command data
 	Qdata	[]string			// queue parms
+	Pname	string `json:",omitempty"`	// pledge the action was generated for; removed before sending to the agent
 }
 
 type agent_cmd struct {			// overall command
@@ -76,12 +90,102 @@ type agent_cmd struct {			// overall command
 type agent struct {
 	id		string
 	jcache	*jsontools.Jsoncache				// buffered input resulting in 'records' that are complete json blobs
+
+	version	string								// from the agent's hello; empty if it hasn't sent one
+	actions	map[string]bool						// action types supported; nil if unknown (older agents are assumed to support all)
+	reach	map[string]bool						// hosts the agent can reach; nil if any
+	parallel int								// number of commands the agent runs in parallel (0 if unknown)
+	connected int64								// time the session was established
+	last_heard int64							// time of the last message from the agent
+	init_wait bool								// initial mac2phost/intermed requests held until hello received
+	healthy	bool								// false if the agent has been silent for too long
+	requeued int								// number of actions moved to other agents when found silent
+}
+
+/*
+	Capabilities announced by an agent in its hello message.
+*/
+type agent_caps struct {
+	Actions		[]string					// action types the agent supports
+	Hosts		[]string					// hosts the agent can reach; empty if any
+	Parallel	int							// max commands run in parallel
+}
+
+/*
+	Agent information returned for a list agents request.
+*/
+type agent_info struct {
+	Id			string
+	Version		string
+	Actions		[]string
+	Hosts		[]string
+	Parallel	int
+	Connected	int64
+	Last_heard	int64
+	Inflight	int							// actions sent to the agent not yet acked
+	Healthy		bool
+	Requeued	int							// actions moved to other agents because this one was silent
 }
 
+/*
+	Action types sent to agents. Used to alert when no healthy agent supports one.
+*/
+var agent_atypes = []string{ "bw_fmod", "bwow_fmod", "dump_state", "flowmod", "intermed_queues", "map_mac2phost", "mirrorwiz", "setqueues" }
+
 type agent_data struct {
 	agents	map[string]*agent					// hash for direct index (based on ID string given to the session)
 	agent_list []*agent							// sequential index into map that allows easier round robin access for sendone
 	aidx	int									// next spot in index for round robin sends
+
+	naid	uint32								// next action id
+	inflight map[uint32]*inflight				// actions sent and not yet acked/nacked
+	ptrack	map[string]*push_track				// outstanding actions for each pledge
+	ack_timeout int64							// seconds we wait for an ack before declaring the action failed
+	hb_timeout int64							// seconds an agent may be silent before it is considered dead
+	unserved string								// action types without a healthy agent when last checked
+}
+
+/*
+	An action which has been sent to an agent and is waiting for the ack/nack.
+*/
+type inflight struct {
+	aid		uint32
+	atype	string
+	pname	string				// pledge the action pushes; empty if not related to a pledge
+	hosts	[]string
+	agent	string				// id of the agent the action was sent to
+	expiry	int64				// time after which we give up waiting
+	act		action				// copy of the action so that it can be sent to another agent
+	lra		bool				// true if sent as a long running request
+}
+
+/*
+	Tracks the actions for a pledge from the time they are sent until all are acked/nacked.
+*/
+type push_track struct {
+	waiting	int					// number of actions not yet acked/nacked
+	nok		int					// number of actions which were successful on all hosts
+	nfail	int					// number of actions which failed on all hosts
+	npart	int					// number of actions which failed on some hosts
+	detail	string				// first failure reported
+}
+
+/*
+	Sent to res_mgr when all of the actions for a pledge have been acked/nacked.
+*/
+type push_report struct {
+	pname	string
+	state	int					// gizmos.PS_ constant
+	detail	string
+}
+
+/*
+	Status of an action on one host as reported in an ack/nack.
+*/
+type host_status struct {
+	Host	string
+	State	int					// 0 == success
+	Edata	[]string			// stderr from the host
 }
 
 /*
@@ -91,9 +195,12 @@ type agent_msg struct {
 	Ctype	string			// command type -- should be response, ack, nack etc.
 	Rtype	string			// type of response (e.g. map_mac2phost, or specific id for ack/nack)
 	Rdata	[]string		// response data
+	Edata	[]string		// response error data
 	State	int				// if an ack/nack some state information
 	Vinfo	string			// agent verion (dbugging mostly)
 	Rid		uint32			// original request id
+	Hstatus	[]host_status	// per host status for an ack/nack
+	Caps	*agent_caps		// capabilities (hello)
 }
 
 /*
@@ -121,6 +228,9 @@ func (ad *agent_data) Mk_agent( aid string ) ( na *agent ) {
 	na = &agent{}
 	na.id = aid
 	na.jcache = jsontools.Mk_jsoncache()
+	na.connected = time.Now().Unix()
+	na.last_heard = na.connected
+	na.healthy = true
 
 	ad.agents[na.id] = na
 	ad.build_list( )
@@ -131,14 +241,16 @@ func (ad *agent_data) Mk_agent( aid string ) ( na *agent ) {
 /*
 	Send the message to one agent. The agent is selected using the current
 	index in the agent_data so that it effectively does a round robin.
+	The id of the agent is returned (empty if there are no agents).
 */
-func (ad *agent_data) send2one( smgr *connman.Cmgr,  msg string ) {
+func (ad *agent_data) send2one( smgr *connman.Cmgr,  msg string ) ( aid string ) {
 	l := len( ad.agents )
 	if l <= 0 {
 		return
 	}
 
-	smgr.Write( ad.agent_list[ad.aidx].id, []byte( msg ) )
+	aid = ad.agent_list[ad.aidx].id
+	smgr.Write( aid, []byte( msg ) )
 	ad.aidx++
 	if ad.aidx >= l {
 		if l > 1 {
@@ -147,6 +259,8 @@ func (ad *agent_data) send2one( smgr *connman.Cmgr,  msg string ) {
 			ad.aidx = 0
 		}
 	}
+
+	return
 }
 
 /*
@@ -175,13 +289,15 @@ func (ad *agent_data) sendbytes2one( smgr *connman.Cmgr,  msg []byte ) {
 	that are not time sensitive (such as intermediate queue setup/checking).
 	
 */
-func (ad *agent_data) sendbytes2lra( smgr *connman.Cmgr,  msg []byte ) {
+func (ad *agent_data) sendbytes2lra( smgr *connman.Cmgr,  msg []byte ) ( aid string ) {
 	l := len( ad.agents )
 	if l <= 0 {
 		return
 	}
 	
-	smgr.Write( ad.agent_list[0].id,  msg )
+	aid = ad.agent_list[0].id
+	smgr.Write( aid,  msg )
+	return
 }
 
 /*
@@ -209,14 +325,558 @@ func (ad *agent_data) send2all( smgr *connman.Cmgr,  msg string ) {
 	}
 }
 
+// ---------------- capabilities and routing -------------------------------------------------------
+
+/*
+	Record the version and capabilities sent by the agent in its hello message.
+*/
+func (a *agent) set_caps( vinfo string, caps *agent_caps ) {
+	a.version = vinfo
+	if caps == nil {
+		return
+	}
+
+	a.actions = make( map[string]bool )
+	for _, at := range caps.Actions {
+		a.actions[at] = true
+	}
+
+	a.reach = nil
+	if len( caps.Hosts ) > 0 {
+		a.reach = make( map[string]bool )
+		for _, h := range caps.Hosts {
+			a.reach[h] = true
+		}
+	}
+	a.parallel = caps.Parallel
+}
+
+/*
+	Returns true if the agent supports the action type. Agents which have not sent a hello
+	are assumed to support everything.
+*/
+func (a *agent) can_do( atype string ) ( bool ) {
+	return a.actions == nil || a.actions[atype]
+}
+
+func (a *agent) can_reach( host string ) ( bool ) {
+	return a.reach == nil || a.reach[host]
+}
+
+/*
+	Return the agent list index to start the search for a capable agent. For long running
+	requests this is always the first agent (the lra), otherwise the round robin index which
+	is advanced skipping the lra if there is more than one agent.
+*/
+func (ad *agent_data) next_idx( lra bool ) ( idx int ) {
+	l := len( ad.agent_list )
+	if lra || l <= 0 {
+		return 0
+	}
+
+	idx = ad.aidx
+	ad.aidx++
+	if ad.aidx >= l {
+		if l > 1 {
+			ad.aidx = 1		// skip the long running agent if more than one agent connected
+		} else {
+			ad.aidx = 0
+		}
+	}
+
+	return
+}
+
+/*
+	Split the command into a command for each agent that will receive some of it. Each action
+	goes to the first healthy agent (searching from the round robin index, or the lra) which supports
+	the action type and reaches all of the action's hosts. If no single agent reaches all of
+	the hosts, the action is split and each host goes to the first capable agent that reaches
+	it. Actions, or hosts, which no agent can handle are placed into the command keyed by the
+	empty string. Order lists the agent ids in the order they were first selected.
+*/
+func (ad *agent_data) route( cmd *agent_cmd, lra bool ) ( cmds map[string]*agent_cmd, order []string ) {
+	cmds = make( map[string]*agent_cmd )
+	add := func( id string, a action ) {
+		if cmds[id] == nil {
+			cmds[id] = &agent_cmd{ Ctype: cmd.Ctype }
+			order = append( order, id )
+		}
+		cmds[id].Actions = append( cmds[id].Actions, a )
+	}
+
+	l := len( ad.agent_list )
+	start := ad.next_idx( lra )
+	for _, act := range cmd.Actions {
+		chosen := ""
+		for i := 0; i < l && chosen == ""; i++ {
+			a := ad.agent_list[(start + i) % l]
+			if ! a.healthy || ! a.can_do( act.Atype ) {
+				continue
+			}
+
+			all := true
+			for _, h := range act.Hosts {
+				if ! a.can_reach( h ) {
+					all = false
+					break
+				}
+			}
+			if all {
+				chosen = a.id
+			}
+		}
+
+		if chosen != "" || len( act.Hosts ) == 0 {
+			add( chosen, act )
+			continue
+		}
+
+		hmap := make( map[string][]string )					// split the hosts among the agents
+		horder := make( []string, 0 )
+		for _, h := range act.Hosts {
+			id := ""
+			for i := 0; i < l; i++ {
+				a := ad.agent_list[(start + i) % l]
+				if a.healthy && a.can_do( act.Atype ) && a.can_reach( h ) {
+					id = a.id
+					break
+				}
+			}
+			if hmap[id] == nil {
+				horder = append( horder, id )
+			}
+			hmap[id] = append( hmap[id], h )
+		}
+
+		for _, id := range horder {
+			part := act
+			part.Hosts = hmap[id]
+			add( id, part )
+		}
+	}
+
+	return
+}
+
+/*
+	Route the command to capable agents, track the actions and send. Actions which no connected
+	agent can handle are failed immediately. If there are no agents at all the actions are
+	tracked and left to time out as before (one may connect). The ids of the actions which were
+	sent are returned.
+*/
+func (ad *agent_data) send_cmd( smgr *connman.Cmgr, cmd *agent_cmd, lra bool ) ( aids []uint32 ) {
+	if len( ad.agents ) <= 0 {
+		ad.track_cmd( cmd )
+		am_sheep.Baa( 2, "no agents connected; command not sent" )
+		return
+	}
+
+	cmds, order := ad.route( cmd, lra )
+	for _, id := range order {
+		c := cmds[id]
+		tracked := ad.track_cmd( c )
+
+		if id == "" {
+			for _, act := range c.Actions {
+				am_sheep.Baa( 1, "WRN: no connected agent supports %s for hosts: %s  [TGUAGT011]", act.Atype, strings.Join( act.Hosts, " " ) )
+			}
+			for _, inf := range tracked {
+				delete( ad.inflight, inf.aid )
+				ad.action_done( inf, gizmos.PS_FAILED, "no capable agent" )
+			}
+			continue
+		}
+
+		jmsg, err := json.Marshal( c )
+		if err != nil {
+			am_sheep.Baa( 1, "WRN: unable to bundle agent command after assigning action ids: %s  [TGUAGT009]", err )
+			for _, inf := range tracked {
+				ad.untrack( inf )
+			}
+			continue
+		}
+
+		smgr.Write( id, jmsg )
+		for _, inf := range tracked {
+			inf.agent = id
+			inf.lra = lra
+			aids = append( aids, inf.aid )
+		}
+	}
+
+	return
+}
+
+/*
+	Unpack the json command string built by another manager and send it to capable agents.
+	If the string cannot be unpacked it is sent, untracked, to the next agent.
+*/
+func (ad *agent_data) send_json( smgr *connman.Cmgr, jstr string, lra bool ) {
+	cmd := &agent_cmd{ }
+	if err := json.Unmarshal( []byte( jstr ), cmd ); err != nil {
+		am_sheep.Baa( 1, "WRN: unable to unpack agent command to assign action ids: %s  [TGUAGT009]", err )
+		ad.send2one( smgr, jstr )
+		return
+	}
+
+	ad.send_cmd( smgr, cmd, lra )
+}
+
+/*
+	Generate the json list of connected agents with their capabilities, the time they were
+	last heard from, their health, and the number of actions waiting to be acked.
+*/
+func (ad *agent_data) list_agents( ) ( string ) {
+	counts := make( map[string]int )
+	for _, inf := range ad.inflight {
+		counts[inf.agent]++
+	}
+
+	ids := make( []string, 0, len( ad.agents ) )
+	for id := range ad.agents {
+		ids = append( ids, id )
+	}
+	sort.Strings( ids )
+
+	list := make( []*agent_info, 0, len( ids ) )
+	for _, id := range ids {
+		a := ad.agents[id]
+		ai := &agent_info {
+			Id:			a.id,
+			Version:	a.version,
+			Actions:	[]string{ },
+			Hosts:		[]string{ },
+			Parallel:	a.parallel,
+			Connected:	a.connected,
+			Last_heard:	a.last_heard,
+			Inflight:	counts[a.id],
+			Healthy:	a.healthy,
+			Requeued:	a.requeued,
+		}
+		if a.actions == nil {
+			ai.Actions = append( ai.Actions, "all" )
+		}
+		for at := range a.actions {
+			ai.Actions = append( ai.Actions, at )
+		}
+		for h := range a.reach {
+			ai.Hosts = append( ai.Hosts, h )
+		}
+		sort.Strings( ai.Actions )
+		sort.Strings( ai.Hosts )
+		list = append( list, ai )
+	}
+
+	jbytes, err := json.Marshal( list )
+	if err != nil {
+		return "[]"
+	}
+	return string( jbytes )
+}
+
+// ---------------- action tracking ----------------------------------------------------------------
+
+/*
+	Assign an id to each action in the command and track it until the agent acks/nacks it
+	or it times out. The pledge name is pulled from the action as the agent has no need for
+	it. Map_mac2phost actions are not tracked as their response carries the data.
+	The tracking blocks are returned so that the caller can fill in the agent once the
+	command is sent.
+*/
+func (ad *agent_data) track_cmd( cmd *agent_cmd ) ( tracked []*inflight ) {
+	now := time.Now().Unix()
+
+	for i := range cmd.Actions {
+		a := &cmd.Actions[i]
+		if a.Atype == "map_mac2phost" {
+			continue
+		}
+
+		ad.naid++
+		if ad.naid == 0 {						// zero is 'no id' to the agent; skip on wrap
+			ad.naid++
+		}
+		a.Aid = ad.naid
+
+		to := ad.ack_timeout
+		if a.Atype == "intermed_queues" {
+			to += 3600							// the agent allows these up to an hour to complete
+		}
+		inf := &inflight {
+			aid:	a.Aid,
+			atype:	a.Atype,
+			pname:	a.Pname,
+			hosts:	a.Hosts,
+			expiry:	now + to,
+		}
+		ad.inflight[a.Aid] = inf
+		tracked = append( tracked, inf )
+		inf.act = *a
+		inf.act.Pname = ""
+
+		if a.Pname != "" {
+			pt := ad.ptrack[a.Pname]
+			if pt == nil {
+				pt = &push_track{ }
+				ad.ptrack[a.Pname] = pt
+			}
+			pt.waiting++
+		}
+		a.Pname = ""
+	}
+
+	return
+}
+
+/*
+	Drop tracking for an action that was never sent.
+*/
+func (ad *agent_data) untrack( inf *inflight ) {
+	delete( ad.inflight, inf.aid )
+	if pt := ad.ptrack[inf.pname]; pt != nil {
+		pt.waiting--
+		if pt.waiting <= 0 {
+			delete( ad.ptrack, inf.pname )
+		}
+	}
+}
+
+/*
+	Record the result of an action. If the action was pushing a pledge, and it was the last action
+	outstanding for the pledge, the overall state is sent to res_mgr: installed if every action was
+	successful on every host, failed if every action failed, and partial otherwise.
+*/
+func (ad *agent_data) action_done( inf *inflight, state int, detail string ) {
+	if inf.pname == "" {
+		return
+	}
+
+	pt := ad.ptrack[inf.pname]
+	if pt == nil {
+		return
+	}
+
+	pt.waiting--
+	switch state {
+		case gizmos.PS_INSTALLED:	pt.nok++
+		case gizmos.PS_FAILED:		pt.nfail++
+		default:					pt.npart++
+	}
+	if pt.detail == "" {
+		pt.detail = detail
+	}
+
+	ad.push_check( inf.pname )
+}
+
+/*
+	If no actions are outstanding for the pledge, send the overall push state to res_mgr.
+*/
+func (ad *agent_data) push_check( pname string ) {
+	pt := ad.ptrack[pname]
+	if pt == nil || pt.waiting > 0 {
+		return
+	}
+	delete( ad.ptrack, pname )
+
+	rpt := &push_report{ pname: pname, detail: pt.detail }
+	switch {
+		case pt.nfail == 0 && pt.npart == 0:	rpt.state = gizmos.PS_INSTALLED
+		case pt.nok == 0 && pt.npart == 0:		rpt.state = gizmos.PS_FAILED
+		default:								rpt.state = gizmos.PS_PARTIAL
+	}
+
+	am_sheep.Baa( 2, "push of %s: %s (%d ok, %d partial, %d failed)", pname, gizmos.Push_state2str( rpt.state ), pt.nok, pt.npart, pt.nfail )
+	msg := ipc.Mk_chmsg( )
+	msg.Send_req( rmgr_ch, nil, REQ_PUSH_STATE, rpt, nil )
+}
+
+/*
+	Deal with an ack/nack from an agent, or a response which carries an action id. The host
+	status list gives the result on each host; older agents send only an overall state.
+*/
+func (ad *agent_data) ack( req *agent_msg ) {
+	inf := ad.inflight[req.Rid]
+	if inf == nil {
+		am_sheep.Baa( 2, "%s for unknown, or expired, action id ignored: %d (%s)", req.Ctype, req.Rid, req.Rtype )
+		return
+	}
+	delete( ad.inflight, req.Rid )
+
+	nfail := 0
+	detail := ""
+	for _, hs := range req.Hstatus {
+		if hs.State != 0 {
+			nfail++
+			emsg := "no error output"
+			if len( hs.Edata ) > 0 {
+				emsg = hs.Edata[0]
+			}
+			if detail == "" {
+				detail = hs.Host + ": " + emsg
+			}
+
+			am_sheep.Baa( 1, "WRN: %s action %d failed on %s: %s  [TGUAGT007]", inf.atype, inf.aid, hs.Host, emsg )
+			for i := 1; i < len( hs.Edata ) && i < 20; i++ {
+				am_sheep.Baa( 2, "  [%d] %s", i, hs.Edata[i] )
+			}
+		}
+	}
+
+	state := gizmos.PS_INSTALLED
+	switch {
+		case len( req.Hstatus ) == 0:
+			if req.State != 0 || req.Ctype == "nack" {
+				state = gizmos.PS_FAILED
+				detail = req.Rtype + " failed"
+				if len( req.Edata ) > 0 {
+					detail += ": " + req.Edata[0]
+				}
+			}
+
+		case nfail == len( req.Hstatus ):
+			state = gizmos.PS_FAILED
+
+		case nfail > 0:
+			state = gizmos.PS_PARTIAL
+	}
+
+	am_sheep.Baa( 2, "%s received for %s action %d from agent %s: %s", req.Ctype, inf.atype, inf.aid, inf.agent, gizmos.Push_state2str( state ) )
+	ad.action_done( inf, state, detail )
+}
+
+/*
+	Fail any actions which have waited too long for the ack/nack.
+*/
+func (ad *agent_data) ack_check( ) {
+	now := time.Now().Unix()
+
+	for aid, inf := range ad.inflight {
+		if now > inf.expiry {
+			delete( ad.inflight, aid )
+			am_sheep.Baa( 1, "WRN: no ack received for %s action %d sent to agent %s; considered failed  [TGUAGT008]", inf.atype, aid, inf.agent )
+			ad.action_done( inf, gizmos.PS_FAILED, "timeout waiting for agent ack" )
+		}
+	}
+}
+
+// ---------------- heartbeats and failover --------------------------------------------------------
+
+/*
+	Send a heartbeat to each agent which has said hello; older agents don't understand them.
+*/
+func (ad *agent_data) heartbeat( smgr *connman.Cmgr ) {
+	for id, a := range ad.agents {
+		if a.version != "" {
+			smgr.Write( id, []byte( `{ "ctype": "heartbeat" }` ) )
+		}
+	}
+}
+
+/*
+	Mark agents which have been silent for too long as unhealthy, and move their unacked actions
+	to other agents. An agent which is heard from again is marked healthy. Only agents which
+	said hello (and thus send heartbeats) are checked.
+*/
+func (ad *agent_data) health_check( smgr *connman.Cmgr ) {
+	now := time.Now().Unix()
+
+	for _, a := range ad.agent_list {
+		if a.version == "" {
+			continue
+		}
+
+		silent := now - a.last_heard
+		switch {
+			case a.healthy && silent > ad.hb_timeout:
+				a.healthy = false
+				am_sheep.Baa( 0, "WRN: agent %s has not been heard from in %d seconds; marked unhealthy  [TGUAGT012]", a.id, silent )
+				ad.failover( smgr, a )
+
+			case ! a.healthy && silent <= ad.hb_timeout:
+				a.healthy = true
+				am_sheep.Baa( 1, "agent %s heard from again; marked healthy", a.id )
+		}
+	}
+
+	ad.check_served( )
+}
+
+/*
+	Send the actions waiting on an ack from the silent agent to another agent. The replacement
+	is tracked under a new id; a late ack from the silent agent is ignored. Dump_state actions
+	are dropped as the reconciler gives up on the audit and will run another.
+*/
+func (ad *agent_data) failover( smgr *connman.Cmgr, a *agent ) {
+	list := make( []*inflight, 0 )
+	for _, inf := range ad.inflight {
+		if inf.agent == a.id {
+			list = append( list, inf )
+		}
+	}
+	sort.Slice( list, func( i, j int ) bool { return list[i].aid < list[j].aid } )		// replay in the order sent
+
+	for _, inf := range list {
+		delete( ad.inflight, inf.aid )
+		if inf.atype == "dump_state" {
+			am_sheep.Baa( 1, "dump_state action %d sent to silent agent %s dropped", inf.aid, a.id )
+			continue
+		}
+
+		act := inf.act
+		act.Pname = inf.pname
+		aids := ad.send_cmd( smgr, &agent_cmd{ Ctype: "action_list", Actions: []action{ act } }, inf.lra )
+		a.requeued++
+		am_sheep.Baa( 1, "%s action %d sent to silent agent %s was requeued: %v", inf.atype, inf.aid, a.id, aids )
+
+		if pt := ad.ptrack[inf.pname]; pt != nil {			// replacement is now counted; drop the original
+			pt.waiting--
+			ad.push_check( inf.pname )
+		}
+	}
+}
+
+/*
+	Alert when the set of action types that no healthy agent supports changes.
+*/
+func (ad *agent_data) check_served( ) {
+	unserved := make( []string, 0 )
+	for _, at := range agent_atypes {
+		served := false
+		for _, a := range ad.agent_list {
+			if a.healthy && a.can_do( at ) {
+				served = true
+				break
+			}
+		}
+		if ! served {
+			unserved = append( unserved, at )
+		}
+	}
+
+	us := strings.Join( unserved, " " )
+	if us == ad.unserved {
+		return
+	}
+	ad.unserved = us
+
+	if us != "" {
+		am_sheep.Baa( 0, "CRI: no healthy agent is able to handle these actions: %s  [TGUAGT013]", us )
+	} else {
+		am_sheep.Baa( 1, "healthy agents are available for all action types" )
+	}
+}
+
 /*
 	Deal with incoming data from an agent. We add the buffer to the cahce
 	(all input is expected to be json) and attempt to pull a blob of json
 	from the cache. If the blob is pulled, then we act on it, else we
 	assume another buffer or more will be coming to complete the blob
-	and we'll do it next time round.
+	and we'll do it next time round. Acks and nacks are passed to the agent
+	data for correlation with the action sent.
 */
-func ( a *agent ) process_input( buf []byte ) {
+func ( a *agent ) process_input( ad *agent_data, buf []byte ) {
 	var (
 		req	agent_msg		// unpacked message struct
 	)
@@ -224,21 +884,45 @@ func ( a *agent ) process_input( buf []byte ) {
 	a.jcache.Add_bytes( buf )
 	jblob := a.jcache.Get_blob()						// get next blob if ready
 	for ; jblob != nil ; {
+		req = agent_msg{ }								// must reset; unmarshal leaves fields missing from the blob untouched
     	err := json.Unmarshal( jblob, &req )           // unpack the json
 
 		if err != nil {
 			am_sheep.Baa( 0, "ERR: unable to unpack agent_message: %s  [TGUAGT000]", err )
 			am_sheep.Baa( 2, "offending json: %s", string( buf ) )
 		} else {
-			am_sheep.Baa( 1, "%s/%s received from agent", req.Ctype, req.Rtype )
+			if req.Ctype != "heartbeat" {
+				am_sheep.Baa( 1, "%s/%s received from agent", req.Ctype, req.Rtype )
+			}
+			a.last_heard = time.Now().Unix()
 	
 			switch( req.Ctype ) {					// "command type"
+				case "heartbeat":					// nothing to do; last heard has been updated
+
+				case "hello":						// version and capabilities sent on connect
+					a.set_caps( req.Vinfo, req.Caps )
+					if req.Caps != nil {
+						am_sheep.Baa( 1, "agent %s: version %s actions=%s hosts=%s parallel=%d", a.id, req.Vinfo,
+							strings.Join( req.Caps.Actions, "," ), strings.Join( req.Caps.Hosts, "," ), req.Caps.Parallel )
+					}
+
+				case "ack", "nack":					// result of an action with per host status
+					ad.ack( &req )
+
 				case "response":					// response to a request
+					if req.Rid != 0 {				// older agents respond to some actions rather than ack them
+						ad.ack( &req )
+					}
+
 					if req.State == 0 {
 						switch( req.Rtype ) {
 							case "map_mac2phost":
 								msg := ipc.Mk_chmsg( )
 								msg.Send_req( nw_ch, nil, REQ_MAC2PHOST, req.Rdata, nil )		// send into network manager -- we don't expect response
+
+							case "dump_state":											// pass to the reconciler for comparison
+								msg := ipc.Mk_chmsg( )
+								msg.Send_req( rc_ch, nil, REQ_DUMP_STATE, &state_dump{ rid: req.Rid, rdata: req.Rdata, hstatus: req.Hstatus }, nil )
 			
 							default:	
 								am_sheep.Baa( 2, "WRN:  success response data from agent was ignored for: %s  [TGUAGT001]", req.Rtype )
@@ -303,15 +987,9 @@ func (ad *agent_data) send_mac2phost( smgr *connman.Cmgr, hlist *string ) {
 	msg.Actions = make( []action, 1 )
 	msg.Actions[0].Atype = "map_mac2phost"
 	msg.Actions[0].Hosts = strings.Split( *hlist, " " )
-	jmsg, err := json.Marshal( msg )			// bundle into a json string
 
-	if err == nil {
-		am_sheep.Baa( 3, "sending mac2phost request: %s", jmsg )
-		ad.sendbytes2lra( smgr, jmsg )						// send as a long running request
-	} else {
-		am_sheep.Baa( 1, "WRN: unable to bundle mac2phost request into json: %s  [TGUAGT004]", err )
-		am_sheep.Baa( 2, "offending json: %s", jmsg )
-	}
+	am_sheep.Baa( 3, "sending mac2phost request: %s", *hlist )
+	ad.send_cmd( smgr, msg, true )							// send as a long running request
 }
 
 /*
@@ -328,14 +1006,30 @@ func (ad *agent_data) send_intermedq( smgr *connman.Cmgr, hlist *string, dscp *s
 	msg.Actions[0].Hosts = strings.Split( *hlist, " " )
 	msg.Actions[0].Dscps = *dscp
 
-	jmsg, err := json.Marshal( msg )			// bundle into a json string
+	am_sheep.Baa( 1, "sending intermediate queue setup request: hosts=%s dscp=%s", *hlist, *dscp )
+	ad.send_cmd( smgr, msg, true )							// send as a long running request
+}
 
-	if err == nil {
-		am_sheep.Baa( 1, "sending intermediate queue setup request: hosts=%s dscp=%s", *hlist, *dscp )
-		ad.sendbytes2lra( smgr, jmsg )						// send as a long running request
-	} else {
-		am_sheep.Baa( 0, "WRN: creating json intermedq command failed: %s  [TGUAGT005]", err )
+/*
+	Build a dump_state request, which lists the flow-mods with the given cookies (and optionally
+	the queues) on each host, and send it to capable agents. The hosts are split among agents
+	if no one agent reaches them all, so the action ids of each part are returned so that the
+	reconciler can match the responses; nil is returned if the request was not sent.
+*/
+func (ad *agent_data) send_dump( smgr *connman.Cmgr, dr *dump_req ) ( []uint32 ) {
+	if dr == nil || len( dr.hosts ) == 0 || len( ad.agents ) == 0 {
+		return nil
 	}
+
+	msg := &agent_cmd{ Ctype: "action_list" }				// create command struct then convert to json
+	msg.Actions = make( []action, 1 )
+	msg.Actions[0].Atype = "dump_state"
+	msg.Actions[0].Hosts = dr.hosts
+	msg.Actions[0].Data = map[string]string{ "cookies": dr.cookies, "queues": fmt.Sprintf( "%v", dr.queues ) }
+
+	aids := ad.send_cmd( smgr, msg, false )
+	am_sheep.Baa( 2, "dump_state request sent: %d hosts, %d actions", len( dr.hosts ), len( aids ) )
+	return aids
 }
 
 // ---------------- utility ------------------------------------------------------------------------
@@ -368,11 +1062,16 @@ func Agent_mgr( ach chan *ipc.Chmsg ) {
 		host_list string = ""
 		dscp_list string = "46 26 18"				// list of dscp values that are used to promote a packet to the pri queue in intermed switches
 		refresh int64 = 60
+		heartbeat int64 = 30							// seconds between heartbeats to agents; 0 disables
 		iqrefresh int64 = 1800							// intermediate queue refresh (this can take a long time, keep from clogging the works)
 	)
 
 	adata = &agent_data{}
 	adata.agents = make( map[string]*agent )
+	adata.inflight = make( map[uint32]*inflight )
+	adata.ptrack = make( map[string]*push_track )
+	adata.ack_timeout = 300
+	adata.hb_timeout = -1
 
 	am_sheep = bleater.Mk_bleater( 0, os.Stderr )		// allocate our bleater and attach it to the master
 	am_sheep.Set_prefix( "agentmgr" )
@@ -396,6 +1095,26 @@ func Agent_mgr( ach chan *ipc.Chmsg ) {
 				iqrefresh = 90
 			}
 		}
+		if p := cfg_data["agent"]["ack_timeout"]; p != nil {		// seconds we wait for an agent to ack an action
+			adata.ack_timeout = int64( clike.Atoi( *p ) )
+			if adata.ack_timeout < 30 {
+				am_sheep.Baa( 1, "ack_timeout in configuration file is too small, set to 30 seconds" )
+				adata.ack_timeout = 30
+			}
+		}
+		if p := cfg_data["agent"]["heartbeat"]; p != nil {
+			heartbeat = int64( clike.Atoi( *p ) )
+		}
+		if p := cfg_data["agent"]["hb_timeout"]; p != nil {		// seconds an agent can be silent before it is considered dead
+			adata.hb_timeout = int64( clike.Atoi( *p ) )
+		}
+	}
+	if adata.hb_timeout < 0 {
+		adata.hb_timeout = heartbeat * 3
+	}
+	if adata.hb_timeout < heartbeat * 2 {
+		am_sheep.Baa( 1, "hb_timeout in configuration file is too small, set to %d seconds", heartbeat * 2 )
+		adata.hb_timeout = heartbeat * 2
 	}
 	if cfg_data["default"] != nil {						// we pick some things from the default section too
 		if p := cfg_data["default"]["pri_dscp"]; p != nil {			// list of dscp (diffserv) values that match for priority promotion
@@ -415,6 +1134,10 @@ func Agent_mgr( ach chan *ipc.Chmsg ) {
 	tklr.Add_spot( 10, ach, REQ_INTERMEDQ, nil, 1 );		  			// tickle once, very soon, to start an intermediate refresh asap
 	tklr.Add_spot( refresh, ach, REQ_MAC2PHOST, nil, ipc.FOREVER );  	// reocurring tickle to get host mapping
 	tklr.Add_spot( iqrefresh, ach, REQ_INTERMEDQ, nil, ipc.FOREVER );  	// reocurring tickle to ensure intermediate switches are properly set
+	tklr.Add_spot( 30, ach, REQ_ACKCHECK, nil, ipc.FOREVER );  			// fail actions which agents never acked
+	if heartbeat > 0 {
+		tklr.Add_spot( heartbeat, ach, REQ_HEARTBEAT, nil, ipc.FOREVER );	// heartbeat to agents and check for silent ones
+	}
 
 	sess_chan := make( chan *connman.Sess_data, 1024 )					// channel for comm from agents (buffers, disconns, etc)
 	smgr := connman.NewManager( port, sess_chan );
@@ -437,14 +1160,33 @@ func Agent_mgr( ach chan *ipc.Chmsg ) {
 
 					case REQ_SENDLONG:					// send a long request to one agent
 						if req.Req_data != nil {
-							adata.send2one( smgr,  req.Req_data.( string ) )
+							adata.send_json( smgr, req.Req_data.( string ), false )
 						}
 
 					case REQ_SENDSHORT:					// send a short request to one agent (round robin)
 						if req.Req_data != nil {
-							adata.send2one( smgr,  req.Req_data.( string ) )
+							adata.send_json( smgr, req.Req_data.( string ), false )
 						}
 
+					case REQ_DUMP_STATE:				// reconciler wants hosts dumped; respond with the action id
+						if dr, ok := req.Req_data.( *dump_req ); ok {
+							req.Response_data = adata.send_dump( smgr, dr )
+						} else {
+							req.Response_data = []uint32( nil )
+						}
+
+					case REQ_LISTAGENTS:				// list agents with capabilities and in-flight counts (json)
+						req.Response_data = adata.list_agents( )
+
+					case REQ_ACKCHECK:					// fail actions which were never acked
+						req.Response_ch = nil
+						adata.ack_check( )
+
+					case REQ_HEARTBEAT:					// heartbeat to agents, then look for silent ones
+						req.Response_ch = nil
+						adata.heartbeat( smgr )
+						adata.health_check( smgr )
+
 					case REQ_MAC2PHOST:					// send a request for agent to generate  mac to phost map
 						if host_list != "" {
 							adata.send_mac2phost( smgr, &host_list )
@@ -477,18 +1219,26 @@ func Agent_mgr( ach chan *ipc.Chmsg ) {
 						a := adata.Mk_agent( sreq.Id )
 						am_sheep.Baa( 1, "new agent: %s [%s]", a.id, sreq.Data )
 						if host_list != "" {											// immediate request for this
-							adata.send_mac2phost( smgr, &host_list )
-							adata.send_intermedq( smgr, &host_list, &dscp_list )
+							if len( adata.agents ) > 1 {								// routing depends on capabilities; wait for its hello
+								a.init_wait = true
+							} else {
+								adata.send_mac2phost( smgr, &host_list )
+								adata.send_intermedq( smgr, &host_list, &dscp_list )
+							}
 						}
 				
 					case connman.ST_DISC:
 						am_sheep.Baa( 1, "agent dropped: %s", sreq.Id )
-						if _, not_nil := adata.agents[sreq.Id]; not_nil {
+						if a, not_nil := adata.agents[sreq.Id]; not_nil {
+							a.healthy = false							// never chosen for the replayed actions
 							delete( adata.agents, sreq.Id )
+							adata.build_list()							// rebuild the list to drop the agent
+							adata.failover( smgr, a )					// replay its unacked actions on the remaining agents
+							adata.check_served( )
 						} else {
 							am_sheep.Baa( 1, "did not find an agent with the id: %s", sreq.Id )
+							adata.build_list()
 						}
-						adata.build_list()			// rebuild the list to drop the agent
 						
 					case connman.ST_DATA:
 						if _, not_nil := adata.agents[sreq.Id]; not_nil {
@@ -497,7 +1247,13 @@ func Agent_mgr( ach chan *ipc.Chmsg ) {
 								cval = len( sreq.Buf )
 							}
 							am_sheep.Baa( 2, "data: [%s]  %d bytes received:  first 100b: %s", sreq.Id, len( sreq.Buf ), sreq.Buf[0:cval] )
-							adata.agents[sreq.Id].process_input( sreq.Buf )
+							a := adata.agents[sreq.Id]
+							a.process_input( adata, sreq.Buf )
+							if a.init_wait && a.version != "" {							// hello received, send the held requests
+								a.init_wait = false
+								adata.send_mac2phost( smgr, &host_list )
+								adata.send_intermedq( smgr, &host_list, &dscp_list )
+							}
 						} else {
 							am_sheep.Baa( 1, "data from unknown agent: [%s]  %d bytes ignored:  %s", sreq.Id, len( sreq.Buf ), sreq.Buf )
 						}
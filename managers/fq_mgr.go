@@ -60,6 +60,10 @@
 				01 Feb 2015 - Corrected bug itroduced when host name removed from fmod parmss (agent w/ ssh-broker changes).
 				19 Feb 2015 - Change in adjust_queues_agent to allow create queues to be driven from agent without -h on command line.
 				21 Mar 2015 - Changes to support new bandwith endpoint flow-mod agent script.
+				17 Oct 2026 - Flows and reservations are sent through the Sdn_ctlr interface rather than
+					directly to skoogi.
+				17 Oct 2026 - Pledge name added to agent actions so that acks can be mapped back.
+				17 Oct 2026 - Added REQ_DESIRED_STATE for the reconciler; the last queue list is kept.
 */
 
 package managers
@@ -286,6 +290,9 @@ func send_bw_fmods( data *Fq_req, ip2mac map[string]*string, phost_suffix *strin
 	msg.Actions[0].Hosts = make( []string, 1 )					// bw endpoint flow-mods created on just one host
 	msg.Actions[0].Hosts[0] = *host
 	msg.Actions[0].Data = data.To_bw_map()						// convert useful data from caller into parms for agent
+	if data.Id != nil {
+		msg.Actions[0].Pname = *data.Id							// allows agent manager to map the ack back to the pledge
+	}
 
 	json, err := json.Marshal( msg )						// bundle into a json string
 	if err != nil {
@@ -338,6 +345,9 @@ func send_bwow_fmods( data *Fq_req, ip2mac map[string]*string, phost_suffix *str
 	msg.Actions[0].Hosts = make( []string, 1 )					// oneway flow-mods created on just one host
 	msg.Actions[0].Hosts[0] = *host
 	msg.Actions[0].Data = data.To_bwow_map()					// convert useful data from caller into parms for agent
+	if data.Id != nil {
+		msg.Actions[0].Pname = *data.Id							// allows agent manager to map the ack back to the pledge
+	}
 
 	json, err := json.Marshal( msg )						// bundle into a json string
 	if err != nil {
@@ -350,6 +360,41 @@ func send_bwow_fmods( data *Fq_req, ip2mac map[string]*string, phost_suffix *str
 	fq_sheep.Baa( 2, "oneway bandwidth flow-mod request sent to agent manager: %s", json )
 }
 
+/*
+	Complete the desired state built by res_mgr for the reconciler: translate the addresses
+	to macs, add the physical host suffix to the host names, and add the host list and the
+	queue list last sent to the agents.
+*/
+func desired_state( ws *want_state, ip2mac map[string]*string, hlist *string, qlist []string, phost_suffix *string ) {
+	for _, f := range ws.flows {
+		if phost_suffix != nil {
+			f.host = *add_phost_suffix( &f.host, phost_suffix )
+		}
+
+		if f.ip1 != nil && ip2mac[*f.ip1] != nil {
+			f.smac = *ip2mac[*f.ip1]
+		}
+		if f.ip2 != nil && ip2mac[*f.ip2] != nil {
+			f.dmac = *ip2mac[*f.ip2]
+		}
+	}
+
+	if hlist != nil {
+		ws.hosts = strings.Fields( *hlist )
+	}
+
+	ws.qraw = qlist
+	ws.qlist = make( []string, 0, len( qlist ) )
+	for _, q := range qlist {
+		toks := strings.SplitN( q, "/", 2 )
+		if len( toks ) == 2 && phost_suffix != nil {
+			ws.qlist = append( ws.qlist, *add_phost_suffix( &toks[0], phost_suffix ) + "/" + toks[1] )
+		} else {
+			ws.qlist = append( ws.qlist, q )
+		}
+	}
+}
+
 /*
 	WARNING: this should be deprecated.  Still needed by steering, but that should change. Tegu
 		should send generic 'setup' actions to the agent and not try to craft flow-mods.
@@ -547,6 +592,9 @@ func send_gfmod_agent( data *Fq_req, ip2mac map[string]*string, hlist *string, p
 			msg.Actions[0].Hosts[0] = hosts[i]
 			msg.Actions[0].Fdata = make( []string, 1 )
 			msg.Actions[0].Fdata[0] = fmt.Sprintf( `%s -t %d -p %d %s %s add 0x%x %s`, table, timeout, data.Pri, match_opts, action_opts, data.Cookie, data.Espq.Switch )
+			if data.Id != nil {
+				msg.Actions[0].Pname = *data.Id
+			}
 
 			json, err := json.Marshal( msg )			// bundle into a json string
 			if err != nil {
@@ -569,6 +617,9 @@ func send_gfmod_agent( data *Fq_req, ip2mac map[string]*string, hlist *string, p
 		msg.Actions[0].Hosts[0] = *sw_name
 		msg.Actions[0].Fdata = make( []string, 1 )
 		msg.Actions[0].Fdata[0] = fmt.Sprintf( `%s -t %d -p %d %s %s add 0x%x %s`, table, timeout, data.Pri, match_opts, action_opts, data.Cookie, *data.Swid )	
+		if data.Id != nil {
+			msg.Actions[0].Pname = *data.Id
+		}
 		json, err := json.Marshal( msg )						// bundle into a json string
 		if err != nil {
 			fq_sheep.Baa( 0, "unable to build json to set flow mod" )
@@ -621,7 +672,7 @@ func req_ip2mac(  rch chan *ipc.Chmsg ) {
 func Fq_mgr( my_chan chan *ipc.Chmsg, sdn_host *string ) {
 
 	var (
-		uri_prefix	string = ""
+		sdnc		gizmos.Sdn_ctlr = nil	// sdn controller interface; nil if not configured
 		msg			*ipc.Chmsg
 		data		[]interface{}			// generic list of data on some requests
 		fdata		*Fq_req					// flow-mod request data
@@ -634,6 +685,7 @@ func Fq_mgr( my_chan chan *ipc.Chmsg, sdn_host *string ) {
 		send_all	bool = false			// send all flow-mods; false means send just ingress/egress and not intermediate switch f-mods
 		alt_table	int = DEF_ALT_TABLE		// meta data marking table
 		phost_suffix *string = nil			// physical host suffix added to each host name in the list from openstack (config)
+		last_qlist	[]string				// queue list last sent to the agents (reconciler)
 
 		//max_link_used	int64 = 0			// the current maximum link utilisation
 	)
@@ -714,9 +766,7 @@ func Fq_mgr( my_chan chan *ipc.Chmsg, sdn_host *string ) {
 		fq_sheep.Baa( 0, "static host list from config used for setting OVS queues: %s", *host_list )
 	}
 
-	if sdn_host != nil  &&  *sdn_host != "" {
-		uri_prefix = fmt.Sprintf( "http://%s", *sdn_host )
-	}
+	sdnc = mk_sdnc( sdn_host, fq_sheep )
 
 	fq_sheep.Baa( 1, "flowmod-queue manager is running, sdn host: %s", *sdn_host )
 	for {
@@ -744,17 +794,17 @@ func Fq_mgr( my_chan chan *ipc.Chmsg, sdn_host *string ) {
 			case REQ_IE_RESERVE:						// proactive ingress/egress reservation flowmod  (this is likely deprecated as of 3/21/2015 -- resmgr invokes the bw_fmods script via agent)
 				fdata = msg.Req_data.( *Fq_req ); 		// user view of what the flow-mod should be
 
-				if uri_prefix != "" {						// an sdn controller -- skoogi -- is enabled
-					msg.State = gizmos.SK_ie_flowmod( &uri_prefix, *fdata.Match.Ip1, *fdata.Match.Ip2, fdata.Expiry, fdata.Espq.Queuenum, fdata.Espq.Switch, fdata.Espq.Port )
+				if sdnc != nil {							// an sdn controller is enabled
+					msg.State = sdnc.Install_flow( &gizmos.Flow{ Src: *fdata.Match.Ip1, Dst: *fdata.Match.Ip2, Expiry: fdata.Expiry, Queue: fdata.Espq.Queuenum, Swid: fdata.Espq.Switch, Port: fdata.Espq.Port } )
 
 					if msg.State == nil {					// no error, no response to requestor
-						fq_sheep.Baa( 2,  "proactive reserve successfully sent: uri=%s h1=%s h2=%s exp=%d qnum=%d swid=%s port=%d dscp=%d",
-									uri_prefix, fdata.Match.Ip1, fdata.Match.Ip2, fdata.Expiry, fdata.Espq.Queuenum, fdata.Espq.Switch, fdata.Espq.Port )
+						fq_sheep.Baa( 2,  "proactive reserve successfully sent: sdnc=%s h1=%s h2=%s exp=%d qnum=%d swid=%s port=%d dscp=%d",
+									sdnc, *fdata.Match.Ip1, *fdata.Match.Ip2, fdata.Expiry, fdata.Espq.Queuenum, fdata.Espq.Switch, fdata.Espq.Port )
 						msg.Response_ch = nil
 					} else {
 						// do we need to suss out the id and mark it failed, or set a timer on it,  so as not to flood reqmgr with errors?
-						fq_sheep.Baa( 1,  "ERR: proactive reserve failed: uri=%s h1=%s h2=%s exp=%d qnum=%d swid=%s port=%d  [TGUFQM008]",
-									uri_prefix, fdata.Match.Ip1, fdata.Match.Ip2, fdata.Expiry, fdata.Espq.Queuenum, fdata.Espq.Switch, fdata.Espq.Port )
+						fq_sheep.Baa( 1,  "ERR: proactive reserve failed: sdnc=%s h1=%s h2=%s exp=%d qnum=%d swid=%s port=%d  [TGUFQM008]",
+									sdnc, *fdata.Match.Ip1, *fdata.Match.Ip2, fdata.Expiry, fdata.Espq.Queuenum, fdata.Espq.Switch, fdata.Espq.Port )
 					}
 				} else {
 																// q-lite now generates one flowmod  in each direction because of the ITONS requirements
@@ -808,8 +858,8 @@ func Fq_mgr( my_chan chan *ipc.Chmsg, sdn_host *string ) {
 				msg.Response_ch = nil						// for now, nothing goes back
 				if msg.Req_data != nil {
 					fq_data := msg.Req_data.( *Fq_req ); 			// request data
-					if uri_prefix != "" {							// an sdn controller -- skoogi -- is enabled (not supported)
-						fq_sheep.Baa( 0, "ERR: steering reservations are not supported with skoogi (SDNC); no flow-mods pushed" )
+					if sdnc != nil {								// an sdn controller is enabled (not supported)
+						fq_sheep.Baa( 0, "ERR: steering reservations are not supported with an sdn controller (%s); no flow-mods pushed", sdnc )
 					} else {
 						send_stfmod_agent( fq_data, ip2mac, host_list )	
 					}
@@ -819,21 +869,30 @@ func Fq_mgr( my_chan chan *ipc.Chmsg, sdn_host *string ) {
 
 			case REQ_SK_RESERVE:							// send a reservation to skoogi
 				data = msg.Req_data.( []interface{} ); 		// msg data expected to be array of interface: h1, h2, expiry, queue h1/2 must be IP addresses
-				if uri_prefix != "" {
-					fq_sheep.Baa( 2,  "msg to reserve: %s %s %s %d %d",  uri_prefix, data[0].(string), data[1].(string), data[2].(int64), data[3].(int) )
-					msg.State = gizmos.SK_reserve( &uri_prefix, data[0].(string), data[1].(string), data[2].(int64), data[3].(int) )
+				if sdnc != nil {
+					fq_sheep.Baa( 2,  "msg to reserve: %s %s %s %d %d",  sdnc, data[0].(string), data[1].(string), data[2].(int64), data[3].(int) )
+					msg.State = sdnc.Install_flow( &gizmos.Flow{ Src: data[0].(string), Dst: data[1].(string), Expiry: data[2].(int64), Queue: data[3].(int) } )
 				} else {
-					fq_sheep.Baa( 1, "reservation not sent, no sdn-host defined:  %s %s %s %d %d",  uri_prefix, data[0].(string), data[1].(string), data[2].(int64), data[3].(int) )
+					fq_sheep.Baa( 1, "reservation not sent, no sdn-host defined:  %s %s %d %d",  data[0].(string), data[1].(string), data[2].(int64), data[3].(int) )
 				}
 
 			case REQ_SETQUEUES:								// request from reservation manager which indicates something changed and queues need to be reset
 				qlist := msg.Req_data.( []interface{} )[0].( []string )
+				last_qlist = qlist
 				if ssq_cmd != nil {
 					adjust_queues( qlist, ssq_cmd, host_list ) 					// if writing to a file and driving a local script
 				} else {
 					adjust_queues_agent( qlist, host_list, phost_suffix )		// if sending json to an agent
 				}
 
+			case REQ_DESIRED_STATE:							// reconciler passes the desired state from res_mgr to be completed
+				if ws, ok := msg.Req_data.( *want_state ); ok {
+					desired_state( ws, ip2mac, host_list, last_qlist, phost_suffix )
+					msg.Response_data = ws
+				} else {
+					msg.State = fmt.Errorf( "desired state missing from request" )
+				}
+
 			case REQ_CHOSTLIST:								// this is tricky as it comes from tickler as a request, and from osifmgr as a response, be careful!
 				msg.Response_ch = nil;						// regardless of source, we should not reply to this request
 
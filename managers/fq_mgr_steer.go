@@ -29,6 +29,7 @@
 
 	Mods:		27 Feb 2015 - changes to deal with lazy update and to correct l* bug.
 				15 Jun 2015 - Cleaned up commented out lines a bit.
+				17 Oct 2026 - Pledge name added to the flow-mod action so that acks can be mapped back.
 */
 
 package managers
@@ -230,6 +231,9 @@ func send_stfmod_agent( data *Fq_req, ip2mac map[string]*string, hlist *string )
 	msg.Actions[0].Hosts = hosts
 	msg.Actions[0].Fdata = make( []string, 1 )
 	msg.Actions[0].Fdata[0] = fmt.Sprintf( `%s -t %d -p %d %s %s add 0xedde br-int`, table, data.Expiry, data.Pri, match_opts, action_opts )
+	if data.Id != nil {
+		msg.Actions[0].Pname = *data.Id							// allows agent manager to map the ack back to the pledge
+	}
 
 	json, err := json.Marshal( msg )			// bundle into a json string
 	if err != nil {
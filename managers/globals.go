@@ -42,6 +42,22 @@
 				26 Feb 2015 - Added support for default gateway sussing.
 				20 Mar 2015 - Added REQ_GET_PHOST_FROM_MAC
 				31 Mar 2015 - Added REQ_GET_PROJ_HOSTS
+				17 Oct 2026 - Added REQ_MODRES
+				17 Oct 2026 - Added REQ_ADDSERIES, REQ_EXPSERIES
+				17 Oct 2026 - Added REQ_FINDSLOT
+				17 Oct 2026 - Added REQ_ADDWAIT, REQ_RETRYWAIT
+				17 Oct 2026 - Added REQ_PREEMPT
+				17 Oct 2026 - Added REQ_REROUTE
+				17 Oct 2026 - Added REQ_DRAIN, REQ_UNDRAIN, REQ_LISTDRAINS
+				17 Oct 2026 - Added REQ_SIMULATE
+				17 Oct 2026 - Added REQ_LINKUSAGE
+				17 Oct 2026 - Added REQ_TOPOCHECK
+				17 Oct 2026 - Added REQ_PUSH_STATE, REQ_ACKCHECK
+				17 Oct 2026 - Added REQ_RECONCILE, REQ_DESIRED_STATE, REQ_DUMP_STATE, REQ_REPUSH and the
+					reconciler channel.
+				17 Oct 2026 - Added REQ_LISTAGENTS
+				17 Oct 2026 - Added REQ_HEARTBEAT
+				17 Oct 2026 - Added REQ_DEGRADED
 */
 
 package managers
@@ -129,6 +145,29 @@ const (
 	REQ_HAS_ANY_ROLE			// given token and role list return true if token lists any role presented
 	REQ_SETDISC					// set the discount value
 	REQ_DUPCHECK				// check for duplicate (resmgr)
+	REQ_MODRES					// modify bandwidth/expiry of an existing reservation (resmgr, network)
+	REQ_ADDSERIES				// add a recurring reservation and admit its first occurrences (resmgr)
+	REQ_EXPSERIES				// generate occurrences of recurring reservations which have come within the horizon (resmgr)
+	REQ_FINDSLOT				// find the earliest window(s) in which a bandwidth reservation would fit (network)
+	REQ_ADDWAIT					// add a rejected reservation to the waitlist (resmgr)
+	REQ_RETRYWAIT				// capacity may have been freed; retry queued reservations (resmgr)
+	REQ_PREEMPT					// yank lower priority reservations and admit a bandwidth reservation (resmgr)
+	REQ_REROUTE					// reservations were moved to new paths after a topology change (resmgr)
+	REQ_DRAIN					// mark link(s) unavailable for a window and report the impact (network)
+	REQ_UNDRAIN					// remove a drain (network)
+	REQ_LISTDRAINS				// list drains (network)
+	REQ_SIMULATE				// what-if capacity simulation (network)
+	REQ_LINKUSAGE				// link utilisation over a time range (network)
+	REQ_TOPOCHECK				// check/validate the static topology file (network)
+	REQ_PUSH_STATE				// agent(s) reported the result of pushing a pledge (resmgr)
+	REQ_ACKCHECK				// look for agent actions which were never acknowledged (agent)
+	REQ_RECONCILE				// start an audit of installed flow-mods/queues and/or return the last report (reconciler)
+	REQ_DESIRED_STATE			// generate/complete the flow-mods and queues which should be installed (resmgr, fqmgr)
+	REQ_DUMP_STATE				// list flow-mods/queues on hosts (agent); the list returned by the agent (reconciler)
+	REQ_REPUSH					// push the named reservations again (resmgr)
+	REQ_LISTAGENTS				// list connected agents and their capabilities
+	REQ_HEARTBEAT				// send heartbeats to agents and check for silent ones (agent)
+	REQ_DEGRADED				// protected reservations which have lost, or regained, their paths (resmgr)
 )
 
 const (
@@ -186,6 +225,7 @@ var (
 	osif_ch		chan	*ipc.Chmsg		// openstack interface
 	fq_ch		chan	*ipc.Chmsg		// flow and queue manager
 	am_ch		chan	*ipc.Chmsg		// agent manager channel
+	rc_ch		chan	*ipc.Chmsg		// reconciler
 
 	tklr	*ipc.Tickler				// tickler that will drive periodic things like checkpointing
 
@@ -203,6 +243,7 @@ var (
 	rm_sheep	*bleater.Bleater
 	http_sheep	*bleater.Bleater
 	qm_sheep	*bleater.Bleater
+	rc_sheep	*bleater.Bleater
 
 	/*
 		http manager needs globals because the http callback doesn't allow private data to be passed
@@ -280,7 +321,7 @@ type Fq_req struct {
 	CAUTION:  this is not implemented as an init() function as we must pass information from the
 			main to here.
 */
-func Initialise( cfg_fname *string, ver *string, nwch chan *ipc.Chmsg, rmch chan *ipc.Chmsg, osifch chan *ipc.Chmsg, fqch chan *ipc.Chmsg, amch chan *ipc.Chmsg ) (err error)  {
+func Initialise( cfg_fname *string, ver *string, nwch chan *ipc.Chmsg, rmch chan *ipc.Chmsg, osifch chan *ipc.Chmsg, fqch chan *ipc.Chmsg, amch chan *ipc.Chmsg, rcch chan *ipc.Chmsg ) (err error)  {
 	err = nil
 
 	def_log_dir := "."
@@ -291,6 +332,7 @@ func Initialise( cfg_fname *string, ver *string, nwch chan *ipc.Chmsg, rmch chan
 	osif_ch = osifch
 	fq_ch = fqch
 	am_ch = amch
+	rc_ch = rcch
 	
 
 	if ver != nil {
@@ -32,16 +32,23 @@
 
 				These requests are supported:
 					POST:
+						audit	(limited)
 						chkpt	(limited)
+						findslot
 						graph	(limited)
+						listagents	(limited)
 						listconns
 						listhosts	(limited)
 						listres
+						modres
 						pause (limited)
 						reserve
 						resume (limited)
 						verbose (limited)
 
+					PUT:
+						reservation	(modify)
+
 					DELETE:
 						reservation
 
@@ -96,12 +103,39 @@
 				16 Jul 2015 : Correct typo in the default admin role string.
 				12 Aug 2015 : Corrected debug message.
 				03 Sep 2015 : Added latency option to verbose.
+				17 Oct 2026 : Added modify reservation support (PUT reservation, and POST modres).
+								Broke steering reservation creation out of parse_post so that it can be
+								shared with the v2 (json) interface. Registered the /tegu/v2/ handler.
+				17 Oct 2026 : Reserve accepts repeat= and duration= to create a recurring reservation.
+				17 Oct 2026 : Added findslot to search for (and optionally book) the earliest window
+								where a bandwidth reservation would fit.
+				17 Oct 2026 : Reserve and ow_reserve accept queue=true (and priority=n) to put a
+								rejected reservation on the waitlist.
+				17 Oct 2026 : Priority is set on the pledge; a bandwidth reservation rejected for lack of
+								capacity preempts lower priority reservations when the network says that
+								doing so would make room.
+				17 Oct 2026 : Reserve accepts protect=link|switch to reserve a disjoint pair of paths.
+				17 Oct 2026 : Added drain, undrain and listdrains for link maintenance windows.
+				17 Oct 2026 : Added simulate for what-if capacity planning.
+				17 Oct 2026 : Added linkusage to report link utilisation over a time range.
+				17 Oct 2026 : Graph accepts format=dot|graphml, and res=<name> to highlight a reservation's paths.
+				17 Oct 2026 : Added topocheck to validate and report on the static topology file.
+				17 Oct 2026 : Added audit to run, or report on, the reconciler's flow-mod and queue audit.
+				17 Oct 2026 : Added listagents to list connected agents and their capabilities.
+				17 Oct 2026 : Simulated reservations are given an empty cookie (nil caused a panic).
+				17 Oct 2026 : Findslot and modres errors are counted in the request error total.
+				17 Oct 2026 : PUT falls back to the POST parser for anything other than reservation/modres
+								so that legacy clients which PUT reserve, listres, etc. still work.
+				17 Oct 2026 : Finalise_bw_res and finalise_bwow_res return the Queued_state from res_mgr when
+								the reservation was put on the waitlist. Validate_hosts keeps an Auth_error
+								from osif. Bandw_pair() and tclass2dscp_koe() moved here from the v2 api.
 */
 
 package managers
 
 import (
 	//"bufio"
+	"bytes"
 	//"encoding/json"
 	//"flag"
 	"fmt"
@@ -170,6 +204,9 @@ func validate_hosts( h1 string, h2 string ) ( h1x string, h2x string, p1 *string
 
 	if req.State != nil {
 		err = fmt.Errorf( "h1 validation failed: %s", req.State )
+		if _, ok := req.State.( *Auth_error ); ok {
+			err = &Auth_error{ reason: err.Error() }
+		}
 		return
 	}
 
@@ -182,6 +219,9 @@ func validate_hosts( h1 string, h2 string ) ( h1x string, h2x string, p1 *string
 
 	if req.State != nil {
 		err = fmt.Errorf( "h2 validation failed: %s", req.State )
+		if _, ok := req.State.( *Auth_error ); ok {
+			err = &Auth_error{ reason: err.Error() }
+		}
 		return
 	}
 
@@ -361,6 +401,44 @@ func dig_data( resp *http.Request ) ( data []byte ) {
 }
 
 
+/*
+	Convert the pair of bandwidth strings to values. If one is missing the other is used
+	for both.
+*/
+func bandw_pair( in string, out string ) ( bandw_in int64, bandw_out int64 ) {
+	if in == "" {
+		in = out
+	}
+	if out == "" {
+		out = in
+	}
+
+	return int64( clike.Atof( in ) ), int64( clike.Atof( out ) )
+}
+
+/*
+	Translate the dscp traffic class into a value and the keep on exit flag (set when the
+	class has a global_ prefix). An empty class, or 0, is voice.
+*/
+func tclass2dscp_koe( tclass string ) ( dscp int, koe bool, err error ) {
+	dscp = tclass2dscp["voice"]							// default to using voice traffic class
+	if tclass == "" || tclass == "0" {
+		return
+	}
+
+	if strings.HasPrefix( tclass, "global_" ) {
+		koe = true
+		dscp = tclass2dscp[tclass[7:]]
+	} else {
+		dscp = tclass2dscp[tclass]
+	}
+	if dscp <= 0 {
+		err = fmt.Errorf( "traffic classifcation string is not valid: %s", tclass )
+	}
+
+	return
+}
+
 /*
 	Given a reservation (pledge) ask network manager to reserve the bandwidth and set queues. If net mgr
 	is successful, then we'll send the reservation off to reservation manager to do the rest (push flow-mods
@@ -370,8 +448,15 @@ func dig_data( resp *http.Request ) ( data []byte ) {
 
 	This function will also check for a duplicate pledge aloready in the inventory and reject it
 	if a dup is found.
+
+	If queue is true, and the network rejects the reservation, it is placed on the waitlist with
+	the given priority rather than being discarded; queued is then the state returned by res_mgr
+	(it is nil otherwise).
+
+	If the network rejects the reservation, but indicates that removing lower priority reservations
+	would make room (Preempt_error), res_mgr is asked to remove them and admit this one.
 */
-func finalise_bw_res( res *gizmos.Pledge_bw, res_paused bool ) ( reason string, jreason string, nerrors int ) {
+func finalise_bw_res( res *gizmos.Pledge_bw, res_paused bool, queue bool, priority int ) ( reason string, jreason string, nerrors int, queued *Queued_state ) {
 
 	nerrors = 0
 	jreason = ""
@@ -420,6 +505,110 @@ func finalise_bw_res( res *gizmos.Pledge_bw, res_paused bool ) ( reason string,
 			res.Pause( false )								// when paused we must mark the reservation as paused and pushed so it doesn't push until resume received
 			res.Set_pushed( )
 		}
+	} else {
+		if pe, ok := req.State.( *Preempt_error ); ok {
+			if res_paused {
+				res.Pause( false )
+				res.Set_pushed( )
+			}
+
+			victims := pe.Get_victims()
+			req = ipc.Mk_chmsg( )
+			req.Send_req( rmgr_ch, my_ch, REQ_PREEMPT, Mk_preempt_req( res, victims ), nil )	// yank the victims and admit
+			req = <- my_ch
+			if req.State == nil {
+				reason = fmt.Sprintf( "reservation accepted; %d lower priority reservation(s) preempted; reservation path has %d entries", len( victims ), len( res.Get_path_list() ) )
+				jreason =  res.To_json()
+				return
+			}
+
+			http_sheep.Baa( 1, "preemption failed for %s: %s", *res.Get_id(), req.State )
+		}
+
+		if queue {
+			return queue_res( res, priority, req.State, res_paused )
+		}
+		reason = fmt.Sprintf( "reservation rejected: %s", req.State )
+		nerrors++
+	}
+
+	return
+}
+
+/*
+	Put a reservation that the network manager rejected onto the waitlist in res_mgr. Why is the
+	reason that the network rejected it. Return values are the same as finalise_bw_res(); jreason
+	is the waitlist entry which includes the state (queued).
+*/
+func queue_res( res gizmos.Pledge, priority int, why error, res_paused bool ) ( reason string, jreason string, nerrors int, queued *Queued_state ) {
+	my_ch := make( chan *ipc.Chmsg )
+	defer close( my_ch )
+
+	wreason := "no path with enough capacity"
+	if why != nil {
+		wreason = fmt.Sprintf( "%s", why )
+	}
+
+	if res_paused {
+		res.Pause( false )									// pledge will be marked pushed when admitted so it doesn't go out until resumed
+	}
+
+	req := ipc.Mk_chmsg( )
+	req.Send_req( rmgr_ch, my_ch, REQ_ADDWAIT, Mk_wait_ent( res, priority, wreason ), nil )
+	req = <- my_ch
+
+	if req.State != nil {
+		return fmt.Sprintf( "reservation rejected: %s", req.State ), "", 1, nil
+	}
+
+	ckptreq := ipc.Mk_chmsg( )
+	ckptreq.Send_req( rmgr_ch, nil, REQ_CHKPT, nil, nil )	// request a chkpt now, but don't wait on it
+	queued = req.Response_data.( *Queued_state )
+	return fmt.Sprintf( "reservation queued until capacity is available: %s", queued.Get_reason() ), queued.Get_json(), 0, queued
+}
+
+/*
+	Complete a recurring bandwidth reservation. Res_mgr admits the occurrences inside of
+	its horizon and rejects the whole series if any one of them cannot be admitted.
+*/
+func finalise_series_res( res *gizmos.Pledge_series, res_paused bool ) ( reason string, jreason string, nerrors int ) {
+
+	nerrors = 0
+	jreason = ""
+	reason = ""
+
+	my_ch := make( chan *ipc.Chmsg )						// allocate channel for responses to our requests
+	defer close( my_ch )									// close it on return
+
+	req := ipc.Mk_chmsg( )
+	gp := gizmos.Pledge( res )								// convert to generic pledge to pass
+	req.Send_req( rmgr_ch, my_ch, REQ_DUPCHECK, &gp, nil )	// see if we have a duplicate in the cache
+	req = <- my_ch
+	if req.Response_data != nil {
+		rp := req.Response_data.( *string )
+		if rp != nil {
+			nerrors = 1
+			reason = fmt.Sprintf( "reservation duplicates existing reservation: %s",  *rp )
+			return
+		}
+	}
+
+	if res_paused {
+		rm_sheep.Baa( 1, "reservations are paused, accepted occurrences will not be pushed until resumed" )
+		res.Pause( false )									// occurrences inherit the paused state as they are generated
+		res.Set_pushed( )
+	}
+
+	req = ipc.Mk_chmsg( )
+	req.Send_req( rmgr_ch, my_ch, REQ_ADDSERIES, res, nil )	// res_mgr admits occurrences in the horizon and adds all to the inventory
+	req = <- my_ch
+
+	if req.State == nil {
+		ckptreq := ipc.Mk_chmsg( )
+		ckptreq.Send_req( rmgr_ch, nil, REQ_CHKPT, nil, nil )	// request a chkpt now, but don't wait on it
+		rule, _ := res.Get_schedule( )
+		reason = fmt.Sprintf( "recurring reservation accepted; schedule: %s", rule )
+		jreason = res.To_json()
 	} else {
 		reason = fmt.Sprintf( "reservation rejected: %s", req.State )
 		nerrors++
@@ -429,9 +618,9 @@ func finalise_bw_res( res *gizmos.Pledge_bw, res_paused bool ) ( reason string,
 }
 
 /*
-	Complete a one-way bandwdith reservation.
+	Complete a one-way bandwdith reservation. Queue and priority are as described for finalise_bw_res().
 */
-func finalise_bwow_res( res *gizmos.Pledge_bwow, res_paused bool ) ( reason string, jreason string, nerrors int ) {
+func finalise_bwow_res( res *gizmos.Pledge_bwow, res_paused bool, queue bool, priority int ) ( reason string, jreason string, nerrors int, queued *Queued_state ) {
 
 	nerrors = 0
 	jreason = ""
@@ -479,16 +668,457 @@ func finalise_bwow_res( res *gizmos.Pledge_bwow, res_paused bool ) ( reason stri
 			res.Pause( false )								// when paused we must mark the reservation as paused and pushed so it doesn't push until resume received
 			res.Set_pushed( )
 		}
-	} else {
-		reason = fmt.Sprintf( "one way reservation rejected: %s", req.State )
-		nerrors++
+	} else {
+		if queue {
+			return queue_res( res, priority, req.State, res_paused )
+		}
+		reason = fmt.Sprintf( "one way reservation rejected: %s", req.State )
+		nerrors++
+	}
+
+	return
+}
+
+
+
+
+/*
+	Build a steering reservation. The endpoints are validated and translated (project/host into
+	tenantID/host), wild cards are resolved and the middle boxes in the comma separated list are
+	looked up. Usrsp is the [token/]project which owns the endpoints and middleboxes. The
+	reservation is returned, but is not added to the inventory; see finalise_steer_res().
+*/
+func mk_steer_res( startt int64, endt int64, usrsp string, ep1 string, ep2 string, mblist string, cookie *string, proto *string ) ( res *gizmos.Pledge_steer, err error ) {
+
+	my_ch := make( chan *ipc.Chmsg )						// allocate channel for responses to our requests
+	defer close( my_ch )									// close it on return
+
+	h1, h2, p1, p2, _, _, err := validate_hosts( usrsp + "/" + ep1, usrsp + "/" + ep2 )		// translate project/host[port] into tenantID/host and if token/project/name rquired validates token.
+	if err != nil {
+		err = fmt.Errorf( "invalid endpoints:  %s", err )
+		http_sheep.Baa( 1, "steering reservation rejected: %s", err )
+		return
+	}
+
+	h1 = wc2name( h1 )										// resolve E* or L* wild cards
+	h2 = wc2name( h2 )
+
+	if h1 != "" {
+		update_graph( &h1, false, h2 == "" )				// pull all of the VM information from osif then send to netmgr (block if h2 is empty)
+	}
+	if h2 != "" {
+		update_graph( &h2, true, true )						// this call will block until netmgr has updated the graph and osif has pushed updates into fqmgr
+	}
+
+	req := ipc.Mk_chmsg( )
+	req.Send_req( osif_ch, my_ch, REQ_VALIDATE_TOKEN, &usrsp, nil )		// validate token and convert user space to ID if name given
+	req = <- my_ch
+	if req.Response_data != nil {
+		if  req.Response_data.( *string ) != nil {
+			usrsp = *(req.Response_data.( *string ))
+		} else {
+			err = fmt.Errorf( "unable to create steering reservation: %s", req.State )
+			return
+		}
+	}
+
+	res_name := mk_resname( )								// name used to track the reservation in the cache and given to queue setting commands for visual debugging
+	res, err = gizmos.Mk_steer_pledge( &h1, &h2, p1, p2, startt, endt, &res_name, cookie, proto )
+	if err != nil {
+		err = fmt.Errorf( "unable to create a steering reservation  %s", err )
+		return
+	}
+
+	mbnames := strings.Split( mblist, "," )
+	for i := range mbnames {								// generate a mbox object for each
+		mbn := ""
+		if strings.Index( mbnames[i], "/" ) < 0 {			// add user space info out front
+			mbn = usrsp + mbnames[i] 						// validation/translation adds a trailing /, so not needed here
+		} else {
+			mbn = mbnames[i]
+		}
+
+		update_graph( &mbn, true, true )						// this call will block until netmgr has updated the graph and osif has pushed updates into fqmgr
+		req.Send_req( nw_ch, my_ch, REQ_HOSTINFO, &mbn, nil )	// get host info string (mac, ip, switch)
+		req = <- my_ch
+		if req.State != nil {
+			http_sheep.Baa( 1, "unable to validate all middle boxes" )
+			err = req.State
+			res = nil
+			return
+		}
+
+		htoks := strings.Split( req.Response_data.( string ), "," )					// results are: ip, mac, switch-id, switch-port; all strings
+		res.Add_mbox( gizmos.Mk_mbox( &mbnames[i], &htoks[1], &htoks[2], clike.Atoi( htoks[3] ) ) )
+	}
+
+	return
+}
+
+/*
+	Complete a steering reservation by adding it to the reservation manager's inventory
+	which will drive the flow-mods. Return values are the same as finalise_bw_res().
+*/
+func finalise_steer_res( res *gizmos.Pledge_steer ) ( reason string, jreason string, nerrors int ) {
+
+	my_ch := make( chan *ipc.Chmsg )						// allocate channel for responses to our requests
+	defer close( my_ch )									// close it on return
+
+	req := ipc.Mk_chmsg( )
+	req.Send_req( rmgr_ch, my_ch, REQ_ADD, res, nil )		// push it into the reservation manager which will drive flow-mods etc
+	req = <- my_ch
+
+	if req.State == nil {
+		ckptreq := ipc.Mk_chmsg( )							// must have new message since we don't wait on a response
+		ckptreq.Send_req( rmgr_ch, nil, REQ_CHKPT, nil, nil )
+		reason = fmt.Sprintf( "steering reservation accepted; reservation has %d middleboxes", res.Get_mbox_count() )
+		jreason =  res.To_json()
+	} else {
+		nerrors++
+		reason = fmt.Sprintf( "%s", req.State )
+	}
+
+	return
+}
+
+
+/*
+	Search for the earliest window(s), of duration seconds and starting between startt and horizon,
+	where a bandwidth reservation between h1 and h2 would fit. Nothing is reserved unless book is
+	true; in that case the windows are tried in order and the first that can be reserved is booked
+	(another request could take the capacity between the search and the booking) and the
+	reservation's json is returned.  Returns the list of start times found which may be empty.
+*/
+func find_slot( h1 string, h2 string, startt int64, horizon int64, duration int64, bandw_in int64, bandw_out int64, count int,
+		book bool, cookie *string, tclass string, ipv6 bool ) ( slots []int64, booked string, err error ) {
+
+	if duration < 1 {
+		return nil, "", fmt.Errorf( "duration must be greater than zero" )
+	}
+	if bandw_in <= 0 || bandw_out <= 0 {
+		return nil, "", fmt.Errorf( "bandwidth value(s) must be greater than zero" )
+	}
+
+	dscp, dscp_koe, err := tclass2dscp_koe( tclass )
+	if err != nil {
+		return
+	}
+
+	h1, h2, p1, p2, v1, v2, err := validate_hosts( h1, h2 )		// translate project/host[:port][{vlan}] into pieces parts and validates token/project
+	if err != nil {
+		return
+	}
+	update_graph( &h1, false, false )							// pull all of the VM information from osif then send to netmgr
+	update_graph( &h2, true, true )								// this call will block until netmgr has updated the graph
+
+	my_ch := make( chan *ipc.Chmsg )
+	defer close( my_ch )
+
+	req := ipc.Mk_chmsg( )
+	req.Send_req( nw_ch, my_ch, REQ_FINDSLOT, Mk_slot_req( &h1, &h2, startt, horizon, duration, bandw_in, bandw_out, count ), nil )
+	req = <- my_ch
+	if req.State != nil {
+		return nil, "", req.State
+	}
+	slots = req.Response_data.( []int64 )
+
+	if book {
+		for _, t := range slots {
+			res_name := mk_resname( )
+			res, err := gizmos.Mk_bw_pledge( &h1, &h2, p1, p2, t, t + duration, bandw_in, bandw_out, &res_name, cookie, dscp, dscp_koe )
+			if res == nil {
+				return slots, "", err
+			}
+
+			res.Set_vlan( v1, v2 )
+			res.Set_matchv6( ipv6 )
+			reason, jreason, ecount, _ := finalise_bw_res( res, res_paused, false, 0 )
+			if ecount == 0 {
+				return slots, jreason, nil
+			}
+			http_sheep.Baa( 1, "findslot: unable to book window starting at %d: %s", t, reason )
+		}
+
+		if len( slots ) > 0 {
+			err = fmt.Errorf( "unable to book any of the windows found" )
+		}
+	}
+
+	return
+}
+
+/*
+	Format the slots, and booked reservation if there is one, as json.
+*/
+func slots2json( slots []int64, duration int64, booked string ) ( string ) {
+	bs := bytes.NewBufferString( `{ "slots": [ ` )
+	sep := ""
+	for _, t := range slots {
+		bs.WriteString( fmt.Sprintf( `%s{ "commence": %d, "expiry": %d }`, sep, t, t + duration ) )
+		sep = ", "
+	}
+	bs.WriteString( " ]" )
+
+	if booked != "" {
+		bs.WriteString( `, "reservation": ` + booked )
+	}
+	bs.WriteString( " }" )
+
+	return bs.String()
+}
+
+/*
+	Parse a findslot request and run the search.  Expected tokens are:
+		findslot [key=value...] bandwidth duration host1,host2 [cookie [dscp]]
+	where keys are start (timestamp, default now), horizon (seconds after start, default 1 day),
+	count (number of windows, default 3), book (true to reserve the first window) and ipv6.
+*/
+func parse_findslot( tokens []string ) ( reason string, jstr string, err error ) {
+	var (
+		bandw_in	int64
+		bandw_out	int64
+		startt		int64
+		horizon		int64 = 86400
+		count		int = 3
+		ipv6		bool
+		tclass		string
+	)
+
+	key_list := "bandw duration hosts cookie dscp"
+	tmap := gizmos.Mixtoks2map( tokens[1:], key_list )
+	if ok, mlist := gizmos.Map_has_all( tmap, "bandw duration hosts" ); !ok {
+		err = fmt.Errorf( "missing parameters: (%s); usage: findslot [start=<time>] [horizon=<sec>] [count=<n>] [book=true] <bandwidth[K|M|G][,<outbandw[K|M|G]> <duration-sec> <host1>[,<host2>] [cookie [dscp]]", mlist )
+		return
+	}
+
+	if strings.Index( *tmap["bandw"], "," ) >= 0 {				// look for inputbandwidth,outputbandwidth
+		subtokens := strings.Split( *tmap["bandw"], "," )
+		bandw_in, bandw_out = bandw_pair( subtokens[0], subtokens[1] )
+	} else {
+		bandw_in, bandw_out = bandw_pair( *tmap["bandw"], "" )
+	}
+
+	startt = time.Now().Unix()
+	if tmap["start"] != nil {
+		if s := clike.Atoi64( *tmap["start"] ); s > startt {
+			startt = s
+		}
+	}
+	if tmap["horizon"] != nil {
+		horizon = clike.Atoi64( *tmap["horizon"] )
+	}
+	if tmap["count"] != nil {
+		count = clike.Atoi( *tmap["count"] )
+	}
+	if tmap["ipv6"] != nil {
+		ipv6 = *tmap["ipv6"] == "true"
+	}
+	if tmap["dscp"] != nil {
+		tclass = *tmap["dscp"]
+	}
+	if tmap["cookie"] == nil {
+		tmap["cookie"] = &empty_str
+	}
+	book := tmap["book"] != nil && *tmap["book"] == "true"
+
+	h1, h2 := gizmos.Str2host1_host2( *tmap["hosts"] )
+	duration := clike.Atoi64( *tmap["duration"] )
+	slots, booked, err := find_slot( h1, h2, startt, startt + horizon, duration, bandw_in, bandw_out, count, book, tmap["cookie"], tclass, ipv6 )
+	if err != nil {
+		return
+	}
+
+	jstr = slots2json( slots, duration, booked )
+	switch {
+		case len( slots ) == 0:
+			reason = fmt.Sprintf( "no window with enough capacity was found in the next %d seconds", horizon )
+
+		case booked != "":
+			reason = fmt.Sprintf( "%d window(s) found; the first that could be booked was reserved", len( slots ) )
+
+		default:
+			reason = fmt.Sprintf( "%d window(s) found", len( slots ) )
+	}
+
+	return
+}
+
+/*
+	Parse a drain request and send it to the network manager. Tokens are:
+		drain [id=<name>] [reroute=true] {link=<link-id>|switch=<switch-id>} [<start>-]<end>
+
+	The impact report (json) generated by the network is returned along with a short reason.
+*/
+func parse_drain( tokens []string ) ( reason string, jstr string, err error ) {
+	tmap := gizmos.Mixtoks2map( tokens[1:], "window" )
+	if ok, mlist := gizmos.Map_has_all( tmap, "window" ); !ok {
+		err = fmt.Errorf( "missing parameters: (%s); usage: drain [id=<name>] [reroute=true] {link=<link-id>|switch=<switch-id>} [<start>-]<end>", mlist )
+		return
+	}
+	if (tmap["link"] == nil) == (tmap["switch"] == nil) {
+		err = fmt.Errorf( "drain: exactly one of link= or switch= must be given" )
+		return
+	}
+
+	id := ""
+	if tmap["id"] != nil {
+		id = *tmap["id"]
+	} else {
+		id = "drain_" + mk_resname( )
+	}
+
+	startt, endt := gizmos.Str2start_end( *tmap["window"] )
+	d, err := gizmos.Mk_drain( &id, startt, endt )
+	if err != nil {
+		return
+	}
+
+	reroute := tmap["reroute"] != nil && *tmap["reroute"] == "true"
+	my_ch := make( chan *ipc.Chmsg )
+	defer close( my_ch )
+
+	req := ipc.Mk_chmsg( )
+	req.Send_req( nw_ch, my_ch, REQ_DRAIN, Mk_drain_req( d, tmap["link"], tmap["switch"], reroute ), nil )
+	req = <- my_ch
+	if req.State != nil {
+		err = req.State
+		return
+	}
+
+	jstr = req.Response_data.( string )
+	reason = fmt.Sprintf( "drain %s added", id )
+	return
+}
+
+/*
+	Parse a link usage request and send it to the network manager. Tokens are:
+		linkusage [link=<link-id>|sw1=<switch-id> sw2=<switch-id>] [top=<n>] [format=json|csv] [<start>-]<end>
+
+	The report is returned as json, or as a json string containing the csv when format=csv.
+*/
+func parse_linkusage( tokens []string ) ( jstr string, err error ) {
+	tmap := gizmos.Mixtoks2map( tokens[1:], "window" )
+	if ok, mlist := gizmos.Map_has_all( tmap, "window" ); !ok {
+		err = fmt.Errorf( "missing parameters: (%s); usage: linkusage [link=<link-id>|sw1=<switch-id> sw2=<switch-id>] [top=<n>] [format=json|csv] [<start>-]<end>", mlist )
+		return
+	}
+	if (tmap["sw1"] == nil) != (tmap["sw2"] == nil) {
+		err = fmt.Errorf( "linkusage: both sw1= and sw2= must be given" )
+		return
+	}
+
+	top := 10
+	if tmap["top"] != nil {
+		top = clike.Atoi( *tmap["top"] )
+	}
+	csv := tmap["format"] != nil && *tmap["format"] == "csv"
+
+	startt, endt := gizmos.Str2start_end( *tmap["window"] )
+	my_ch := make( chan *ipc.Chmsg )
+	defer close( my_ch )
+
+	req := ipc.Mk_chmsg( )
+	req.Send_req( nw_ch, my_ch, REQ_LINKUSAGE, Mk_usage_req( tmap["link"], tmap["sw1"], tmap["sw2"], startt, endt, top, csv ), nil )
+	req = <- my_ch
+	if req.State != nil {
+		err = req.State
+		return
+	}
+
+	jstr = req.Response_data.( string )
+	if csv {
+		jstr = fmt.Sprintf( "%q", jstr )
+	}
+	return
+}
+
+/*
+	Parse a simulate request and send it to the network manager. Tokens are key=value pairs
+	and each key may be given more than once:
+		simulate [rmlink=<link-id>] [setcap=<link-id>,<capacity>] [addlink=<sw1>,<sw2>,<capacity>] [reserve=<bandwidth>,[<start>-]<end>,<host1>,<host2>]
+
+	Changes are applied in the order given. The json result generated by the network is returned
+	along with a short reason.
+*/
+func parse_simulate( tokens []string ) ( reason string, jstr string, err error ) {
+	usage := "usage: simulate [rmlink=<link-id>] [setcap=<link-id>,<capacity>] [addlink=<sw1>,<sw2>,<capacity>] [reserve=<bandwidth>,[<start>-]<end>,<host1>,<host2>]"
+	if len( tokens ) < 2 {
+		err = fmt.Errorf( "missing parameters; %s", usage )
+		return
+	}
+
+	sr := Mk_sim_req( )
+	for _, tok := range tokens[1:] {
+		kv := strings.SplitN( tok, "=", 2 )
+		if len( kv ) != 2 || kv[1] == "" {
+			err = fmt.Errorf( "simulate: bad parameter: %s; %s", tok, usage )
+			return
+		}
+		fields := strings.Split( kv[1], "," )
+
+		switch kv[0] {
+			case "rmlink":
+				sr.Add_rmlink( &kv[1] )
+
+			case "setcap":
+				if len( fields ) != 2 {
+					err = fmt.Errorf( "simulate: setcap expects <link-id>,<capacity>: %s", tok )
+					return
+				}
+				sr.Add_setcap( &fields[0], int64( clike.Atof( fields[1] ) ) )
+
+			case "addlink":
+				if len( fields ) != 3 {
+					err = fmt.Errorf( "simulate: addlink expects <sw1>,<sw2>,<capacity>: %s", tok )
+					return
+				}
+				sr.Add_link( &fields[0], &fields[1], int64( clike.Atof( fields[2] ) ) )
+
+			case "reserve":
+				if len( fields ) != 4 || fields[1] == "" {
+					err = fmt.Errorf( "simulate: reserve expects <bandwidth>,<window>,<host1>,<host2>: %s", tok )
+					return
+				}
+
+				h1, h2, p1, p2, _, _, verr := validate_hosts( fields[2], fields[3] )
+				if verr != nil {
+					err = fmt.Errorf( "simulate: %s", verr )
+					return
+				}
+				update_graph( &h1, false, false )
+				update_graph( &h2, false, true )
+
+				bandw := int64( clike.Atof( fields[0] ) )
+				startt, endt := gizmos.Str2start_end( fields[1] )
+				res_name := "sim_" + mk_resname( )
+				p, perr := gizmos.Mk_bw_pledge( &h1, &h2, p1, p2, startt, endt, bandw, bandw, &res_name, &empty_str, tclass2dscp["voice"], false )
+				if perr != nil {
+					err = fmt.Errorf( "simulate: %s", perr )
+					return
+				}
+				sr.Add_pledge( p )
+
+			default:
+				err = fmt.Errorf( "simulate: unknown parameter: %s; %s", kv[0], usage )
+				return
+		}
 	}
 
-	return
-}
-
+	my_ch := make( chan *ipc.Chmsg )
+	defer close( my_ch )
 
+	req := ipc.Mk_chmsg( )
+	req.Send_req( nw_ch, my_ch, REQ_SIMULATE, sr, nil )
+	req = <- my_ch
+	if req.State != nil {
+		err = req.State
+		return
+	}
 
+	jstr = req.Response_data.( string )
+	reason = "simulation complete; no changes were made"
+	return
+}
 
 // ---- main parsers ------------------------------------------------------------------------------------
 /*
@@ -496,14 +1126,21 @@ func finalise_bwow_res( res *gizmos.Pledge_bwow, res_paused bool ) ( reason stri
 	to be sent in the body. Supported requests:
 
 		ckpt
+		drain [id=<name>] [reroute=true] {link=<link-id>|switch=<switch-id>} [<start>-]<end>
+		linkusage [link=<link-id>|sw1=<switch-id> sw2=<switch-id>] [top=<n>] [format=json|csv] [<start>-]<end>
+		listdrains
 		listhosts
 		listulcaps
 		listres
 		listconns
+		modres [bandw=<bandwidth[K|M|G][,outbandwidth[K|M|G]>] [expiry=<end>|+<sec>|unbounded] <name> [cookie]
 		reserve <bandwidth[K|M|G][,outbandwidth[K|M|G]> [<start>-]<end> <host1>[-<host2] [cookie]
-		graph
+		graph [project=<name>] [format=json|dot|graphml] [res=<name>]
 		ping
 		listconns <hostname|hostip>
+		simulate [rmlink=<link-id>] [setcap=<link-id>,<cap>] [addlink=<sw1>,<sw2>,<cap>] [reserve=<bandw>,<window>,<host1>,<host2>]
+		topocheck
+		undrain <name>
 
 
 	Because this is drien from within the go http support library, we expect a few globals
@@ -565,6 +1202,21 @@ func parse_post( out http.ResponseWriter, recs []string, sender string ) (state
 			http_sheep.Baa( 3, "processing request: %s %d tokens", tokens[0], ntokens )
 			switch tokens[0] {
 
+				case "audit":													// audit [run] -- report on the last flow-mod/queue audit, or start one
+					if validate_auth( &auth_data, is_token, admin_roles ) {
+						run := ntokens > 1 && tokens[1] == "run"
+						req = ipc.Mk_chmsg( )
+						req.Send_req( rc_ch, my_ch, REQ_RECONCILE, run, nil )
+						req = <- my_ch
+						state = "OK"
+						jreason = req.Response_data.( string )
+						if run {
+							reason = "audit started"
+						} else {
+							reason = ""
+						}
+					}
+
 				case "cancelres":												// cancel reservation
 					err := delete_reservation( tokens )
 					if err != nil {
@@ -583,6 +1235,30 @@ func parse_post( out http.ResponseWriter, recs []string, sender string ) (state
 						reason = "checkpoint was requested"
 					}
 
+				case "drain":													// mark a link, or a switch's links, unavailable for a window
+					if validate_auth( &auth_data, is_token, admin_roles ) {
+						dreason, djson, err := parse_drain( tokens )
+						if err != nil {
+							reason = fmt.Sprintf( "%s", err )
+							nerrors++
+						} else {
+							reason = dreason
+							jreason = djson
+							state = "OK"
+						}
+					}
+
+				case "findslot":												// search for the earliest window(s) where a reservation would fit
+					sreason, sjson, err := parse_findslot( tokens )
+					if err != nil {
+						reason = fmt.Sprintf( "%s", err )
+						nerrors++
+					} else {
+						reason = sreason
+						jreason = sjson
+						state = "OK"
+					}
+
 				case "graph":
 					if validate_auth( &auth_data, is_token, sysproc_roles ) {
 						tmap := gizmos.Mixtoks2map( tokens[1:], "" )			// look for project=pname[,pname] on the request
@@ -602,17 +1278,59 @@ func parse_post( out http.ResponseWriter, recs []string, sender string ) (state
 
 						req = ipc.Mk_chmsg( )
 
-						req.Send_req( nw_ch, my_ch, REQ_NETGRAPH, nil, nil )	// request to net thread; it will create a json blob and attach to the request which it sends back
+						format := GRAPH_JSON
+						if tmap["format"] != nil {
+							format = *tmap["format"]
+						}
+						req.Send_req( nw_ch, my_ch, REQ_NETGRAPH, Mk_graph_req( format, tmap["res"] ), nil )	// request to net thread; it will create a json blob (or dot/graphml) and attach to the request which it sends back
 						req = <- my_ch											// hard wait for network thread response
-						if req.Response_data != nil {
+						if req.State != nil {
+							reason = fmt.Sprintf( "%s", req.State )
+						} else if req.Response_data != nil {
 							state = "OK"
 							jreason = string( req.Response_data.(string) )
+							if format != GRAPH_JSON {
+								jreason = fmt.Sprintf( "%q", jreason )		// dot and graphml are returned as a string
+							}
 							reason = ""
 						} else {
 							reason = "no output from network thread"
 						}
 					}
 
+				case "linkusage":											// allocation over time for a link, and the busiest links
+					if validate_auth( &auth_data, is_token, admin_roles ) {
+						ujson, err := parse_linkusage( tokens )
+						if err != nil {
+							reason = fmt.Sprintf( "%s", err )
+							nerrors++
+						} else {
+							reason = "link usage"
+							jreason = ujson
+							state = "OK"
+						}
+					}
+
+				case "listagents":											// list connected agents, capabilities and in-flight actions
+					if validate_auth( &auth_data, is_token, admin_roles ) {
+						req = ipc.Mk_chmsg( )
+						req.Send_req( am_ch, my_ch, REQ_LISTAGENTS, nil, nil )
+						req = <- my_ch
+						state = "OK"
+						jreason = req.Response_data.( string )
+						reason = ""
+					}
+
+				case "listdrains":											// list link maintenance windows
+					if validate_auth( &auth_data, is_token, admin_roles ) {
+						req = ipc.Mk_chmsg( )
+						req.Send_req( nw_ch, my_ch, REQ_LISTDRAINS, nil, nil )
+						req = <- my_ch
+						state = "OK"
+						jreason = req.Response_data.( string )
+						reason = ""
+					}
+
 				case "listulcaps":											// list user link capacities known to network manager
 					if validate_auth( &auth_data, is_token, admin_roles ) {
 						req = ipc.Mk_chmsg( )
@@ -686,6 +1404,17 @@ func parse_post( out http.ResponseWriter, recs []string, sender string ) (state
 						}
 					}
 
+				case "modres":													// modify bandwidth/expiry of an existing reservation
+					mjson, err := modify_reservation( tokens )
+					if err != nil {
+						reason = fmt.Sprintf( "%s", err )
+						nerrors++
+					} else {
+						jreason = mjson
+						state = "OK"
+						reason = "reservation was modified"
+					}
+
 				case "pause":
 					if validate_auth( &auth_data, is_token, admin_roles ) {
 						if res_paused {							// already in a paused state, just say so and go on
@@ -760,7 +1489,7 @@ func parse_post( out http.ResponseWriter, recs []string, sender string ) (state
 													update_graph( h2, true, true )							// this call will block until netmgr has updated the graph and osif has pushed updates into fqmgr
 
 													sp.Reset_pushed()													// it's not pushed at this point
-													reason, jreason, ecount = finalise_bw_res( sp, res_paused )	// allocate in network and add to res manager inventory
+													reason, jreason, ecount, _ = finalise_bw_res( sp, res_paused, false, 0 )	// allocate in network and add to res manager inventory
 													if ecount == 0 {
 														http_sheep.Baa( 1, "reservation refreshed: %s", *sp.Get_id() )
 													} else {
@@ -831,6 +1560,32 @@ func parse_post( out http.ResponseWriter, recs []string, sender string ) (state
 								}
 							}
 
+							if err == nil && tmap["repeat"] != nil {					// recurring; window is the span of the series and each occurrence lasts duration seconds
+								var sres *gizmos.Pledge_series
+
+								if tmap["duration"] == nil || clike.Atoi64( *tmap["duration"] ) <= 0 {
+									err = fmt.Errorf( "duration=<seconds> must be supplied with repeat" )
+								} else {
+									res_name := mk_resname( )
+									sres, err = gizmos.Mk_series_pledge( &h1, &h2, p1, p2, startt, endt, *tmap["repeat"], clike.Atoi64( *tmap["duration"] ), bandw_in, bandw_out, &res_name, tmap["cookie"], dscp, dscp_koe )
+								}
+
+								if sres != nil {
+									sres.Set_vlan( v1, v2 )
+									if tmap["ipv6"] != nil {
+										sres.Set_matchv6( *tmap["ipv6"] == "true" )
+									}
+
+									reason, jreason, ecount = finalise_series_res( sres, res_paused )
+									if ecount == 0 {
+										state = "OK"
+									} else {
+										nerrors += ecount - 1
+									}
+									break
+								}
+							}
+
 							if err == nil {
 								res_name := mk_resname( )					// name used to track the reservation in the cache and given to queue setting commands for visual debugging
 								res, err = gizmos.Mk_bw_pledge( &h1, &h2, p1, p2, startt, endt, bandw_in, bandw_out, &res_name, tmap["cookie"], dscp, dscp_koe )
@@ -843,7 +1598,24 @@ func parse_post( out http.ResponseWriter, recs []string, sender string ) (state
 								res.Set_matchv6( *tmap["ipv6"] == "true" )
 							}
 							
-							reason, jreason, ecount = finalise_bw_res( res, res_paused )	// check for dup, allocate in network, and add to res manager inventory
+							queue := tmap["queue"] != nil && *tmap["queue"] == "true"		// if rejected, wait for capacity
+							priority := 0
+							if tmap["priority"] != nil {
+								priority = clike.Atoi( *tmap["priority"] )
+							}
+							res.Set_priority( priority )					// may preempt lower priority reservations if capacity is short
+
+							if tmap["protect"] != nil {
+								prot, perr := gizmos.Str2protect( *tmap["protect"] )
+								if perr != nil {
+									nerrors++
+									reason = fmt.Sprintf( "reservation rejected: %s", perr )
+									break
+								}
+								res.Set_protect( prot )						// reserve a disjoint pair of paths
+							}
+
+							reason, jreason, ecount, _ = finalise_bw_res( res, res_paused, queue, priority )	// check for dup, allocate in network, and add to res manager inventory
 							if ecount == 0 {
 								state = "OK"
 							} else {
@@ -910,7 +1682,13 @@ func parse_post( out http.ResponseWriter, recs []string, sender string ) (state
 							res.Set_matchv6( *tmap["ipv6"] == "true" )
 						}
 						
-						reason, jreason, ecount = finalise_bwow_res( res, res_paused )		// check for dup, allocate in network, and add to res manager inventory
+						queue := tmap["queue"] != nil && *tmap["queue"] == "true"		// if rejected, wait for capacity
+						priority := 0
+						if tmap["priority"] != nil {
+							priority = clike.Atoi( *tmap["priority"] )
+						}
+						res.Set_priority( priority )
+						reason, jreason, ecount, _ = finalise_bwow_res( res, res_paused, queue, priority )		// check for dup, allocate in network, and add to res manager inventory
 						if ecount == 0 {
 							state = "OK"
 						} else {
@@ -945,8 +1723,6 @@ func parse_post( out http.ResponseWriter, recs []string, sender string ) (state
 					}
 
 			case "steer":								// parse a steering request and make it happen
-					var res *gizmos.Pledge_steer
-
 					if ntokens < 5  {
 						nerrors++
 						reason = fmt.Sprintf( "incorrect number of parameters supplied: usage: steer [start-]end [token/]tenant ep1 ep2 mblist [cookie]; received: %s", recs[i] )
@@ -955,90 +1731,23 @@ func parse_post( out http.ResponseWriter, recs []string, sender string ) (state
 
 					tmap := gizmos.Mixtoks2map( tokens[1:], "window usrsp ep1 ep2 mblist cookie" )		// map tokens in order to these names	(not as efficient, but makes code easier to read below)
 
-					h1, h2, p1, p2, _, _, err := validate_hosts( *tmap["usrsp"] + "/" + *tmap["ep1"], *tmap["usrsp"] + "/" + *tmap["ep2"] )		// translate project/host[port] into tenantID/host and if token/project/name rquired validates token.
-					if err != nil {
-						reason = fmt.Sprintf( "invalid endpoints:  %s", err )
-						http_sheep.Baa( 1, "steering reservation rejected: %s", reason )
-						nerrors++
-						break
-					}
-
-					h1 = wc2name( h1 )							// resolve E* or L* wild cards
-					h2 = wc2name( h2 )
-
-					if h1 != "" {
-						update_graph( &h1, false, h2 == "" )					// pull all of the VM information from osif then send to netmgr (block if h2 is empty)
-					}
-					if h2 != "" {
-						update_graph( &h2, true, true )							// this call will block until netmgr has updated the graph and osif has pushed updates into fqmgr
-					}
-
-					req := ipc.Mk_chmsg( )
-					req.Send_req( osif_ch, my_ch, REQ_VALIDATE_TOKEN, tmap["usrsp"], nil )		// validate token and convert user space to ID if name given
-					req = <- my_ch
-					if req.Response_data != nil {
-						if  req.Response_data.( *string ) != nil {
-							tmap["usrsp"] = req.Response_data.( *string )
-						} else {
-							nerrors++
-							reason = fmt.Sprintf( "unable to create steering reservation: %s", req.State )
-							break;
-						}
-					}
-
 					if tmap["proto"] != nil { // DEBUG
 						http_sheep.Baa( 1, "steering using  proto: %s", *tmap["proto"] )
 					}
 
 					startt, endt = gizmos.Str2start_end( *tmap["window"] )		// split time token into start/end timestamps
-					res_name := mk_resname( )									// name used to track the reservation in the cache and given to queue setting commands for visual debugging
-
-					res, err = gizmos.Mk_steer_pledge( &h1, &h2, p1, p2, startt, endt, &res_name, tmap["cookie"], tmap["proto"] )
+					res, err := mk_steer_res( startt, endt, *tmap["usrsp"], *tmap["ep1"], *tmap["ep2"], *tmap["mblist"], tmap["cookie"], tmap["proto"] )
 					if err != nil {
-						reason = fmt.Sprintf( "unable to create a steering reservation  %s", err )
+						reason = fmt.Sprintf( "%s", err )
 						nerrors++
 						break
 					}
 
-					mbnames := strings.Split( *tmap["mblist"], "," )
-					for i := range mbnames {									// generate a mbox object for each
-						mbn := ""
-						if strings.Index( mbnames[i], "/" ) < 0 {				// add user space info out front
-							if tmap["usrsp"] != nil {
-								mbn = *tmap["usrsp"] + mbnames[i] 					// validation/translation adds a trailing /, so not needed here
-							}
-						} else {
-							mbn = mbnames[i]
-						}
-
-						update_graph( &mbn, true, true )							// this call will block until netmgr has updated the graph and osif has pushed updates into fqmgr
-						req.Send_req( nw_ch, my_ch, REQ_HOSTINFO, &mbn, nil )		// get host info string (mac, ip, switch)
-						req = <- my_ch
-						if req.State != nil {
-							break
-						} else {
-							htoks := strings.Split( req.Response_data.( string ), "," )					// results are: ip, mac, switch-id, switch-port; all strings
-							res.Add_mbox( gizmos.Mk_mbox( &mbnames[i], &htoks[1], &htoks[2], clike.Atoi( htoks[3] ) ) )
-						}
-					}
-
-					if req.State == nil {											// all middle boxes were validated
-						//ip := gizmos.Pledge( res )									// must pass an interface to resmgr
-						req.Send_req( rmgr_ch, my_ch, REQ_ADD, res, nil )			// push it into the reservation manager which will drive flow-mods etc
-						req = <- my_ch										
-					} else {
-						http_sheep.Baa( 1, "unable to validate all middle boxes" )
-					}
-
-					if req.State == nil {
-						ckptreq := ipc.Mk_chmsg( )								// must have new message since we don't wait on a response
-						ckptreq.Send_req( rmgr_ch, nil, REQ_CHKPT, nil, nil )
+					reason, jreason, ecount = finalise_steer_res( res )
+					if ecount == 0 {
 						state = "OK"
-						reason = fmt.Sprintf( "steering reservation accepted; reservation has %d middleboxes", len( mbnames ) )
-						jreason =  res.To_json()
 					} else {
-						nerrors++
-						reason = fmt.Sprintf( "%s", req.State )
+						nerrors += ecount
 					}
 					http_sheep.Baa( 1, "steering reservation %s; errors: %s", state, reason )
 
@@ -1083,6 +1792,53 @@ func parse_post( out http.ResponseWriter, recs []string, sender string ) (state
 						}
 					}
 
+				case "simulate":												// what-if capacity simulation; the network is not changed
+					if validate_auth( &auth_data, is_token, admin_roles ) {
+						sreason, sjson, err := parse_simulate( tokens )
+						if err != nil {
+							reason = fmt.Sprintf( "%s", err )
+							nerrors++
+						} else {
+							reason = sreason
+							jreason = sjson
+							state = "OK"
+						}
+					}
+
+				case "topocheck":											// validate the static topology file and report the topology in use
+					if validate_auth( &auth_data, is_token, admin_roles ) {
+						req = ipc.Mk_chmsg( )
+						req.Send_req( nw_ch, my_ch, REQ_TOPOCHECK, nil, nil )
+						req = <- my_ch
+						if req.State == nil {
+							state = "OK"
+							jreason = req.Response_data.( string )
+							reason = "topology file state"
+						} else {
+							reason = fmt.Sprintf( "%s", req.State )
+							nerrors++
+						}
+					}
+
+				case "undrain":									// remove a link maintenance window
+					if validate_auth( &auth_data, is_token, admin_roles ) {
+						if ntokens == 2 {
+							req = ipc.Mk_chmsg( )
+							req.Send_req( nw_ch, my_ch, REQ_UNDRAIN, &tokens[1], nil )
+							req = <- my_ch
+							if req.State == nil {
+								state = "OK"
+								reason = req.Response_data.( string )
+							} else {
+								reason = fmt.Sprintf( "%s", req.State )
+								nerrors++
+							}
+						} else {
+							reason = fmt.Sprintf( "incorrect number of parameters received (%d); expected drain-name", ntokens )
+							nerrors++
+						}
+					}
+
 				case "verbose":									// verbose n [child-bleater]
 					if validate_auth( &auth_data, is_token, admin_roles ) {
 						if ntokens > 1 {
@@ -1113,6 +1869,9 @@ func parse_post( out http.ResponseWriter, recs []string, sender string ) (state
 									case "agent":
 										am_sheep.Set_level( nv )
 
+									case "reconciler", "audit":
+										rc_sheep.Set_level( nv )
+
 									case "tegu", "master":
 										tegu_sheep.Set_level( nv )
 
@@ -1128,7 +1887,7 @@ func parse_post( out http.ResponseWriter, recs []string, sender string ) (state
 									default:
 										state = "ERROR"
 										http_sheep.Baa( 1, "unrecognised subsystem name given with verbose level: %s", tokens[2] )
-										jreason = fmt.Sprintf( `"unrecognsed subsystem name given; must be one of: agent, osif, resmgr, http, fqmgr, or net"` )
+										jreason = fmt.Sprintf( `"unrecognsed subsystem name given; must be one of: agent, osif, resmgr, http, fqmgr, reconciler, or net"` )
 								}
 
 								if state == "OK" {
@@ -1182,9 +1941,172 @@ func parse_post( out http.ResponseWriter, recs []string, sender string ) (state
 	return
 }
 
-func parse_put( out http.ResponseWriter, recs []string, sender string ) (state string, msg string) {
+/*
+	Modify an existing reservation. Currently the only thing that can be modified is a
+	reservation, so we require a token 0 which indicates what, to allow for other things
+	in future. Like delete, we wrap the call to the function that does the work so that
+	the POST parser can support it (modres) too.
+
+	Supported put actions:
+		reservation [bandw=<in>[,<out>]] [expiry=<end>|+<sec>|unbounded] <name> [<cookie>]
+
+	PUT was once processed exactly as POST, so if any record is not a put action the whole
+	batch is given to the POST parser (with reservation changed to modres) so that results
+	are reported in a single list and legacy clients which PUT reserve, listres, etc. still work.
+*/
+func parse_put( out http.ResponseWriter, recs []string, sender string ) ( state string, msg string ) {
+	for i := 0; i < len( recs ); i++ {
+		ntokens, tokens := token.Tokenise_qpopulated( recs[i], " " )
+		if ntokens < 1 || len( tokens[0] ) < 2 || tokens[0][0:1] == "#" {
+			continue
+		}
+
+		if tokens[0] != "reservation" && tokens[0] != "modres" {
+			http_sheep.Baa( 2, "parse_put: %s is not a put action; batch passed to post parser", tokens[0] )
+			precs := make( []string, len( recs ) )
+			for j := range recs {
+				precs[j] = recs[j]
+				if ptoks := strings.SplitN( strings.TrimLeft( recs[j], " \t" ), " ", 2 ); ptoks[0] == "reservation" {
+					precs[j] = "modres"
+					if len( ptoks ) > 1 {
+						precs[j] += " " + ptoks[1]
+					}
+				}
+			}
+
+			return parse_post( out, precs, sender )
+		}
+	}
+
+	var (
+		sep			string = ""							// json output list separator
+		req_count	int = 0								// requests processed this batch
+		tokens		[]string							// parsed tokens from the http data
+		ntokens		int
+		nerrors		int = 0								// overall error count -- final status is error if non-zero
+		jdetails	string = ""							// result details in json
+		comment		string = ""							// comment about the state
+	)
+
+	fmt.Fprintf( out,  "\"reqstate\":[ " )				// wrap request output into an array
+	state = "OK"
+	for i := 0; i < len( recs ); i++ {
+		http_sheep.Baa( 3, "put received buffer (%s)", recs[i] )
+
+		ntokens, tokens = token.Tokenise_qpopulated( recs[i], " " )		// split and keep populated tokens (treats successive sep chrs as one), preserves spaces in "s
+
+		if ntokens < 1 || len( tokens[0] ) < 2 || tokens[0][0:1] == "#" {		// prevent issues if empty line, skip comment.
+			continue
+		}
+
+		req_count++
+		state = "ERROR"
+		jdetails = ""
+
+		http_sheep.Baa( 2, "parse_put for %s", tokens[0] )
+		switch tokens[0] {
+			case "reservation", "modres":							// expect:  reservation [key=value...] name(id) [cookie]
+				mjson, err := modify_reservation( tokens )
+				if err == nil {
+					comment = "reservation successfully modified"
+					jdetails = mjson
+					state = "OK"
+				} else {
+					nerrors++
+					comment = fmt.Sprintf( "reservation modify failed: %s", err )
+				}
+
+			default:
+				nerrors++
+				comment = fmt.Sprintf( "unknown put command: %s", tokens[0] )
+		}
+
+		if jdetails != "" {
+			fmt.Fprintf( out, "%s{ \"status\": \"%s\", \"request\": \"%d\", \"comment\": \"%s\", \"details\": %s }", sep, state, req_count, comment, jdetails )
+		} else {
+			fmt.Fprintf( out, "%s{ \"status\": \"%s\", \"request\": \"%d\", \"comment\": \"%s\" }", sep, state, req_count, comment )
+		}
+
+		sep = ","
+	}
+
+	fmt.Fprintf( out,  "]," )				// close the request output array (caller sends the final object)
+
+	if nerrors > 0 {
+		state = "ERROR"		// must set on the off chance that last request was ok
+	}
+
+	if req_count <= 0 {
+		msg = fmt.Sprintf( "no requests found in input" )
+		state = "ERROR"
+	} else {
+		msg = fmt.Sprintf( "%d errors processing requests in %d requests", nerrors, req_count )
+	}
+
+	return
+}
+
+/*
+	Modify the bandwidth and/or expiry time of a reservation based on the tokens passed in.
+	Called from either the put parser or the post parser (modres).  Token[0] is the request
+	name and is ignored. The reservation keeps its name, cookie and queues; the network
+	manager checks capacity for the new values and the reservation is left unchanged if
+	there is not enough.
+
+	On success the json representation of the modified reservation is returned.
+*/
+func modify_reservation( tokens []string ) ( jstr string, err error ) {
+	var (
+		bandw_in	int64 = 0
+		bandw_out	int64 = 0
+		expiry		int64 = 0
+	)
+
+	key_list := "name cookie"
+	tmap := gizmos.Mixtoks2map( tokens[1:], key_list )		// bandw= and expiry= must precede the name
+	if tmap["name"] == nil {
+		err = fmt.Errorf( "missing parameters; usage: modres [bandw=<bandwidth[K|M|G][,outbandw[K|M|G]>] [expiry={<end-time>|+sec|unbounded}] <name> [cookie]" )
+		return
+	}
+
+	if tmap["bandw"] != nil {
+		if strings.Index( *tmap["bandw"], "," ) >= 0 {				// look for inputbandwidth,outputbandwidth
+			subtokens := strings.Split( *tmap["bandw"], "," )
+			bandw_in = int64( clike.Atof( subtokens[0] ) )
+			bandw_out = int64( clike.Atof( subtokens[1] ) )
+		} else {
+			bandw_in = int64( clike.Atof( *tmap["bandw"] ) )		// no comma, so single value applied to each
+			bandw_out = bandw_in
+		}
+
+		if bandw_in <= 0 || bandw_out <= 0 {
+			err = fmt.Errorf( "bandwidth value(s) must be greater than zero: %s", *tmap["bandw"] )
+			return
+		}
+	}
+
+	if tmap["expiry"] != nil {
+		_, expiry = gizmos.Str2start_end( *tmap["expiry"] )		// allows +sec, timestamp or unbounded
+	}
+
+	my_ch := make( chan *ipc.Chmsg )
+	defer close( my_ch )
+
+	req := ipc.Mk_chmsg( )
+	req.Send_req( rmgr_ch, my_ch, REQ_MODRES, Mk_bw_mod( tmap["name"], tmap["cookie"], expiry, bandw_in, bandw_out ), nil )
+	req = <- my_ch
+	if req.State != nil {
+		err = req.State
+		return
+	}
+
+	if req.Response_data != nil {
+		jstr = req.Response_data.( string )
+	}
+
+	ckptreq := ipc.Mk_chmsg( )									// request checkpoint but no need to wait on it
+	ckptreq.Send_req( rmgr_ch, nil, REQ_CHKPT, nil, nil )
 
-	state, msg = parse_post( out, recs, sender )
 	return
 }
 
@@ -1324,7 +2246,7 @@ func parse_get( out http.ResponseWriter, recs []string, sender string ) (state s
 
 /*
 	Deal with input from the other side sent to tegu/api. See http_mirror_api.go for
-	the mirror api handler and related functions.
+	the mirror api handler and http_v2_api.go for the json interface.
 	this is invoked directly by the http listener.
 	Because we are driven as a callback, and cannot controll the parameters passed in, we
 	must (sadly) rely on globals for some information; sigh. (There might be a way to deal
@@ -1492,6 +2414,7 @@ func Http_api( api_port *string, nwch chan *ipc.Chmsg, rmch chan *ipc.Chmsg ) {
 
 	http.HandleFunc( "/tegu/api", api_deal_with )					// reserve/delete etc should eventually be removed from this
 	http.HandleFunc( "/tegu/bandwidth", api_deal_with )				// define bandwidth callback TODO: add a callback specifically for bandwidth things
+	http.HandleFunc( "/tegu/v2/", v2_handler )						// json interface (see http_v2_api.go)
 
 	if enable_mirroring {
 		http.HandleFunc( "/tegu/mirrors/", mirror_handler )
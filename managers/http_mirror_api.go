@@ -40,6 +40,7 @@
 				05 Jun 2015 - added token auth to mirroring
 				22 Jun 2015 - write error messages in JSON, to play nice with tegu_req
 				29 Jun 2015 - Fixed fallout from config section name change.
+				17 Oct 2026 - An unbounded end time now means no expiry rather than 1/1/2025.
 */
 
 package managers
@@ -78,7 +79,7 @@ func generateMirrorName( ) ( string ) {
  *	Convert "s" to startt, and "e" to endt.
  *	If s is "", set startt to "now"
  *	If e is "+nnn", set endt to start + nnn.
- *	If e is "+unbounded", set endt to 1/1/2025. (this date is enforced elsewhere in the code)
+ *	If e is "unbounded" or "+unbounded", set endt to gizmos.TS_INFINITE (the mirror never expires).
  */
 func checkTimes(s string, e string) (startt int64, endt int64, err error) {
 	err = nil
@@ -89,11 +90,11 @@ func checkTimes(s string, e string) (startt int64, endt int64, err error) {
 	} else {
 		startt, err = strconv.ParseInt(s, 0, 64)
 	}
-	if e[0:1] == "+" {
+	if e == "unbounded" || e == "+unbounded" {
+		endt = gizmos.TS_INFINITE
+	} else if e[0:1] == "+" {
 		endt, err = strconv.ParseInt(e[1:], 0, 64)
 		endt += startt
-	} else if e == "unbounded" {
-		endt = gizmos.DEF_END_TS		// 1/1/2025
 	} else {
 		endt, err = strconv.ParseInt(e, 0, 64)
 	}
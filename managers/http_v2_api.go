@@ -0,0 +1,616 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+	Mnemonic:	http_v2_api
+	Abstract:	This provides a versioned, JSON, ReST-ish interface (all URLs underneath /tegu/v2/)
+				alongside the line oriented interface at /tegu/api.  Requests which need input
+				expect a JSON object in the body; responses are JSON and the HTTP status code
+				reflects the outcome of the request. Errors are returned as { "error": "reason" }.
+				As with mirroring, a token (when needed) is passed in the X-Auth-Tegu header.
+
+				These requests are supported:
+					POST   /tegu/v2/reservations
+					GET    /tegu/v2/reservations
+					GET    /tegu/v2/reservations/<name>[?cookie=cookie]
+					PUT    /tegu/v2/reservations/<name>
+					DELETE /tegu/v2/reservations/<name>[?cookie=cookie]
+					POST   /tegu/v2/slots
+					POST   /tegu/v2/steering
+					GET    /tegu/v2/steering/<name>[?cookie=cookie]
+					DELETE /tegu/v2/steering/<name>[?cookie=cookie]
+					GET    /tegu/v2/hosts[?project=pname]
+
+				The work is done by the same functions that the line oriented interface uses
+				(validate_hosts, finalise_bw_res, etc.), so the two interfaces behave the same.
+
+	Date:		17 October 2026
+
+	Mods:		17 Oct 2026 - Status codes come from the type of the error (Not_found_error, Auth_error)
+					or the Queued_state returned, not from the message text. Bandwidth and dscp
+					conversion moved to http_api.go (shared with findslot).
+*/
+
+package managers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/att/gopkgs/clike"
+	"github.com/att/gopkgs/ipc"
+
+	"github.com/att/tegu/gizmos"
+)
+
+/*
+	Body of a POST /tegu/v2/reservations request.  Bandwidth values are strings
+	which may have a K, M or G suffix (e.g. "10M"); if only one is supplied it is used
+	for both directions. Times are the same as those accepted on a reserve request:
+	a timestamp, +seconds, or unbounded for the end time.
+*/
+type v2_res_req struct {
+	Bandwidth_in	string	`json:"bandwidth_in"`
+	Bandwidth_out	string	`json:"bandwidth_out"`
+	Start_time		string	`json:"start_time"`		// optional, now if omitted
+	End_time		string	`json:"end_time"`			// required
+	Host1			string	`json:"host1"`				// required; [token/][project/]host[:port][{vlan}]
+	Host2			string	`json:"host2"`				// optional; any if omitted
+	Cookie			string	`json:"cookie"`
+	Dscp			string	`json:"dscp"`				// traffic class (voice, data, control, global_*)
+	Ipv6			bool	`json:"ipv6"`
+	Oneway			bool	`json:"oneway"`				// one way (outbound) reservation when true
+	Repeat			string	`json:"repeat"`				// recurrence rule (see gizmos/schedule.go); start/end bound the series
+	Duration		int64	`json:"duration"`			// seconds each occurrence lasts; required with repeat
+	Queue			bool	`json:"queue"`				// wait for capacity rather than being rejected
+	Priority		int		`json:"priority"`			// larger values are admitted first from the waitlist and may preempt
+	Protect			string	`json:"protect"`			// none, link or switch; reserve a disjoint pair of paths
+}
+
+/*
+	Body of a PUT /tegu/v2/reservations/<name> request. Omitted values are left unchanged.
+*/
+type v2_mod_req struct {
+	Bandwidth_in	string	`json:"bandwidth_in"`
+	Bandwidth_out	string	`json:"bandwidth_out"`
+	End_time		string	`json:"end_time"`
+	Cookie			string	`json:"cookie"`
+}
+
+/*
+	Body of a POST /tegu/v2/slots request.
+*/
+type v2_slot_req struct {
+	Bandwidth_in	string	`json:"bandwidth_in"`
+	Bandwidth_out	string	`json:"bandwidth_out"`
+	Duration		int64	`json:"duration"`			// required; seconds
+	Start_time		string	`json:"start_time"`		// optional, now if omitted
+	Horizon			int64	`json:"horizon"`			// optional; seconds after start (1 day)
+	Count			int		`json:"count"`				// optional; max windows to return (3)
+	Book			bool	`json:"book"`				// reserve the first window that can be booked
+	Host1			string	`json:"host1"`				// required
+	Host2			string	`json:"host2"`				// optional; any if omitted
+	Cookie			string	`json:"cookie"`
+	Dscp			string	`json:"dscp"`
+	Ipv6			bool	`json:"ipv6"`
+}
+
+/*
+	Body of a POST /tegu/v2/steering request.
+*/
+type v2_steer_req struct {
+	Start_time		string		`json:"start_time"`		// optional, now if omitted
+	End_time		string		`json:"end_time"`		// required
+	Project			string		`json:"project"`		// required; [token/]project
+	Endpoint1		string		`json:"endpoint1"`		// required
+	Endpoint2		string		`json:"endpoint2"`		// required
+	Middleboxes		[]string	`json:"middleboxes"`	// required
+	Cookie			string		`json:"cookie"`
+	Protocol		string		`json:"protocol"`
+}
+
+// -----------------------------------------------------------------------------------------------------------
+
+/*
+	Split the URL path into the collection (reservations, steering, hosts) and the
+	name of the thing in the collection (empty if not given).
+*/
+func v2_path( in *http.Request ) ( collection string, name string ) {
+	t := strings.Trim( strings.TrimPrefix( in.URL.Path, "/tegu/v2" ), "/" )
+	tt := strings.SplitN( t, "/", 2 )
+	collection = tt[0]
+	if len( tt ) > 1 {
+		name = tt[1]
+	}
+
+	return
+}
+
+/*
+	Build the URL for a named thing in a collection.
+*/
+func v2_url( in *http.Request, collection string, name string ) ( string ) {
+	scheme := "http"
+	if isSSL {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf( "%s://%s/tegu/v2/%s/%s", scheme, in.Host, collection, name )
+}
+
+/*
+	Convert start and end time strings into timestamps. End may be a timestamp, +seconds
+	(relative to the start) or unbounded.
+*/
+func v2_window( start string, end string ) ( startt int64, endt int64, err error ) {
+	if end == "" {
+		err = fmt.Errorf( "end_time is required" )
+		return
+	}
+
+	if start == "" {
+		startt, endt = gizmos.Str2start_end( end )
+	} else {
+		if end[0:1] == "+" && end != "+unbounded" {
+			end = fmt.Sprintf( "%d", clike.Atoll( start ) + clike.Atoll( end[1:] ) )
+		}
+		startt, endt = gizmos.Str2start_end( start + "-" + strings.TrimPrefix( end, "+" ) )
+	}
+
+	if endt <= startt {
+		err = fmt.Errorf( "end_time must be after start_time and in the future" )
+	}
+
+	return
+}
+
+/*
+	Map an error returned by the reservation manager or osif to an http status: not found and
+	not authorised have their own codes, anything else gets the default code.
+*/
+func v2_state2code( state error, dflt int ) ( int ) {
+	switch state.( type ) {
+		case *Not_found_error:
+			return http.StatusNotFound
+
+		case *Auth_error:
+			return http.StatusUnauthorized
+	}
+
+	return dflt
+}
+
+// ---- reservations -----------------------------------------------------------------------------------------
+
+/*
+	Create a bandwidth (or one way bandwidth) reservation.  Returns 201 and the reservation
+	on success, 400 if the request is bad, and 409 if the reservation cannot be made
+	(duplicate or insufficient capacity).
+*/
+func v2_res_post( in *http.Request, data []byte ) ( code int, msg string ) {
+	var req v2_res_req
+
+	if err := json.Unmarshal( data, &req ); err != nil {
+		return http.StatusBadRequest, "bad JSON: " + err.Error()
+	}
+	if req.Host1 == "" || (req.Bandwidth_in == "" && req.Bandwidth_out == "") {
+		return http.StatusBadRequest, "missing a required field: host1, end_time and a bandwidth are required"
+	}
+	if req.Host2 == "" {
+		req.Host2 = "any"
+	}
+
+	startt, endt, err := v2_window( req.Start_time, req.End_time )
+	if err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+
+	bandw_in, bandw_out := bandw_pair( req.Bandwidth_in, req.Bandwidth_out )
+	if bandw_in <= 0 || bandw_out <= 0 {
+		return http.StatusBadRequest, "bandwidth value(s) must be greater than zero"
+	}
+
+	dscp, dscp_koe, err := tclass2dscp_koe( req.Dscp )
+	if err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+
+	h1, h2, p1, p2, v1, v2, err := validate_hosts( req.Host1, req.Host2 )		// translate project/host[:port][{vlan}] into pieces parts and validates token/project
+	if err != nil {
+		return v2_state2code( err, http.StatusBadRequest ), err.Error()
+	}
+
+	update_graph( &h1, false, false )						// pull all of the VM information from osif then send to netmgr
+	update_graph( &h2, true, true )							// this call will block until netmgr has updated the graph and osif has pushed updates into fqmgr
+
+	var (
+		reason	string
+		jreason	string
+		ecount	int
+		queued	*Queued_state					// set if the reservation was put on the waitlist
+	)
+	res_name := mk_resname( )
+	if req.Repeat != "" {
+		if req.Oneway {
+			return http.StatusBadRequest, "oneway reservations cannot be recurring"
+		}
+
+		res, err := gizmos.Mk_series_pledge( &h1, &h2, p1, p2, startt, endt, req.Repeat, req.Duration, bandw_in, bandw_out, &res_name, &req.Cookie, dscp, dscp_koe )
+		if res == nil {
+			return http.StatusBadRequest, fmt.Sprintf( "reservation rejected: %s", err )
+		}
+
+		res.Set_vlan( v1, v2 )
+		res.Set_matchv6( req.Ipv6 )
+		reason, jreason, ecount = finalise_series_res( res, res_paused )
+	} else if req.Oneway {
+		res, err := gizmos.Mk_bwow_pledge( &h1, &h2, p1, p2, startt, endt, bandw_out, &res_name, &req.Cookie, dscp )
+		if res == nil {
+			return http.StatusBadRequest, fmt.Sprintf( "reservation rejected: %s", err )
+		}
+
+		res.Set_vlan( v1 )
+		res.Set_matchv6( req.Ipv6 )
+		res.Set_priority( req.Priority )
+		reason, jreason, ecount, queued = finalise_bwow_res( res, res_paused, req.Queue, req.Priority )
+	} else {
+		res, err := gizmos.Mk_bw_pledge( &h1, &h2, p1, p2, startt, endt, bandw_in, bandw_out, &res_name, &req.Cookie, dscp, dscp_koe )
+		if res == nil {
+			return http.StatusBadRequest, fmt.Sprintf( "reservation rejected: %s", err )
+		}
+
+		res.Set_vlan( v1, v2 )
+		res.Set_matchv6( req.Ipv6 )
+		res.Set_priority( req.Priority )
+		if req.Protect != "" {
+			prot, perr := gizmos.Str2protect( req.Protect )
+			if perr != nil {
+				return http.StatusBadRequest, fmt.Sprintf( "reservation rejected: %s", perr )
+			}
+			res.Set_protect( prot )
+		}
+		reason, jreason, ecount, queued = finalise_bw_res( res, res_paused, req.Queue, req.Priority )	// check for dup, allocate in network, and add to res manager inventory
+	}
+
+	if ecount > 0 {
+		return http.StatusConflict, reason
+	}
+
+	if queued != nil {													// on the waitlist, not yet created
+		http_sheep.Baa( 1, "v2 reservation queued: %s: %s", res_name, reason )
+		return http.StatusAccepted, jreason
+	}
+
+	http_sheep.Baa( 1, "v2 reservation created: %s: %s", res_name, reason )
+	return http.StatusCreated, jreason
+}
+
+/*
+	List all reservations, or a single reservation when the name is given.
+*/
+func v2_res_get( name string, cookie string ) ( code int, msg string ) {
+	my_ch := make( chan *ipc.Chmsg )
+	defer close( my_ch )
+
+	req := ipc.Mk_chmsg( )
+	if name == "" {
+		req.Send_req( rmgr_ch, my_ch, REQ_LIST, nil, nil )
+		req = <- my_ch
+		if req.State != nil {
+			return http.StatusInternalServerError, req.State.Error()
+		}
+
+		return http.StatusOK, req.Response_data.( string )
+	}
+
+	req.Send_req( rmgr_ch, my_ch, REQ_GET, []*string { &name, &cookie }, nil )
+	req = <- my_ch
+	if req.State != nil {
+		return v2_state2code( req.State, http.StatusNotFound ), req.State.Error()
+	}
+
+	p := req.Response_data.( *gizmos.Pledge )
+	return http.StatusOK, (*p).To_json()
+}
+
+/*
+	Modify the bandwidth and/or end time of a reservation. Returns the modified reservation.
+*/
+func v2_res_put( name string, data []byte ) ( code int, msg string ) {
+	var (
+		req			v2_mod_req
+		bandw_in	int64 = 0
+		bandw_out	int64 = 0
+		expiry		int64 = 0
+	)
+
+	if name == "" {
+		return http.StatusMethodNotAllowed, "a reservation name must be given on a PUT"
+	}
+	if err := json.Unmarshal( data, &req ); err != nil {
+		return http.StatusBadRequest, "bad JSON: " + err.Error()
+	}
+
+	if req.Bandwidth_in != "" || req.Bandwidth_out != "" {
+		bandw_in, bandw_out = bandw_pair( req.Bandwidth_in, req.Bandwidth_out )
+		if bandw_in <= 0 || bandw_out <= 0 {
+			return http.StatusBadRequest, "bandwidth value(s) must be greater than zero"
+		}
+	}
+
+	if req.End_time != "" {
+		_, expiry = gizmos.Str2start_end( req.End_time )			// allows +sec, timestamp or unbounded
+	}
+
+	my_ch := make( chan *ipc.Chmsg )
+	defer close( my_ch )
+
+	mreq := ipc.Mk_chmsg( )
+	mreq.Send_req( rmgr_ch, my_ch, REQ_MODRES, Mk_bw_mod( &name, &req.Cookie, expiry, bandw_in, bandw_out ), nil )
+	mreq = <- my_ch
+	if mreq.State != nil {
+		return v2_state2code( mreq.State, http.StatusConflict ), mreq.State.Error()
+	}
+
+	ckptreq := ipc.Mk_chmsg( )
+	ckptreq.Send_req( rmgr_ch, nil, REQ_CHKPT, nil, nil )
+
+	return http.StatusOK, mreq.Response_data.( string )
+}
+
+/*
+	Delete a reservation (bandwidth or steering).
+*/
+func v2_res_delete( name string, cookie string ) ( code int, msg string ) {
+	if name == "" {
+		return http.StatusMethodNotAllowed, "a reservation name must be given on a DELETE"
+	}
+
+	tokens := []string { "reservation", name }
+	if cookie != "" {
+		tokens = append( tokens, cookie )
+	}
+
+	if err := delete_reservation( tokens ); err != nil {
+		return v2_state2code( err, http.StatusNotFound ), err.Error()
+	}
+
+	return http.StatusNoContent, ""
+}
+
+// ---- steering ---------------------------------------------------------------------------------------------
+
+/*
+	Create a steering reservation. Returns 201 and the reservation on success.
+*/
+func v2_steer_post( data []byte ) ( code int, msg string ) {
+	var req v2_steer_req
+
+	if err := json.Unmarshal( data, &req ); err != nil {
+		return http.StatusBadRequest, "bad JSON: " + err.Error()
+	}
+	if req.Project == "" || req.Endpoint1 == "" || req.Endpoint2 == "" || len( req.Middleboxes ) == 0 {
+		return http.StatusBadRequest, "missing a required field: project, endpoint1, endpoint2, middleboxes and end_time are required"
+	}
+
+	startt, endt, err := v2_window( req.Start_time, req.End_time )
+	if err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+
+	var proto *string
+	if req.Protocol != "" {
+		proto = &req.Protocol
+	}
+
+	res, err := mk_steer_res( startt, endt, req.Project, req.Endpoint1, req.Endpoint2, strings.Join( req.Middleboxes, "," ), &req.Cookie, proto )
+	if err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+
+	reason, jreason, ecount := finalise_steer_res( res )
+	if ecount > 0 {
+		return http.StatusConflict, reason
+	}
+
+	return http.StatusCreated, jreason
+}
+
+// ---- slots ------------------------------------------------------------------------------------------------
+
+/*
+	Search for the earliest windows where a bandwidth reservation would fit, and optionally
+	book the first. Returns 200 and the list of windows (which may be empty); if a window
+	was booked the reservation is included. 409 is returned if windows were found but none
+	could be booked.
+*/
+func v2_slot_post( data []byte ) ( code int, msg string ) {
+	var req v2_slot_req
+
+	if err := json.Unmarshal( data, &req ); err != nil {
+		return http.StatusBadRequest, "bad JSON: " + err.Error()
+	}
+	if req.Host1 == "" || req.Duration <= 0 || (req.Bandwidth_in == "" && req.Bandwidth_out == "") {
+		return http.StatusBadRequest, "missing a required field: host1, duration and a bandwidth are required"
+	}
+	if req.Host2 == "" {
+		req.Host2 = "any"
+	}
+	if req.Horizon <= 0 {
+		req.Horizon = 86400
+	}
+	if req.Count <= 0 {
+		req.Count = 3
+	}
+
+	startt := time.Now().Unix()
+	if s := clike.Atoi64( req.Start_time ); s > startt {
+		startt = s
+	}
+
+	bandw_in, bandw_out := bandw_pair( req.Bandwidth_in, req.Bandwidth_out )
+	slots, booked, err := find_slot( req.Host1, req.Host2, startt, startt + req.Horizon, req.Duration, bandw_in, bandw_out, req.Count, req.Book, &req.Cookie, req.Dscp, req.Ipv6 )
+	if err != nil {
+		if len( slots ) > 0 {
+			return http.StatusConflict, err.Error()
+		}
+		return http.StatusBadRequest, err.Error()
+	}
+
+	return http.StatusOK, slots2json( slots, req.Duration, booked )
+}
+
+// ---- hosts ------------------------------------------------------------------------------------------------
+
+/*
+	List the hosts known to the network manager. If a project is given, its VMs are
+	forced into the network graph first (as is done for listhosts).
+*/
+func v2_hosts_get( in *http.Request ) ( code int, msg string ) {
+	auth_data := in.RemoteAddr
+	is_token := false
+	if in.Header != nil && in.Header["X-Auth-Tegu"] != nil {
+		auth_data = in.Header["X-Auth-Tegu"][0]
+		is_token = true
+	}
+	if ! validate_auth( &auth_data, is_token, sysproc_roles ) {
+		return http.StatusUnauthorized, "you are not authorised to list hosts"
+	}
+
+	my_ch := make( chan *ipc.Chmsg )
+	defer close( my_ch )
+
+	if project := in.URL.Query().Get( "project" ); project != "" {
+		http_sheep.Baa( 1, "v2 hosts is forcing update of all VMs for the project: %s", project )
+		req := ipc.Mk_chmsg( )
+		req.Send_req( osif_ch, my_ch, REQ_GET_PROJ_HOSTS, &project, nil )
+		req = <- my_ch
+		if req.Response_data == nil {
+			return http.StatusBadRequest, fmt.Sprintf( "unable to load project data: %s", req.State )
+		}
+
+		req.Send_req( nw_ch, my_ch, REQ_ADD, req.Response_data, nil )	// must block until done so the list reflects the update
+		req = <- my_ch
+	}
+
+	req := ipc.Mk_chmsg( )
+	req.Send_req( nw_ch, my_ch, REQ_LISTHOSTS, nil, nil )
+	req = <- my_ch
+	if req.State != nil {
+		return http.StatusInternalServerError, req.State.Error()
+	}
+
+	return http.StatusOK, req.Response_data.( string )
+}
+
+// -----------------------------------------------------------------------------------------------------------
+
+/*
+	All requests to the /tegu/v2/ URL subtree are funneled here for handling.
+*/
+func v2_handler( out http.ResponseWriter, in *http.Request ) {
+	code := http.StatusOK
+	msg := ""
+	location := ""
+
+	collection, name := v2_path( in )
+	cookie := in.URL.Query().Get( "cookie" )
+
+	if ! accept_requests {
+		code = http.StatusServiceUnavailable
+		msg = "tegu is running, but is not accepting requests; try again later"
+	} else {
+		data := dig_data( in )
+		if data == nil {
+			data = []byte{}
+		}
+
+		http_sheep.Baa( 1, "v2 request from %s: %s %s", in.RemoteAddr, in.Method, in.RequestURI )
+		switch collection + " " + in.Method {
+			case "reservations POST":
+				code, msg = v2_res_post( in, data )
+
+			case "reservations GET", "steering GET":
+				code, msg = v2_res_get( name, cookie )
+
+			case "reservations PUT":
+				code, msg = v2_res_put( name, data )
+
+			case "reservations DELETE", "steering DELETE":
+				code, msg = v2_res_delete( name, cookie )
+
+			case "slots POST":
+				code, msg = v2_slot_post( data )
+
+			case "steering POST":
+				code, msg = v2_steer_post( data )
+
+			case "hosts GET":
+				code, msg = v2_hosts_get( in )
+
+			default:
+				switch collection {
+					case "reservations", "slots", "steering", "hosts":
+						code = http.StatusMethodNotAllowed
+						msg = fmt.Sprintf( "%s is not supported for %s", in.Method, collection )
+
+					default:
+						code = http.StatusNotFound
+						msg = fmt.Sprintf( "unknown resource: %s", in.URL.Path )
+				}
+		}
+
+		if code == http.StatusCreated {
+			if id := v2_json_id( msg ); id != "" {
+				location = v2_url( in, collection, id )
+			}
+		}
+	}
+
+	hdr := out.Header()
+	hdr.Add( "Content-type", "application/json" )
+	if location != "" {
+		hdr.Add( "Location", location )
+	}
+	if code >= http.StatusBadRequest {
+		http_sheep.Baa( 2, "v2 response: %d %s", code, msg )
+		msg = fmt.Sprintf( `{ "error": %q }`, msg )
+	}
+	out.WriteHeader( code )
+	out.Write( []byte( msg ) )
+}
+
+/*
+	Dig the reservation id from the json generated by a pledge.
+*/
+func v2_json_id( jstr string ) ( string ) {
+	var id struct {
+		Id	string	`json:"id"`
+	}
+
+	if json.Unmarshal( []byte( jstr ), &id ) != nil {
+		return ""
+	}
+
+	return id.Id
+}
@@ -26,7 +26,7 @@
 	Date:		08 June 2015
 	Author:		E. Scott Daniels
 
-	Mods:
+	Mods:		17 Oct 2026 - Added mk_sdnc() to create the sdn controller interface from the config.
 */
 
 package managers
@@ -39,6 +39,7 @@ import (
 
 	"github.com/att/gopkgs/bleater"
 	"github.com/att/gopkgs/ipc"
+	"github.com/att/tegu/gizmos"
 )
 
 
@@ -53,6 +54,43 @@ func req_hosts(  rch chan *ipc.Chmsg, sheep *bleater.Bleater ) {
 	req.Send_req( osif_ch, rch, REQ_CHOSTLIST, nil, nil )
 }
 
+/*
+	Create the interface to the sdn controller running on host (host:port). The type of the
+	controller (floodlight or rest) and the credentials used by the rest controller come from
+	the default section of the config file (sdn_type, sdn_user, sdn_passwd). Nil is returned
+	if host is empty or is not host:port (tegu-lite with a static topology file), or if the
+	type is not known.
+*/
+func mk_sdnc( host *string, sheep *bleater.Bleater ) ( gizmos.Sdn_ctlr ) {
+	if host == nil || strings.Index( *host, ":" ) < 0 {
+		return nil
+	}
+
+	kind := ""
+	user := ""
+	pw := ""
+	if cfg_data["default"] != nil {
+		if p := cfg_data["default"]["sdn_type"]; p != nil {
+			kind = *p
+		}
+		if p := cfg_data["default"]["sdn_user"]; p != nil {
+			user = *p
+		}
+		if p := cfg_data["default"]["sdn_passwd"]; p != nil {
+			pw = *p
+		}
+	}
+
+	sdnc, err := gizmos.Mk_sdn_ctlr( kind, *host, user, pw )
+	if err != nil {
+		sheep.Baa( 0, "ERR: unable to create sdn controller interface for %s: %s", *host, err )
+		return nil
+	}
+
+	sheep.Baa( 1, "sdn controller: %s", sdnc )
+	return sdnc
+}
+
 /*
 	Given a VM name of the form project/stuff, or just stuff, return stuff.
 */
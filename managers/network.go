@@ -82,6 +82,22 @@
 				18 Jun 2015 - Added oneway rate limiting and delete support.
  				02 Jul 2015 - Extended the physical host refresh rate.
 				03 Sep 2015 - Correct nil pointer core dump cause.
+				17 Oct 2026 - Added support for modifying a bandwidth reservation in place (REQ_MODRES).
+					Discount calculation moved to discount_bw() so that it can be shared.
+				17 Oct 2026 - Added REQ_FINDSLOT to search for the earliest window with capacity.
+				17 Oct 2026 - Res_mgr is asked to retry queued reservations when a rebuild grows the graph.
+				17 Oct 2026 - Track admitted pledges so that a reservation with a priority can preempt.
+				17 Oct 2026 - Pass reservation protection to build_paths; check protected reservations on rebuild.
+				17 Oct 2026 - Link capacity follows the controller; reservations are rerouted when links leave/shrink.
+				17 Oct 2026 - Added REQ_DRAIN, REQ_UNDRAIN and REQ_LISTDRAINS for link maintenance windows.
+				17 Oct 2026 - Added REQ_SIMULATE for what-if capacity simulation.
+				17 Oct 2026 - Added REQ_LINKUSAGE for link utilisation timelines.
+				17 Oct 2026 - REQ_NETGRAPH accepts a Graph_req to render the graph as DOT or GraphML.
+				17 Oct 2026 - The static topology file is watched and validated (REQ_TOPOCHECK); the last
+					good topology is kept when the file is not valid.
+				17 Oct 2026 - Links and hosts are fetched through the Sdn_ctlr interface (floodlight or rest).
+				17 Oct 2026 - Pass mlag_paths to preemption, reroute and drain so mlag usage is kept in step.
+				17 Oct 2026 - Queued reservations are also retried when a link's capacity goes up.
 */
 
 package managers
@@ -126,6 +142,7 @@ type Network struct {
 	mlags		map[string]*gizmos.Mlag		// reference to each mlag link group by name
 	hupdate		bool						// set to true only if hosts is updated after gwmap has size (chkpt reload timing)
 	relaxed		bool						// if true, we're in relaxed mode which means we don't path find or do admission control.
+	admitted	map[string]gizmos.Pledge	// pledges which have been allocated capacity, by queue id (preemption candidates)
 }
 
 
@@ -143,6 +160,7 @@ func mk_network( mk_links bool ) ( n *Network ) {
 		n.links = make( map[string]*gizmos.Link, 2048 )		// must maintain a list of links so when we rebuild we preserve obligations
 		n.vlinks = make( map[string]*gizmos.Link, 2048 )
 		n.mlags = make( map[string]*gizmos.Mlag, 2048 )
+		n.admitted = make( map[string]gizmos.Pledge, 2048 )
 	}
 
 	return
@@ -505,14 +523,18 @@ func (n *Network) name2ip( hname *string ) (ip *string, err error) {
 	Mlag is a pointer to the string which is the name of the mlag group that this link belongs to.
 
 	We use this to reference the links from the previously created graph so as to preserve obligations.
-	(TODO: it would make sense to vet the obligations to ensure that they can still be met should
-	a switch depart from the network.)
+	If the link exists, and the capacity given differs from the link's capacity, the link's capacity
+	is changed; reservations which no longer fit are rerouted (see network_reroute.go).
 */
 func (n *Network) find_link( ssw string, dsw string, capacity int64, link_alarm_thresh int, mlag  *string, lnk ...*gizmos.Link ) (l *gizmos.Link) {
 
 	id := fmt.Sprintf( "%s-%s", ssw, dsw )
 	l = n.links[id]
 	if l != nil {
+		if capacity > 0 && capacity != l.Get_allotment().Get_max_capacity() {
+			net_sheep.Baa( 1, "link capacity changed: %s %d -> %d", id, l.Get_allotment().Get_max_capacity(), capacity )
+			l.Mod_capacity( capacity )
+		}
 		if lnk != nil {										// dont assume that the links shared the same allotment previously though they probably do
 			l.Set_allotment( lnk[0].Get_allotment( ) )
 		}
@@ -603,10 +625,13 @@ func (n Network) find_swvlink( sw1 string, sw2 string  ) ( l *gizmos.Link ) {
 
 	Tegu-lite:  sdnhost might be a file which contains a static graph, in json form,
 	describing the physical network. The string is assumed to be a filename if it
-	does _not_ contain a ':'.
+	does _not_ contain a ':'. The file is read and validated by topo (see gizmos/topo_file.go)
+	and the last good set of links is used.
+
+	When sdnc is not nil the links and hosts are requested from the sdn controller.
 	
 */
-func build( old_net *Network, flhost *string, max_capacity int64, link_headroom int, link_alarm_thresh int, host_list *string ) (n *Network) {
+func build( old_net *Network, flhost *string, max_capacity int64, link_headroom int, link_alarm_thresh int, host_list *string, topo *gizmos.Topo_file, sdnc gizmos.Sdn_ctlr ) (n *Network) {
 	var (
 		ssw		*gizmos.Switch
 		dsw		*gizmos.Switch
@@ -626,12 +651,23 @@ func build( old_net *Network, flhost *string, max_capacity int64, link_headroom
 		hr_factor = 100 - int64( link_headroom )
 	}
 
-	if strings.Index( *flhost, ":" ) >= 0  {
-		links = gizmos.FL_links( flhost )					// request the current set of links from floodlight
-		hlist = gizmos.FL_hosts( flhost )					// get a current host list from floodlight
+	if sdnc != nil  {
+		if links, err = sdnc.Get_links( ); err != nil {		// request the current set of links from the controller
+			net_sheep.Baa( 1, "WRN: unable to get links from sdn controller: %s  [TGUNET015]", err )
+		}
+		if hlist, err = sdnc.Get_hosts( ); err != nil {		// and the current host list
+			net_sheep.Baa( 1, "WRN: unable to get hosts from sdn controller: %s  [TGUNET016]", err )
+		}
 	} else {
 		hlist = old_net.build_hlist()						// simulate output from floodlight by building the host list from openstack maps
-		links, err = gizmos.Read_json_links( *flhost )
+		if topo != nil {
+			links = append( []gizmos.FL_link_json{ }, topo.Get_links()... )		// copy; we fill in default capacities
+			if len( links ) <= 0 && topo.Get_result() != nil {
+				err = fmt.Errorf( "%s", strings.Join( topo.Get_result().Get_errors(), "; " ) )
+			}
+		} else {
+			links, err = gizmos.Read_json_links( *flhost )
+		}
 		if err != nil || len( links ) <= 0 {
 			if host_list != nil {
 				net_sheep.Baa_some( "star", 500, 1, "generating a dummy star topology: json file empty, or non-existent: %s", *flhost )
@@ -953,11 +989,60 @@ func (net *Network) xfer_maps( old_net *Network ) {
 	net.fip2ip = old_net.fip2ip
 	net.ip2fip = old_net.ip2fip
 	net.limits = old_net.limits
+	net.admitted = old_net.admitted
 }
 
 
+/*
+	Check the topology file for changes and log the result. Returns true if new links were
+	accepted and the graph should be rebuilt.
+*/
+func topo_checked( topo *gizmos.Topo_file ) ( bool ) {
+	changed, checked := topo.Check( )
+	if checked {
+		if changed {
+			net_sheep.Baa( 1, "static topology accepted: %s", topo.To_json() )
+		} else {
+			net_sheep.Baa( 0, "ERR: static topology rejected; the last good topology is kept: %s  [TGUNET014]", strings.Join( topo.Get_result().Get_errors(), "; " ) )
+		}
+	}
+
+	return changed
+}
+
 // --------- public -------------------------------------------------------------------------------------------
 
+/*
+	Apply the discount to the inbound and outbound bandwidth amounts returning the reduced
+	values.  The discount is a percentage if between 1 and 100 inclusive, and a hard value
+	otherwise. Neither value is reduced below 10.
+*/
+func discount_bw( bandw_in int64, bandw_out int64, discount int64 ) ( int64, int64 ) {
+	if discount <= 0 {
+		return bandw_in, bandw_out
+	}
+
+	suffix := "bps"
+	if discount < 101 {
+		bandw_in -=  ((bandw_in * discount)/100)
+		bandw_out -=  ((bandw_out * discount)/100)
+		suffix = "%"
+	} else {
+		bandw_in -= discount
+		bandw_out -= discount
+	}
+
+	if bandw_out < 10 {			// add some sanity, and keep it from going too low
+		bandw_out = 10
+	}
+	if bandw_in < 10 {
+		bandw_in = 10
+	}
+	net_sheep.Baa( 1, "bandwidth was reduced by a discount of %d%s: in=%d out=%d", discount, suffix, bandw_in, bandw_out )
+
+	return bandw_in, bandw_out
+}
+
 /*
 	to be executed as a go routine.
 	nch is the channel we are expected to listen on for api requests etc.
@@ -979,6 +1064,10 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 		discount 		int64 = 0					// bandwidth discount value (pct if between 1 and 100 inclusive; hard value otherwise
 		relaxed			bool = false				// set with relaxed = true in config
 		hlist			*string = &empty_str		// host list we'll give to build should we need to build a dummy star topo
+		reroute			bool = true					// move reservations off of links which leave/shrink on a rebuild
+		topo			*gizmos.Topo_file = nil		// static topology file (lite) when sdn_host is a file name
+		sdnc			gizmos.Sdn_ctlr = nil		// sdn controller when sdn_host is host:port
+		topo_check		int = 10					// seconds between checks of the topology file for changes; 0 disables
 
 	)
 
@@ -1021,9 +1110,15 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 		if p := cfg_data["network"]["relaxed"]; p != nil {
 			relaxed = *p ==  "true" || *p ==  "True" || *p == "TRUE"
 		}
+		if p := cfg_data["network"]["reroute"]; p != nil {
+			reroute = *p ==  "true" || *p ==  "True" || *p == "TRUE"
+		}
 		if p := cfg_data["network"]["refresh"]; p != nil {
 			refresh = clike.Atoi( *p ); 			
 		}
+		if p := cfg_data["network"]["topo_check"]; p != nil {
+			topo_check = clike.Atoi( *p )
+		}
 		if p := cfg_data["network"]["link_max_cap"]; p != nil {
 			max_link_cap = clike.Atoi64( *p )
 		}
@@ -1085,7 +1180,14 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 
 	net_sheep.Baa( 1,  "network_mgr thread started: sdn_hpst=%s max_link_cap=%d refresh=%d", *sdn_host, max_link_cap, refresh )
 
-	act_net = build( nil, sdn_host, max_link_cap, link_headroom, link_alarm_thresh, &empty_str )
+	if strings.Index( *sdn_host, ":" ) < 0 {								// static topology file; validate before first build
+		topo = gizmos.Mk_topo_file( *sdn_host )
+		topo_checked( topo )
+	} else {
+		sdnc = mk_sdnc( sdn_host, net_sheep )
+	}
+
+	act_net = build( nil, sdn_host, max_link_cap, link_headroom, link_alarm_thresh, &empty_str, topo, sdnc )
 	if act_net == nil {
 		net_sheep.Baa( 0, "ERR: initial build of network failed -- core dump likely to follow!  [TGUNET011]" )		// this is bad and WILL cause a core dump
 	} else {
@@ -1097,6 +1199,9 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 	tklr.Add_spot( 2, nch, REQ_CHOSTLIST, nil, 1 ) 		 						// tickle once, very soon after starting, to get a host list
 	tklr.Add_spot( int64( refresh * 2 ), nch, REQ_CHOSTLIST, nil, ipc.FOREVER )  	// get a host list from openstack now and again
 	tklr.Add_spot( int64( refresh ), nch, REQ_NETUPDATE, nil, ipc.FOREVER )		// add tickle spot to drive rebuild of network
+	if topo != nil && topo_check > 0 {
+		tklr.Add_spot( int64( topo_check ), nch, REQ_TOPOCHECK, nil, ipc.FOREVER )	// watch the topology file for changes
+	}
 	
 	for {
 		select {					// assume we might have multiple channels in future
@@ -1123,8 +1228,8 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 						if ok {
 							h1, h2, _, _, commence, expiry, bandw_in, bandw_out := p.Get_values( )
 							net_sheep.Baa( 1,  "has-capacity request received on channel  %s -> %s", h1, h2 )
-							pcount_in, path_list_out, o_cap_trip := act_net.build_paths( h1, h2, commence, expiry,  bandw_out, find_all_paths, false );
-							pcount_out, path_list_in, i_cap_trip := act_net.build_paths( h2, h1, commence, expiry, bandw_in, find_all_paths, true ); 	// reverse path
+							pcount_in, path_list_out, o_cap_trip := act_net.build_paths( h1, h2, commence, expiry,  bandw_out, find_all_paths, false, p.Get_protect() );
+							pcount_out, path_list_in, i_cap_trip := act_net.build_paths( h2, h1, commence, expiry, bandw_in, find_all_paths, true, p.Get_protect() ); 	// reverse path
 
 							if pcount_out > 0  && pcount_in > 0  {
 								path_list := make( []*gizmos.Path, pcount_out + pcount_in )		// combine the lists
@@ -1200,6 +1305,7 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 									p.Set_qid( qid ) 												// and add the queue id to the pledge
 
 									if gate.Add_queue( c, e, p.Get_bandwidth(), qid, fence ) {		// create queue AND inc utilisation on the link
+										act_net.admit_pledge( qid, p )
 										req.Response_data = gate									// finally safe to set gate as the return data
 										req.State = nil												// and nil state to indicate OK
 									} else {
@@ -1228,25 +1334,7 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 							h1, h2, _, _, commence, expiry, bandw_in, bandw_out := p.Get_values( )		// ports can be ignored
 							net_sheep.Baa( 1,  "network: bw reservation request received: %s -> %s  from %d to %d", *h1, *h2, commence, expiry )
 
-							suffix := "bps"
-							if discount > 0 {
-								if discount < 101 {
-									bandw_in -=  ((bandw_in * discount)/100)
-									bandw_out -=  ((bandw_out * discount)/100)
-									suffix = "%"
-								} else {
-									bandw_in -= discount
-									bandw_out -= discount
-								}
-
-								if bandw_out < 10 {			// add some sanity, and keep it from going too low
-									bandw_out = 10
-								}
-								if bandw_in < 10 {
-									bandw_in = 10
-								}
-								net_sheep.Baa( 1, "bandwidth was reduced by a discount of %d%s: in=%d out=%d", discount, suffix, bandw_in, bandw_out )
-							}
+							bandw_in, bandw_out = discount_bw( bandw_in, bandw_out, discount )
 
 							ip1, err := act_net.name2ip( h1 )
 							if err == nil {
@@ -1255,8 +1343,8 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 
 							if err == nil {
 								net_sheep.Baa( 2,  "network: attempt to find path between  %s -> %s", *ip1, *ip2 )
-								pcount_out, path_list_out, o_cap_trip := act_net.build_paths( ip1, ip2, commence, expiry, bandw_out, find_all_paths, false ); 	// outbound path
-								pcount_in, path_list_in, i_cap_trip := act_net.build_paths( ip2, ip1, commence, expiry, bandw_in, find_all_paths, true ); 		// inbound path
+								pcount_out, path_list_out, o_cap_trip := act_net.build_paths( ip1, ip2, commence, expiry, bandw_out, find_all_paths, false, p.Get_protect() ); 	// outbound path
+								pcount_in, path_list_in, i_cap_trip := act_net.build_paths( ip2, ip1, commence, expiry, bandw_in, find_all_paths, true, p.Get_protect() ); 		// inbound path
 
 								if pcount_out > 0  &&  pcount_in > 0  {
 									net_sheep.Baa( 1,  "network: %d acceptable path(s) found icap=%v ocap=%v", pcount_out + pcount_in, i_cap_trip, o_cap_trip )
@@ -1285,11 +1373,16 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 										}
 									}
 
+									act_net.admit_pledge( qid, p )
 									req.Response_data = path_list
 									req.State = nil
 								} else {
 									req.Response_data = nil
-									if i_cap_trip {
+									if victims := act_net.find_preempt( p, ip1, ip2, bandw_in, bandw_out, find_all_paths, mlag_paths ); victims != nil {
+										req.State = &Preempt_error{ victims: victims }						// requestor may yank these and try again
+									} else if p.Get_protect() != gizmos.PROT_NONE && ! i_cap_trip && ! o_cap_trip {
+										req.State = fmt.Errorf( "unable to generate a path: no %s disjoint pair of paths", gizmos.Protect2str( p.Get_protect() ) )
+									} else if i_cap_trip {
 										req.State = fmt.Errorf( "unable to generate a path: no capacity (h1<-h2)" )		// tedious, but we'll break out direction
 									} else {
 										if o_cap_trip {
@@ -1311,6 +1404,24 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 
 
 
+					case REQ_MODRES:								// change bandwidth and/or expiry of an existing reservation
+						m, ok := req.Req_data.( *Bw_mod )
+						if ok {
+							req.State = act_net.mod_bw_res( m, discount, mlag_paths )
+						} else {
+							net_sheep.Baa( 1, "internal mishap: data passed to modres wasn't a bw modification" )
+							req.State = fmt.Errorf( "unable to modify reservation in network, internal data corruption." )
+						}
+
+					case REQ_FINDSLOT:								// find the earliest window(s) where a reservation would fit; nothing is reserved
+						sr, ok := req.Req_data.( *Slot_req )
+						if ok {
+							req.Response_data, req.State = act_net.find_slots( sr, discount, find_all_paths )
+						} else {
+							net_sheep.Baa( 1, "internal mishap: data passed to findslot wasn't a slot request" )
+							req.State = fmt.Errorf( "unable to search for a slot, internal data corruption." )
+						}
+
 					case REQ_DEL:									// delete the utilisation for the given reservation
 						switch p := req.Req_data.( type ) {
 							case *gizmos.Pledge_bw:
@@ -1324,6 +1435,7 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 									net_sheep.Baa( 1,  "network: deleting path %d associated with usr=%s", i, *fence.Name )
 									path_list[i].Set_queue( qid, commence, expiry, -path_list[i].Get_bandwidth(), fence )		// reduce queues on the path as needed
 								}
+								act_net.release_pledge( qid )
 
 							case *gizmos.Pledge_bwow:
 								net_sheep.Baa( 1,  "network: deleting oneway reservation: %s", *p.Get_id() )
@@ -1331,6 +1443,7 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 								gate := p.Get_gate()
 								fence := act_net.get_fence( gate.Get_usr() )
 								gate.Set_queue( p.Get_qid(), commence, expiry, -p.Get_bandwidth(), fence )				// reduce queues
+								act_net.release_pledge( p.Get_qid() )
 
 							default:
 								net_sheep.Baa( 1, "internal mishap: req_del wasn't passed a bandwidth or oneway pledge; nothing done by network" )
@@ -1351,7 +1464,7 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 									}
 							}
 
-							new_net := build( act_net, sdn_host, max_link_cap, link_headroom, link_alarm_thresh, hlist )
+							new_net := build( act_net, sdn_host, max_link_cap, link_headroom, link_alarm_thresh, hlist, topo, sdnc )
 							if new_net != nil {
 								new_net.xfer_maps( act_net )				// copy maps from old net to the new graph
 								act_net = new_net							// and finally use it
@@ -1491,10 +1604,38 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 
 					case REQ_NETUPDATE:											// build a new network graph
 						net_sheep.Baa( 2, "rebuilding network graph" )			// less chatty with lazy changes
-						new_net := build( act_net, sdn_host, max_link_cap, link_headroom, link_alarm_thresh, hlist )
+						nlinks := len( act_net.links )							// links map is shared with the new graph, so count now
+						ocaps := act_net.link_caps( )							// capacities are changed in place by build, so capture now
+						new_net := build( act_net, sdn_host, max_link_cap, link_headroom, link_alarm_thresh, hlist, topo, sdnc )
 						if new_net != nil {
 							new_net.xfer_maps( act_net )						// copy maps from old net to the new graph
+							grew := len( new_net.links ) > nlinks || len( new_net.switches ) > len( act_net.switches ) || len( new_net.hosts ) > len( act_net.hosts )
 							act_net = new_net
+							grew = grew || act_net.caps_grew( ocaps )			// a link added back, or given more capacity
+							act_net.prune_admitted( )
+							act_net.prune_drains( )
+
+							if reroute && ! act_net.relaxed {
+								if len( act_net.switches ) == 0 {
+									net_sheep.Baa( 1, "reroute: new graph is empty, reservations not rerouted" )	// likely a controller hiccup; don't move everything
+								} else if changed := act_net.changed_links( ocaps ); len( changed ) > 0 {
+									rpt := act_net.reroute( changed, discount, find_all_paths, mlag_paths )
+									if rpt.Has_entries( ) {
+										net_sheep.Baa( 0, "reroute: %d reservation(s) moved, %d could not be moved: %s", len( rpt.Get_moved() ), len( rpt.Get_failed() ), rpt.To_json() )
+										rreq := ipc.Mk_chmsg( )
+										rreq.Send_req( rmgr_ch, nil, REQ_REROUTE, rpt, nil )		// res_mgr must push flow-mods for the new paths
+									}
+								}
+							}
+							if drpt := act_net.check_protected( ); drpt != nil {	// res_mgr marks protected reservations which lost a path
+								dreq := ipc.Mk_chmsg( )
+								dreq.Send_req( rmgr_ch, nil, REQ_DEGRADED, drpt, nil )
+							}
+
+							if grew {											// new capacity might allow queued reservations to be admitted
+								rreq := ipc.Mk_chmsg( )
+								rreq.Send_req( rmgr_ch, nil, REQ_RETRYWAIT, nil, nil )
+							}
 
 							net_sheep.Baa( 2, "network graph rebuild completed" )		// timing during debugging
 						} else {
@@ -1537,7 +1678,11 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 						}
 						
 					case REQ_NETGRAPH:							// dump the current network graph
-						req.Response_data = act_net.to_json()
+						if gr, ok := req.Req_data.( *Graph_req ); ok {
+							req.Response_data, req.State = act_net.export_graph( gr )
+						} else {
+							req.Response_data = act_net.to_json()
+						}
 
 					case REQ_LISTHOSTS:							// spew out a json list of hosts with name, ip, switch id and port
 						req.Response_data = act_net.host_list( )
@@ -1545,6 +1690,54 @@ func Network_mgr( nch chan *ipc.Chmsg, sdn_host *string ) {
 					case REQ_LISTULCAP:							// user link capacity list
 						req.Response_data = act_net.fence_list( )
 
+					case REQ_DRAIN:								// mark link(s) unavailable for a window; response is the impact report
+						jstr, rpt, err := act_net.add_drain( req.Req_data.( *Drain_req ), discount, find_all_paths, mlag_paths )
+						if err == nil {
+							req.Response_data = jstr
+							if rpt.Has_entries( ) {
+								rreq := ipc.Mk_chmsg( )
+								rreq.Send_req( rmgr_ch, nil, REQ_REROUTE, rpt, nil )		// res_mgr must push flow-mods for the new paths
+							}
+						} else {
+							req.Response_data = nil
+						}
+						req.State = err
+
+					case REQ_UNDRAIN:
+						if n := act_net.rm_drain( req.Req_data.( *string ) ); n > 0 {
+							req.Response_data = fmt.Sprintf( "drain removed from %d link(s)", n )
+							req.State = nil
+						} else {
+							req.Response_data = nil
+							req.State = fmt.Errorf( "no such drain: %s", *req.Req_data.( *string ) )
+						}
+
+					case REQ_LISTDRAINS:
+						req.Response_data = act_net.drain_list( )
+
+					case REQ_LINKUSAGE:							// allocation over time for a link and the hottest links
+						req.Response_data, req.State = act_net.link_usage( req.Req_data.( *Usage_req ) )
+
+					case REQ_TOPOCHECK:							// check the static topology file; rebuild soon if it changed
+						if topo != nil {
+							if topo_checked( topo ) {
+								tklr.Add_spot( 1, nch, REQ_NETUPDATE, nil, 1 )
+							}
+							req.Response_data = topo.To_json( )
+						} else {
+							req.Response_data = nil
+							req.State = fmt.Errorf( "not using a static topology file; sdn_host is: %s", *sdn_host )
+						}
+
+					case REQ_SIMULATE:							// what-if; the live graph is not changed
+						jstr, err := act_net.simulate( req.Req_data.( *Sim_req ), discount, find_all_paths, mlag_paths )
+						if err == nil {
+							req.Response_data = jstr
+						} else {
+							req.Response_data = nil
+						}
+						req.State = err
+
 					case REQ_LISTCONNS:							// for a given host spit out the switch(es) and port(s)
 						hname := req.Req_data.( *string )
 						host := act_net.hosts[*hname]
@@ -0,0 +1,293 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	network_drain
+	Abstract:	Network manager functions which support link maintenance (drain) windows. An admin
+				marks a link (both directions) or every link of a switch as unavailable for a window;
+				the drain is attached to each link and path finding treats the link as having no
+				capacity during the window. Links are kept across graph rebuilds, so drains are too.
+
+				When a drain is added an impact report is generated which lists each admitted
+				bandwidth reservation with a path that crosses a drained link during the window.
+				If requested, those reservations are moved to new paths (see network_reroute.go)
+				before the window starts, and the report indicates which could not be moved.
+
+				Drains are not checkpointed; they must be added again if tegu is restarted.
+
+	Date:		17 October 2026
+
+	Mods:		17 Oct 2026 - Pass mlag_paths through to the reroute of impacted reservations.
+				17 Oct 2026 - Oneway reservations with a gate on a drained link are listed in the
+					impact report (and as failed when rerouting; they cannot be moved).
+*/
+
+package managers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/att/tegu/gizmos"
+)
+
+/*
+	Passed to the network on a REQ_DRAIN request. Either the link id or the switch id is given.
+*/
+type Drain_req struct {
+	drain		*gizmos.Drain
+	link		*string				// link id; the link in the other direction is drained too
+	swid		*string				// all links to/from the switch are drained
+	reroute		bool				// move affected reservations to new paths
+}
+
+/*
+	Create a drain request.
+*/
+func Mk_drain_req( d *gizmos.Drain, link *string, swid *string, reroute bool ) ( *Drain_req ) {
+	return &Drain_req {
+		drain:		d,
+		link:		link,
+		swid:		swid,
+		reroute:	reroute,
+	}
+}
+
+/*
+	Return the link with the id, and the link(s) in the other direction between the same switches.
+*/
+func (n *Network) link_pair( id *string ) ( links []*gizmos.Link, err error ) {
+	l := n.links[*id]
+	if l == nil {
+		return nil, fmt.Errorf( "unknown link: %s", *id )
+	}
+	links = append( links, l )
+
+	s1, s2 := l.Get_sw_names( )
+	for _, rl := range n.links {									// ids of the reverse link may include interface names, so match switches
+		r1, r2 := rl.Get_sw_names( )
+		if *r1 == *s2 && *r2 == *s1 {
+			links = append( links, rl )
+		}
+	}
+
+	return
+}
+
+/*
+	Return the set of links named by the request, sorted by id.
+*/
+func (n *Network) drain_links( dr *Drain_req ) ( links []*gizmos.Link, err error ) {
+	switch {
+		case dr.link != nil:
+			if links, err = n.link_pair( dr.link ); err != nil {
+				return
+			}
+
+		case dr.swid != nil:
+			for _, l := range n.links {
+				s1, s2 := l.Get_sw_names( )
+				if *s1 == *dr.swid || *s2 == *dr.swid {
+					links = append( links, l )
+				}
+			}
+			if len( links ) == 0 {
+				return nil, fmt.Errorf( "unknown switch, or switch has no links: %s", *dr.swid )
+			}
+
+		default:
+			return nil, fmt.Errorf( "no link or switch given to drain" )
+	}
+
+	sort.Slice( links, func( i, j int ) bool { return *links[i].Get_id() < *links[j].Get_id() } )
+	return
+}
+
+/*
+	Return the admitted bandwidth reservations which have a path using one of the links during
+	the window, and the oneway reservations with a gate which uses one of the links.
+*/
+func (n *Network) drain_impact( links []*gizmos.Link, commence int64, conclude int64 ) ( impacted []*gizmos.Pledge_bw, ow []*gizmos.Pledge_bwow ) {
+	lset := make( map[*gizmos.Link]bool )
+	for _, l := range links {
+		lset[l] = true
+	}
+
+	for _, p := range n.admitted {
+		if p.Is_expired() {
+			continue
+		}
+		pc, pe := p.Get_window( )
+		if pc >= conclude || pe <= commence {
+			continue
+		}
+
+		switch bp := p.( type ) {
+			case *gizmos.Pledge_bw:
+				hit := false
+				for _, path := range bp.Get_path_list() {
+					for _, l := range path.Get_links() {
+						if lset[l] {
+							hit = true
+							break
+						}
+					}
+				}
+				if hit {
+					impacted = append( impacted, bp )
+				}
+
+			case *gizmos.Pledge_bwow:
+				g := bp.Get_gate()
+				if g == nil {
+					break
+				}
+				for _, l := range g.Get_links() {
+					if lset[l] {
+						ow = append( ow, bp )
+						break
+					}
+				}
+		}
+	}
+
+	sort.Slice( impacted, func( i, j int ) bool { return *impacted[i].Get_id() < *impacted[j].Get_id() } )
+	sort.Slice( ow, func( i, j int ) bool { return *ow[i].Get_id() < *ow[j].Get_id() } )
+	return
+}
+
+/*
+	Attach the drain to the links named in the request and generate the impact report (json).
+	If reroute is set in the request, the affected reservations are moved and the reroute report
+	is returned so that it can be passed to res_mgr; it is nil otherwise.
+*/
+func (n *Network) add_drain( dr *Drain_req, discount int64, find_all bool, mlag_paths bool ) ( jstr string, rpt *Reroute_rpt, err error ) {
+	if dr == nil || dr.drain == nil {
+		return "", nil, fmt.Errorf( "no drain given" )
+	}
+
+	links, err := n.drain_links( dr )
+	if err != nil {
+		return
+	}
+
+	d := dr.drain
+	for _, l := range links {
+		l.Add_drain( d )
+	}
+	commence, conclude := d.Get_window( )
+	net_sheep.Baa( 1, "drain %s added to %d link(s) from %d to %d", *d.Get_id(), len( links ), commence, conclude )
+
+	impacted, ow := n.drain_impact( links, commence, conclude )
+	if dr.reroute && len( impacted ) + len( ow ) > 0 {
+		rpt = n.reroute_list( impacted, discount, find_all, mlag_paths )
+		fail_oneway( rpt, ow, "the oneway reservation's gate uses a drained link; oneway reservations are not rerouted" )
+	}
+
+	lids := make( []string, len( links ) )
+	for i, l := range links {
+		lids[i] = fmt.Sprintf( "%q", *l.Get_id() )
+	}
+
+	all := make( []gizmos.Pledge, 0, len( impacted ) + len( ow ) )
+	for _, p := range impacted {
+		all = append( all, p )
+	}
+	for _, p := range ow {
+		all = append( all, p )
+	}
+
+	plist := make( []string, len( all ) )
+	for i, p := range all {
+		h1, h2 := p.Get_hosts( )
+		pc, pe := p.Get_window( )
+		state := "impacted"
+		reason := ""
+		if rpt != nil {
+			state = "rerouted"
+			for _, f := range rpt.Get_failed() {
+				if *f == *p.Get_id() {
+					state = "failed"
+					reason = rpt.reasons[*f]
+				}
+			}
+		}
+		plist[i] = fmt.Sprintf( `{ "name": %q, "host1": %q, "host2": %q, "commence": %d, "expiry": %d, "priority": %d, "state": %q, "reason": %q }`,
+			*p.Get_id(), *h1, *h2, pc, pe, p.Get_priority(), state, reason )
+	}
+
+	jstr = fmt.Sprintf( `{ "drain": %s, "links": [ %s ], "impact": [ %s ] }`, d.To_json(), strings.Join( lids, ", " ), strings.Join( plist, ", " ) )
+	return
+}
+
+/*
+	Remove the drain from every link. Returns the number of links it was removed from.
+*/
+func (n *Network) rm_drain( id *string ) ( count int ) {
+	for _, l := range n.links {
+		if l.Rm_drain( id ) {
+			count++
+		}
+	}
+
+	return
+}
+
+/*
+	Drop drains which have ended.
+*/
+func (n *Network) prune_drains( ) {
+	now := time.Now().Unix()
+	for _, l := range n.links {
+		l.Prune_drains( now )
+	}
+}
+
+/*
+	Generate a json list of the drains with the links each is attached to.
+*/
+func (n *Network) drain_list( ) ( string ) {
+	dlinks := make( map[string][]string )
+	drains := make( map[string]*gizmos.Drain )
+	for _, l := range n.links {
+		for _, d := range l.Get_drains() {
+			id := *d.Get_id()
+			drains[id] = d
+			dlinks[id] = append( dlinks[id], fmt.Sprintf( "%q", *l.Get_id() ) )
+		}
+	}
+
+	ids := make( []string, 0, len( drains ) )
+	for id := range drains {
+		ids = append( ids, id )
+	}
+	sort.Strings( ids )
+
+	list := make( []string, len( ids ) )
+	for i, id := range ids {
+		sort.Strings( dlinks[id] )
+		list[i] = fmt.Sprintf( `{ "drain": %s, "links": [ %s ] }`, drains[id].To_json(), strings.Join( dlinks[id], ", " ) )
+	}
+
+	return fmt.Sprintf( `{ "drains": [ %s ] }`, strings.Join( list, ", " ) )
+}
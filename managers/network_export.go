@@ -0,0 +1,294 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	network_export
+	Abstract:	Render the current network graph as Graphviz DOT or GraphML so that it can be
+				visualised. Switches and hosts are nodes; links (with capacity, current allocation
+				and mlag group) and host attachments (with the switch port) are edges. The links
+				used by the paths of a reservation can be highlighted.
+
+	Date:		17 October 2026
+
+*/
+
+package managers
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/att/tegu/gizmos"
+)
+
+const (
+	GRAPH_JSON		string = "json"
+	GRAPH_DOT		string = "dot"
+	GRAPH_GRAPHML	string = "graphml"
+)
+
+/*
+	Passed to the network on a REQ_NETGRAPH request. A nil request is the same as json.
+*/
+type Graph_req struct {
+	format	string
+	res		*string				// name of the reservation whose paths are highlighted; may be nil
+}
+
+/*
+	Create a graph request.
+*/
+func Mk_graph_req( format string, res *string ) ( *Graph_req ) {
+	return &Graph_req {
+		format:	format,
+		res:	res,
+	}
+}
+
+/*
+	Graph information collected once for either output format.
+*/
+type export_link struct {
+	id			string
+	from		string
+	to			string
+	capacity	int64
+	alloc		int64
+	mlag		string
+	hot			bool				// on a path of the highlighted reservation
+}
+
+type export_host struct {
+	name		string
+	mac			string
+	ip			string
+	swid		string
+	port		int
+}
+
+/*
+	Return the set of links used by the paths of the named reservation.
+*/
+func (n *Network) res_links( name *string ) ( links map[*gizmos.Link]bool, err error ) {
+	links = make( map[*gizmos.Link]bool )
+	if name == nil {
+		return
+	}
+
+	for _, p := range n.admitted {
+		bp, ok := p.( *gizmos.Pledge_bw )
+		if ok && *bp.Get_id() == *name {
+			for _, path := range bp.Get_path_list() {
+				for _, l := range path.Get_links() {
+					links[l] = true
+				}
+			}
+			return
+		}
+	}
+
+	return nil, fmt.Errorf( "reservation not found, or is not an admitted bandwidth reservation: %s", *name )
+}
+
+/*
+	Collect the switch ids, links and hosts of the current graph, sorted so that output is stable.
+*/
+func (n *Network) export_info( hl map[*gizmos.Link]bool ) ( swids []string, links []*export_link, hosts []*export_host ) {
+	now := time.Now().Unix()
+
+	for id, sw := range n.switches {
+		swids = append( swids, id )
+		for i := 0; ; i++ {
+			l := sw.Get_link( i )
+			if l == nil {
+				break
+			}
+
+			s1, s2 := l.Get_sw_names( )
+			el := &export_link {
+				id:			*l.Get_id(),
+				from:		*s1,
+				to:			*s2,
+				capacity:	l.Get_allotment().Get_max_capacity(),
+				alloc:		l.Get_allocation( now ),
+				hot:		hl[l],
+			}
+			if m := l.Get_mlag(); m != nil {
+				el.mlag = *m
+			}
+			links = append( links, el )
+		}
+	}
+	sort.Strings( swids )
+	sort.Slice( links, func( i, j int ) bool { return links[i].id < links[j].id } )
+
+	seen := make( map[*gizmos.Host]bool )				// hosts are in the map by several keys
+	for _, h := range n.hosts {
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+
+		mac := h.Get_mac( )
+		ip4, _ := h.Get_addresses( )
+		for i := 0; ; i++ {
+			sw, port := h.Get_switch_port( i )
+			if sw == nil {
+				break
+			}
+
+			eh := &export_host{ swid: *sw.Get_id(), port: port }
+			if mac != nil {
+				eh.mac = *mac
+			}
+			if ip4 != nil {
+				eh.ip = *ip4
+			}
+			eh.name = eh.mac
+			if eh.name == "" {
+				eh.name = eh.ip
+			}
+			hosts = append( hosts, eh )
+		}
+	}
+	sort.Slice( hosts, func( i, j int ) bool {
+		if hosts[i].name != hosts[j].name {
+			return hosts[i].name < hosts[j].name
+		}
+		return hosts[i].swid < hosts[j].swid
+	} )
+
+	return
+}
+
+/*
+	Generate a Graphviz (DOT) representation of the graph.
+*/
+func (n *Network) to_dot( hl map[*gizmos.Link]bool ) ( string ) {
+	swids, links, hosts := n.export_info( hl )
+
+	b := &strings.Builder{ }
+	b.WriteString( "digraph tegu {\n" )
+	b.WriteString( "\tnode [shape=ellipse];\n" )
+	for _, id := range swids {
+		fmt.Fprintf( b, "\t%q [label=%q];\n", id, id )
+	}
+
+	hnames := make( map[string]bool )
+	for _, h := range hosts {
+		if ! hnames[h.name] {
+			hnames[h.name] = true
+			fmt.Fprintf( b, "\t%q [shape=box, label=%q];\n", "host:" + h.name, h.name + "\n" + h.ip )
+		}
+		fmt.Fprintf( b, "\t%q -> %q [dir=none, style=dashed, label=%q];\n", "host:" + h.name, h.swid, fmt.Sprintf( "port %d", h.port ) )
+	}
+
+	for _, l := range links {
+		label := fmt.Sprintf( "%d/%d", l.alloc, l.capacity )
+		if l.mlag != "" {
+			label += " mlag=" + l.mlag
+		}
+		attrs := fmt.Sprintf( "label=%q", label )
+		if l.hot {
+			attrs += ", color=red, penwidth=3"
+		}
+		fmt.Fprintf( b, "\t%q -> %q [%s];\n", l.from, l.to, attrs )
+	}
+	b.WriteString( "}\n" )
+
+	return b.String()
+}
+
+/*
+	Generate a GraphML representation of the graph.
+*/
+func (n *Network) to_graphml( hl map[*gizmos.Link]bool ) ( string ) {
+	swids, links, hosts := n.export_info( hl )
+	esc := html.EscapeString
+
+	b := &strings.Builder{ }
+	b.WriteString( `<?xml version="1.0" encoding="UTF-8"?>` + "\n" )
+	b.WriteString( `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n" )
+	b.WriteString( `  <key id="type" for="node" attr.name="type" attr.type="string"/>` + "\n" )
+	b.WriteString( `  <key id="ip" for="node" attr.name="ip" attr.type="string"/>` + "\n" )
+	b.WriteString( `  <key id="capacity" for="edge" attr.name="capacity" attr.type="long"/>` + "\n" )
+	b.WriteString( `  <key id="allocated" for="edge" attr.name="allocated" attr.type="long"/>` + "\n" )
+	b.WriteString( `  <key id="mlag" for="edge" attr.name="mlag" attr.type="string"/>` + "\n" )
+	b.WriteString( `  <key id="port" for="edge" attr.name="port" attr.type="int"/>` + "\n" )
+	b.WriteString( `  <key id="highlight" for="edge" attr.name="highlight" attr.type="boolean"/>` + "\n" )
+	b.WriteString( `  <graph id="tegu" edgedefault="directed">` + "\n" )
+
+	for _, id := range swids {
+		fmt.Fprintf( b, "    <node id=\"%s\"><data key=\"type\">switch</data></node>\n", esc( id ) )
+	}
+
+	hnames := make( map[string]bool )
+	for _, h := range hosts {
+		hid := esc( "host:" + h.name )
+		if ! hnames[h.name] {
+			hnames[h.name] = true
+			fmt.Fprintf( b, "    <node id=\"%s\"><data key=\"type\">host</data><data key=\"ip\">%s</data></node>\n", hid, esc( h.ip ) )
+		}
+		fmt.Fprintf( b, "    <edge source=\"%s\" target=\"%s\"><data key=\"port\">%d</data></edge>\n", hid, esc( h.swid ), h.port )
+	}
+
+	for _, l := range links {
+		fmt.Fprintf( b, "    <edge id=\"%s\" source=\"%s\" target=\"%s\"><data key=\"capacity\">%d</data><data key=\"allocated\">%d</data>",
+			esc( l.id ), esc( l.from ), esc( l.to ), l.capacity, l.alloc )
+		if l.mlag != "" {
+			fmt.Fprintf( b, "<data key=\"mlag\">%s</data>", esc( l.mlag ) )
+		}
+		if l.hot {
+			b.WriteString( "<data key=\"highlight\">true</data>" )
+		}
+		b.WriteString( "</edge>\n" )
+	}
+
+	b.WriteString( "  </graph>\n</graphml>\n" )
+	return b.String()
+}
+
+/*
+	Render the graph in the requested format.
+*/
+func (n *Network) export_graph( gr *Graph_req ) ( string, error ) {
+	if gr == nil || gr.format == "" || gr.format == GRAPH_JSON {
+		return n.to_json( ), nil
+	}
+
+	hl, err := n.res_links( gr.res )
+	if err != nil {
+		return "", err
+	}
+
+	switch gr.format {
+		case GRAPH_DOT:
+			return n.to_dot( hl ), nil
+
+		case GRAPH_GRAPHML:
+			return n.to_graphml( hl ), nil
+	}
+
+	return "", fmt.Errorf( "unknown graph format: %s; expected json, dot or graphml", gr.format )
+}
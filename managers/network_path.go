@@ -27,13 +27,18 @@
 	Date:		09 June 2015 (broken out of main-line network.go)
 	Author:		E. Scott Daniels
 
-	Mods:
+	Mods:		17 Oct 2026 - Added mod_bw_res() to change a reservation's bandwidth/expiry in place.
+				17 Oct 2026 - Added find_slots() to search for the earliest window with capacity.
+				17 Oct 2026 - Added protected (disjoint pair) path finding and check_protected().
+				17 Oct 2026 - Find_slots ignores drains when collecting links; drain edges are tried as start times.
+				17 Oct 2026 - Check_protected returns a report for res_mgr rather than setting the degraded flag.
 */
 
 package managers
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	//"github.com/att/gopkgs/bleater"
@@ -51,6 +56,42 @@ type host_pair struct {
 	fip	*string			// floating IP address needed for this segment
 }
 
+/*
+	Describes a search for the earliest window(s) in which a bandwidth reservation of the
+	given duration would fit.  Start times between commence and horizon (inclusive) are
+	considered and at most max windows are returned.
+*/
+type Slot_req struct {
+	h1			*string			// host names (validated and translated as for a reservation)
+	h2			*string
+	commence	int64			// earliest start time
+	horizon		int64			// latest start time
+	duration	int64			// seconds the reservation would last
+	bandw_in	int64
+	bandw_out	int64
+	max			int				// max number of windows to return
+}
+
+/*
+	Create a slot search request.
+*/
+func Mk_slot_req( h1 *string, h2 *string, commence int64, horizon int64, duration int64, bandw_in int64, bandw_out int64, max int ) ( *Slot_req ) {
+	if max < 1 {
+		max = 1
+	}
+
+	return &Slot_req {
+		h1:			h1,
+		h2:			h2,
+		commence:	commence,
+		horizon:	horizon,
+		duration:	duration,
+		bandw_in:	bandw_in,
+		bandw_out:	bandw_out,
+		max:		max,
+	}
+}
+
 
 
 // ------------------------------------------------------------------------------------------------------------------
@@ -297,6 +338,119 @@ func (n *Network) find_all_paths( ssw *gizmos.Switch, h1 *gizmos.Host, h2 *gizmo
 	return
 }
 
+/*
+	A helper function for find_paths() which finds a pair of disjoint paths (link or switch disjoint
+	depending on protect) between h1 and h2 starting at ssw. Both paths can support inc_cap; the
+	first is the primary and the second is marked as the backup. The paths are built in the same
+	manner as find_shortest_path() builds a path. Nil is returned if a pair cannot be found; cap_trip
+	is set if one or more links were not usable because of capacity.
+*/
+func (n *Network) find_disjoint_paths( ssw *gizmos.Switch, h1 *gizmos.Host, h2 *gizmos.Host, usr *string, commence int64, conclude int64, inc_cap int64, usr_max int64, protect int ) ( paths []*gizmos.Path, cap_trip bool ) {
+	if usr_max <= 0 {
+		net_sheep.Baa( 1, "no protected paths generated: user link capacity set to 0" )
+		return
+	}
+
+	lpaths, eps, cap_trip, err := ssw.Disjoint_paths_to( h2.Get_mac(), commence, conclude, inc_cap, usr, usr_max, protect == gizmos.PROT_SWITCH )
+	if err != nil {
+		net_sheep.Baa( 1, "find_disjoint: %s protection from %s: %s", gizmos.Protect2str( protect ), ssw.To_str(), err )
+		return nil, cap_trip
+	}
+
+	for k := range lpaths {
+		path := gizmos.Mk_path( h1, h2 )
+		path.Set_reverse( true )									// built from h2 back to h1 as is the shortest path
+		path.Set_bandwidth( inc_cap )
+		path.Set_backup( k > 0 )
+
+		tsw := eps[k]
+		lnk := n.find_vlink( *(tsw.Get_id()), h2.Get_port( tsw ), -1, nil, nil )		// endpoint -- virtual link out from switch to h2
+		lnk.Add_lbp( *(h2.Get_mac()) )
+		lnk.Set_forward( tsw )
+		path.Add_endpoint( lnk )
+
+		for i := len( lpaths[k] ) - 1; i >= 0; i-- {
+			path.Add_link( lpaths[k][i] )
+			path.Add_switch( lpaths[k][i].Get_forward_sw() )
+		}
+		path.Add_switch( ssw )
+
+		lnk = n.find_vlink( *(ssw.Get_id()), h1.Get_port( ssw ), -1, nil, nil )		// endpoint is a virt link from switch to h1
+		lnk.Add_lbp( *(h1.Get_mac()) )
+		lnk.Set_forward( ssw )
+		path.Add_endpoint( lnk )
+
+		path.Flip_endpoints()
+		paths = append( paths, path )
+		net_sheep.Baa( 2, "find_disjoint: path[%d] backup=%v: %s", k, k > 0, path.To_str() )
+	}
+
+	return
+}
+
+/*
+	The names of protected reservations which have lost a path (degraded) and those which have
+	all of their paths (intact). Sent to res_mgr which owns the reservations and sets, or clears,
+	the degraded flag.
+*/
+type Degrade_rpt struct {
+	degraded	[]*string
+	intact		[]*string
+}
+
+/*
+	Check each protected reservation to see that all of the links in each of its paths are still
+	part of the network; run after the graph is rebuilt.  Reservations which have lost a link are
+	listed as degraded (their capacity is left reserved on the links which remain), and those with
+	all of their links are listed as intact. Nil is returned if there are no protected reservations.
+	The pledges are not changed here as they belong to res_mgr.
+*/
+func (n *Network) check_protected( ) ( rpt *Degrade_rpt ) {
+	if n.relaxed {
+		return nil
+	}
+
+	live := make( map[*gizmos.Link]bool )
+	for _, sw := range n.switches {
+		for i := 0; ; i++ {
+			l := sw.Get_link( i )
+			if l == nil {
+				break
+			}
+			live[l] = true
+		}
+	}
+
+	for _, p := range n.admitted {
+		bp, ok := p.( *gizmos.Pledge_bw )
+		if ! ok || bp.Get_protect() == gizmos.PROT_NONE || bp.Is_expired() {
+			continue
+		}
+
+		lost := 0
+		for _, path := range bp.Get_path_list() {
+			for _, l := range path.Get_links() {
+				if ! live[l] {
+					lost++
+					break
+				}
+			}
+		}
+
+		if rpt == nil {
+			rpt = &Degrade_rpt { }
+		}
+		if lost > 0 {
+			net_sheep.Baa( 2, "check_protected: reservation %s has %d path(s) lost from the network", *bp.Get_id(), lost )
+			rpt.degraded = append( rpt.degraded, bp.Get_id() )
+		} else {
+			rpt.intact = append( rpt.intact, bp.Get_id() )
+		}
+	}
+
+	return
+}
+
 /*
 	A helper function for find_paths() that is used when running in 'relaxed' mode. In relaxed mode we don't
 	actually find a path between the endpoints as we aren't doign admission control, but need to simulate
@@ -352,8 +506,11 @@ func (n *Network) find_relaxed_path( sw1 *gizmos.Switch, h1 *gizmos.Host, sw2 *g
 
 	If find_all is set, and mlog_paths is false, then we will suss out all possible paths between h1 and h2 and not
 	just the shortest path.
+
+	If protect is not PROT_NONE a pair of disjoint paths (primary and backup) is found rather than a single
+	path, and find_all is ignored.
 */
-func (n *Network) find_paths( h1nm *string, h2nm *string, usr *string, commence int64, conclude int64, inc_cap int64, extip *string, ext_flag *string, find_all bool ) ( pcount int, path_list []*gizmos.Path, cap_trip bool ) {
+func (n *Network) find_paths( h1nm *string, h2nm *string, usr *string, commence int64, conclude int64, inc_cap int64, extip *string, ext_flag *string, find_all bool, protect int ) ( pcount int, path_list []*gizmos.Path, cap_trip bool ) {
 	var (
 		path	*gizmos.Path
 		ssw 	*gizmos.Switch		// starting switch
@@ -418,7 +575,9 @@ func (n *Network) find_paths( h1nm *string, h2nm *string, usr *string, commence
 		if ssw.Has_host( h1nm )  &&  ssw.Has_host( h2nm ) {			// if both hosts are on the same switch, there's no path if they both have the same port (both external to our view)
 			p1 := h1.Get_port( ssw )
 			p2 := h2.Get_port( ssw )
-			if p1 < 0 || p1 != p2 {									// when ports differ we'll create/find the vlink between them	(in Tegu-lite port == -128 is legit and will dup)
+			if protect != gizmos.PROT_NONE && ! n.relaxed {
+				net_sheep.Baa( 1, "path[%d]: hosts on same switch, a protected (disjoint) pair of paths is not possible: %s", plidx, ssw.To_str( ) )
+			} else if p1 < 0 || p1 != p2 {							// when ports differ we'll create/find the vlink between them	(in Tegu-lite port == -128 is legit and will dup)
 				m1 := h1.Get_mac( )
 				m2 := h2.Get_mac( )
 
@@ -468,7 +627,23 @@ func (n *Network) find_paths( h1nm *string, h2nm *string, usr *string, commence
 					net_sheep.Baa( 1, "find_paths: find_relaxed failed: %s", err )
 				}
 			} else {
-				if find_all {																		// find all possible paths not just shortest
+				if protect != gizmos.PROT_NONE {													// disjoint pair; primary and backup
+					var dpaths []*gizmos.Path
+					dpaths, cap_trip = n.find_disjoint_paths( ssw, h1, h2, usr, commence, conclude, inc_cap, fence.Get_limit_max(), protect )
+					if cap_trip {
+						lcap_trip = true
+					}
+					for _, dp := range dpaths {
+						if plidx >= len( path_list ) {
+							net_sheep.Baa( 0,  "CRI: find-path: internal error -- path size > num of links.  [TGUNET006]" )
+							return
+						}
+						dp.Set_extip( extip, ext_flag )
+						path_list[plidx] = dp
+						plidx++
+					}
+					path = nil
+				} else if find_all {																// find all possible paths not just shortest
 					path, err = n.find_all_paths( ssw, h1, h2, usr, commence, conclude, inc_cap, fence.Get_limit_max() )		// find a 'scramble' path
 					if err != nil {
 						net_sheep.Baa( 1, "find_paths: find_all failed: %s", err )
@@ -515,8 +690,10 @@ func (n *Network) find_paths( h1nm *string, h2nm *string, usr *string, commence
 
 	rpath is true if this function is called to build the reverse path.  It is necessary in order to
 	properly set the external ip address flag (src/dest).
+
+	Protect is one of the gizmos PROT_ constants; see find_paths().
 */
-func (n *Network) build_paths( h1nm *string, h2nm *string, commence int64, conclude int64, inc_cap int64, find_all bool, rpath bool ) ( pcount int, path_list []*gizmos.Path, cap_trip bool ) {
+func (n *Network) build_paths( h1nm *string, h2nm *string, commence int64, conclude int64, inc_cap int64, find_all bool, rpath bool, protect int ) ( pcount int, path_list []*gizmos.Path, cap_trip bool ) {
 	var (
 		num int = 0				// must declare num as := assignment doesnt work when ipath[n] is in the list
 		src_flag string = "-S"	// flags that indicate which direction the external address is
@@ -547,7 +724,7 @@ func (n *Network) build_paths( h1nm *string, h2nm *string, commence int64, concl
 		ext_flag = &dst_flag
 	}
 	for i := range pair_list {
-		num, ipaths[i], cap_trip = n.find_paths( pair_list[i].h1, pair_list[i].h2, pair_list[i].usr, commence, conclude, inc_cap, pair_list[i].fip, ext_flag, find_all )	
+		num, ipaths[i], cap_trip = n.find_paths( pair_list[i].h1, pair_list[i].h2, pair_list[i].usr, commence, conclude, inc_cap, pair_list[i].fip, ext_flag, find_all, protect )	
 		if num > 0 {
 			total_paths += num
 			ok_count++
@@ -597,3 +774,163 @@ func (n *Network) build_paths( h1nm *string, h2nm *string, commence int64, concl
 
 	return
 }
+
+/*
+	Modify the bandwidth and/or expiry of an existing bandwidth reservation. The current utilisation
+	of each path in the reservation is released, and then each path is checked to see that it can
+	support the new amount over the new window. If all paths can, the new amount is set on the paths;
+	if any cannot, the original utilisation is restored and an error is returned.  Because this runs
+	in the network manager goroutine the release/check/set sequence is atomic with respect to any
+	other reservation.
+
+	The pledge itself is NOT changed; the caller (res_mgr) must update the pledge only after this
+	returns without error so that the window used here to release the original utilisation is the
+	window that was used to create it.
+*/
+func (n *Network) mod_bw_res( m *Bw_mod, discount int64, mlag_paths bool ) ( err error ) {
+	if m == nil || m.pledge == nil {
+		return fmt.Errorf( "no reservation to modify" )
+	}
+
+	p := m.pledge
+	commence, expiry := p.Get_window( )
+	new_expiry := expiry
+	if m.expiry > 0 {
+		new_expiry = m.expiry
+	}
+
+	bandw_in := p.Get_bandw_in()
+	if m.bandw_in > 0 {
+		bandw_in = m.bandw_in
+	}
+	bandw_out := p.Get_bandw_out()
+	if m.bandw_out > 0 {
+		bandw_out = m.bandw_out
+	}
+	bandw_in, bandw_out = discount_bw( bandw_in, bandw_out, discount )
+
+	net_sheep.Baa( 1, "network: modifying bandwidth reservation: %s  expiry %d -> %d  bw in=%d out=%d", *p.Get_id(), expiry, new_expiry, bandw_in, bandw_out )
+
+	qid := p.Get_qid()
+	path_list := p.Get_path_list( )
+	fences := make( []*gizmos.Fence, len( path_list ) )
+	old_amt := make( []int64, len( path_list ) )
+	new_amt := make( []int64, len( path_list ) )
+
+	for i := range path_list {											// release what the reservation has now
+		fences[i] = n.get_fence( path_list[i].Get_usr() )
+		old_amt[i] = path_list[i].Get_bandwidth()
+		if path_list[i].Is_inbound() {
+			new_amt[i] = bandw_in
+		} else {
+			new_amt[i] = bandw_out
+		}
+
+		path_list[i].Set_queue( qid, commence, expiry, -old_amt[i], fences[i] )
+		if mlag_paths {
+			path_list[i].Inc_mlag( commence, expiry, -old_amt[i], fences[i], n.mlags )
+		}
+	}
+
+	if ! n.relaxed {
+		for i := range path_list {
+			if able, cerr := path_list[i].Has_capacity( commence, new_expiry, new_amt[i], fences[i].Name, fences[i].Get_limit_max() ); ! able {
+				if cerr == nil {
+					cerr = fmt.Errorf( "path %d cannot support %d", i, new_amt[i] )
+				}
+				err = fmt.Errorf( "unable to modify reservation: no capacity: %s", cerr )
+				break
+			}
+		}
+	}
+
+	if err != nil {														// put things back as they were
+		net_sheep.Baa( 1, "network: modification of %s rejected, original reservation restored: %s", *p.Get_id(), err )
+		new_amt = old_amt
+		new_expiry = expiry
+	}
+
+	for i := range path_list {
+		path_list[i].Set_queue( qid, commence, new_expiry, new_amt[i], fences[i] )
+		path_list[i].Set_bandwidth( new_amt[i] )
+		if mlag_paths {
+			path_list[i].Inc_mlag( commence, new_expiry, new_amt[i], fences[i], n.mlags )
+		}
+	}
+
+	return
+}
+
+/*
+	Search for the earliest start time(s) at which the reservation described by the slot request
+	would be admitted.  The links which might be used are collected by finding all paths between
+	the hosts (in both directions) ignoring capacity and drains; the allocation on those links can
+	change only at the start of one of their time slices, or when a drain starts or ends, so the
+	only start times that need to be tried are the requested commence time and each of these
+	boundaries up to the horizon. Each of these is tried,
+	in order, with the same path finding used for a reservation until max start times are found.
+	Nothing is reserved.
+*/
+func (n *Network) find_slots( sr *Slot_req, discount int64, find_all bool ) ( slots []int64, err error ) {
+	if sr == nil || sr.h1 == nil || sr.h2 == nil {
+		return nil, fmt.Errorf( "no hosts given" )
+	}
+	if sr.duration < 1 || sr.horizon < sr.commence {
+		return nil, fmt.Errorf( "invalid duration or search window" )
+	}
+
+	ip1, err := n.name2ip( sr.h1 )
+	if err != nil {
+		return nil, fmt.Errorf( "unable to map host name to a known IP address: %s", err )
+	}
+	ip2, err := n.name2ip( sr.h2 )
+	if err != nil {
+		return nil, fmt.Errorf( "unable to map host name to a known IP address: %s", err )
+	}
+
+	bandw_in, bandw_out := discount_bw( sr.bandw_in, sr.bandw_out, discount )
+	net_sheep.Baa( 1, "network: searching for slot: %s -> %s  %ds  between %d and %d", *sr.h1, *sr.h2, sr.duration, sr.commence, sr.horizon )
+
+	if n.relaxed {											// no admission control; it fits right away
+		return []int64 { sr.commence }, nil
+	}
+
+	conclude := sr.horizon + sr.duration
+	for _, l := range n.links {								// a link drained for part of the window may be usable later
+		l.Set_drain_check( false )
+	}
+	nout, out_paths, _ := n.build_paths( ip1, ip2, sr.commence, conclude, 0, true, false, gizmos.PROT_NONE )		// every link that any path might use
+	nin, in_paths, _ := n.build_paths( ip2, ip1, sr.commence, conclude, 0, true, true, gizmos.PROT_NONE )
+	for _, l := range n.links {
+		l.Set_drain_check( true )
+	}
+	if nout <= 0 || nin <= 0 {
+		return nil, fmt.Errorf( "no path between hosts" )
+	}
+
+	seen := make( map[int64]bool )
+	cand := []int64 { sr.commence }
+	for _, p := range append( out_paths[:nout], in_paths[:nin]... ) {
+		for _, t := range p.Get_boundaries( sr.commence, sr.horizon ) {
+			if ! seen[t] {
+				seen[t] = true
+				cand = append( cand, t )
+			}
+		}
+	}
+	sort.Slice( cand, func( i, j int ) bool { return cand[i] < cand[j] } )
+
+	for _, t := range cand {
+		if nout, _, _ = n.build_paths( ip1, ip2, t, t + sr.duration, bandw_out, find_all, false, gizmos.PROT_NONE ); nout > 0 {
+			if nin, _, _ = n.build_paths( ip2, ip1, t, t + sr.duration, bandw_in, find_all, true, gizmos.PROT_NONE ); nin > 0 {
+				slots = append( slots, t )
+				if len( slots ) >= sr.max {
+					break
+				}
+			}
+		}
+	}
+
+	net_sheep.Baa( 1, "network: slot search tried %d start times, found %d", len( cand ), len( slots ) )
+	return slots, nil
+}
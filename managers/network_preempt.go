@@ -0,0 +1,237 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	network_preempt
+	Abstract:	Network manager functions which support priority preemption. The network keeps
+				a reference to each bandwidth and oneway pledge that it has allocated capacity for
+				(keyed by queue id) so that when a reservation with a priority cannot be satisfied
+				it can determine which lower priority reservations would need to be removed to make
+				room.  The network does not remove them; the set is returned to the requestor (as a
+				Preempt_error) which asks res_mgr to yank them and then admit the new reservation.
+
+				The set is minimal in the sense that no reservation in it can be left in place:
+				candidates are released lowest priority (and largest bandwidth) first until the new
+				reservation fits, and then each released reservation is put back if the new one
+				still fits without its capacity.
+
+	Date:		17 October 2026
+
+	Mods:		17 Oct 2026 - Adjust mlag utilisation along with the links when releasing candidates.
+*/
+
+package managers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/att/tegu/gizmos"
+)
+
+/*
+	Returned as the state on a bandwidth reserve request when there is not enough capacity,
+	but there would be if the listed (lower priority) reservations were removed.
+*/
+type Preempt_error struct {
+	victims		[]*string			// names of the reservations which must be removed
+}
+
+/*
+	Error interface.
+*/
+func (pe *Preempt_error) Error( ) ( string ) {
+	names := make( []string, len( pe.victims ) )
+	for i, v := range pe.victims {
+		names[i] = *v
+	}
+
+	return fmt.Sprintf( "unable to generate a path: no capacity unless %d lower priority reservations are preempted: %s", len( names ), strings.Join( names, " " ) )
+}
+
+/*
+	Return the names of the reservations which must be preempted.
+*/
+func (pe *Preempt_error) Get_victims( ) ( []*string ) {
+	return pe.victims
+}
+
+/*
+	Remember that capacity has been allocated to the pledge.
+*/
+func (n *Network) admit_pledge( qid *string, p gizmos.Pledge ) {
+	if qid == nil {
+		return
+	}
+	if n.admitted == nil {
+		n.admitted = make( map[string]gizmos.Pledge )
+	}
+
+	n.admitted[*qid] = p
+}
+
+/*
+	Forget the pledge which was allocated the queue; called when the utilisation is released.
+*/
+func (n *Network) release_pledge( qid *string ) {
+	if qid != nil && n.admitted != nil {
+		delete( n.admitted, *qid )
+	}
+}
+
+/*
+	Drop references to pledges which have expired.
+*/
+func (n *Network) prune_admitted( ) {
+	for qid, p := range n.admitted {
+		if p.Is_expired( ) {
+			delete( n.admitted, qid )
+		}
+	}
+}
+
+/*
+	Add (sign == 1) or remove (sign == -1) the utilisation of an admitted pledge. Used to
+	temporarily release a candidate's capacity while looking for a preemption set. If mlag_paths
+	is true the usage of the mlag members was bumped when the pledge was admitted, and is
+	adjusted too.
+*/
+func (n *Network) adjust_utilisation( p gizmos.Pledge, sign int64, mlag_paths bool ) {
+	switch sp := p.(type) {
+		case *gizmos.Pledge_bw:
+			commence, expiry := sp.Get_window( )
+			qid := sp.Get_qid( )
+			for _, path := range sp.Get_path_list( ) {
+				fence := n.get_fence( path.Get_usr() )
+				path.Set_queue( qid, commence, expiry, sign * path.Get_bandwidth(), fence )
+				if mlag_paths {
+					path.Inc_mlag( commence, expiry, sign * path.Get_bandwidth(), fence, n.mlags )
+				}
+			}
+
+		case *gizmos.Pledge_bwow:
+			commence, expiry := sp.Get_window( )
+			if gate := sp.Get_gate(); gate != nil {
+				gate.Set_queue( sp.Get_qid(), commence, expiry, sign * sp.Get_bandwidth(), n.get_fence( gate.Get_usr() ) )
+			}
+	}
+}
+
+/*
+	Return the bandwidth that a pledge has reserved; used to release the biggest candidates first.
+*/
+func pledge_bandw( p gizmos.Pledge ) ( int64 ) {
+	switch sp := p.(type) {
+		case *gizmos.Pledge_bw:
+			return sp.Get_bandw_in() + sp.Get_bandw_out()
+
+		case *gizmos.Pledge_bwow:
+			return sp.Get_bandwidth()
+	}
+
+	return 0
+}
+
+/*
+	Determine the minimal set of lower priority reservations which, if removed, would allow
+	a path to be found for the bandwidth pledge. Ip1 and ip2 are the pledge's hosts already
+	translated, and the bandwidth values have been discounted. The network is left unchanged.
+	Nil is returned if the pledge has no priority or if removing every lower priority reservation
+	would not make enough room.
+*/
+func (n *Network) find_preempt( p *gizmos.Pledge_bw, ip1 *string, ip2 *string, bandw_in int64, bandw_out int64, find_all bool, mlag_paths bool ) ( victims []*string ) {
+	var (
+		cands	[]gizmos.Pledge
+		chosen	[]gizmos.Pledge
+	)
+
+	pri := p.Get_priority( )
+	if pri <= 0 {
+		return nil
+	}
+
+	n.prune_admitted( )
+	commence, expiry := p.Get_window( )
+	for _, c := range n.admitted {
+		cc, ce := c.Get_window( )
+		if c.Get_priority( ) < pri && cc <= expiry && ce >= commence {		// lower priority and overlaps the window
+			cands = append( cands, c )
+		}
+	}
+	if len( cands ) == 0 {
+		return nil
+	}
+
+	sort.Slice( cands, func( i, j int ) bool {
+		if cands[i].Get_priority() != cands[j].Get_priority() {
+			return cands[i].Get_priority() < cands[j].Get_priority()
+		}
+		if pledge_bandw( cands[i] ) != pledge_bandw( cands[j] ) {
+			return pledge_bandw( cands[i] ) > pledge_bandw( cands[j] )
+		}
+		return *cands[i].Get_id() < *cands[j].Get_id()					// stable results
+	} )
+
+	fits := func( ) ( bool ) {
+		nout, _, _ := n.build_paths( ip1, ip2, commence, expiry, bandw_out, find_all, false, p.Get_protect() )
+		if nout <= 0 {
+			return false
+		}
+		nin, _, _ := n.build_paths( ip2, ip1, commence, expiry, bandw_in, find_all, true, p.Get_protect() )
+		return nin > 0
+	}
+
+	ok := false
+	for _, c := range cands {								// release until it fits
+		n.adjust_utilisation( c, -1, mlag_paths )
+		chosen = append( chosen, c )
+		if fits( ) {
+			ok = true
+			break
+		}
+	}
+
+	if ok {
+		var keep []gizmos.Pledge
+		for i := len( chosen ) - 1; i >= 0; i-- {			// put back any that aren't needed, last released first
+			n.adjust_utilisation( chosen[i], 1, mlag_paths )
+			if fits( ) {
+				continue
+			}
+			n.adjust_utilisation( chosen[i], -1, mlag_paths )
+			keep = append( keep, chosen[i] )
+		}
+		chosen = keep
+	}
+
+	for _, c := range chosen {								// leave the network as we found it
+		n.adjust_utilisation( c, 1, mlag_paths )
+		if ok {
+			victims = append( victims, c.Get_id() )
+		}
+	}
+
+	if ok {
+		net_sheep.Baa( 1, "preemption of %d reservation(s) would allow %s (priority %d) to be admitted", len( victims ), *p.Get_id(), pri )
+	}
+	return
+}
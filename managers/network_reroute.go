@@ -0,0 +1,376 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	network_reroute
+	Abstract:	Network manager functions which move admitted bandwidth reservations off of links
+				which have left the network, or whose capacity was reduced below what has been
+				promised, when the graph is rebuilt.  The capacity of each link in the graph is
+				captured before the rebuild and compared with the new graph; any admitted
+				reservation with a path that uses a link which is gone, or a reduced link which
+				can no longer support its obligations during the reservation's window, is given
+				new paths (found with the same path finding used to admit it). The capacity is
+				moved to the new paths, and res_mgr is sent the report (REQ_REROUTE) which carries
+				the new path lists; res_mgr owns the pledges, so it attaches the paths and then
+				pushes flow-mods for the moved reservations.  A reservation that cannot be moved
+				is left on its old paths and listed in the report as failed. Oneway reservations
+				are not moved; if a link used by the gate is affected they are listed as failed.
+
+	Date:		17 October 2026
+
+	Mods:		17 Oct 2026 - Pass mlag_paths through so mlag usage is released with the old paths.
+				17 Oct 2026 - Bump mlag usage for the new paths.
+				17 Oct 2026 - New paths are passed to res_mgr in the report rather than set on the
+					pledge here (pledges belong to res_mgr). Affected oneway reservations are reported.
+				17 Oct 2026 - Added caps_grew() so that added link capacity retries the waitlist.
+*/
+
+package managers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/att/tegu/gizmos"
+)
+
+/*
+	The results of an attempt to reroute reservations.
+*/
+type Reroute_rpt struct {
+	moved	[]*string				// reservations given new paths
+	paths	map[string][]*gizmos.Path	// the new paths for each moved reservation
+	failed	[]*string				// reservations which could not be moved
+	reasons	map[string]string		// reason for each failure
+}
+
+/*
+	Create an empty report.
+*/
+func Mk_reroute_rpt( ) ( *Reroute_rpt ) {
+	return &Reroute_rpt {
+		paths:		make( map[string][]*gizmos.Path ),
+		reasons:	make( map[string]string ),
+	}
+}
+
+/*
+	Return the names of the reservations which were moved.
+*/
+func (rr *Reroute_rpt) Get_moved( ) ( []*string ) {
+	return rr.moved
+}
+
+/*
+	Return the new path list for a moved reservation; nil if it wasn't moved.
+*/
+func (rr *Reroute_rpt) Get_paths( name *string ) ( []*gizmos.Path ) {
+	if rr == nil || name == nil {
+		return nil
+	}
+
+	return rr.paths[*name]
+}
+
+/*
+	Add a reservation which could not be moved to the report.
+*/
+func (rr *Reroute_rpt) add_failed( id *string, reason string ) {
+	rr.failed = append( rr.failed, id )
+	rr.reasons[*id] = reason
+}
+
+/*
+	Return the names of the reservations which could not be moved.
+*/
+func (rr *Reroute_rpt) Get_failed( ) ( []*string ) {
+	return rr.failed
+}
+
+/*
+	Return true if the report lists anything.
+*/
+func (rr *Reroute_rpt) Has_entries( ) ( bool ) {
+	return rr != nil && (len( rr.moved ) > 0 || len( rr.failed ) > 0)
+}
+
+/*
+	Generate a json representation of the report.
+*/
+func (rr *Reroute_rpt) To_json( ) ( string ) {
+	if rr == nil {
+		return `{ "moved": [], "failed": [] }`
+	}
+
+	moved := make( []string, len( rr.moved ) )
+	for i, name := range rr.moved {
+		moved[i] = fmt.Sprintf( "%q", *name )
+	}
+	failed := make( []string, len( rr.failed ) )
+	for i, name := range rr.failed {
+		failed[i] = fmt.Sprintf( `{ "name": %q, "reason": %q }`, *name, rr.reasons[*name] )
+	}
+
+	return fmt.Sprintf( `{ "moved": [ %s ], "failed": [ %s ] }`, strings.Join( moved, ", " ), strings.Join( failed, ", " ) )
+}
+
+/*
+	Return a map of the current capacity of each link in the graph. Links in the links map
+	are kept across rebuilds, so only those attached to a switch in this graph are included.
+*/
+func (n *Network) link_caps( ) ( caps map[*gizmos.Link]int64 ) {
+	caps = make( map[*gizmos.Link]int64 )
+	for _, sw := range n.switches {
+		for i := 0; ; i++ {
+			l := sw.Get_link( i )
+			if l == nil {
+				break
+			}
+			caps[l] = l.Get_allotment().Get_max_capacity()
+		}
+	}
+
+	return
+}
+
+/*
+	Compare the link capacities captured from the previous graph with the links in this graph.
+	The returned map contains the links which were removed (true) and the links whose capacity
+	was reduced (false).
+*/
+func (n *Network) changed_links( ocaps map[*gizmos.Link]int64 ) ( changed map[*gizmos.Link]bool ) {
+	changed = make( map[*gizmos.Link]bool )
+	ncaps := n.link_caps( )
+	for l, ocap := range ocaps {
+		ncap, ok := ncaps[l]
+		switch {
+			case ! ok:
+				net_sheep.Baa( 1, "reroute: link is no longer in the network: %s", *l.Get_id() )
+				changed[l] = true
+
+			case ncap < ocap:
+				net_sheep.Baa( 1, "reroute: link capacity was reduced: %s %d -> %d", *l.Get_id(), ocap, ncap )
+				changed[l] = false
+		}
+	}
+
+	return
+}
+
+/*
+	Return true if a link in this graph is new, or has more capacity than it had when the
+	capacities were captured from the previous graph.
+*/
+func (n *Network) caps_grew( ocaps map[*gizmos.Link]int64 ) ( bool ) {
+	for l, ncap := range n.link_caps( ) {
+		if ocap, ok := ocaps[l]; ! ok || ncap > ocap {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+	Return true if a path in the list uses a link in the changed map which either has been removed,
+	or cannot support what has been promised on it between commence and expiry.
+*/
+func paths_affected( plist []*gizmos.Path, changed map[*gizmos.Link]bool, commence int64, expiry int64 ) ( bool ) {
+	for _, path := range plist {
+		for _, l := range path.Get_links() {
+			if gone, ok := changed[l]; ok {
+				if gone {
+					return true
+				}
+				if able, _ := l.Has_capacity( commence, expiry, 0, nil, 100 ); ! able {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+/*
+	Return true if a link used by the gate of a oneway reservation is affected (see paths_affected).
+*/
+func gate_affected( g *gizmos.Gate, changed map[*gizmos.Link]bool, commence int64, expiry int64 ) ( bool ) {
+	if g == nil {
+		return false
+	}
+
+	for _, l := range g.Get_links() {
+		if gone, ok := changed[l]; ok {
+			if gone {
+				return true
+			}
+			if able, _ := l.Has_capacity( commence, expiry, 0, nil, 100 ); ! able {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+/*
+	Find new paths for the bandwidth pledge and move its capacity to them.  The bandwidth values
+	are discounted as they were when the pledge was admitted. If new paths in both directions
+	cannot be found the pledge's capacity is restored on its old paths and an error is returned.
+	When mlag_paths is true the usage of the mlag members is moved along with the capacity.
+	The new path list is returned; it is NOT set on the pledge as res_mgr owns it.
+*/
+func (n *Network) repath( p *gizmos.Pledge_bw, discount int64, find_all bool, mlag_paths bool ) ( plist []*gizmos.Path, err error ) {
+	h1, h2, _, _, commence, expiry, bandw_in, bandw_out := p.Get_values( )
+	ip1, err := n.name2ip( h1 )
+	if err != nil {
+		return
+	}
+	ip2, err := n.name2ip( h2 )
+	if err != nil {
+		return
+	}
+
+	start := time.Now().Unix()							// only the remainder of the window matters
+	if commence > start {
+		start = commence
+	}
+
+	bandw_in, bandw_out = discount_bw( bandw_in, bandw_out, discount )
+	n.adjust_utilisation( p, -1, mlag_paths )						// release what is held on the old paths before looking
+
+	nout, out_paths, o_cap_trip := n.build_paths( ip1, ip2, start, expiry, bandw_out, find_all, false, p.Get_protect() )
+	nin, in_paths, i_cap_trip := n.build_paths( ip2, ip1, start, expiry, bandw_in, find_all, true, p.Get_protect() )
+	if nout <= 0 || nin <= 0 {
+		n.adjust_utilisation( p, 1, mlag_paths )						// put it back; it's not going anywhere
+		if i_cap_trip || o_cap_trip {
+			return nil, fmt.Errorf( "no path with enough capacity" )
+		}
+		return nil, fmt.Errorf( "no path" )
+	}
+
+	plist = make( []*gizmos.Path, 0, nout + nin )
+	plist = append( plist, out_paths[0:nout]... )
+	plist = append( plist, in_paths[0:nin]... )
+
+	qid := p.Get_qid( )
+	for _, path := range plist {
+		fence := n.get_fence( path.Get_usr() )
+		path.Set_queue( qid, commence, expiry, path.Get_bandwidth(), fence )
+		if mlag_paths {
+			path.Inc_mlag( commence, expiry, path.Get_bandwidth(), fence, n.mlags )		// as when admitted, so the release on a later move balances
+		}
+	}
+
+	return
+}
+
+/*
+	Attempt to move each admitted bandwidth reservation with a path affected by the changed links
+	(see changed_links()) onto new paths. Oneway reservations whose gate uses an affected link
+	are listed as failed.
+*/
+func (n *Network) reroute( changed map[*gizmos.Link]bool, discount int64, find_all bool, mlag_paths bool ) ( rpt *Reroute_rpt ) {
+	var (
+		cands	[]*gizmos.Pledge_bw
+		ow		[]*gizmos.Pledge_bwow
+	)
+
+	if len( changed ) == 0 {
+		return Mk_reroute_rpt( )
+	}
+
+	now := time.Now().Unix()
+	for _, p := range n.admitted {
+		switch bp := p.( type ) {
+			case *gizmos.Pledge_bw:
+				if bp.Is_expired() {
+					continue
+				}
+				commence, expiry := bp.Get_window( )
+				if commence < now {
+					commence = now
+				}
+				if paths_affected( bp.Get_path_list(), changed, commence, expiry ) {
+					cands = append( cands, bp )
+				}
+
+			case *gizmos.Pledge_bwow:
+				if bp.Is_expired() {
+					continue
+				}
+				commence, expiry := bp.Get_window( )
+				if commence < now {
+					commence = now
+				}
+				if gate_affected( bp.Get_gate(), changed, commence, expiry ) {
+					ow = append( ow, bp )
+				}
+		}
+	}
+
+	rpt = n.reroute_list( cands, discount, find_all, mlag_paths )
+	fail_oneway( rpt, ow, "a link used by the oneway reservation's gate was removed or lost capacity; oneway reservations are not rerouted" )
+	return
+}
+
+/*
+	List each oneway reservation as failed in the report.
+*/
+func fail_oneway( rpt *Reroute_rpt, ow []*gizmos.Pledge_bwow, reason string ) {
+	sort.Slice( ow, func( i, j int ) bool { return *ow[i].Get_id() < *ow[j].Get_id() } )
+	for _, p := range ow {
+		net_sheep.Baa( 0, "WRN: reroute: oneway reservation %s could not be moved: %s  [TGUNET013]", *p.Get_id(), reason )
+		rpt.add_failed( p.Get_id(), reason )
+	}
+}
+
+/*
+	Attempt to move each pledge in the list to new paths, highest priority first so that they
+	have the first chance at any remaining capacity.
+*/
+func (n *Network) reroute_list( cands []*gizmos.Pledge_bw, discount int64, find_all bool, mlag_paths bool ) ( rpt *Reroute_rpt ) {
+	rpt = Mk_reroute_rpt( )
+
+	sort.Slice( cands, func( i, j int ) bool {
+		if cands[i].Get_priority() != cands[j].Get_priority() {
+			return cands[i].Get_priority() > cands[j].Get_priority()
+		}
+		return *cands[i].Get_id() < *cands[j].Get_id()
+	} )
+
+	for _, p := range cands {
+		id := p.Get_id()
+		if plist, err := n.repath( p, discount, find_all, mlag_paths ); err != nil {
+			net_sheep.Baa( 0, "WRN: reroute: reservation %s could not be moved to new paths: %s  [TGUNET013]", *id, err )
+			rpt.add_failed( id, err.Error() )
+		} else {
+			net_sheep.Baa( 1, "reroute: reservation %s moved to %d new path(s)", *id, len( plist ) )
+			rpt.moved = append( rpt.moved, id )
+			rpt.paths[*id] = plist
+		}
+	}
+
+	return
+}
@@ -0,0 +1,400 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	network_sim
+	Abstract:	What-if simulation of capacity changes. The current graph is copied (switches,
+				hosts, links, mlags and user fences) with empty obligations, a list of hypothetical
+				changes (link removal, link capacity change, new link) is applied to the copy,
+				and then every admitted bandwidth and oneway reservation is admitted again on the
+				copy, highest priority first, followed by a batch of hypothetical reservations. The
+				result lists the reservations which could not be admitted and the peak utilisation
+				of each link, busiest first. The live graph is not changed.
+
+				Oneway reservations are admitted through a gate on the source host's switch, as
+				they are on the live graph, and so use capacity on that switch's links.
+
+	Date:		17 October 2026
+
+	Mods:		17 Oct 2026 - Replay oneway reservations too.
+*/
+
+package managers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/att/tegu/gizmos"
+)
+
+const (
+	SIM_RMLINK	int = iota			// remove a link (both directions)
+	SIM_SETCAP						// change a link's capacity (both directions)
+	SIM_ADDLINK						// add a link between two switches (both directions)
+)
+
+/*
+	A single hypothetical change.
+*/
+type Sim_change struct {
+	kind		int
+	link		*string				// link id for rmlink and setcap
+	sw1			*string				// switches for addlink
+	sw2			*string
+	capacity	int64
+}
+
+/*
+	Passed to the network on a REQ_SIMULATE request.
+*/
+type Sim_req struct {
+	changes		[]*Sim_change
+	pledges		[]*gizmos.Pledge_bw		// hypothetical reservations admitted after the current ones
+}
+
+/*
+	Create an empty simulation request.
+*/
+func Mk_sim_req( ) ( *Sim_req ) {
+	return &Sim_req { }
+}
+
+/*
+	Add the removal of a link to the request.
+*/
+func (sr *Sim_req) Add_rmlink( id *string ) {
+	sr.changes = append( sr.changes, &Sim_change{ kind: SIM_RMLINK, link: id } )
+}
+
+/*
+	Add a link capacity change to the request.
+*/
+func (sr *Sim_req) Add_setcap( id *string, capacity int64 ) {
+	sr.changes = append( sr.changes, &Sim_change{ kind: SIM_SETCAP, link: id, capacity: capacity } )
+}
+
+/*
+	Add a new link to the request.
+*/
+func (sr *Sim_req) Add_link( sw1 *string, sw2 *string, capacity int64 ) {
+	sr.changes = append( sr.changes, &Sim_change{ kind: SIM_ADDLINK, sw1: sw1, sw2: sw2, capacity: capacity } )
+}
+
+/*
+	Add a hypothetical reservation to the request.
+*/
+func (sr *Sim_req) Add_pledge( p *gizmos.Pledge_bw ) {
+	sr.pledges = append( sr.pledges, p )
+}
+
+/*
+	Build a copy of the network for simulation. Links in skip are left out.  Obligations are
+	empty (links which share an obligation in the live graph share one in the copy), the
+	translation maps are shared (they are only read), and user fences are copied.
+*/
+func (n *Network) clone_sim( skip map[*gizmos.Link]bool ) ( sn *Network ) {
+	sn = mk_network( true )
+	sn.xfer_maps( n )
+	sn.admitted = make( map[string]gizmos.Pledge )
+	sn.limits = make( map[string]*gizmos.Fence )
+	for k, f := range n.limits {
+		sn.limits[k] = f.Copy( f.Name )
+	}
+
+	swmap := make( map[*gizmos.Switch]*gizmos.Switch )
+	for id, sw := range n.switches {
+		nsw := gizmos.Mk_switch( sw.Get_id() )
+		sn.switches[id] = nsw
+		swmap[sw] = nsw
+	}
+
+	obmap := make( map[*gizmos.Obligation]*gizmos.Obligation )
+	for _, sw := range n.switches {
+		for i := 0; ; i++ {
+			l := sw.Get_link( i )
+			if l == nil {
+				break
+			}
+			if skip[l] {
+				continue
+			}
+
+			ob := obmap[l.Get_allotment()]
+			if ob == nil {
+				ob = l.Get_allotment().Clone_empty( )
+				obmap[l.Get_allotment()] = ob
+			}
+
+			nl := l.Clone( swmap[l.Get_forward_sw()], swmap[l.Get_backward_sw()], ob )
+			swmap[sw].Add_link( nl )
+			sn.links[*nl.Get_id()] = nl
+
+			if m := nl.Get_mlag(); m != nil {
+				if sn.mlags[*m] == nil {
+					sn.mlags[*m] = gizmos.Mk_mlag( m, ob )
+				} else {
+					sn.mlags[*m].Add_link( ob )
+				}
+			}
+		}
+	}
+
+	hmap := make( map[*gizmos.Host]*gizmos.Host )				// hosts are referenced by mac and ip, copy each just once
+	for key, h := range n.hosts {
+		nh := hmap[h]
+		if nh == nil {
+			nh = h.Clone( swmap )
+			hmap[h] = nh
+		}
+		sn.hosts[key] = nh
+	}
+
+	return
+}
+
+/*
+	Apply a capacity change or new link to the simulation network.
+*/
+func (sn *Network) sim_apply( c *Sim_change ) ( err error ) {
+	switch c.kind {
+		case SIM_SETCAP:
+			links, err := sn.link_pair( c.link )
+			if err != nil {
+				return err
+			}
+			for _, l := range links {
+				l.Mod_capacity( c.capacity )
+			}
+
+		case SIM_ADDLINK:
+			for _, pr := range [][2]*string{ { c.sw1, c.sw2 }, { c.sw2, c.sw1 } } {
+				ssw := sn.switches[*pr[0]]
+				if ssw == nil {
+					ssw = gizmos.Mk_switch( pr[0] )
+					sn.switches[*pr[0]] = ssw
+				}
+				dsw := sn.switches[*pr[1]]
+				if dsw == nil {
+					dsw = gizmos.Mk_switch( pr[1] )
+					sn.switches[*pr[1]] = dsw
+				}
+
+				l := sn.find_link( *pr[0], *pr[1], c.capacity, 0, nil )
+				l.Set_forward( dsw )
+				l.Set_backward( ssw )
+				ssw.Add_link( l )
+			}
+
+		case SIM_RMLINK:								// applied when the network is copied
+
+		default:
+			err = fmt.Errorf( "unknown simulation change: %d", c.kind )
+	}
+
+	return
+}
+
+/*
+	Admit the reservation on the simulation network for the remainder of its window.
+*/
+func (sn *Network) sim_admit( p *gizmos.Pledge_bw, discount int64, find_all bool, mlag_paths bool ) ( err error ) {
+	h1, h2, _, _, commence, expiry, bandw_in, bandw_out := p.Get_values( )
+	ip1, err := sn.name2ip( h1 )
+	if err != nil {
+		return
+	}
+	ip2, err := sn.name2ip( h2 )
+	if err != nil {
+		return
+	}
+
+	if now := time.Now().Unix(); commence < now {
+		commence = now
+	}
+
+	bandw_in, bandw_out = discount_bw( bandw_in, bandw_out, discount )
+	nout, out_paths, o_cap_trip := sn.build_paths( ip1, ip2, commence, expiry, bandw_out, find_all, false, p.Get_protect() )
+	nin, in_paths, i_cap_trip := sn.build_paths( ip2, ip1, commence, expiry, bandw_in, find_all, true, p.Get_protect() )
+	if nout <= 0 || nin <= 0 {
+		if i_cap_trip || o_cap_trip {
+			return fmt.Errorf( "no path with enough capacity" )
+		}
+		return fmt.Errorf( "no path" )
+	}
+
+	plist := append( out_paths[0:nout], in_paths[0:nin]... )
+	for _, path := range plist {
+		fence := sn.get_fence( path.Get_usr() )
+		path.Set_queue( p.Get_id(), commence, expiry, path.Get_bandwidth(), fence )
+		if mlag_paths {
+			path.Inc_mlag( commence, expiry, path.Get_bandwidth(), fence, sn.mlags )
+		}
+	}
+
+	return
+}
+
+/*
+	Admit the oneway reservation on the simulation network for the remainder of its window. A
+	gate is created on the simulation network in the same way as the network manager does
+	for a live reservation.
+*/
+func (sn *Network) sim_admit_ow( p *gizmos.Pledge_bwow ) ( err error ) {
+	src, dest := p.Get_hosts( )
+	ips, err := sn.name2ip( src )
+	if err != nil {
+		return
+	}
+	sh := sn.hosts[*ips]
+	if sh == nil {
+		return fmt.Errorf( "source host not in the network" )
+	}
+	var dh *gizmos.Host
+	if ipd, derr := sn.name2ip( dest ); derr == nil && ipd != nil {
+		dh = sn.hosts[*ipd]											// nil for an external address
+	}
+
+	usr := "nobody"
+	if toks := strings.SplitN( *src, "/", 2 ); len( toks ) > 1 {
+		usr = toks[0]
+	}
+
+	commence, expiry := p.Get_window( )
+	if now := time.Now().Unix(); commence < now {
+		commence = now
+	}
+
+	ssw, _ := sh.Get_switch_port( 0 )
+	gate := gizmos.Mk_gate( sh, dh, ssw, p.Get_bandwidth(), usr )
+	fence := sn.get_fence( &usr )
+	if ! gate.Has_capacity( commence, expiry, p.Get_bandwidth(), &usr, fence.Get_limit_max() ) {
+		return fmt.Errorf( "no capacity on (v)switch: %s", gizmos.Safe_string( gate.Get_sw_name() ) )
+	}
+	if ! gate.Add_queue( commence, expiry, p.Get_bandwidth(), p.Get_id(), fence ) {
+		return fmt.Errorf( "unable to set queue on (v)switch: %s", gizmos.Safe_string( gate.Get_sw_name() ) )
+	}
+
+	return
+}
+
+/*
+	Run the simulation and return the results as json. The live network is not changed.
+*/
+func (n *Network) simulate( sr *Sim_req, discount int64, find_all bool, mlag_paths bool ) ( jstr string, err error ) {
+	var (
+		replay	[]gizmos.Pledge
+		failed	[]string
+	)
+
+	if sr == nil {
+		return "", fmt.Errorf( "no simulation request" )
+	}
+
+	skip := make( map[*gizmos.Link]bool )
+	for _, c := range sr.changes {
+		if c.kind == SIM_RMLINK {
+			links, err := n.link_pair( c.link )
+			if err != nil {
+				return "", err
+			}
+			for _, l := range links {
+				skip[l] = true
+			}
+		}
+	}
+
+	sn := n.clone_sim( skip )
+	for _, c := range sr.changes {
+		if err = sn.sim_apply( c ); err != nil {
+			return
+		}
+	}
+
+	for _, p := range n.admitted {
+		switch p.(type) {
+			case *gizmos.Pledge_bw, *gizmos.Pledge_bwow:
+				if ! p.Is_expired() {
+					replay = append( replay, p )
+				}
+		}
+	}
+	sort.Slice( replay, func( i, j int ) bool {
+		if replay[i].Get_priority() != replay[j].Get_priority() {
+			return replay[i].Get_priority() > replay[j].Get_priority()
+		}
+		return *replay[i].Get_id() < *replay[j].Get_id()
+	} )
+
+	nrep := len( replay )
+	for _, p := range sr.pledges {									// hypothetical reservations go last
+		replay = append( replay, p )
+	}
+	for i, p := range replay {
+		var err error
+
+		switch sp := p.(type) {
+			case *gizmos.Pledge_bw:
+				err = sn.sim_admit( sp, discount, find_all, mlag_paths )
+
+			case *gizmos.Pledge_bwow:
+				err = sn.sim_admit_ow( sp )
+		}
+		if err != nil {
+			h1, h2 := p.Get_hosts( )
+			failed = append( failed, fmt.Sprintf( `{ "name": %q, "host1": %q, "host2": %q, "new": %v, "reason": %q }`, *p.Get_id(), *h1, *h2, i >= nrep, err ) )
+		}
+	}
+
+	type sim_link struct {
+		id			string
+		capacity	int64
+		peak		int64
+		pct			int64
+	}
+	slinks := make( []sim_link, 0, len( sn.links ) )
+	for id, l := range sn.links {
+		ob := l.Get_allotment()
+		sl := sim_link{ id: id, capacity: ob.Get_max_capacity(), peak: ob.Get_max_allocation() }
+		if sl.capacity > 0 {
+			sl.pct = (sl.peak * 100) / sl.capacity
+		}
+		slinks = append( slinks, sl )
+	}
+	sort.Slice( slinks, func( i, j int ) bool {					// hottest links first
+		if slinks[i].pct != slinks[j].pct {
+			return slinks[i].pct > slinks[j].pct
+		}
+		return slinks[i].id < slinks[j].id
+	} )
+
+	llist := make( []string, len( slinks ) )
+	for i, sl := range slinks {
+		llist[i] = fmt.Sprintf( `{ "id": %q, "capacity": %d, "peak": %d, "pctg": %d }`, sl.id, sl.capacity, sl.peak, sl.pct )
+	}
+
+	net_sheep.Baa( 1, "simulate: %d change(s), %d reservation(s) replayed, %d new, %d would fail", len( sr.changes ), nrep, len( sr.pledges ), len( failed ) )
+	jstr = fmt.Sprintf( `{ "changes": %d, "replayed": %d, "new": %d, "failed": [ %s ], "links": [ %s ] }`,
+		len( sr.changes ), nrep, len( sr.pledges ), strings.Join( failed, ", " ), strings.Join( llist, ", " ) )
+	return
+}
@@ -0,0 +1,191 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	network_usage
+	Abstract:	Network manager functions which report link utilisation over a time range.
+				For a link (by id, or all links from one switch to another) the allocation
+				step function is generated from the link's obligation: each step gives the
+				window, the amount allocated, the capacity and the amount used by each user.
+				A network-wide summary lists the links with the highest peak allocation
+				(as a percentage of capacity) during the range.  Output is json or csv.
+
+	Date:		17 October 2026
+
+*/
+
+package managers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/att/tegu/gizmos"
+)
+
+/*
+	Passed to the network on a REQ_LINKUSAGE request. If link, or sw1 and sw2, are nil only
+	the summary is generated.
+*/
+type Usage_req struct {
+	link		*string				// link id
+	sw1			*string				// or all links from sw1 to sw2
+	sw2			*string
+	commence	int64
+	conclude	int64
+	top			int					// number of links in the summary
+	csv			bool				// generate csv rather than json
+}
+
+/*
+	Create a link usage request.
+*/
+func Mk_usage_req( link *string, sw1 *string, sw2 *string, commence int64, conclude int64, top int, csv bool ) ( *Usage_req ) {
+	return &Usage_req {
+		link:		link,
+		sw1:		sw1,
+		sw2:		sw2,
+		commence:	commence,
+		conclude:	conclude,
+		top:		top,
+		csv:		csv,
+	}
+}
+
+/*
+	Summary information for one link.
+*/
+type link_peak struct {
+	id			string
+	capacity	int64
+	peak		int64
+	pct			int64
+}
+
+/*
+	Return the links named by the request, sorted by id.
+*/
+func (n *Network) usage_links( ur *Usage_req ) ( links []*gizmos.Link, err error ) {
+	switch {
+		case ur.link != nil:
+			l := n.links[*ur.link]
+			if l == nil {
+				return nil, fmt.Errorf( "unknown link: %s", *ur.link )
+			}
+			links = append( links, l )
+
+		case ur.sw1 != nil && ur.sw2 != nil:
+			for _, l := range n.links {
+				s1, s2 := l.Get_sw_names( )
+				if *s1 == *ur.sw1 && *s2 == *ur.sw2 {
+					links = append( links, l )
+				}
+			}
+			if len( links ) == 0 {
+				return nil, fmt.Errorf( "no link from %s to %s", *ur.sw1, *ur.sw2 )
+			}
+			sort.Slice( links, func( i, j int ) bool { return *links[i].Get_id() < *links[j].Get_id() } )
+	}
+
+	return
+}
+
+/*
+	Return the top links in the current graph ordered by peak allocation (percentage of capacity)
+	during the window.
+*/
+func (n *Network) hot_links( commence int64, conclude int64, top int ) ( list []*link_peak ) {
+	for l := range n.link_caps( ) {
+		ob := l.Get_allotment( )
+		lp := &link_peak{ id: *l.Get_id(), capacity: ob.Get_max_capacity(), peak: ob.Get_peak( commence, conclude ) }
+		if lp.capacity > 0 {
+			lp.pct = (lp.peak * 100) / lp.capacity
+		}
+		list = append( list, lp )
+	}
+
+	sort.Slice( list, func( i, j int ) bool {
+		if list[i].pct != list[j].pct {
+			return list[i].pct > list[j].pct
+		}
+		return list[i].id < list[j].id
+	} )
+
+	if top > 0 && len( list ) > top {
+		list = list[0:top]
+	}
+
+	return
+}
+
+/*
+	Generate the usage report for the request.
+*/
+func (n *Network) link_usage( ur *Usage_req ) ( rpt string, err error ) {
+	if ur == nil {
+		return "", fmt.Errorf( "no usage request" )
+	}
+	if ur.conclude < ur.commence {
+		return "", fmt.Errorf( "usage window is empty: %d to %d", ur.commence, ur.conclude )
+	}
+
+	links, err := n.usage_links( ur )
+	if err != nil {
+		return
+	}
+	hot := n.hot_links( ur.commence, ur.conclude, ur.top )
+
+	if ur.csv {
+		rpt = "link,commence,conclude,allocated,max_capacity,pctg,users\n"
+		for _, l := range links {
+			for _, u := range l.Get_allotment().Get_usage( ur.commence, ur.conclude ) {
+				rpt += fmt.Sprintf( "%s,%s\n", *l.Get_id(), u.To_csv() )
+			}
+		}
+
+		rpt += "\nlink,max_capacity,peak,pctg\n"
+		for _, lp := range hot {
+			rpt += fmt.Sprintf( "%s,%d,%d,%d\n", lp.id, lp.capacity, lp.peak, lp.pct )
+		}
+
+		return
+	}
+
+	llist := make( []string, len( links ) )
+	for i, l := range links {
+		steps := l.Get_allotment().Get_usage( ur.commence, ur.conclude )
+		slist := make( []string, len( steps ) )
+		for j, u := range steps {
+			slist[j] = u.To_json( )
+		}
+		llist[i] = fmt.Sprintf( `{ "id": %q, "steps": [ %s ] }`, *l.Get_id(), strings.Join( slist, ", " ) )
+	}
+
+	hlist := make( []string, len( hot ) )
+	for i, lp := range hot {
+		hlist[i] = fmt.Sprintf( `{ "id": %q, "max_capacity": %d, "peak": %d, "pctg": %d }`, lp.id, lp.capacity, lp.peak, lp.pct )
+	}
+
+	rpt = fmt.Sprintf( `{ "commence": %d, "conclude": %d, "links": [ %s ], "hottest": [ %s ] }`,
+		ur.commence, ur.conclude, strings.Join( llist, ", " ), strings.Join( hlist, ", " ) )
+	return
+}
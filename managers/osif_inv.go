@@ -0,0 +1,323 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	osif_inv
+	Abstract:	The inventory backend used by the osif manager. The inventory is the source
+				of VM (host) names, addresses, MAC addresses, gateways and physical hosts, and
+				it validates tokens. The osif manager passes each request (REQ_VALIDATE_HOST,
+				REQ_GET_HOSTINFO, REQ_GET_DEFGW, REQ_IP2MACMAP, etc.) to the backend selected
+				by the osif:inventory setting in the config file:
+					openstack	the original openstack (keystone/nova/neutron) interface (default)
+					static		a json file describing projects, hosts and tokens (osif_static.go)
+
+				This file also contains the openstack backend which wraps the functions in
+				osif.go and osif_proj.go.
+
+	Date:		17 October 2026
+
+*/
+
+package managers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/att/gopkgs/clike"
+	"github.com/att/gopkgs/ipc"
+	"github.com/att/gopkgs/ostack"
+)
+
+const (
+	INV_OPENSTACK	string = "openstack"
+	INV_STATIC		string = "static"
+)
+
+/*
+	Functions that an inventory backend must provide. The host, project-hosts and default
+	gateway requests may need to query a remote service; the backend is given the message
+	and must write the response to msg.Response_ch itself, possibly from a go routine.
+*/
+type Host_inventory interface {
+	Refresh( )																// periodic refresh (REQ_GENCREDS)
+	Get_ip2mac( ) ( map[string]*string, error )								// [project/]ip to mac map
+	Get_phosts( ) ( *string, error )										// space separated list of physical hosts
+	Validate_host( raw *string, tok_req bool ) ( *string, error )			// validate/translate [token/][project/]host
+	Validate_admin( token *string ) ( error )								// token must be for the tegu admin
+	Has_any_role( token *string, roles *string ) ( bool, error )			// token[/project] has one of the comma separated roles
+	Pname2id( name *string ) ( *string )									// project name (or id) to id; nil if unknown
+	Get_hostinfo( msg *ipc.Chmsg )											// project/host -> *Net_vm
+	Get_proj_hosts( msg *ipc.Chmsg )										// project (or _all_proj) -> []*Net_vm
+	Get_defgw( msg *ipc.Chmsg )												// project[/junk] -> default gateway
+	String( ) ( string )
+}
+
+/*
+	Create the inventory backend named in the config file. The refresh value returned is the
+	number of seconds between Refresh() calls; zero if the backend doesn't need them.
+*/
+func Mk_host_inventory( ) ( inv Host_inventory, refresh int64, err error ) {
+	kind := INV_OPENSTACK
+	if cfg_data["osif"] != nil {
+		if p := cfg_data["osif"]["inventory"]; p != nil {
+			kind = strings.ToLower( *p )
+		}
+	}
+
+	switch kind {
+		case INV_OPENSTACK:
+			inv, refresh = mk_os_inventory( )
+
+		case INV_STATIC:
+			fname := "/etc/tegu/inventory.json"
+			refresh = 60
+			if p := cfg_data["osif"]["inventory_file"]; p != nil {
+				fname = *p
+			}
+			if p := cfg_data["osif"]["refresh"]; p != nil {
+				if refresh = int64( clike.Atoi( *p ) ); refresh < 15 {
+					refresh = 15
+				}
+			}
+			inv, err = Mk_static_inventory( fname, cfg_data["osif"]["usr"] )
+
+		default:
+			err = fmt.Errorf( "unknown inventory type: %s; expected openstack or static", kind )
+	}
+
+	return
+}
+
+// ---------------------- openstack ----------------------------------------------------
+
+/*
+	Openstack backend. The maps are replaced (not updated) when refreshed, so a go routine
+	which was given a reference to a map can continue to use it safely.
+*/
+type os_inventory struct {
+	os_list		string						// ostack_list from the config
+	os_refs		map[string]*ostack.Ostack	// creds for each project we need to request info from
+	os_projects map[string]*osif_project	// list of project info (maps)
+	os_admin	*ostack.Ostack				// admin creds
+	id2pname	map[string]*string			// project id/name translation maps
+	pname2id	map[string]*string
+	def_usr		*string
+}
+
+/*
+	Create the openstack backend from the config file (osif section). This blocks until the admin
+	creds can be authorised.
+*/
+func mk_os_inventory( ) ( oi *os_inventory, refresh int64 ) {
+	var (
+		os_sects	[]string					// sections in the config file
+		def_passwd	*string						// defaults and what we assume are the admin creds
+		def_url		*string
+		def_project	*string
+		def_region	*string
+	)
+
+	oi = &os_inventory{ }
+
+	if cfg_data["osif"] != nil {								// cannot imagine that this section is missing, but don't fail if it is
+		def_passwd = cfg_data["osif"]["passwd"]				// defaults applied if non-section given in list, or info omitted from the section
+		oi.def_usr = cfg_data["osif"]["usr"]
+		def_url = cfg_data["osif"]["url"]
+		def_project = cfg_data["osif"]["project"]
+
+		p := cfg_data["osif"]["debug"]
+		if p != nil {
+			v := clike.Atoi( *p )
+			if v > -5 {
+				ostack.Set_debugging( v )
+			}
+		}
+
+		p = cfg_data["osif"]["region"]
+		if p != nil {
+			def_region = p
+		}
+
+		p = cfg_data["osif"]["ostack_list"] 				// preferred placement in osif section
+		if p == nil {
+			p = cfg_data["default"]["ostack_list"] 			// originally in default, so backwards compatable
+		}
+		if p != nil {
+			oi.os_list = *p
+		}
+	}
+
+	if oi.os_list == " " || oi.os_list == "" || oi.os_list == "off" {
+		osif_sheep.Baa( 0, "osif disabled: no openstack list (ostack_list) defined in configuration file or setting is 'off'" )
+		return
+	}
+
+	// TODO -- investigate getting id2pname maps from each specific set of creds defined if an overarching admin name is not given
+
+	oi.os_admin = get_admin_creds( def_url, oi.def_usr, def_passwd, def_project, def_region )		// this will block until we authenticate
+	if oi.os_admin != nil {
+		osif_sheep.Baa( 1, "admin creds generated, mapping tenants" )
+		oi.pname2id, oi.id2pname, _ = oi.os_admin.Map_tenants( )				// list only projects we belong to
+		for k, v := range oi.pname2id {
+			osif_sheep.Baa( 1, "project known: %s %s", k, *v )				// useful to see in log what projects we can see
+		}
+	} else {
+		oi.id2pname = make( map[string]*string )				// empty maps and we'll never generate a translation from project name to tenant ID since there are no default admin creds
+		oi.pname2id = make( map[string]*string )
+		if def_project != nil {
+			osif_sheep.Baa( 0, "WRN: unable to use admin information (%s, proj=%s, reg=%s) to authorise with openstack  [TGUOSI009]", oi.def_usr, def_project, def_region )
+		} else {
+			osif_sheep.Baa( 0, "WRN: unable to use admin information (%s, proj=no-project, reg=%s) to authorise with openstack  [TGUOSI009]", oi.def_usr, def_region )	// YES msg ids are duplicated here
+		}
+	}
+
+	if oi.os_list == "all" {
+		oi.os_refs, _ = refresh_creds( oi.os_admin, oi.os_refs, oi.id2pname )		// for each project in id2pname get current ostack struct (auth)
+		for k := range oi.os_refs {
+			osif_sheep.Baa( 1, "inital os_list member: %s", k )
+		}
+	} else {
+		if strings.Index( oi.os_list, "," ) > 0 {
+			os_sects = strings.Split( oi.os_list, "," )
+		} else {
+			os_sects = strings.Split( oi.os_list, " " )
+		}
+
+		oi.os_refs = make( map[string]*ostack.Ostack, len( os_sects ) * 2 )		// length is a guideline, not a hard value
+		for i := 0; i < len( os_sects ); i++ {
+			osif_sheep.Baa( 1, "creating openstack interface for %s", os_sects[i] )
+			url := def_url
+			usr := oi.def_usr
+			passwd := def_passwd
+			project := &os_sects[i]
+
+			if cfg_data[os_sects[i]] != nil {						// section name supplied, override defaults with information from the section
+				if cfg_data[os_sects[i]]["url"] != nil {
+					url = cfg_data[os_sects[i]]["url"]
+				}
+				if cfg_data[os_sects[i]]["usr"] != nil {
+					usr = cfg_data[os_sects[i]]["usr"]
+				}
+				if cfg_data[os_sects[i]]["passwd"] != nil {
+					passwd = cfg_data[os_sects[i]]["passwd"]
+				}
+				if cfg_data[os_sects[i]]["project"] != nil {
+					project = cfg_data[os_sects[i]]["project"]
+				}
+			}
+			oi.os_refs[*project] = ostack.Mk_ostack( url, usr, passwd, project )
+			oi.os_refs["_ref_"] = oi.os_refs[*project]					// a quick access reference when any one will do
+		}
+	}
+
+	oi.os_projects = make( map[string]*osif_project )
+	add2projects( oi.os_projects, oi.os_refs, oi.pname2id, 0 )				// add refernces to the projects list
+
+	if oi.os_admin != nil {
+		refresh = 180
+	}
+	return
+}
+
+/*
+	Update the project maps and creds.
+*/
+func (oi *os_inventory) update( ) {
+	if oi.os_admin != nil {
+		oi.os_refs, oi.pname2id, oi.id2pname = update_project( oi.os_admin, oi.os_refs, oi.os_projects, oi.pname2id, oi.id2pname, oi.os_list == "all" )
+	}
+}
+
+/*
+	Ensure that we have creds for the project in the [token/]project/host string; if not attempt to get them.
+*/
+func (oi *os_inventory) check_project( raw *string ) {
+	if ! have_project( raw, oi.pname2id, oi.id2pname ) {
+		oi.update( )
+	}
+}
+
+func (oi *os_inventory) Refresh( ) {
+	oi.update( )
+}
+
+func (oi *os_inventory) Get_ip2mac( ) ( map[string]*string, error ) {
+	return get_ip2mac( oi.os_projects )
+}
+
+func (oi *os_inventory) Get_phosts( ) ( *string, error ) {
+	return get_hosts( oi.os_refs )
+}
+
+func (oi *os_inventory) Validate_host( raw *string, tok_req bool ) ( *string, error ) {
+	oi.check_project( raw )
+
+	os_refs := oi.os_refs
+	tok2proj := func( tok *string ) ( *string, *string, error ) {
+		return token2project( os_refs, tok )
+	}
+	return validate_token( raw, tok2proj, oi.pname2id, tok_req )
+}
+
+func (oi *os_inventory) Validate_admin( token *string ) ( error ) {
+	if oi.os_admin == nil {
+		return fmt.Errorf( "openstack is not configured; unable to validate the admin token" )
+	}
+
+	oi.check_project( token )
+	return validate_admin_token( oi.os_admin, token, oi.def_usr )
+}
+
+func (oi *os_inventory) Has_any_role( token *string, roles *string ) ( bool, error ) {
+	if oi.os_admin == nil {
+		return false, fmt.Errorf( "openstack is not configured; unable to verify roles" )
+	}
+
+	return has_any_role( oi.os_refs, oi.os_admin, token, roles )
+}
+
+func (oi *os_inventory) Pname2id( name *string ) ( *string ) {
+	if id := oi.pname2id[*name]; id != nil {
+		return id
+	}
+	if oi.id2pname[*name] != nil {				// if in id map, then return the stirng (the id) they passed (#202)
+		return name
+	}
+
+	return nil
+}
+
+func (oi *os_inventory) Get_hostinfo( msg *ipc.Chmsg ) {
+	go get_os_hostinfo( msg, oi.os_refs, oi.os_projects, oi.id2pname, oi.pname2id )		// do it asynch and return the result on the message channel
+}
+
+func (oi *os_inventory) Get_proj_hosts( msg *ipc.Chmsg ) {
+	go get_all_osvm_info( msg, oi.os_refs, oi.os_projects, oi.id2pname, oi.pname2id )
+}
+
+func (oi *os_inventory) Get_defgw( msg *ipc.Chmsg ) {
+	go get_os_defgw( msg, oi.os_refs, oi.os_projects, oi.id2pname, oi.pname2id )
+}
+
+func (oi *os_inventory) String( ) ( string ) {
+	return fmt.Sprintf( "openstack: list=%q projects=%d", oi.os_list, len( oi.os_projects ) )
+}
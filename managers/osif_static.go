@@ -0,0 +1,560 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	osif_static
+	Abstract:	Static inventory backend for the osif manager. Allows tegu to be used where
+				there is no openstack (bare-metal and lab deployments). The inventory is a json
+				file (osif:inventory_file) of the form:
+					{
+						"admin": "tegu",
+						"phosts": [ "compute1", "compute2" ],
+						"projects": [ {
+							"name": "lab", "id": "0123",
+							"gateways": [ { "ip": "10.0.0.1", "mac": "fa:16:3e:00:00:01", "phost": "net1", "cidr": "10.0.0.0/24" } ],
+							"hosts": [ { "name": "vm1", "id": "vm1-id", "ip4": "10.0.0.2", "mac": "fa:16:3e:00:00:02",
+										 "phost": "compute1", "fip": "135.1.1.2" } ]
+						} ],
+						"tokens": [ { "token": "secret", "project": "lab", "user": "tegu", "roles": [ "admin" ] } ]
+					}
+
+				Names, addresses and gateways are returned with the project id prefixed
+				(id/name, id/ip) as the openstack backend does. Project id defaults to the name.
+				When phosts is omitted the physical hosts named by the hosts and gateways are used.
+				The admin user defaults to osif:usr from the config file.
+
+				The file is checked when Refresh() is called and reloaded if it changed; if the
+				new contents are not valid the last good inventory is kept.
+
+	Date:		17 October 2026
+
+*/
+
+package managers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/att/gopkgs/ipc"
+)
+
+// ---- json in the inventory file ----------------------------------------------------------
+type inv_host_json struct {
+	Name	string
+	Id		string
+	Ip4		string
+	Ip6		string
+	Mac		string
+	Phost	string
+	Fip		string
+}
+
+type inv_gw_json struct {
+	Ip		string
+	Mac		string
+	Phost	string
+	Cidr	string
+}
+
+type inv_project_json struct {
+	Name		string
+	Id			string
+	Gateways	[]inv_gw_json
+	Hosts		[]inv_host_json
+}
+
+type inv_token_json struct {
+	Token	string
+	Project	string
+	User	string
+	Roles	[]string
+}
+
+type inv_json struct {
+	Admin		string
+	Phosts		[]string
+	Projects	[]inv_project_json
+	Tokens		[]inv_token_json
+}
+
+/*
+	A loaded inventory. Replaced, never updated, when the file is reloaded.
+*/
+type inv_data struct {
+	projects	map[string]*inv_project_json		// by name and by id
+	pname2id	map[string]*string
+	id2pname	map[string]*string
+	tokens		map[string]*inv_token_json
+	phosts		string
+	admin		string
+	nhosts		int
+}
+
+type static_inventory struct {
+	fname		string
+	def_admin	string						// admin user from the config (used if not in the file)
+	mtime		time.Time
+	size		int64
+	data		*inv_data
+}
+
+/*
+	Create a static inventory from the named file. Admin is the tegu admin user name (osif:usr)
+	and may be nil. An error is returned if the file cannot be loaded.
+*/
+func Mk_static_inventory( fname string, admin *string ) ( si *static_inventory, err error ) {
+	si = &static_inventory {
+		fname:	fname,
+	}
+	if admin != nil {
+		si.def_admin = *admin
+	}
+
+	if _, err = si.reload( ); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+/*
+	Parse and validate the inventory file contents.
+*/
+func parse_inventory( buf []byte, def_admin string ) ( d *inv_data, err error ) {
+	ij := &inv_json{ }
+	if err = json.Unmarshal( buf, ij ); err != nil {
+		return nil, err
+	}
+
+	d = &inv_data {
+		projects:	make( map[string]*inv_project_json ),
+		pname2id:	make( map[string]*string ),
+		id2pname:	make( map[string]*string ),
+		tokens:		make( map[string]*inv_token_json ),
+		admin:		ij.Admin,
+	}
+	if d.admin == "" {
+		d.admin = def_admin
+	}
+
+	pmap := make( map[string]bool )
+	for i := range ij.Projects {
+		p := &ij.Projects[i]
+		if p.Name == "" {
+			return nil, fmt.Errorf( "project %d has no name", i )
+		}
+		if p.Id == "" {
+			p.Id = p.Name
+		}
+		if d.projects[p.Name] != nil || d.projects[p.Id] != nil {
+			return nil, fmt.Errorf( "project %s (%s) is defined more than once", p.Name, p.Id )
+		}
+		d.projects[p.Name] = p
+		d.projects[p.Id] = p
+		d.pname2id[p.Name] = &p.Id
+		d.id2pname[p.Id] = &p.Name
+
+		seen := make( map[string]bool )
+		for j, h := range p.Hosts {
+			if h.Name == "" || h.Ip4 == "" {
+				return nil, fmt.Errorf( "project %s: host %d must have a name and ip4 address", p.Name, j )
+			}
+			if seen[h.Name] || seen[h.Ip4] {
+				return nil, fmt.Errorf( "project %s: host %s (%s) is defined more than once", p.Name, h.Name, h.Ip4 )
+			}
+			seen[h.Name] = true
+			seen[h.Ip4] = true
+			if h.Id == "" {
+				p.Hosts[j].Id = h.Name
+			}
+			if h.Phost != "" {
+				pmap[h.Phost] = true
+			}
+			d.nhosts++
+		}
+		for j, g := range p.Gateways {
+			if g.Ip == "" {
+				return nil, fmt.Errorf( "project %s: gateway %d has no ip address", p.Name, j )
+			}
+			if g.Cidr != "" && len( strings.Split( g.Cidr, "/" ) ) != 2 {
+				return nil, fmt.Errorf( "project %s: gateway %s: cidr is not address/bits: %s", p.Name, g.Ip, g.Cidr )
+			}
+			if g.Phost != "" {
+				pmap[g.Phost] = true
+			}
+		}
+	}
+
+	for i := range ij.Tokens {
+		t := &ij.Tokens[i]
+		if t.Token == "" {
+			return nil, fmt.Errorf( "token %d is empty", i )
+		}
+		if t.Project != "" && d.projects[t.Project] == nil {
+			return nil, fmt.Errorf( "token %d: unknown project: %s", i, t.Project )
+		}
+		d.tokens[t.Token] = t
+	}
+
+	if len( ij.Phosts ) > 0 {
+		d.phosts = strings.Join( ij.Phosts, " " )
+	} else {
+		plist := make( []string, 0, len( pmap ) )
+		for h := range pmap {
+			plist = append( plist, h )
+		}
+		sort.Strings( plist )
+		d.phosts = strings.Join( plist, " " )
+	}
+
+	return
+}
+
+/*
+	Reload the file if it has changed. Returns true if new data was loaded. On error the
+	current data is kept.
+*/
+func (si *static_inventory) reload( ) ( changed bool, err error ) {
+	fi, err := os.Stat( si.fname )
+	if err != nil {
+		return
+	}
+	if si.data != nil && fi.ModTime().Equal( si.mtime ) && fi.Size() == si.size {
+		return
+	}
+
+	si.mtime = fi.ModTime()
+	si.size = fi.Size()
+
+	buf, err := ioutil.ReadFile( si.fname )
+	if err != nil {
+		return
+	}
+
+	d, err := parse_inventory( buf, si.def_admin )
+	if err != nil {
+		return false, fmt.Errorf( "%s: %s", si.fname, err )
+	}
+
+	si.data = d
+	osif_sheep.Baa( 1, "static inventory loaded from %s: %d projects, %d hosts", si.fname, len( d.pname2id ), d.nhosts )
+	return true, nil
+}
+
+/*
+	Find the project given a name or id; a leading bang (unvalidated) is ignored.
+*/
+func (si *static_inventory) find_project( name string ) ( *inv_project_json ) {
+	return si.data.projects[strings.TrimPrefix( name, "!" )]
+}
+
+/*
+	Find the gateway for the address.
+*/
+func inv_ip2gw( p *inv_project_json, ip string ) ( *string ) {
+	for _, g := range p.Gateways {
+		if g.Cidr != "" {
+			ctoks := strings.Split( g.Cidr, "/" )
+			if in_subnet( ip, ctoks[0], ctoks[1] ) {
+				gw := p.Id + "/" + g.Ip
+				return &gw
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+	Build the gateway (mac -> project/ip) map for the project.
+*/
+func inv_gwmap( p *inv_project_json ) ( gwmap map[string]*string ) {
+	gwmap = make( map[string]*string, len( p.Gateways ) )
+	for _, g := range p.Gateways {
+		if g.Mac != "" {
+			ip := p.Id + "/" + g.Ip
+			gwmap[g.Mac] = &ip
+		}
+	}
+
+	return
+}
+
+/*
+	Return a string pointer, or nil if the string is empty; when prefix is given it is added.
+*/
+func inv_str( prefix string, s string ) ( *string ) {
+	if s == "" {
+		return nil
+	}
+	if prefix != "" {
+		s = prefix + "/" + s
+	}
+
+	return &s
+}
+
+/*
+	Build the network insertion block for a host.
+*/
+func inv_host2vm( p *inv_project_json, h *inv_host_json ) ( *Net_vm ) {
+	return Mk_netreq_vm( inv_str( p.Id, h.Name ), inv_str( "", h.Id ), inv_str( p.Id, h.Ip4 ), inv_str( p.Id, h.Ip6 ), inv_str( "", h.Phost ),
+		inv_str( "", h.Mac ), inv_ip2gw( p, h.Ip4 ), inv_str( p.Id, h.Fip ), inv_gwmap( p ) )
+}
+
+/*
+	Build the network insertion block for a gateway. Gateways are nameless so the address is used.
+*/
+func inv_gw2vm( p *inv_project_json, g *inv_gw_json ) ( *Net_vm ) {
+	ip := inv_str( p.Id, g.Ip )
+	return Mk_netreq_vm( ip, ip, ip, nil, inv_str( "", g.Phost ), inv_str( "", g.Mac ), nil, nil, inv_gwmap( p ) )
+}
+
+/*
+	Map a token to the project name and id that it was issued for.
+*/
+func (si *static_inventory) tok2proj( tok *string ) ( pname *string, pid *string, err error ) {
+	t := si.data.tokens[*tok]
+	if t == nil {
+		return nil, nil, fmt.Errorf( "token is not valid" )
+	}
+	p := si.find_project( t.Project )
+	if p == nil {
+		return nil, nil, fmt.Errorf( "token was not issued for a project" )
+	}
+
+	return &p.Name, &p.Id, nil
+}
+
+// ---- Host_inventory interface -------------------------------------------------------------
+
+func (si *static_inventory) Refresh( ) {
+	if _, err := si.reload( ); err != nil {
+		osif_sheep.Baa( 0, "ERR: static inventory not reloaded, last good inventory kept: %s  [TGUOSI014]", err )
+	}
+}
+
+func (si *static_inventory) Get_ip2mac( ) ( map[string]*string, error ) {
+	m := make( map[string]*string )
+	for id, p := range si.data.projects {
+		if id != p.Id {							// projects are in the map by name and id; do each once
+			continue
+		}
+
+		for i := range p.Hosts {
+			h := &p.Hosts[i]
+			if h.Mac != "" {
+				m[p.Id + "/" + h.Ip4] = &h.Mac
+				if h.Ip6 != "" {
+					m[p.Id + "/" + h.Ip6] = &h.Mac
+				}
+			}
+		}
+		for i := range p.Gateways {
+			g := &p.Gateways[i]
+			if g.Mac != "" {
+				m[p.Id + "/" + g.Ip] = &g.Mac
+			}
+		}
+	}
+
+	return m, nil
+}
+
+func (si *static_inventory) Get_phosts( ) ( *string, error ) {
+	s := si.data.phosts
+	return &s, nil
+}
+
+func (si *static_inventory) Validate_host( raw *string, tok_req bool ) ( *string, error ) {
+	return validate_token( raw, si.tok2proj, si.data.pname2id, tok_req )
+}
+
+func (si *static_inventory) Validate_admin( token *string ) ( error ) {
+	tok := strings.SplitN( *token, "/", 2 )[0]
+	t := si.data.tokens[tok]
+	if t == nil {
+		osif_sheep.Baa( 1, "admin token invalid: unknown token" )
+		return fmt.Errorf( "token is not valid" )
+	}
+	if si.data.admin == "" || t.User != si.data.admin {
+		osif_sheep.Baa( 1, "admin token invalid: issued for %s", t.User )
+		return fmt.Errorf( "token was not issued for the tegu admin user" )
+	}
+
+	return nil
+}
+
+func (si *static_inventory) Has_any_role( token *string, roles *string ) ( bool, error ) {
+	toks := strings.SplitN( *token, "/", 2 )
+	t := si.data.tokens[toks[0]]
+	if t == nil {
+		return false, fmt.Errorf( "has_any_role: token is not valid" )
+	}
+
+	if len( toks ) > 1 {												// token/project; must be for the project
+		if toks[1] == "" {
+			return false, fmt.Errorf( "project portion of token/project was empty" )
+		}
+		if p := si.find_project( toks[1] ); p == nil || (p.Name != t.Project && p.Id != t.Project) {
+			return false, fmt.Errorf( "has_any_role: token is not valid for project: %s", toks[1] )
+		}
+	}
+
+	for _, want := range strings.Split( *roles, "," ) {
+		for _, r := range t.Roles {
+			if r == want {
+				osif_sheep.Baa( 2, "has_any_role: token validated for roles: %s", *roles )
+				return true, nil
+			}
+		}
+	}
+
+	return false, fmt.Errorf( "has_any_role: token not valid for roles: %s", *roles )
+}
+
+func (si *static_inventory) Pname2id( name *string ) ( *string ) {
+	if p := si.data.projects[*name]; p != nil {
+		return &p.Id
+	}
+
+	return nil
+}
+
+/*
+	Request data is project/host where host is a name, ip address or id.
+*/
+func (si *static_inventory) Get_hostinfo( msg *ipc.Chmsg ) {
+	msg.Response_data = nil
+
+	tokens := strings.Split( *(msg.Req_data.( *string )), "/" )
+	if len( tokens ) != 2 || tokens[0] == "" || tokens[1] == "" {
+		msg.State = fmt.Errorf( "invalid project/hostname string: %s", *(msg.Req_data.( *string )) )
+		msg.Response_ch <- msg
+		return
+	}
+
+	if tokens[0] == "!" { 								// !//ipaddress was given; we've got nothing
+		msg.Response_ch <- msg
+		return
+	}
+
+	p := si.find_project( tokens[0] )
+	if p == nil {
+		msg.State = fmt.Errorf( "%s could not be mapped to a project", *(msg.Req_data.( *string )) )
+		msg.Response_ch <- msg
+		return
+	}
+
+	for i := range p.Hosts {
+		h := &p.Hosts[i]
+		if h.Name == tokens[1] || h.Ip4 == tokens[1] || h.Id == tokens[1] || (h.Ip6 != "" && h.Ip6 == tokens[1]) {
+			msg.Response_data = inv_host2vm( p, h )
+			msg.Response_ch <- msg
+			return
+		}
+	}
+	for i := range p.Gateways {
+		if p.Gateways[i].Ip == tokens[1] {
+			msg.Response_data = inv_gw2vm( p, &p.Gateways[i] )
+			msg.Response_ch <- msg
+			return
+		}
+	}
+
+	msg.State = fmt.Errorf( "unable to retrieve host info: %s not in the inventory", *(msg.Req_data.( *string )) )
+	msg.Response_ch <- msg
+}
+
+/*
+	Request data is a project name or id, or _all_proj for all projects.
+*/
+func (si *static_inventory) Get_proj_hosts( msg *ipc.Chmsg ) {
+	msg.Response_data = nil
+	msg.State = nil
+
+	if msg.Req_data == nil {
+		msg.State = fmt.Errorf( "osvm_info: request data didn't contain a project name or ID" )
+		msg.Response_ch <- msg
+		return
+	}
+
+	pname := msg.Req_data.( *string )
+	ilist := make( []*Net_vm, 0 )
+	for id, p := range si.data.projects {
+		if id != p.Id || (*pname != "_all_proj" && *pname != p.Name && *pname != p.Id) {
+			continue
+		}
+
+		for i := range p.Hosts {
+			ilist = append( ilist, inv_host2vm( p, &p.Hosts[i] ) )
+		}
+		for i := range p.Gateways {
+			ilist = append( ilist, inv_gw2vm( p, &p.Gateways[i] ) )
+		}
+	}
+
+	if *pname != "_all_proj" && si.find_project( *pname ) == nil {
+		msg.State = fmt.Errorf( "projvm_info: %s could not be mapped to a project id", *pname )
+	} else {
+		msg.Response_data = ilist
+	}
+	msg.Response_ch <- msg
+}
+
+/*
+	Request data is project[/junk]; the first gateway listed for the project is returned.
+*/
+func (si *static_inventory) Get_defgw( msg *ipc.Chmsg ) {
+	var gw *string = nil
+	msg.Response_data = gw							// callers expect a *string, even when nil
+
+	if msg.Req_data == nil {
+		msg.State = fmt.Errorf( "defgw: missing data in request" )
+		msg.Response_ch <- msg
+		return
+	}
+
+	tokens := strings.Split( *(msg.Req_data.( *string )), "/" )
+	p := si.find_project( tokens[0] )
+	if p == nil || tokens[0] == "!" || tokens[0] == "" {
+		msg.State = fmt.Errorf( "%s could not be mapped to a project", *(msg.Req_data.( *string )) )
+	} else {
+		if len( p.Gateways ) > 0 {
+			gw = inv_str( p.Id, p.Gateways[0].Ip )
+			msg.Response_data = gw
+		} else {
+			msg.State = fmt.Errorf( "no gateway defined for project: %s", p.Name )
+		}
+	}
+
+	msg.Response_ch <- msg
+}
+
+func (si *static_inventory) String( ) ( string ) {
+	return fmt.Sprintf( "static: %s projects=%d hosts=%d", si.fname, len( si.data.pname2id ), si.data.nhosts )
+}
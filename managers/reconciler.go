@@ -0,0 +1,639 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	reconciler
+	Abstract:	Audits the flow-mods and queues installed on the hosts against what tegu believes
+				should be there. Without this, if OVS is restarted or flow-mods are deleted by
+				hand, tegu does not notice until the next hto_limit refresh, and flow-mods for
+				cancelled reservations can linger.
+
+				An audit is a chain of requests, each answered back to the reconciler:
+					res_mgr		lists the endpoint host, cookie and addresses of the flow-mods for
+								each active bandwidth and oneway reservation
+					fq_mgr		translates the addresses to macs, adds the physical host suffix,
+								and supplies its host list and the queue map last sent to the agents
+					agent_mgr	sends a dump_state action to an agent which lists the flow-mods with
+								tegu's bandwidth cookies, and the queues, on each host; the agent's
+								response is passed to the reconciler
+
+				The two are then compared and the differences (drift) are written to the log and
+				kept for the audit api request:
+					missing_flow	a reservation that the agents reported as installed has no flow-mods on a host
+					stale_flow		a flow-mod with a tegu cookie belongs to no active reservation
+					missing_queue	a queue in the queue map is not set on the host
+					extra_queue		a queue set on the host is not in the queue map
+					unreachable		the agent was unable to list the host
+
+				When repair is on, reservations with missing flow-mods are pushed again, stale flow-mods
+				are deleted and, if any queue drifted, the queue map is sent to the agents again. A
+				stale flow-mod is deleted only if it was also stale in the previous audit; this prevents
+				the flow-mods of a reservation which activated while the audit was running from being
+				removed.
+
+				Steering and mirroring flow-mods are not audited.
+
+	Config:		These variables are referenced if in the config file (defaults in parens):
+					reconciler:interval	- seconds between audits; 0 turns off periodic audits (900)
+					reconciler:repair	- true to repair drift rather than just report it (false)
+					reconciler:queues	- true to audit queues; qos-lite removes the HTB queues unless allow_cq is set on the host (false)
+					reconciler:timeout	- seconds after which an unfinished audit is abandoned (300)
+					reconciler:verbose	- bleat level
+
+	Date:		17 October 2026
+
+*/
+
+package managers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/att/gopkgs/bleater"
+	"github.com/att/gopkgs/clike"
+	"github.com/att/gopkgs/ipc"
+)
+
+const (
+	bw_cookie	string = "0xb0ff"		// cookies the agent (driver and scripts) puts on bandwidth and oneway flow-mods
+	bwow_cookie	string = "0xf00d"
+)
+
+const (
+	rc_idle		int = iota				// audit phases
+	rc_want_rm							// waiting on res_mgr for the reservation flow-mods
+	rc_want_fq							// waiting on fq_mgr for macs, hosts and queues
+	rc_dump								// waiting on the agent's dump
+)
+
+/*
+	A flow-mod set which should exist on a host. Res_mgr fills in everything but the macs which
+	fq_mgr adds.
+*/
+type want_flow struct {
+	pname	string
+	host	string				// host the flow-mods are set on
+	cookie	string
+	ip1		*string				// addresses that fq_mgr translates to macs
+	ip2		*string
+	smac	string
+	dmac	string				// empty for a oneway reservation to an external address
+	verify	bool				// must exist (reported installed); if false only protected from being called stale
+}
+
+type want_state struct {
+	flows	[]*want_flow
+	qraw	[]string			// queue map last sent to the agents (host/port,res,qnum,min,max,pri)
+	qlist	[]string			// queue map with the physical host suffix added
+	hosts	[]string			// hosts that the agents manage
+}
+
+/*
+	Request to agent_mgr to send a dump_state action.
+*/
+type dump_req struct {
+	hosts	[]string
+	cookies	string				// space separated list
+	queues	bool
+}
+
+/*
+	The agent's response to a dump_state action as passed along by agent_mgr.
+*/
+type state_dump struct {
+	rid		uint32				// action id of the dump_state action
+	rdata	[]string			// flow and queue records (see agent/ovs/dump.go)
+	hstatus	[]host_status
+}
+
+type drift struct {
+	Kind	string
+	Host	string
+	Res		string `json:",omitempty"`
+	Detail	string
+}
+
+type drift_rpt struct {
+	State		string			// running, complete or abandoned
+	Start		int64
+	End			int64
+	Hosts		int				// hosts listed by the agent
+	Flows		int				// flow-mods listed
+	Queues		int				// queues listed
+	Drift		[]*drift
+	Repushed	[]string		// reservations pushed again
+	Deleted		int				// stale flow-mods deleted
+	Qreset		bool			// queue map resent
+}
+
+type reconciler struct {
+	repair	bool
+	queues	bool
+	timeout	int64
+	phase	int
+	aids	map[uint32]bool		// ids of the dump_state actions we are waiting on (split if no one agent reaches all hosts)
+	dhosts	[]string			// hosts the dump was requested for
+	dump	*state_dump			// dump responses collected so far
+	want	*want_state
+	rpt		*drift_rpt			// audit in progress
+	last	*drift_rpt			// last audit finished (or abandoned)
+	stale	map[string]bool		// stale flow-mods found by the last audit (host cookie table match)
+}
+
+// ---------------- support -----------------------------------------------------------------------
+
+/*
+	Cookies are compared by value; ovs might list them differently than we generate them.
+*/
+func norm_cookie( c string ) ( string ) {
+	if v, err := strconv.ParseUint( c, 0, 64 ); err == nil {
+		return fmt.Sprintf( "0x%x", v )
+	}
+
+	return c
+}
+
+/*
+	Build the key used to match the flow-mods listed on a host with those wanted. Bandwidth
+	reservations have flow-mods in both directions, so the order of the macs is ignored.
+*/
+func flow_key( cookie string, mac1 string, mac2 string ) ( string ) {
+	if mac1 == "" {
+		mac1 = "-"
+	}
+	if mac2 == "" {
+		mac2 = "-"
+	}
+	if mac2 < mac1 {
+		mac1, mac2 = mac2, mac1
+	}
+
+	return norm_cookie( cookie ) + " " + mac1 + " " + mac2
+}
+
+/*
+	Return the queue numbers (other than 0 which is always there) that the queue map puts on the
+	outward port of each host.
+*/
+func qmap_queues( qlist []string ) ( hq map[string]map[int]bool ) {
+	hq = make( map[string]map[int]bool )
+	for _, q := range qlist {
+		toks := strings.Split( q, "," )
+		if len( toks ) < 3 {
+			continue
+		}
+		hp := strings.SplitN( toks[0], "/", 2 )
+		if len( hp ) != 2 || hp[1] != "-128" {
+			continue
+		}
+
+		if n := clike.Atoi( toks[2] ); n > 0 {
+			if hq[hp[0]] == nil {
+				hq[hp[0]] = make( map[int]bool )
+			}
+			hq[hp[0]][n] = true
+		}
+	}
+
+	return
+}
+
+func (rc *reconciler) add_drift( kind string, host string, res string, detail string ) {
+	rc.rpt.Drift = append( rc.rpt.Drift, &drift{ Kind: kind, Host: host, Res: res, Detail: detail } )
+
+	if len( rc.rpt.Drift ) <= 50 {
+		if res != "" {
+			rc_sheep.Baa( 1, "WRN: drift: %s on %s: reservation %s: %s  [TGURCN001]", kind, host, res, detail )
+		} else {
+			rc_sheep.Baa( 1, "WRN: drift: %s on %s: %s  [TGURCN001]", kind, host, detail )
+		}
+	}
+}
+
+/*
+	Generate the json returned for an audit api request: whether an audit is running and the
+	report from the last one.
+*/
+func (rc *reconciler) report_json( ) ( string ) {
+	last := rc.last
+	if last == nil {
+		last = &drift_rpt{ State: "none", Drift: []*drift{ } }
+	}
+
+	jlast, err := json.Marshal( last )
+	if err != nil {
+		return fmt.Sprintf( `{ "running": %v, "last": { "State": "error" } }`, rc.phase != rc_idle )
+	}
+
+	return fmt.Sprintf( `{ "running": %v, "last": %s }`, rc.phase != rc_idle, jlast )
+}
+
+// ---------------- audit -------------------------------------------------------------------------
+
+/*
+	End the audit in progress and save its report.
+*/
+func (rc *reconciler) finish( state string ) {
+	rc.rpt.State = state
+	rc.rpt.End = time.Now().Unix()
+	rc.last = rc.rpt
+	rc.rpt = nil
+	rc.want = nil
+	rc.dump = nil
+	rc.aids = nil
+	rc.phase = rc_idle
+}
+
+/*
+	Abandon the audit in progress.
+*/
+func (rc *reconciler) abandon( reason string ) {
+	rc_sheep.Baa( 1, "WRN: audit abandoned: %s  [TGURCN000]", reason )
+	rc.finish( "abandoned" )
+}
+
+/*
+	Start an audit by asking res_mgr for the flow-mods which should be installed. If an audit
+	is already running it is left to finish unless it has run too long.
+*/
+func (rc *reconciler) start( my_chan chan *ipc.Chmsg ) {
+	now := time.Now().Unix()
+
+	if rc.phase != rc_idle {
+		if now < rc.rpt.Start + rc.timeout {
+			rc_sheep.Baa( 2, "audit already running, not restarted" )
+			return
+		}
+		rc.abandon( fmt.Sprintf( "not finished after %ds", rc.timeout ) )
+	}
+
+	rc_sheep.Baa( 2, "starting audit" )
+	rc.rpt = &drift_rpt{ State: "running", Start: now, Drift: []*drift{ } }
+	rc.phase = rc_want_rm
+
+	msg := ipc.Mk_chmsg( )
+	msg.Send_req( rmgr_ch, my_chan, REQ_DESIRED_STATE, nil, nil )
+}
+
+/*
+	Deal with the desired state as it comes back from res_mgr, and then from fq_mgr. Once
+	complete the agent manager is asked to dump the hosts.
+*/
+func (rc *reconciler) desired( msg *ipc.Chmsg, my_chan chan *ipc.Chmsg ) {
+	ws, ok := msg.Response_data.( *want_state )
+	if msg.State != nil || ! ok || ws == nil {
+		rc.abandon( fmt.Sprintf( "unable to get desired state: %v", msg.State ) )
+		return
+	}
+
+	switch rc.phase {
+		case rc_want_rm:
+			rc.phase = rc_want_fq
+			tmsg := ipc.Mk_chmsg( )
+			tmsg.Send_req( fq_ch, my_chan, REQ_DESIRED_STATE, ws, nil )
+
+		case rc_want_fq:
+			rc.want = ws
+
+			hmap := make( map[string]bool )
+			for _, h := range ws.hosts {
+				hmap[h] = true
+			}
+			for _, f := range ws.flows {
+				hmap[f.host] = true
+			}
+			if rc.queues {
+				for h := range qmap_queues( ws.qlist ) {
+					hmap[h] = true
+				}
+			}
+			dr := &dump_req{ hosts: make( []string, 0, len( hmap ) ), cookies: bw_cookie + " " + bwow_cookie, queues: rc.queues }
+			for h := range hmap {
+				dr.hosts = append( dr.hosts, h )
+			}
+			sort.Strings( dr.hosts )
+
+			if len( dr.hosts ) == 0 {
+				rc_sheep.Baa( 1, "audit: no hosts known, nothing to audit" )
+				rc.finish( "complete" )
+				return
+			}
+
+			rc_sheep.Baa( 2, "audit: %d reservation flow-mod sets, requesting dump from %d hosts", len( ws.flows ), len( dr.hosts ) )
+			rc.phase = rc_dump
+			rc.aids = nil
+			rc.dhosts = dr.hosts
+			rc.dump = &state_dump{ }
+			tmsg := ipc.Mk_chmsg( )
+			tmsg.Send_req( am_ch, my_chan, REQ_DUMP_STATE, dr, nil )
+
+		default:
+			rc_sheep.Baa( 2, "desired state received when not expected; ignored" )
+	}
+}
+
+/*
+	Compare the agent's dump with the desired state, report the drift and repair it if enabled.
+*/
+func (rc *reconciler) compare( sd *state_dump ) {
+	failed := make( map[string]bool )
+	seen := make( map[string]bool )
+	for _, hs := range sd.hstatus {
+		seen[hs.Host] = true
+		if hs.State != 0 {
+			failed[hs.Host] = true
+			emsg := "no reason given"
+			if len( hs.Edata ) > 0 {
+				emsg = hs.Edata[0]
+			}
+			rc.add_drift( "unreachable", hs.Host, "", emsg )
+		} else {
+			rc.rpt.Hosts++
+		}
+	}
+	for _, h := range rc.dhosts {
+		if ! seen[h] {
+			failed[h] = true
+			rc.add_drift( "unreachable", h, "", "no agent able to reach the host" )
+		}
+	}
+
+	actual := make( map[string]map[string]bool )		// flow keys listed on each host
+	aqueues := make( map[string]map[int]bool )			// queue numbers listed on each host
+	stale := make( map[string]bool )
+	dels := make( map[string][]string )					// send_ovs_fmod delete parms for each host
+
+	wanted := make( map[string]map[string]bool )		// flow keys wanted on each host
+	for _, f := range rc.want.flows {
+		if wanted[f.host] == nil {
+			wanted[f.host] = make( map[string]bool )
+		}
+		wanted[f.host][flow_key( f.cookie, f.smac, f.dmac )] = true
+	}
+
+	for _, rec := range sd.rdata {
+		toks := strings.Fields( rec )
+		switch {
+			case len( toks ) >= 7 && toks[0] == "flow":			// flow host cookie table smac dmac match
+				host := toks[1]
+				rc.rpt.Flows++
+				key := flow_key( toks[2], toks[4], toks[5] )
+				if actual[host] == nil {
+					actual[host] = make( map[string]bool )
+				}
+				actual[host][key] = true
+
+				if ! wanted[host][key] {
+					rc.add_drift( "stale_flow", host, "", fmt.Sprintf( "cookie=%s table=%s %s", toks[2], toks[3], toks[6] ) )
+
+					sid := strings.Join( toks[1:4], " " ) + " " + toks[6]
+					stale[sid] = true
+					if rc.stale[sid] {								// stale last time too; safe to delete
+						parms := "--match"
+						if toks[3] != "0" {
+							parms = "-T " + toks[3] + " " + parms
+						}
+						if toks[4] != "-" {
+							parms += " -s " + toks[4]
+						}
+						if toks[5] != "-" {
+							parms += " -d " + toks[5]
+						}
+						dels[host] = append( dels[host], parms + " --action del " + toks[2] + " br-int" )
+					}
+				}
+
+			case len( toks ) >= 6 && toks[0] == "queue":		// queue host port qnum min max
+				rc.rpt.Queues++
+				if n := clike.Atoi( toks[3] ); n > 0 {
+					if aqueues[toks[1]] == nil {
+						aqueues[toks[1]] = make( map[int]bool )
+					}
+					aqueues[toks[1]][n] = true
+				}
+
+			default:
+				rc_sheep.Baa( 2, "unrecognised record in dump ignored: %s", rec )
+		}
+	}
+	rc.stale = stale
+
+	repush := make( map[string]bool )
+	for _, f := range rc.want.flows {
+		if ! f.verify || f.smac == "" || failed[f.host] {
+			continue
+		}
+		if ! actual[f.host][flow_key( f.cookie, f.smac, f.dmac )] {
+			rc.add_drift( "missing_flow", f.host, f.pname, fmt.Sprintf( "no flow-mods with cookie %s for %s %s", f.cookie, f.smac, f.dmac ) )
+			repush[f.pname] = true
+		}
+	}
+
+	qdrift := false
+	if rc.queues {
+		wq := qmap_queues( rc.want.qlist )
+		for host, ql := range wq {
+			if failed[host] {
+				continue
+			}
+			for n := range ql {
+				if ! aqueues[host][n] {
+					rc.add_drift( "missing_queue", host, "", fmt.Sprintf( "queue %d", n ) )
+					qdrift = true
+				}
+			}
+		}
+		for host, ql := range aqueues {
+			for n := range ql {
+				if ! wq[host][n] {
+					rc.add_drift( "extra_queue", host, "", fmt.Sprintf( "queue %d", n ) )
+					qdrift = true
+				}
+			}
+		}
+	}
+
+	if rc.repair {
+		rc.fix( repush, dels, qdrift )
+	}
+
+	if len( rc.rpt.Drift ) > 0 {
+		rc_sheep.Baa( 1, "audit complete: %d hosts, %d flow-mods, %d queues, %d differences; %d reservations pushed, %d flow-mods deleted",
+			rc.rpt.Hosts, rc.rpt.Flows, rc.rpt.Queues, len( rc.rpt.Drift ), len( rc.rpt.Repushed ), rc.rpt.Deleted )
+	} else {
+		rc_sheep.Baa( 1, "audit complete: %d hosts, %d flow-mods, %d queues, no drift", rc.rpt.Hosts, rc.rpt.Flows, rc.rpt.Queues )
+	}
+	rc.finish( "complete" )
+}
+
+/*
+	Repair drift: push reservations again, delete stale flow-mods and resend the queue map.
+*/
+func (rc *reconciler) fix( repush map[string]bool, dels map[string][]string, qdrift bool ) {
+	if len( repush ) > 0 {
+		names := make( []string, 0, len( repush ) )
+		for n := range repush {
+			names = append( names, n )
+		}
+		sort.Strings( names )
+		rc.rpt.Repushed = names
+
+		msg := ipc.Mk_chmsg( )
+		msg.Send_req( rmgr_ch, nil, REQ_REPUSH, names, nil )
+	}
+
+	for host, fdata := range dels {
+		cmd := &agent_cmd{ Ctype: "action_list" }
+		cmd.Actions = make( []action, 1 )
+		cmd.Actions[0].Atype = "flowmod"
+		cmd.Actions[0].Hosts = []string{ host }
+		cmd.Actions[0].Fdata = fdata
+
+		jcmd, err := json.Marshal( cmd )
+		if err != nil {
+			rc_sheep.Baa( 1, "WRN: unable to bundle stale flow-mod delete into json: %s  [TGURCN002]", err )
+			continue
+		}
+		rc_sheep.Baa( 1, "deleting %d stale flow-mods on %s", len( fdata ), host )
+		msg := ipc.Mk_chmsg( )
+		msg.Send_req( am_ch, nil, REQ_SENDSHORT, string( jcmd ), nil )
+		rc.rpt.Deleted += len( fdata )
+	}
+
+	if qdrift && rc.want.qraw != nil {
+		rc_sheep.Baa( 1, "queues drifted, resending the queue map" )
+		fq_data := make( []interface{}, 1 )
+		fq_data[FQ_QLIST] = rc.want.qraw
+		msg := ipc.Mk_chmsg( )
+		msg.Send_req( fq_ch, nil, REQ_SETQUEUES, fq_data, nil )
+		rc.rpt.Qreset = true
+	}
+}
+
+// ---------------- main reconciler goroutine -----------------------------------------------------
+
+func Reconciler( my_chan chan *ipc.Chmsg ) {
+	var (
+		interval	int64 = 900
+	)
+
+	rc := &reconciler{ timeout: 300, stale: make( map[string]bool ) }
+
+	rc_sheep = bleater.Mk_bleater( 0, os.Stderr )		// allocate our bleater and attach it to the master
+	rc_sheep.Set_prefix( "reconciler" )
+	tegu_sheep.Add_child( rc_sheep )					// we become a child so that if the master vol is adjusted we'll react too
+
+	if cfg_data["reconciler"] != nil {
+		if p := cfg_data["reconciler"]["interval"]; p != nil {
+			interval = clike.Atoi64( *p )
+			if interval > 0 && interval < 60 {
+				rc_sheep.Baa( 1, "interval in configuration file is too small, set to 60 seconds" )
+				interval = 60
+			}
+		}
+		if p := cfg_data["reconciler"]["repair"]; p != nil {
+			rc.repair = *p == "true"
+		}
+		if p := cfg_data["reconciler"]["queues"]; p != nil {
+			rc.queues = *p == "true"
+		}
+		if p := cfg_data["reconciler"]["timeout"]; p != nil {
+			rc.timeout = clike.Atoi64( *p )
+			if rc.timeout < 30 {
+				rc.timeout = 30
+			}
+		}
+		if p := cfg_data["reconciler"]["verbose"]; p != nil {
+			rc_sheep.Set_level( uint( clike.Atoi( *p ) ) )
+		}
+	}
+
+	if interval > 0 {
+		tklr.Add_spot( interval, my_chan, REQ_RECONCILE, nil, ipc.FOREVER )		// periodic audit
+	}
+	rc_sheep.Baa( 1, "reconciler started: interval=%ds repair=%v queues=%v", interval, rc.repair, rc.queues )
+
+	for {
+		msg := <- my_chan
+		msg.State = nil
+
+		rc_sheep.Baa( 3, "processing message: %d", msg.Msg_type )
+		switch msg.Msg_type {
+			case REQ_NOOP:
+
+			case REQ_RECONCILE:							// from tickler, or audit api request (run is true to start an audit)
+				run, ok := msg.Req_data.( bool )
+				if ! ok || run {
+					rc.start( my_chan )
+				}
+				msg.Response_data = rc.report_json( )
+
+			case REQ_DESIRED_STATE:						// response from res_mgr or fq_mgr
+				msg.Response_ch = nil
+				rc.desired( msg, my_chan )
+
+			case REQ_DUMP_STATE:						// response from agent_mgr with the action id, or the agent's dump
+				msg.Response_ch = nil
+				switch data := msg.Req_data.( type ) {
+					case *dump_req:						// our request came back with the action ids
+						if rc.phase == rc_dump {
+							if aids, ok := msg.Response_data.( []uint32 ); ok && len( aids ) > 0 {
+								rc.aids = make( map[uint32]bool )
+								for _, aid := range aids {
+									rc.aids[aid] = true
+								}
+							} else {
+								rc.abandon( "no agents to dump the hosts" )
+							}
+						}
+
+					case *state_dump:
+						if rc.phase == rc_dump && rc.aids[data.rid] {
+							delete( rc.aids, data.rid )
+							rc.dump.rdata = append( rc.dump.rdata, data.rdata... )
+							rc.dump.hstatus = append( rc.dump.hstatus, data.hstatus... )
+							if len( rc.aids ) == 0 {
+								rc.compare( rc.dump )
+							}
+						} else {
+							rc_sheep.Baa( 2, "dump for action %d ignored; not one expected", data.rid )
+						}
+				}
+
+			default:
+				rc_sheep.Baa( 1, "unknown request: %d", msg.Msg_type )
+				msg.Response_data = nil
+				if msg.Response_ch != nil {
+					msg.State = fmt.Errorf( "unknown request (%d)", msg.Msg_type )
+				}
+		}
+
+		if msg.Response_ch != nil {
+			msg.Response_ch <- msg
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	req_error
+	Abstract:	Errors returned as the state on a request when the reason for the failure matters
+				to the requestor (the v2 api uses them to pick the http status).  Res_mgr returns
+				a Not_found_error when the named reservation doesn't exist and an Auth_error when
+				the cookie doesn't match; osif returns an Auth_error when a token cannot be
+				validated.
+
+	Date:		17 October 2026
+*/
+
+package managers
+
+import (
+	"fmt"
+)
+
+/*
+	The named thing (reservation) does not exist.
+*/
+type Not_found_error struct {
+	what	string				// description of what wasn't found
+	name	string
+}
+
+/*
+	Error interface.
+*/
+func (e *Not_found_error) Error( ) ( string ) {
+	return fmt.Sprintf( "cannot find %s: %s", e.what, e.name )
+}
+
+/*
+	The requestor isn't allowed to do what was asked (bad cookie or token).
+*/
+type Auth_error struct {
+	reason	string
+}
+
+/*
+	Error interface.
+*/
+func (e *Auth_error) Error( ) ( string ) {
+	return e.reason
+}
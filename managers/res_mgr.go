@@ -46,6 +46,9 @@
 
 					resmgr:res_refresh - The rate (seconds) that reservations are refreshed if hto-limit is non-zero.
 
+					resmgr:requeue_preempted - If true, reservations removed by a higher priority reservation are
+									queued and admitted again when capacity is available (default false).
+
 
 	TODO:		need a way to detect when skoogie/controller has been reset meaning that all
 				pushed reservations need to be pushed again.
@@ -98,6 +101,17 @@
 				25 Jun 2015 : Corrected bug preventing mirror reserations from being deleted (they require an agent
 						command to be run and it wasn't.)
 				08 Sep 2015 : Prevent checkpoint files from being written in the same second (gh#22).
+				17 Oct 2026 : Added REQ_MODRES to modify a bandwidth reservation in place.
+				17 Oct 2026 : Added recurring (series) reservation support.
+				17 Oct 2026 : Added the reservation waitlist.
+				17 Oct 2026 : Added REQ_PREEMPT and yank support for oneway reservations.
+				17 Oct 2026 : Steering reservations are restored from the checkpoint.
+				17 Oct 2026 : Added REQ_REROUTE; reservations moved by the network are pushed again.
+				17 Oct 2026 : Added push state (REQ_PUSH_STATE) from agent acks, and retry of failed pushes.
+				17 Oct 2026 : Added REQ_DESIRED_STATE and REQ_REPUSH for the reconciler.
+				17 Oct 2026 : Rerouted reservations get their new path list here rather than from network.
+				17 Oct 2026 : Added REQ_DEGRADED; the degraded flag of protected reservations is set here.
+				17 Oct 2026 : Get_res returns a Not_found_error or Auth_error so the cause can be tested.
 */
 
 package managers
@@ -129,6 +143,8 @@ type Inventory struct {
 	cache		map[string]*gizmos.Pledge		// cache of pledges
 	ulcap_cache	map[string]int					// cache of user limit values (max value)
 	chkpt		*chkpt.Chkpt
+	waitlist	[]*Wait_ent						// reservations waiting for capacity (see res_mgr_wait.go)
+	wait_seq	int64							// sequence number given to the last entry queued
 }
 
 // --- Private --------------------------------------------------------------------------
@@ -151,7 +167,7 @@ func ( i *Inventory ) res2json( ) (json string, err error) {
 		}
 	}
 
-	json += " ] }"
+	json += fmt.Sprintf( ` ], "waitlist": [ %s ] }`, i.wait2json( ) )
 
 	return
 }
@@ -205,9 +221,10 @@ func get_hostinfo( name *string ) ( *string, *string, *string, int ) {
 
 /*
 	Handles a response from the fq-manager that indicates the attempt to send a proactive ingress/egress flowmod to skoogi
-	has failed.  Issues a warning to the log, and resets the pushed flag for the associated reservation.
+	has failed.  Issues a warning to the log, and resets the pushed flag for the associated reservation
+	unless it has already been retried limit times.
 */
-func (i *Inventory) failed_push( msg *ipc.Chmsg ) {
+func (i *Inventory) failed_push( msg *ipc.Chmsg, limit int ) {
 	if msg.Req_data == nil {
 		rm_sheep.Baa( 0, "IER: notification of failed push had no information" )
 		return
@@ -215,11 +232,41 @@ func (i *Inventory) failed_push( msg *ipc.Chmsg ) {
 
 	fq_data := msg.Req_data.( *Fq_req ) 		// data that was passed to fq_mgr (we'll dig out pledge id
 
-	// TODO: set a counter in pledge so that we only try to push so many times before giving up.
 	rm_sheep.Baa( 1, "WRN: proactive ie reservation push failed, pledge marked unpushed: %s  [TGURMG002]", *fq_data.Id )
 	p := i.cache[*fq_data.Id]
 	if p != nil {
-		(*p).Reset_pushed()
+		(*p).Set_push_state( gizmos.PS_FAILED )
+		(*p).Retry_push( limit )
+	}
+}
+
+/*
+	Handles the push state reported by agent manager once all of the agent actions for a pledge
+	have been acked/nacked (or timed out). If the push failed, or only partially succeeded, the
+	pledge is marked unpushed so that push_retries() will send it again; the number of retries
+	is capped by limit.
+*/
+func (i *Inventory) push_state( rpt *push_report, limit int ) {
+	p := i.cache[rpt.pname]
+	if p == nil {
+		rm_sheep.Baa( 2, "push state for unknown reservation ignored: %s", rpt.pname )
+		return
+	}
+
+	(*p).Set_push_state( rpt.state )
+	if rpt.state == gizmos.PS_INSTALLED {
+		rm_sheep.Baa( 2, "reservation flow-mods installed: %s", rpt.pname )
+		return
+	}
+
+	if (*p).Is_expired() {
+		return
+	}
+
+	if (*p).Retry_push( limit ) {
+		rm_sheep.Baa( 1, "WRN: reservation push %s, will retry: %s: %s  [TGURMG009]", gizmos.Push_state2str( rpt.state ), rpt.pname, rpt.detail )
+	} else {
+		rm_sheep.Baa( 0, "ERR: reservation push %s, retry limit reached: %s: %s  [TGURMG010]", gizmos.Push_state2str( rpt.state ), rpt.pname, rpt.detail )
 	}
 }
 
@@ -345,21 +392,16 @@ func (i *Inventory) push_reservations( ch chan *ipc.Chmsg, alt_table int, hto_li
 			} else {
 				if (*p).Is_active() || (*p).Is_active_soon( 15 ) {	// not pushed, and became active while we napped, or will activate in the next 15 seconds
 					switch (*p).(type) {
-						case *gizmos.Pledge_bwow:
-							bwow_push_res( p, &rname, ch, hto_limit, pref_v6 )
-							(*p).Set_pushed( )
-
 						case *gizmos.Pledge_bw:
 							bw_push_count++
-							bw_push_res( p, &rname, ch, hto_limit, alt_table, pref_v6 )
-	
+
 						case *gizmos.Pledge_steer:
 							st_push_count++
-							push_st_reservation( p, rname, ch, hto_limit )
-	
-						case *gizmos.Pledge_mirror:
-							push_mirror_reservation( p, rname, ch )
+
+						case *gizmos.Pledge_series:				// nothing to push; the occurrences are pushed on their own
+							(*p).Set_pushed( )
 					}
+					i.push_pledge( p, rname, ch, alt_table, hto_limit, pref_v6 )
 
 					pushed_count++
 				} else {					// stil pending
@@ -376,6 +418,110 @@ func (i *Inventory) push_reservations( ch chan *ipc.Chmsg, alt_table int, hto_li
 	return pushed_count
 }
 
+/*
+	Push a single reservation and mark its push state pending. Returns false if the pledge
+	is of a type which is not pushed.
+*/
+func (i *Inventory) push_pledge( p *gizmos.Pledge, rname string, ch chan *ipc.Chmsg, alt_table int, hto_limit int64, pref_v6 bool ) ( bool ) {
+	switch (*p).(type) {
+		case *gizmos.Pledge_bwow:
+			bwow_push_res( p, &rname, ch, hto_limit, pref_v6 )
+			(*p).Set_pushed( )
+
+		case *gizmos.Pledge_bw:
+			bw_push_res( p, &rname, ch, hto_limit, alt_table, pref_v6 )
+
+		case *gizmos.Pledge_steer:
+			push_st_reservation( p, rname, ch, hto_limit )
+
+		case *gizmos.Pledge_mirror:
+			push_mirror_reservation( p, rname, ch )
+
+		default:
+			return false
+	}
+
+	(*p).Set_push_state( gizmos.PS_PENDING )
+	return true
+}
+
+/*
+	Push active reservations whose last push failed, or partly failed, and which were marked
+	unpushed by push_state(). Returns the number pushed.
+*/
+func (i *Inventory) push_retries( ch chan *ipc.Chmsg, alt_table int, hto_limit int64, pref_v6 bool ) ( n int ) {
+	for rname, p := range i.cache {
+		if p == nil || (*p).Is_pushed() || (*p).Is_expired() || ! (*p).Is_active() {
+			continue
+		}
+
+		ps := (*p).Get_push_state()
+		if ps != gizmos.PS_FAILED && ps != gizmos.PS_PARTIAL {
+			continue
+		}
+
+		rm_sheep.Baa( 1, "retrying push of reservation: %s", rname )
+		if i.push_pledge( p, rname, ch, alt_table, hto_limit, pref_v6 ) {
+			n++
+		}
+	}
+
+	return
+}
+
+/*
+	Push the named reservations again; the reconciler found that their flow-mods are missing
+	from one or more hosts. Reservations which are no longer active are skipped. Returns the
+	number pushed.
+*/
+func (i *Inventory) repush( names []string, ch chan *ipc.Chmsg, alt_table int, hto_limit int64, pref_v6 bool ) ( n int ) {
+	for _, rname := range names {
+		p := i.cache[rname]
+		if p == nil || (*p).Is_expired() || ! (*p).Is_active() || (*p).Is_paused() {
+			continue
+		}
+
+		rm_sheep.Baa( 1, "pushing reservation again; flow-mods missing: %s", rname )
+		if i.push_pledge( p, rname, ch, alt_table, hto_limit, pref_v6 ) {
+			n++
+		}
+	}
+
+	return
+}
+
+/*
+	Build the list of bandwidth and oneway flow-mod sets which should be installed for the
+	reconciler. Flow-mods are expected (verified) only for reservations which are active, and
+	which the agents reported as installed. Reservations which will activate shortly, or that
+	are paused or still being pushed, are listed only so that their flow-mods are not
+	considered stale.
+*/
+func (i *Inventory) desired_state( pref_v6 bool ) ( ws *want_state ) {
+	ws = &want_state{ flows: make( []*want_flow, 0, len( i.cache ) ) }
+
+	for rname, p := range i.cache {
+		if p == nil || (*p).Is_expired() {
+			continue
+		}
+		if ! (*p).Is_active() && ! (*p).Is_active_soon( 60 ) {
+			continue
+		}
+
+		verify := (*p).Is_active() && (*p).Is_pushed() && ! (*p).Is_paused() && (*p).Get_push_state() == gizmos.PS_INSTALLED
+		name := rname
+		switch (*p).(type) {
+			case *gizmos.Pledge_bw:
+				ws.flows = append( ws.flows, bw_want_flows( p, &name, pref_v6, verify )... )
+
+			case *gizmos.Pledge_bwow:
+				ws.flows = append( ws.flows, bwow_want_flows( p, &name, pref_v6, verify )... )
+		}
+	}
+
+	return
+}
+
 /*
 	Turn pause mode on for all current reservations and reset their push flag so thta they all get pushed again.
 */
@@ -383,6 +529,9 @@ func (i *Inventory) pause_on( ) {
 	for _, p := range i.cache {
 		(*p).Pause( true )					// also reset the push flag		
 	}
+	for _, w := range i.waitlist {			// queued reservations are paused when admitted
+		(*w.pledge).Pause( true )
+	}
 }
 
 /*
@@ -392,6 +541,11 @@ func (i *Inventory) pause_off( ) {
 	for _, p := range i.cache {
 		(*p).Resume( true )					// also reset the push flag		
 	}
+	for _, w := range i.waitlist {
+		if w.state == WAIT_QUEUED {
+			(*w.pledge).Resume( true )
+		}
+	}
 }
 
 /*
@@ -404,6 +558,56 @@ func (i *Inventory) reset_push() {
 	}
 }
 
+/*
+	Set the new path list on each reservation that the network moved, and reset its pushed flag so
+	that flow-mods for the new paths are sent. The paths are set here, rather than by the network
+	manager, as res_mgr owns the reservations. Returns the number of reservations reset.  Those which
+	could not be moved are left as they are; they are listed in the log with the reason.
+*/
+func (i *Inventory) rerouted( rpt *Reroute_rpt ) ( n int ) {
+	for _, name := range rpt.Get_moved() {
+		if p := i.cache[*name]; p != nil {
+			if bp, ok := (*p).( *gizmos.Pledge_bw ); ok {
+				if plist := rpt.Get_paths( name ); plist != nil {
+					bp.Set_path_list( plist )
+				}
+			}
+			(*p).Reset_pushed( )
+			n++
+		}
+	}
+
+	for _, name := range rpt.Get_failed() {
+		rm_sheep.Baa( 1, "resmgr: reservation %s remains on its original path(s) after a topology change: %s", *name, rpt.reasons[*name] )
+	}
+
+	return
+}
+
+/*
+	Set or clear the degraded flag on the protected reservations listed by the network after a
+	graph rebuild. Only changes are logged.
+*/
+func (i *Inventory) degraded( rpt *Degrade_rpt ) {
+	for _, name := range rpt.degraded {
+		if p := i.cache[*name]; p != nil {
+			if bp, ok := (*p).( *gizmos.Pledge_bw ); ok && ! bp.Is_degraded() {
+				rm_sheep.Baa( 0, "WRN: protected reservation %s is degraded: path(s) lost from the network  [TGURMG011]", *name )
+				bp.Set_degraded( true )
+			}
+		}
+	}
+
+	for _, name := range rpt.intact {
+		if p := i.cache[*name]; p != nil {
+			if bp, ok := (*p).( *gizmos.Pledge_bw ); ok && bp.Is_degraded() {
+				rm_sheep.Baa( 1, "protected reservation %s is no longer degraded; all paths are present", *name )
+				bp.Set_degraded( false )
+			}
+		}
+	}
+}
+
 /*
 	Run the set of reservations in the cache and write any that are not expired out to the checkpoint file.
 	For expired reservations, we'll delete them if they test positive for extinction (dead for more than 120
@@ -449,6 +653,12 @@ func (i *Inventory) write_chkpt( last int64 ) ( retry bool, timestamp int64 ) {
 		}
 	}
 
+	for _, w := range i.waitlist {								// waitlist follows the inventory so admitted entries can find their pledge
+		if s := w.To_chkpt(); s != "expired" {
+			fmt.Fprintf( i.chkpt, "%s\n", s )
+		}
+	}
+
 	ckpt_name, err := i.chkpt.Close( )
 	if err != nil {
 		rm_sheep.Baa( 0, "CRI: resmgr: checkpoint write failed: %s: %s  [TGURMG004]", ckpt_name, err )
@@ -496,6 +706,11 @@ func (i *Inventory) load_chkpt( fname *string ) ( err error ) {
 						i.add_ulcap( &toks[1], &toks[2] )
 					}
 
+				case "wait:":
+					if werr := i.load_wait( rec ); werr != nil {
+						rm_sheep.Baa( 0, "ERR: resmgr: ckpt_load: unable to restore waitlist entry: %s  [TGURMG000]", werr )
+					}
+
 				default:
 					p, err = gizmos.Json2pledge( &rec )			// convert any type of json pledge to Pledge
 		
@@ -508,7 +723,15 @@ func (i *Inventory) load_chkpt( fname *string ) ( err error ) {
 									err = i.Add_res( p )								// assume we can just add it back in as is
 
 								case *gizmos.Pledge_steer:
-									rm_sheep.Baa( 0, "did not restore steering reservation from checkpoint; not implemented" )
+									restore_steer( sp, my_ch )							// update graph and refresh middlebox switch/port; flow-mods go on next push
+									rm_sheep.Baa( 1, "steering reservation restored from checkpoint: %s; %d middleboxes", *(sp.Get_id()), sp.Get_mbox_count() )
+									err = i.Add_res( p )
+
+								case *gizmos.Pledge_series:
+									h1, h2 := sp.Get_hosts( )							// occurrences are restored as bw pledges; the series just needs the graph to know the hosts
+									update_graph( h1, false, false )
+									update_graph( h2, true, true )
+									err = i.Add_res( p )
 
 								case *gizmos.Pledge_bwow:
 									h1, h2 := sp.Get_hosts( )							// get the host names, fetch ostack data and update graph
@@ -685,14 +908,14 @@ func (inv *Inventory) Get_res( name *string, cookie *string ) (p *gizmos.Pledge,
 	state = nil
 	p = inv.cache[*name]
 	if p == nil {
-		state = fmt.Errorf( "cannot find reservation: %s", *name )
+		state = &Not_found_error{ what: "reservation", name: *name }
 		return
 	}
 
 	if ! (*p).Is_valid_cookie( cookie ) &&  *cookie != *super_cookie {
 		rm_sheep.Baa( 2, "resgmgr: denied fetch of reservation: cookie supplied (%s) didn't match that on pledge %s", *cookie, *name )
 		p = nil
-		state = fmt.Errorf( "not authorised to access or delete reservation: %s", *name )
+		state = &Auth_error{ reason: "not authorised to access or delete reservation: " + *name }
 		return
 	}
 
@@ -775,9 +998,18 @@ func (inv *Inventory) Del_res( name *string, cookie *string ) (state error) {
 				state = req.State
 				p.Set_expiry( time.Now().Unix() + 15 )				// set the expiry to 15s from now which will force it out
 				(*gp).Reset_pushed()						// force push of flow-mods that reset the expiry
+
+			case *gizmos.Pledge_series:
+				state = inv.del_series( p )							// deletes generated occurrences and stops further generation
 		}
 	} else {
-		rm_sheep.Baa( 2, "resgmgr: unable to delete reservation: not found: %s", *name )
+		if found, wstate := inv.del_wait( name, cookie ); found {	// still on the waitlist; just abandon it
+			state = wstate
+		} else if ps, _ := inv.series_of( name ); ps != nil {		// occurrence not yet generated; skip it when the time comes
+			state = inv.skip_occurrence( name, cookie )
+		} else {
+			rm_sheep.Baa( 2, "resgmgr: unable to delete reservation: not found: %s", *name )
+		}
 	}
 
 	return
@@ -799,6 +1031,9 @@ func (inv *Inventory) Del_all_res( cookie *string ) ( ndel int ) {
 	plist = make( []*string, len( inv.cache ) )			// build a list so we can safely remove from the map
 	for _, pledge := range inv.cache {
 		if ! (*pledge).Is_expired( ) {
+			if ps, _ := inv.series_of( (*pledge).Get_id() ); ps != nil && ! ps.Is_expired() {
+				continue									// occurrences are deleted with their series
+			}
 			plist[i] = (*pledge).Get_id()
 			i++
 		}
@@ -816,6 +1051,14 @@ func (inv *Inventory) Del_all_res( cookie *string ) ( ndel int ) {
 		}
 	}
 
+	for _, w := range inv.waitlist {							// queued reservations are abandoned too
+		if w.state == WAIT_QUEUED {
+			if found, err := inv.del_wait( (*w.pledge).Get_id(), cookie ); found && err == nil {
+				ndel++
+			}
+		}
+	}
+
 	rm_sheep.Baa( 1, "delete all deleted %d reservations %s", ndel )
 	return
 }
@@ -864,6 +1107,27 @@ func (inv *Inventory) yank_res( name *string ) ( p *gizmos.Pledge, state error)
 				cp.Set_expiry( time.Now().Unix() + 1 )			// force clone to be expired
 				cp.Reset_pushed( )								// force it to go out again
 
+			case *gizmos.Pledge_bwow:
+				rm_sheep.Baa( 2, "resgmgr: yanked oneway reservation: %s", (*p).To_str() )
+				cp := pldg.Clone( *name + ".yank" )
+				cp.Set_gate( pldg.Get_gate() )					// network needs the gate to release the queue
+
+				icp := gizmos.Pledge(cp)
+				inv.cache[*name + ".yank"] = &icp
+
+				inv.cache[*name] = nil
+				delete( inv.cache, *name )
+
+				ch := make( chan *ipc.Chmsg )
+				defer close( ch )
+				req := ipc.Mk_chmsg( )
+				req.Send_req( nw_ch, ch, REQ_DEL, cp, nil )
+				req = <- ch
+				state = req.State
+
+				cp.Set_expiry( time.Now().Unix() + 1 )
+				cp.Reset_pushed( )
+
 			// not supported for other pledge types
 		}
 	} else {
@@ -895,6 +1159,9 @@ func Res_manager( my_chan chan *ipc.Chmsg, cookie *string ) {
 		res_refresh	int64 = 0			// next time when we must force all reservations to refresh flow-mods (hto_limit nonzero)
 		rr_rate		int = 3600			// refresh rate (1 hour)
 		favour_v6 bool = true			// favour ipv6 addresses if a host has both defined.
+		series_horizon int64 = 86400 * 7	// occurrences of recurring reservations are admitted this far ahead
+		requeue_preempted bool = false	// preempted reservations are queued rather than discarded
+		push_retry	int = 3				// number of times a failed push is retried
 	)
 
 	super_cookie = cookie				// global for all methods
@@ -965,6 +1232,25 @@ func Res_manager( my_chan chan *ipc.Chmsg, cookie *string ) {
 				}
 			}
 		}
+
+		p = cfg_data["resmgr"]["requeue_preempted"]			// preempted reservations go on the waitlist as queued
+		if p != nil {
+			requeue_preempted = *p == "true"
+		}
+
+		p = cfg_data["resmgr"]["push_retry"]				// number of times a push which the agent(s) report failed is retried
+		if p != nil {
+			push_retry = clike.Atoi( *p )
+		}
+
+		p = cfg_data["resmgr"]["series_horizon"]			// how far ahead occurrences of recurring reservations are generated
+		if p != nil {
+			series_horizon = int64( clike.Atoi( *p ) )
+			if series_horizon < 3600 {
+				rm_sheep.Baa( 0, "NOTICE: series horizon in config is too low (%ds) and was changed to 3600s", series_horizon )
+				series_horizon = 3600
+			}
+		}
 	}
 
 	rm_sheep.Baa( 1, "ovs table number %d used for metadata marking", alt_table )
@@ -977,6 +1263,8 @@ func Res_manager( my_chan chan *ipc.Chmsg, cookie *string ) {
 	tklr.Add_spot( 2, my_chan, REQ_PUSH, nil, ipc.FOREVER )			// push reservations to agent just before they go live
 	tklr.Add_spot( 1, my_chan, REQ_SETQUEUES, nil, ipc.FOREVER )	// drives us to see if queues need to be adjusted
 	tklr.Add_spot( 5, my_chan, REQ_RTRY_CHKPT, nil, ipc.FOREVER )		// ensures that we retried any missed checkpoints
+	tklr.Add_spot( 60, my_chan, REQ_EXPSERIES, nil, ipc.FOREVER )		// generate occurrences of recurring reservations as they come inside of the horizon
+	tklr.Add_spot( 30, my_chan, REQ_RETRYWAIT, nil, ipc.FOREVER )		// backstop to retry/abandon queued reservations
 
 	rm_sheep.Baa( 3, "res_mgr is running  %x", my_chan )
 	for {
@@ -1019,9 +1307,74 @@ func Res_manager( my_chan chan *ipc.Chmsg, cookie *string ) {
 					msg.State = inv.Del_res( data[0], data[1] )
 				}
 
+				if n, _ := inv.retry_wait( ); n > 0 {					// deleted capacity may allow queued reservations in
+					tmsg := ipc.Mk_chmsg( )
+					tmsg.Send_req( nw_ch, my_chan, queue_gen_type, time.Now().Unix(), nil )
+				}
 				inv.push_reservations( my_chan, alt_table, int64( hto_limit ), favour_v6 )			// must force a push to push augmented (shortened) reservations
 				msg.Response_data = nil
 
+			case REQ_MODRES:										// user initiated modification -- requires cookie
+				msg.Response_data = nil
+				p, err := inv.mod_res( msg.Req_data.( *Bw_mod ) )
+				msg.State = err
+				if err == nil {
+					msg.Response_data = p.To_json()
+					tmsg := ipc.Mk_chmsg( )
+					tmsg.Send_req( nw_ch, my_chan, queue_gen_type, time.Now().Unix(), nil )		// queues must be regenerated; flow-mods are pushed when the map arrives
+				}
+
+			case REQ_ADDSERIES:										// add a recurring reservation; occurrences inside of the horizon must all be admitted
+				msg.Response_data = nil
+				msg.State = inv.add_series( msg.Req_data.( *gizmos.Pledge_series ), series_horizon )
+
+			case REQ_EXPSERIES:										// driven by tickler to admit occurrences as they come inside of the horizon
+				if inv.expand_series( series_horizon ) > 0 && all_sys_up {
+					retry_chkpt, last_chkpt = inv.write_chkpt( last_chkpt )
+				}
+
+			case REQ_ADDWAIT:										// add a rejected reservation to the waitlist; response is a Queued_state
+				msg.Response_data = nil
+				msg.State = nil
+				w := msg.Req_data.( *Wait_ent )
+				if _, err := inv.add_wait( w ); err == nil {
+					msg.Response_data = &Queued_state{ reason: w.reason, json: w.To_json() }
+				} else {
+					msg.State = err
+				}
+
+			case REQ_PREEMPT:										// yank lower priority reservations and admit a new one
+				msg.Response_data = nil
+				if msg.State = inv.preempt( msg.Req_data.( *Preempt_req ), requeue_preempted ); msg.State == nil {
+					tmsg := ipc.Mk_chmsg( )
+					tmsg.Send_req( nw_ch, my_chan, queue_gen_type, time.Now().Unix(), nil )		// new queues; flow-mods pushed when the map arrives
+					if all_sys_up {
+						retry_chkpt, last_chkpt = inv.write_chkpt( last_chkpt )
+					}
+				}
+
+			case REQ_REROUTE:										// network moved reservations after a topology change
+				msg.Response_data = nil
+				if inv.rerouted( msg.Req_data.( *Reroute_rpt ) ) > 0 {
+					tmsg := ipc.Mk_chmsg( )
+					tmsg.Send_req( nw_ch, my_chan, queue_gen_type, time.Now().Unix(), nil )		// queues for the new paths; flow-mods pushed when the map arrives
+				}
+
+			case REQ_DEGRADED:										// network found protected reservations which lost/regained a path
+				msg.Response_data = nil
+				inv.degraded( msg.Req_data.( *Degrade_rpt ) )
+
+			case REQ_RETRYWAIT:										// capacity may have been freed (tickler, network rebuild)
+				msg.Response_data = nil
+				nadmitted, nchanged := inv.retry_wait( )
+				if nadmitted > 0 {
+					tmsg := ipc.Mk_chmsg( )
+					tmsg.Send_req( nw_ch, my_chan, queue_gen_type, time.Now().Unix(), nil )		// queues for the admitted reservations; flow-mods pushed when the map arrives
+				}
+				if nchanged > 0 && all_sys_up {
+					retry_chkpt, last_chkpt = inv.write_chkpt( last_chkpt )
+				}
+
 			case REQ_DUPCHECK:
 				if msg.Req_data != nil {
 					msg.Response_data, msg.State = inv.dup_check(  msg.Req_data.( *gizmos.Pledge ) )
@@ -1055,6 +1408,11 @@ func Res_manager( my_chan chan *ipc.Chmsg, cookie *string ) {
 
 			case REQ_SETQUEUES:							// driven about every second to reset the queues if a reservation state has changed
 				now := time.Now().Unix()
+				if now > last_qcheck  &&  inv.any_concluded( now - last_qcheck ) {
+					if _, nc := inv.retry_wait( ); nc > 0 && all_sys_up {	// capacity freed by the expiry may let queued reservations in; queue map requested below
+						retry_chkpt, last_chkpt = inv.write_chkpt( last_chkpt )
+					}
+				}
 				if now > last_qcheck  &&  inv.any_concluded( now - last_qcheck ) || inv.any_commencing( now - last_qcheck, 0 ) {
 					rm_sheep.Baa( 1, "reservation state change detected, requesting queue map from net-mgr" )
 					tmsg := ipc.Mk_chmsg( )
@@ -1063,6 +1421,8 @@ func Res_manager( my_chan chan *ipc.Chmsg, cookie *string ) {
 				last_qcheck = now
 
 			case REQ_PUSH:								// driven every few seconds to check for need to refresh because of switch max timeout setting
+				inv.push_retries( my_chan, alt_table, int64( hto_limit ), favour_v6 )		// pushes which agents reported as failed
+
 				if hto_limit > 0 {						// if reservation flow-mods are capped with a hard timeout limit
 					now := time.Now().Unix()
 					if now > res_refresh {
@@ -1075,18 +1435,37 @@ func Res_manager( my_chan chan *ipc.Chmsg, cookie *string ) {
 				}
 
 
+			case REQ_DESIRED_STATE:						// reconciler wants the flow-mods which should be installed
+				msg.Response_data = inv.desired_state( favour_v6 )
+
+			case REQ_REPUSH:							// reconciler found reservations with missing flow-mods
+				msg.Response_ch = nil
+				if names, ok := msg.Req_data.( []string ); ok {
+					inv.repush( names, my_chan, alt_table, int64( hto_limit ), favour_v6 )
+				}
+
 			case REQ_PLEDGE_LIST:						// generate a list of pledges that are related to the given VM
 				msg.Response_data, msg.State = inv.pledge_list(  msg.Req_data.( *string ) )
 
 			case REQ_SETULCAP:							// user link capacity; expect array of two string pointers (name and value)
 				data := msg.Req_data.( []*string )
 				inv.add_ulcap( data[0], data[1] )
+				if n, _ := inv.retry_wait( ); n > 0 {					// network handles the new cap before our admission requests
+					tmsg := ipc.Mk_chmsg( )
+					tmsg.Send_req( nw_ch, my_chan, queue_gen_type, time.Now().Unix(), nil )
+				}
 				retry_chkpt, last_chkpt = inv.write_chkpt( last_chkpt )
 
 			// CAUTION: the requests below come back as asynch responses rather than as initial message
 			case REQ_IE_RESERVE:						// an IE reservation failed
 				msg.Response_ch = nil					// immediately disable to prevent loop
-				inv.failed_push( msg )					// suss out the pledge and mark it unpushed
+				inv.failed_push( msg, push_retry )		// suss out the pledge and mark it unpushed
+
+			case REQ_PUSH_STATE:						// agent manager heard from the agent(s) for all actions pushing a pledge
+				msg.Response_ch = nil
+				if msg.Req_data != nil {
+					inv.push_state( msg.Req_data.( *push_report ), push_retry )
+				}
 
 			case REQ_GEN_QMAP:							// response caries the queue map that now should be sent to fq-mgr to drive a queue update
 				fallthrough
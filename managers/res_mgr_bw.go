@@ -31,11 +31,15 @@
 				26 May 2015 - Changes to support pledge as an interface.
 				11 Jun 2015 - Added bwow support and renamed bw push function.
 				18 Jun 2015 - Added oneway rate limiting support.
+				17 Oct 2026 - Added modification of an existing bandwidth reservation (Bw_mod).
+				17 Oct 2026 - Backup paths of a protected reservation are not pushed.
+				17 Oct 2026 - Added bw_want_flows and bwow_want_flows for the reconciler.
 */
 
 package managers
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
@@ -43,6 +47,96 @@ import (
 	"github.com/att/tegu/gizmos"
 )
 
+/*
+	Describes a change to an existing bandwidth reservation. Values that are zero are left
+	unchanged. The pledge is filled in by res_mgr after the name/cookie pair is validated
+	and is what the network manager uses to adjust the link obligations.
+*/
+type Bw_mod struct {
+	name		*string				// reservation name and cookie as supplied by the user
+	cookie		*string
+	expiry		int64				// new expiry time (0 == no change)
+	bandw_in	int64				// new bandwidth amounts (0 == no change)
+	bandw_out	int64
+	pledge		*gizmos.Pledge_bw
+}
+
+/*
+	Create a modification request.
+*/
+func Mk_bw_mod( name *string, cookie *string, expiry int64, bandw_in int64, bandw_out int64 ) ( m *Bw_mod ) {
+	if cookie == nil {
+		cookie = &empty_str
+	}
+
+	m = &Bw_mod {
+		name:		name,
+		cookie:		cookie,
+		expiry:		expiry,
+		bandw_in:	bandw_in,
+		bandw_out:	bandw_out,
+	}
+
+	return
+}
+
+/*
+	Modify the bandwidth and/or expiry of an existing bandwidth reservation keeping the same
+	name, cookie and queue id. The network manager is asked to release the current utilisation,
+	verify capacity for the new values, and apply them (restoring the original if there is not
+	capacity). Only after the network has accepted the change is the pledge updated; the pledge
+	is marked unpushed so that bw_push_res sends flow-mods with the new expiry.
+	The modified pledge is returned on success.
+*/
+func (inv *Inventory) mod_res( m *Bw_mod ) ( p *gizmos.Pledge_bw, state error ) {
+	if m == nil || m.name == nil {
+		return nil, fmt.Errorf( "no reservation name given" )
+	}
+
+	gp, state := inv.Get_res( m.name, m.cookie )
+	if gp == nil {
+		return
+	}
+
+	p, ok := (*gp).( *gizmos.Pledge_bw )
+	if ! ok {
+		return nil, fmt.Errorf( "only bandwidth reservations may be modified: %s", *m.name )
+	}
+
+	if p.Is_expired( ) {
+		return nil, fmt.Errorf( "reservation has expired: %s", *m.name )
+	}
+
+	if m.expiry > 0 {
+		commence, _ := p.Get_window( )
+		if m.expiry <= time.Now().Unix() || m.expiry <= commence {
+			return nil, fmt.Errorf( "new expiry time (%d) must be in the future and after the reservation start time", m.expiry )
+		}
+	}
+
+	if m.expiry <= 0 && m.bandw_in <= 0 && m.bandw_out <= 0 {
+		return nil, fmt.Errorf( "no changes given for reservation: %s", *m.name )
+	}
+
+	m.pledge = p
+	ch := make( chan *ipc.Chmsg )
+	defer close( ch )									// close it on return
+	req := ipc.Mk_chmsg( )
+	req.Send_req( nw_ch, ch, REQ_MODRES, m, nil )		// network does the capacity check and adjusts link utilisation
+	req = <- ch
+	if req.State != nil {
+		return nil, req.State
+	}
+
+	if m.expiry > 0 {
+		p.Set_expiry( m.expiry )						// only now safe to change the window
+	}
+	p.Set_bandw( m.bandw_in, m.bandw_out )			// both setters reset the pushed flag
+
+	rm_sheep.Baa( 1, "resgmgr: modified reservation: %s", p.To_chkpt() )
+	return p, nil
+}
+
 /*
 	For a single bandwidth pledge, this function sets things up and sends needed requests to the fq-manger to
 	create any necessary flow-mods.   This has changed drastically now that we expect one agent
@@ -95,6 +189,10 @@ func bw_push_res( gp *gizmos.Pledge, rname *string, ch chan *ipc.Chmsg, to_limit
 		timestamp := time.Now().Unix() + 16					// assume this will fall within the first few seconds of the reservation as we use it to find queue in timeslice
 
 		for i := range plist { 								// for each path, send fmgr requests for each endpoint
+			if plist[i].Is_backup() {						// capacity is held on a protected reservation's backup, but no flow-mods
+				continue
+			}
+
 			freq := Mk_fqreq( rname )						// default flow mod request with empty match/actions (for bw requests, we don't need priority or such things)
 
 			freq.Ipv6 = p.Get_matchv6()						// should we force a match on IPv6 rather than IPv4?
@@ -268,3 +366,71 @@ func bwow_push_res( gp *gizmos.Pledge, rname *string, ch chan *ipc.Chmsg, to_lim
 		rm_sheep.Baa( 1, "oneway not pushed: could not map one/both hosts to an IP address" )
 	}
 }
+
+/*
+	List the endpoint flow-mod sets which bw_push_res would cause to be installed for the
+	pledge: one set on the endpoint host of each (non-backup) path. Addresses are left for
+	fq_mgr to translate to macs. Verify is set on each to indicate whether the flow-mods
+	must exist.
+*/
+func bw_want_flows( gp *gizmos.Pledge, rname *string, pref_v6 bool, verify bool ) ( flows []*want_flow ) {
+	p, ok :=  (*gp).( *gizmos.Pledge_bw )
+	if ! ok {
+		return nil
+	}
+
+	timestamp := time.Now().Unix() + 16
+	plist := p.Get_path_list( )
+	for i := range plist {
+		if plist[i].Is_backup() {
+			continue
+		}
+
+		espq := plist[i].Get_ilink_spq( rname, timestamp )
+		if espq == nil || espq.Switch == "" {
+			continue
+		}
+
+		flows = append( flows, &want_flow {
+			pname:	*rname,
+			host:	espq.Switch,
+			cookie:	bw_cookie,
+			ip1:	plist[i].Get_h1().Get_address( pref_v6 ),
+			ip2:	plist[i].Get_h2().Get_address( pref_v6 ),
+			verify:	verify,
+		} )
+	}
+
+	return
+}
+
+/*
+	List the flow-mod set which bwow_push_res would cause to be installed for the oneway pledge.
+*/
+func bwow_want_flows( gp *gizmos.Pledge, rname *string, pref_v6 bool, verify bool ) ( flows []*want_flow ) {
+	p, ok :=  (*gp).( *gizmos.Pledge_bwow )
+	if ! ok {
+		return nil
+	}
+
+	gate := p.Get_gate( )
+	if gate == nil {
+		return nil
+	}
+
+	espq := gate.Get_spq( rname, time.Now().Unix() + 16 )
+	if espq == nil || espq.Switch == "" {
+		return nil
+	}
+
+	flows = append( flows, &want_flow {
+		pname:	*rname,
+		host:	espq.Switch,
+		cookie:	bwow_cookie,
+		ip1:	gate.Get_src().Get_address( pref_v6 ),
+		ip2:	gate.Get_dest().Get_address( pref_v6 ),
+		verify:	verify,
+	} )
+
+	return
+}
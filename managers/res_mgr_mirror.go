@@ -26,6 +26,7 @@
 
 	Mods:		23 Feb 2015 : Created.
 				26 May 2015 - Changes to support pledge as an interface.
+				17 Oct 2026 - Pledge name added to the action so that the agent ack can be mapped back.
 */
 
 package managers
@@ -56,6 +57,7 @@ func push_mirror_reservation( gp *gizmos.Pledge, rname string, ch chan *ipc.Chms
 	rm_sheep.Baa( 1, "Adding mirror %s on host %s", *id, *host )
 	json := `{ "ctype": "action_list", "actions": [ { `
 	json += `"atype": "mirrorwiz", `
+	json += fmt.Sprintf(`"pname": %q, `, rname)
 	json += fmt.Sprintf(`"hosts": [ %q ], `,  *host)
 	if strings.Contains(ports2, ",vlan:") {
 		// Because we have to store the ports list and the vlans in the same field
@@ -91,6 +93,7 @@ func undo_mirror_reservation( gp *gizmos.Pledge, rname string, ch chan *ipc.Chms
 	rm_sheep.Baa( 1, "Deleting mirror %s on host %s", *id, *host )
 	json := `{ "ctype": "action_list", "actions": [ { `
 	json += `"atype": "mirrorwiz", `
+	json += fmt.Sprintf(`"pname": %q, `, rname)
 	json += fmt.Sprintf(`"hosts": [ %q ], `,  *host)
 	json += fmt.Sprintf(`"qdata": [ "del", %q ] `, *id)
 	json += `} ] }`
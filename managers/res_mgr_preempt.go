@@ -0,0 +1,194 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	res_mgr_preempt
+	Abstract:	reservation manager functions that support priority preemption. When the network
+				manager rejects a bandwidth reservation with a Preempt_error, the requestor sends
+				the pledge and the list of victims to res_mgr (REQ_PREEMPT). Each victim is yanked
+				(the same way a refresh yanks a reservation, so flow-mods are allowed to drop) and
+				the new reservation is then admitted. If the admission still fails the victims are
+				put back.
+
+				The owner of a preempted reservation is notified with a warning in the log and
+				an entry on the waitlist which is reported by listres. If resmgr:requeue_preempted
+				is true the entry is queued and the reservation is admitted again when capacity
+				becomes available before its window closes; otherwise the entry is left in the
+				preempted state.
+
+	Date:		17 October 2026
+
+	Mods:		17 Oct 2026 - Release the network admission on every failure after it was granted.
+*/
+
+package managers
+
+import (
+	"fmt"
+
+	"github.com/att/gopkgs/ipc"
+	"github.com/att/tegu/gizmos"
+)
+
+/*
+	Passed to res_mgr on a REQ_PREEMPT request.
+*/
+type Preempt_req struct {
+	pledge		*gizmos.Pledge_bw			// the reservation to admit
+	victims		[]*string					// names of the reservations to remove first
+}
+
+/*
+	Create a preemption request.
+*/
+func Mk_preempt_req( p *gizmos.Pledge_bw, victims []*string ) ( *Preempt_req ) {
+	return &Preempt_req {
+		pledge:		p,
+		victims:	victims,
+	}
+}
+
+/*
+	Put a reservation which could not be restored after a failed preemption, or which was
+	preempted, on the waitlist. The entry is queued if requeue is true.
+*/
+func (inv *Inventory) wait_preempted( p *gizmos.Pledge, requeue bool, reason string ) {
+	w := Mk_wait_ent( *p, (*p).Get_priority(), reason )
+	inv.wait_seq++
+	w.seq = inv.wait_seq
+	if ! requeue {
+		w.state = WAIT_PREEMPTED
+	}
+
+	inv.waitlist = append( inv.waitlist, w )
+	inv.sort_wait( )
+}
+
+/*
+	Yank the victims and admit the pledge.  Each victim must still be in the inventory and must
+	have a lower priority than the pledge; if not the request fails without anything being changed.
+	If the network rejects the pledge after the victims have been removed, the victims are
+	admitted again and the error from the network is returned.  On success the victims are
+	placed on the waitlist (queued if requeue is true) and the pledge is added to the inventory.
+*/
+func (inv *Inventory) preempt( pr *Preempt_req, requeue bool ) ( err error ) {
+	var (
+		yanked	[]*gizmos.Pledge
+	)
+
+	if pr == nil || pr.pledge == nil || len( pr.victims ) == 0 {
+		return fmt.Errorf( "preempt: no pledge or victims given" )
+	}
+
+	pid := pr.pledge.Get_id()
+	pri := pr.pledge.Get_priority()
+	for _, name := range pr.victims {
+		vp := inv.cache[*name]
+		if vp == nil || (*vp).Is_expired() {
+			return fmt.Errorf( "preempt: reservation to preempt no longer exists: %s", *name )
+		}
+		if (*vp).Get_priority() >= pri {
+			return fmt.Errorf( "preempt: reservation %s is not a lower priority than %s", *name, *pid )
+		}
+		if ! preemptable( vp ) {
+			return fmt.Errorf( "preempt: reservation cannot be preempted: %s", *name )
+		}
+	}
+
+	for _, name := range pr.victims {
+		vp, state := inv.yank_res( name )
+		if state != nil {
+			rm_sheep.Baa( 1, "resgmgr: preempt: network error removing reservation %s: %s", *name, state )
+		}
+		yanked = append( yanked, vp )
+	}
+
+	ch := make( chan *ipc.Chmsg )
+	defer close( ch )
+
+	if err = nw_admit( pr.pledge, ch ); err == nil {
+		if err = inv.Add_res( pr.pledge ); err != nil {
+			nw_release( pr.pledge, ch )							// give it back before the victims are restored
+		}
+	}
+
+	if err != nil {
+		rm_sheep.Baa( 1, "resgmgr: preempt: %s not admitted after removing %d reservation(s), restoring them: %s", *pid, len( yanked ), err )
+		for _, vp := range yanked {
+			inv.restore_yanked( vp, ch, requeue )
+		}
+		return
+	}
+
+	for _, vp := range yanked {
+		id := (*vp).Get_id()
+		h1, h2 := (*vp).Get_hosts()
+		rm_sheep.Baa( 0, "WRN: resmgr: reservation %s (%s -> %s) preempted by %s (priority %d > %d) requeued=%v  [TGURMG006]",
+			*id, *h1, *h2, *pid, pri, (*vp).Get_priority(), requeue )
+		inv.wait_preempted( vp, requeue, fmt.Sprintf( "preempted by %s (priority %d)", *pid, pri ) )
+	}
+
+	return
+}
+
+/*
+	Return true if the pledge is a type which can be preempted.
+*/
+func preemptable( p *gizmos.Pledge ) ( bool ) {
+	switch (*p).(type) {
+		case *gizmos.Pledge_bw, *gizmos.Pledge_bwow:
+			return true
+	}
+
+	return false
+}
+
+/*
+	Put a yanked reservation back: the expired clone is removed from the inventory and the
+	reservation is admitted again. If it cannot be (unlikely as its capacity was just freed)
+	it is put on the waitlist.
+*/
+func (inv *Inventory) restore_yanked( p *gizmos.Pledge, ch chan *ipc.Chmsg, requeue bool ) {
+	var err error
+
+	id := (*p).Get_id()
+	delete( inv.cache, *id + ".yank" )
+
+	switch sp := (*p).(type) {
+		case *gizmos.Pledge_bw:
+			err = nw_admit( sp, ch )
+
+		case *gizmos.Pledge_bwow:
+			err = nw_admit_ow( sp, ch )
+	}
+
+	if err == nil {
+		(*p).Reset_pushed( )								// flow-mods may have been dropped
+		if err = inv.Add_res( p ); err != nil {
+			nw_release( *p, ch )
+		}
+	}
+
+	if err != nil {
+		rm_sheep.Baa( 0, "WRN: resmgr: reservation %s could not be restored after failed preemption: %s  [TGURMG007]", *id, err )
+		inv.wait_preempted( p, requeue, fmt.Sprintf( "not restored after failed preemption: %s", err ) )
+	}
+}
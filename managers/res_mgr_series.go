@@ -0,0 +1,238 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	res_mgr_series
+	Abstract:	reservation manager functions that support recurring (series) reservations.
+				A series lives in the inventory along side of the occurrences that have been
+				generated from it; each occurrence is an ordinary bandwidth pledge named
+				<series-id>.<n> and is pushed, modified and deleted as any other bandwidth
+				reservation.  Occurrences are generated, and admitted by the network manager,
+				when they come within the horizon (resmgr:series_horizon seconds, default
+				7 days).
+
+	Date:		17 October 2026
+
+	Mods:		17 Oct 2026 - Release an occurrence's admission if it can't be added to the inventory.
+				17 Oct 2026 - Unknown occurrences are reported with a Not_found_error.
+*/
+
+package managers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/att/gopkgs/ipc"
+	"github.com/att/tegu/gizmos"
+)
+
+/*
+	Ask the network manager to admit the bandwidth pledge. On success the path list is
+	set in the pledge, otherwise the error from the network manager is returned.
+*/
+func nw_admit( p *gizmos.Pledge_bw, ch chan *ipc.Chmsg ) ( err error ) {
+	req := ipc.Mk_chmsg( )
+	req.Send_req( nw_ch, ch, REQ_BW_RESERVE, p, nil )
+	req = <- ch
+
+	if req.Response_data == nil {
+		if req.State == nil {
+			return fmt.Errorf( "no path with enough capacity" )
+		}
+		return req.State
+	}
+
+	p.Set_path_list( req.Response_data.( []*gizmos.Path ) )
+	return nil
+}
+
+/*
+	Admit the occurrences in the list and add them to the inventory. If all_or_none is set,
+	any failure causes the occurrences admitted so far to be released and the error is
+	returned; otherwise failures are logged and the occurrence is skipped.
+*/
+func (inv *Inventory) admit_occurrences( ps *gizmos.Pledge_series, list []*gizmos.Pledge_bw, all_or_none bool ) ( nadded int, err error ) {
+	var (
+		admitted	[]*gizmos.Pledge_bw
+	)
+
+	ch := make( chan *ipc.Chmsg )
+	defer close( ch )
+
+	for _, op := range list {
+		gp := gizmos.Pledge( op )
+		if rid, _ := inv.dup_check( &gp ); rid != nil {
+			err = fmt.Errorf( "occurrence %s duplicates existing reservation: %s", *op.Get_id(), *rid )
+		} else {
+			err = nw_admit( op, ch )
+		}
+
+		if err != nil {
+			if all_or_none {
+				for _, ap := range admitted {						// release what we've admitted; nothing has been added to the inventory
+					nw_release( ap, ch )
+				}
+				return 0, fmt.Errorf( "occurrence %s could not be admitted: %s", *op.Get_id(), err )
+			}
+
+			rm_sheep.Baa( 0, "WRN: resmgr: series %s: occurrence %s not admitted: %s  [TGURMG005]", *ps.Get_id(), *op.Get_id(), err )
+			err = nil
+			continue
+		}
+
+		if ps.Is_paused( ) {
+			op.Pause( false )										// paused reservations are marked pushed so they don't go out until resumed
+			op.Set_pushed( )
+		}
+		admitted = append( admitted, op )
+	}
+
+	for _, op := range admitted {
+		if inv.Add_res( op ) == nil {
+			nadded++
+		} else {
+			nw_release( op, ch )
+		}
+	}
+
+	return
+}
+
+/*
+	Add a series to the inventory admitting each occurrence which commences before the horizon.
+	Either all of those occurrences are admitted, or none are and the series is rejected.
+*/
+func (inv *Inventory) add_series( ps *gizmos.Pledge_series, horizon int64 ) ( err error ) {
+	if ps == nil {
+		return fmt.Errorf( "no series pledge given" )
+	}
+	if inv.cache[*ps.Get_id()] != nil {
+		return fmt.Errorf( "reservation already exists: %s", *ps.Get_id() )
+	}
+
+	list := ps.Next_occurrences( time.Now().Unix() + horizon )
+	nadded, err := inv.admit_occurrences( ps, list, true )
+	if err != nil {
+		return
+	}
+
+	err = inv.Add_res( ps )
+	rm_sheep.Baa( 1, "resgmgr: series %s added with %d occurrences inside of the horizon", *ps.Get_id(), nadded )
+	return
+}
+
+/*
+	Generate and admit occurrences of each series which have come inside of the horizon.
+	Returns the number of occurrences added.
+*/
+func (inv *Inventory) expand_series( horizon int64 ) ( nadded int ) {
+	until := time.Now().Unix() + horizon
+
+	for _, p := range inv.cache {
+		if ps, ok := (*p).( *gizmos.Pledge_series ); ok && ! ps.Is_expired() {
+			list := ps.Next_occurrences( until )
+			if len( list ) > 0 {
+				n, _ := inv.admit_occurrences( ps, list, false )
+				nadded += n
+			}
+		}
+	}
+
+	if nadded > 0 {
+		rm_sheep.Baa( 1, "resgmgr: %d series occurrences added", nadded )
+	}
+	return
+}
+
+/*
+	If the name is that of an occurrence, return the series that it belongs to and the
+	occurrence number. Ps is nil if the name isn't an occurrence of a series in the inventory.
+*/
+func (inv *Inventory) series_of( name *string ) ( ps *gizmos.Pledge_series, n int ) {
+	if name == nil {
+		return nil, 0
+	}
+
+	dot := strings.LastIndex( *name, "." )
+	if dot < 0 {
+		return nil, 0
+	}
+
+	sname := (*name)[:dot]
+	if gp := inv.cache[sname]; gp != nil {
+		if ps, ok := (*gp).( *gizmos.Pledge_series ); ok {
+			if n, ok := ps.Occurrence_num( name ); ok {
+				return ps, n
+			}
+		}
+	}
+
+	return nil, 0
+}
+
+/*
+	Delete a series: each of the occurrences which have not expired, and which have not
+	already been deleted (expiry forced to within 15s of now, or before the commence time),
+	are deleted and the series is then expired so that no more are generated.
+*/
+func (inv *Inventory) del_series( ps *gizmos.Pledge_series ) ( state error ) {
+	now := time.Now().Unix()
+
+	for name, p := range inv.cache {
+		commence, expiry := (*p).Get_window()
+		if _, ok := (*p).( *gizmos.Pledge_bw ); ok && expiry > now + 15 && expiry > commence {
+			if sp, _ := inv.series_of( &name ); sp == ps {
+				oname := name
+				if err := inv.Del_res( &oname, super_cookie ); err != nil && state == nil {
+					state = err
+				}
+			}
+		}
+	}
+
+	ps.Set_expiry( time.Now().Unix() )
+	rm_sheep.Baa( 1, "resgmgr: deleted series: %s", *ps.Get_id() )
+	return
+}
+
+/*
+	Cancel an occurrence which has not yet been generated.  The cookie must match the
+	series (or be the super cookie).
+*/
+func (inv *Inventory) skip_occurrence( name *string, cookie *string ) ( state error ) {
+	ps, n := inv.series_of( name )
+	if ps == nil {
+		return &Not_found_error{ what: "reservation", name: *name }
+	}
+
+	if _, state = inv.Get_res( ps.Get_id(), cookie ); state != nil {
+		return
+	}
+
+	if ! ps.Skip( n ) {
+		return &Not_found_error{ what: "reservation", name: *name }
+	}
+
+	rm_sheep.Baa( 1, "resgmgr: series %s: occurrence %d will be skipped", *ps.Get_id(), n )
+	return nil
+}
@@ -31,6 +31,8 @@
 				27 Feb 2015 - Changes to work with lazy updates, long duration reservations
 					and e*->l* fixes.
 				26 May 2015 - Changes to support pledge as an interface.
+				17 Oct 2026 - Added restore_steer() to support restoring steering reservations
+					from a checkpoint.
 */
 
 package managers
@@ -43,7 +45,7 @@ import (
 	"time"
 
 	//"github.com/att/gopkgs/bleater"
-	//"github.com/att/gopkgs/clike"
+	"github.com/att/gopkgs/clike"
 	"github.com/att/gopkgs/ipc"
 	"github.com/att/tegu/gizmos"
 )
@@ -233,6 +235,72 @@ func steer_fmods( ep1 *string, ep2 *string, mblist []*gizmos.Mbox, expiry int64,
 }
 
 
+/*
+	Return the name used to look up a middlebox. Middleboxes are recorded with the name given
+	on the request which might not include the project; if not, the project of the first
+	endpoint which has one is added.
+*/
+func steer_mbname( mbid *string, h1 *string, h2 *string ) ( string ) {
+	if strings.Index( *mbid, "/" ) >= 0 {
+		return *mbid
+	}
+
+	for _, h := range []*string{ h1, h2 } {
+		if h != nil {
+			if i := strings.Index( *h, "/" ); i >= 0 {
+				return (*h)[0:i+1] + *mbid
+			}
+		}
+	}
+
+	return *mbid
+}
+
+/*
+	Prepare a steering pledge that was restored from a checkpoint. The endpoints and middleboxes
+	are pushed into the network graph (as is done when the reservation is created), and the
+	mac, switch and port of each middlebox are looked up again as the middlebox might have moved
+	while we were down. If a middlebox cannot be found the checkpointed values are kept. The pledge
+	is left marked as unpushed so that push_reservations() will send its flow-mods via
+	push_st_reservation().
+*/
+func restore_steer( sp *gizmos.Pledge_steer, ch chan *ipc.Chmsg ) {
+	h1, h2 := sp.Get_hosts( )
+	if *h1 != "" {
+		update_graph( h1, false, *h2 == "" )						// block if h2 isn't there to block on
+	}
+	if *h2 != "" {
+		update_graph( h2, true, true )
+	}
+
+	for i := 0; i < sp.Get_mbox_count(); i++ {
+		mb := sp.Get_mbox( i )
+		mbn := steer_mbname( mb.Get_id(), h1, h2 )
+		update_graph( &mbn, true, true )
+
+		req := ipc.Mk_chmsg( )
+		req.Send_req( nw_ch, ch, REQ_HOSTINFO, &mbn, nil )			// get host info string (ip, mac, switch, port)
+		req = <- ch
+		if req.State != nil {
+			rm_sheep.Baa( 0, "WRN: resmgr: ckpt_load: unable to refresh middlebox %s for steering reservation %s, checkpointed switch/port kept: %s  [TGURMG008]", mbn, *sp.Get_id(), req.State )
+			continue
+		}
+
+		htoks := strings.Split( req.Response_data.( string ), "," )		// ip, mac, switch-id, switch-port
+		if len( htoks ) < 4 {
+			continue
+		}
+
+		swid, port := mb.Get_sw_port( )
+		if *swid != htoks[2] || port != clike.Atoi( htoks[3] ) {
+			rm_sheep.Baa( 1, "resmgr: ckpt_load: middlebox %s moved from %s/%d to %s/%s", mbn, *swid, port, htoks[2], htoks[3] )
+		}
+		sp.Set_mbox( i, gizmos.Mk_mbox( mb.Get_id(), &htoks[1], &htoks[2], clike.Atoi( htoks[3] ) ) )
+	}
+
+	sp.Reset_pushed( )
+}
+
 /*
 	Push the fmod requests to fq-mgr for a steering resrvation.
 */
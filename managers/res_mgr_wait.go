@@ -0,0 +1,426 @@
+// vi: sw=4 ts=4:
+/*
+ ---------------------------------------------------------------------------
+   Copyright (c) 2013-2015 AT&T Intellectual Property
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at:
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+ ---------------------------------------------------------------------------
+*/
+
+
+/*
+
+	Mnemonic:	res_mgr_wait
+	Abstract:	reservation manager functions that support the waitlist. A bandwidth or one way
+				reservation which is rejected by the network manager can be queued (queue=true on
+				the request) rather than discarded. Queued reservations are not in the inventory;
+				they are retried, highest priority first and in the order queued within a priority,
+				whenever capacity might have been freed: a reservation is deleted or expires, a user
+				link capacity is changed, or the network graph is rebuilt.  A queued reservation is
+				abandoned if its window closes before it can be admitted, or if the owner deletes it.
+
+				Entries remain on the list, with their state (queued, admitted, abandoned), until
+				the reservation's window has closed so that the state can be reported by listres.
+				All entries are written to the checkpoint.
+
+	Date:		17 October 2026
+
+	Mods:		17 Oct 2026 - Added the preempted state.
+				17 Oct 2026 - Release the network admission if the reservation can't be added to the inventory.
+				17 Oct 2026 - REQ_ADDWAIT responds with a Queued_state; bad cookie on delete is an Auth_error.
+*/
+
+package managers
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/att/gopkgs/clike"
+	"github.com/att/gopkgs/ipc"
+	"github.com/att/tegu/gizmos"
+)
+
+const (
+	WAIT_QUEUED int = iota				// waiting for capacity
+	WAIT_ADMITTED						// admitted and added to the inventory
+	WAIT_ABANDONED						// window closed, or deleted, before it could be admitted
+	WAIT_PREEMPTED						// removed from the inventory by a higher priority reservation and not requeued
+)
+
+var wait_states = []string { "queued", "admitted", "abandoned", "preempted" }
+
+/*
+	An entry on the waitlist.
+*/
+type Wait_ent struct {
+	pledge		*gizmos.Pledge
+	priority	int					// larger values are retried first
+	seq			int64				// order queued; first in first out within a priority
+	queued		int64				// time that the reservation was queued
+	state		int					// one of the WAIT_ constants
+	reason		string				// reason the last admission attempt failed, or why it was abandoned
+}
+
+/*
+	The response to a successful REQ_ADDWAIT; tells the requestor that the reservation was queued
+	rather than created.
+*/
+type Queued_state struct {
+	reason		string				// why the reservation couldn't be admitted
+	json		string				// the waitlist entry
+}
+
+/*
+	Return the json of the waitlist entry.
+*/
+func (qs *Queued_state) Get_json( ) ( string ) {
+	return qs.json
+}
+
+/*
+	Return the reason that the reservation was queued.
+*/
+func (qs *Queued_state) Get_reason( ) ( string ) {
+	return qs.reason
+}
+
+/*
+	Create a waitlist entry for the pledge. Reason is the reason that the initial
+	admission attempt failed.
+*/
+func Mk_wait_ent( p gizmos.Pledge, priority int, reason string ) ( w *Wait_ent ) {
+	w = &Wait_ent {
+		pledge:		&p,
+		priority:	priority,
+		queued:		time.Now().Unix(),
+		state:		WAIT_QUEUED,
+		reason:		reason,
+	}
+
+	return
+}
+
+/*
+	Generate the json used to report the entry on listres.
+*/
+func (w *Wait_ent) To_json( ) ( string ) {
+	return fmt.Sprintf( `{ "id": %q, "state": %q, "priority": %d, "queued": %d, "reason": %q, "reservation": %s }`,
+		*(*w.pledge).Get_id(), wait_states[w.state], w.priority, w.queued, w.reason, (*w.pledge).To_json() )
+}
+
+/*
+	Generate the checkpoint record for the entry, or "expired" if the pledge has expired.
+	The record is:  wait: <state> <priority> <seq> <queued> <pledge-chkpt-json>
+*/
+func (w *Wait_ent) To_chkpt( ) ( string ) {
+	pc := (*w.pledge).To_chkpt()
+	if pc == "expired" {
+		return pc
+	}
+
+	return fmt.Sprintf( "wait: %s %d %d %d %s", wait_states[w.state], w.priority, w.seq, w.queued, pc )
+}
+
+/*
+	Ask the network manager to approve a one way pledge. On success the gate is set in the pledge,
+	otherwise the error from the network manager is returned.
+*/
+func nw_admit_ow( p *gizmos.Pledge_bwow, ch chan *ipc.Chmsg ) ( err error ) {
+	req := ipc.Mk_chmsg( )
+	req.Send_req( nw_ch, ch, REQ_BWOW_RESERVE, p, nil )
+	req = <- ch
+
+	if req.Response_data == nil {
+		if req.State == nil {
+			return fmt.Errorf( "unable to allocate a gate" )
+		}
+		return req.State
+	}
+
+	p.Set_gate( req.Response_data.( *gizmos.Gate ) )
+	return nil
+}
+
+/*
+	Release the capacity allocated by the network manager when a bandwidth or one way pledge
+	was admitted, but could not be added to the inventory.
+*/
+func nw_release( p gizmos.Pledge, ch chan *ipc.Chmsg ) {
+	req := ipc.Mk_chmsg( )
+	req.Send_req( nw_ch, ch, REQ_DEL, p, nil )
+	req = <- ch
+	if req.State != nil {
+		rm_sheep.Baa( 1, "resgmgr: unable to release network capacity for %s: %s", *p.Get_id(), req.State )
+	}
+}
+
+/*
+	Sort the waitlist such that the highest priority is first and entries with the same
+	priority are in the order they were queued.
+*/
+func (inv *Inventory) sort_wait( ) {
+	sort.SliceStable( inv.waitlist, func( i, j int ) bool {
+		if inv.waitlist[i].priority != inv.waitlist[j].priority {
+			return inv.waitlist[i].priority > inv.waitlist[j].priority
+		}
+		return inv.waitlist[i].seq < inv.waitlist[j].seq
+	} )
+}
+
+/*
+	Return the queued entry with the given name, or nil if there isn't one.
+*/
+func (inv *Inventory) get_wait( name *string ) ( *Wait_ent ) {
+	for _, w := range inv.waitlist {
+		if w.state == WAIT_QUEUED && *(*w.pledge).Get_id() == *name {
+			return w
+		}
+	}
+
+	return nil
+}
+
+/*
+	Add an entry to the waitlist. The pledge is rejected if it duplicates a reservation in the
+	inventory or one that is already queued.  The position (1 based) on the list of queued
+	entries is returned.
+*/
+func (inv *Inventory) add_wait( w *Wait_ent ) ( pos int, err error ) {
+	if w == nil || w.pledge == nil {
+		return 0, fmt.Errorf( "no pledge given to queue" )
+	}
+
+	id := (*w.pledge).Get_id()
+	if inv.cache[*id] != nil || inv.get_wait( id ) != nil {
+		return 0, fmt.Errorf( "reservation already exists: %s", *id )
+	}
+
+	if rid, _ := inv.dup_check( w.pledge ); rid != nil {
+		return 0, fmt.Errorf( "reservation duplicates existing reservation: %s", *rid )
+	}
+	for _, qw := range inv.waitlist {
+		if qw.state == WAIT_QUEUED && (*w.pledge).Equals( qw.pledge ) {
+			return 0, fmt.Errorf( "reservation duplicates queued reservation: %s", *(*qw.pledge).Get_id() )
+		}
+	}
+
+	inv.wait_seq++
+	w.seq = inv.wait_seq
+	w.state = WAIT_QUEUED
+	inv.waitlist = append( inv.waitlist, w )
+	inv.sort_wait( )
+
+	for _, qw := range inv.waitlist {
+		if qw.state == WAIT_QUEUED {
+			pos++
+		}
+		if qw == w {
+			break
+		}
+	}
+
+	rm_sheep.Baa( 1, "resgmgr: reservation queued: %s priority=%d position=%d: %s", *id, w.priority, pos, w.reason )
+	return
+}
+
+/*
+	Attempt to admit each queued reservation, in priority order.  Entries whose window has closed
+	(or will within a few seconds) are abandoned, and entries which are no longer queued are dropped
+	once the window of their reservation has been closed for a couple of minutes. Returns the number
+	of reservations admitted and the number of entries which changed state or were dropped (the
+	caller should checkpoint if either is non-zero).
+*/
+func (inv *Inventory) retry_wait( ) ( nadmitted int, nchanged int ) {
+	if len( inv.waitlist ) == 0 {
+		return 0, 0
+	}
+
+	ch := make( chan *ipc.Chmsg )
+	defer close( ch )
+
+	now := time.Now().Unix()
+	keep := inv.waitlist[:0]
+	for _, w := range inv.waitlist {
+		p := w.pledge
+		_, expiry := (*p).Get_window()
+
+		switch w.state {
+			case WAIT_QUEUED:
+				if expiry <= now + 15 {
+					w.state = WAIT_ABANDONED
+					w.reason = "window closed before capacity became available"
+					nchanged++
+					rm_sheep.Baa( 1, "resgmgr: queued reservation abandoned: %s: %s", *(*p).Get_id(), w.reason )
+					break
+				}
+
+				var err error
+				switch sp := (*p).(type) {
+					case *gizmos.Pledge_bw:
+						err = nw_admit( sp, ch )
+
+					case *gizmos.Pledge_bwow:
+						err = nw_admit_ow( sp, ch )
+
+					default:
+						err = fmt.Errorf( "reservation type cannot be queued" )
+				}
+
+				if err == nil {
+					if (*p).Is_paused( ) {
+						(*p).Set_pushed( )							// paused reservations are marked pushed so they don't go out until resumed
+					}
+					if err = inv.Add_res( p ); err != nil {
+						nw_release( *p, ch )						// not admitted after all; give back the capacity before the next retry
+					}
+				}
+
+				if err == nil {
+					w.state = WAIT_ADMITTED
+					w.reason = ""
+					nadmitted++
+					nchanged++
+					rm_sheep.Baa( 1, "resgmgr: queued reservation admitted: %s", *(*p).Get_id() )
+				} else {
+					w.reason = fmt.Sprintf( "%s", err )
+					rm_sheep.Baa( 2, "resgmgr: queued reservation still waiting: %s: %s", *(*p).Get_id(), err )
+				}
+
+			default:
+				if expiry < now - 120 {
+					nchanged++
+					continue										// long gone; drop from the list
+				}
+		}
+
+		keep = append( keep, w )
+	}
+	inv.waitlist = keep
+
+	if nadmitted > 0 {
+		rm_sheep.Baa( 1, "resgmgr: %d queued reservations admitted", nadmitted )
+	}
+	return
+}
+
+/*
+	Abandon the queued reservation with the given name. The cookie must match the one on the
+	reservation, or be the super cookie. Found is false if there is no queued entry with the name.
+*/
+func (inv *Inventory) del_wait( name *string, cookie *string ) ( found bool, state error ) {
+	w := inv.get_wait( name )
+	if w == nil {
+		return false, nil
+	}
+
+	if ! (*w.pledge).Is_valid_cookie( cookie ) && *cookie != *super_cookie {
+		return true, &Auth_error{ reason: "not authorised to access or delete reservation: " + *name }
+	}
+
+	w.state = WAIT_ABANDONED
+	w.reason = "deleted while queued"
+	rm_sheep.Baa( 1, "resgmgr: queued reservation deleted: %s", *name )
+	return true, nil
+}
+
+/*
+	Generate the json list of waitlist entries (without the brackets).
+*/
+func (inv *Inventory) wait2json( ) ( string ) {
+	sep := ""
+	bs := bytes.NewBufferString( "" )
+	for _, w := range inv.waitlist {
+		bs.WriteString( fmt.Sprintf( "%s%s", sep, w.To_json() ) )
+		sep = ","
+	}
+
+	return bs.String()
+}
+
+/*
+	Restore a waitlist entry from a checkpoint record. Admitted entries refer to the pledge
+	in the inventory if it was restored; queued entries will be retried on the next attempt.
+*/
+func (inv *Inventory) load_wait( rec string ) ( err error ) {
+	toks := strings.SplitN( strings.TrimSpace( rec ), " ", 6 )
+	if len( toks ) < 6 {
+		return fmt.Errorf( "bad waitlist checkpoint record: %s", rec )
+	}
+
+	state := -1
+	for i, s := range wait_states {
+		if s == toks[1] {
+			state = i
+		}
+	}
+	if state < 0 {
+		return fmt.Errorf( "bad waitlist state in checkpoint record: %s", toks[1] )
+	}
+
+	p, err := gizmos.Json2pledge( &toks[5] )
+	if err != nil {
+		return
+	}
+
+	if state == WAIT_ADMITTED {
+		if cp := inv.cache[*(*p).Get_id()]; cp != nil {
+			p = cp
+		}
+	}
+
+	if state == WAIT_QUEUED {
+		if h1, h2 := wait_hosts( p ); h1 != nil {
+			update_graph( h1, false, false )						// network must know the hosts before it can admit the reservation
+			if h2 != nil {
+				update_graph( h2, true, true )
+			}
+		}
+	}
+
+	w := &Wait_ent {
+		pledge:		p,
+		priority:	clike.Atoi( toks[2] ),
+		seq:		clike.Atoi64( toks[3] ),
+		queued:		clike.Atoi64( toks[4] ),
+		state:		state,
+	}
+	if state == WAIT_ABANDONED {
+		w.reason = "abandoned before checkpoint was restored"
+	}
+	if w.seq > inv.wait_seq {
+		inv.wait_seq = w.seq
+	}
+
+	inv.waitlist = append( inv.waitlist, w )
+	inv.sort_wait( )
+	return
+}
+
+/*
+	Return the hosts of a pledge that can be queued.
+*/
+func wait_hosts( p *gizmos.Pledge ) ( h1 *string, h2 *string ) {
+	switch sp := (*p).(type) {
+		case *gizmos.Pledge_bw:
+			return sp.Get_hosts( )
+
+		case *gizmos.Pledge_bwow:
+			return sp.Get_hosts( )
+	}
+
+	return nil, nil
+}